@@ -9,6 +9,7 @@ func ReplaceGlobals(logger *zap.Logger) func() {
 }
 
 func SetGlobalConfig(config Config) func() {
+	config.atomicLevel = &globalLevel
 	logger := configureAndBuildLogger(config)
 	return ReplaceGlobals(logger)
 }
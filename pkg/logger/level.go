@@ -0,0 +1,27 @@
+package logger
+
+import (
+	"strings"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// globalLevel is shared by the loggers built by InitLogger and SetGlobalConfig. Loggers installed with
+// ReplaceGlobals and the OpenTelemetry export keep their own level.
+var globalLevel = zap.NewAtomicLevel()
+
+// SetLevel changes the level of the global logger at runtime.
+func SetLevel(level string) error {
+	parsed, err := zapcore.ParseLevel(strings.ToLower(level))
+	if err != nil {
+		return err
+	}
+
+	globalLevel.SetLevel(parsed)
+	return nil
+}
+
+func GetLevel() string {
+	return globalLevel.Level().String()
+}
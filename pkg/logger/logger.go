@@ -24,6 +24,9 @@ type Config struct {
 	Level         string               `json:"level"`
 	Encoding      string               `json:"encoding"`
 	OpenTelemetry *OpenTelemetryConfig `json:"opentelemetry,omitempty"`
+
+	// atomicLevel is set for the global logger, whose level can be changed with SetLevel.
+	atomicLevel *zap.AtomicLevel
 }
 
 type Field struct {
@@ -40,6 +43,7 @@ func init() {
 }
 
 func InitLogger(config Config) {
+	config.atomicLevel = &globalLevel
 	baseLogger := configureAndBuildLogger(config)
 	zap.ReplaceGlobals(baseLogger)
 }
@@ -121,6 +125,10 @@ func createStandardLoggerInternal(config Config) *zap.Logger {
 		level = zapcore.InfoLevel
 	}
 	cfg.Level = zap.NewAtomicLevelAt(level)
+	if config.atomicLevel != nil {
+		config.atomicLevel.SetLevel(level)
+		cfg.Level = *config.atomicLevel
+	}
 
 	logger, err := cfg.Build(defaultOptions...)
 	if err != nil {
@@ -322,6 +322,34 @@ func TestInitLogger(t *testing.T) {
 	globalLogger.Info("test global logger")
 }
 
+func TestSetLevel(t *testing.T) {
+	originalLogger := zap.L()
+	defer zap.ReplaceGlobals(originalLogger)
+	defer InitLogger(defaultConfig)
+
+	InitLogger(Config{Level: "info", Encoding: "json"})
+	if L().Core().Enabled(zapcore.DebugLevel) {
+		t.Fatal("expected debug level to be disabled")
+	}
+
+	if err := SetLevel("DEBUG"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !L().Core().Enabled(zapcore.DebugLevel) {
+		t.Error("expected debug level to be enabled after SetLevel")
+	}
+	if GetLevel() != "debug" {
+		t.Errorf("expected level debug, got %s", GetLevel())
+	}
+
+	if err := SetLevel("verbose"); err == nil {
+		t.Error("expected an error for an unknown level")
+	}
+	if GetLevel() != "debug" {
+		t.Errorf("expected level to be unchanged, got %s", GetLevel())
+	}
+}
+
 func TestOpenTelemetryProviderRegistration(t *testing.T) {
 	// Clean up
 	RegisterOpenTelemetryProvider(nil)
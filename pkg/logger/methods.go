@@ -9,6 +9,8 @@ import (
 const (
 	loggerKey    = "golem.logger"
 	RequestIDKey = "request_id"
+	TraceIDKey   = "trace_id"
+	SpanIDKey    = "span_id"
 	// contextFieldKey is the field name used by otelzap bridge to detect context.Context
 	// and automatically extract trace information for log-trace correlation
 	contextFieldKey = "context"
@@ -5,7 +5,14 @@ import (
 	"github.com/prometheus/client_golang/prometheus"
 )
 
-type Histogram struct{ Buckets []float64 }
+type Histogram struct {
+	Buckets []float64
+	// NativeBucketFactor, when greater than 1, also exposes the histogram as a Prometheus native
+	// (exponential) histogram whose bucket boundaries grow by this factor.
+	NativeBucketFactor float64
+	// NativeMaxBucketNumber bounds the number of native buckets. Zero means no limit.
+	NativeMaxBucketNumber uint32
+}
 
 func (h *Histogram) Update(collector prometheus.Collector, value float64, labels ...string) error {
 	if len(labels) > 0 {
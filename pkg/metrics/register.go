@@ -6,10 +6,13 @@ import (
 	"github.com/zondax/golem/pkg/metrics/collectors"
 	"regexp"
 	"strings"
+	"time"
 )
 
 const (
 	APPNameLabel = "app_name"
+
+	nativeHistogramMinResetDuration = time.Hour
 )
 
 type collectorRegister func(name, help string, labels []string, handler MetricHandler) (prometheus.Collector, error)
@@ -66,10 +69,21 @@ func registerCounter(name, help string, labels []string, handler MetricHandler)
 
 func registerHistogram(name, help string, labels []string, handler MetricHandler) (prometheus.Collector, error) {
 	if histogramUpdater, ok := handler.(*collectors.Histogram); ok {
+		opts := prometheus.HistogramOpts{
+			Name:                           name,
+			Help:                           help,
+			Buckets:                        histogramUpdater.Buckets,
+			NativeHistogramBucketFactor:    histogramUpdater.NativeBucketFactor,
+			NativeHistogramMaxBucketNumber: histogramUpdater.NativeMaxBucketNumber,
+		}
+		if histogramUpdater.NativeMaxBucketNumber > 0 {
+			opts.NativeHistogramMinResetDuration = nativeHistogramMinResetDuration
+		}
+
 		if len(labels) == 0 {
-			return prometheus.NewHistogram(prometheus.HistogramOpts{Name: name, Help: help, Buckets: histogramUpdater.Buckets}), nil
+			return prometheus.NewHistogram(opts), nil
 		}
-		return prometheus.NewHistogramVec(prometheus.HistogramOpts{Name: name, Help: help, Buckets: histogramUpdater.Buckets}, labels), nil
+		return prometheus.NewHistogramVec(opts, labels), nil
 	}
 
 	return nil, fmt.Errorf("invalid handler type for histogram")
@@ -2,6 +2,7 @@ package metrics
 
 import (
 	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"github.com/zondax/golem/pkg/metrics/collectors"
@@ -82,3 +83,23 @@ func (suite *MetricsTestSuite) TestMetricNameFormatting() {
 		})
 	}
 }
+
+func TestRegisterNativeHistogram(t *testing.T) {
+	tm := &taskMetrics{metrics: make(map[string]MetricDetail)}
+	err := tm.RegisterMetric("test_native_histogram", "help", []string{"path"}, &collectors.Histogram{
+		Buckets:               []float64{1, 10},
+		NativeBucketFactor:    1.1,
+		NativeMaxBucketNumber: 100,
+	})
+	assert.NoError(t, err)
+
+	histogram := tm.metrics["test_native_histogram"].Collector.(*prometheus.HistogramVec)
+	histogram.WithLabelValues("/", "app").Observe(5)
+
+	metricChan := make(chan prometheus.Metric, 1)
+	histogram.Collect(metricChan)
+	var metric dto.Metric
+	assert.NoError(t, (<-metricChan).Write(&metric))
+	assert.Len(t, metric.GetHistogram().GetBucket(), 2)
+	assert.Equal(t, int32(3), metric.GetHistogram().GetSchema())
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"os"
 	"os/signal"
+	"sync"
 	"syscall"
 	"time"
 
@@ -18,8 +19,36 @@ type Task interface {
 	Stop() error
 }
 
+// BlockingTask is a Task whose Start only returns once Stop is called, such as a server. The runner
+// calls its Stop as soon as it shuts down, concurrently with Start. Other tasks are stopped once Start
+// returns, on the goroutine that runs them.
+type BlockingTask interface {
+	Task
+	// BlocksUntilStopped marks the task as blocking; the runner never calls it.
+	BlocksUntilStopped()
+}
+
 const MaximumPendingTasks = 1000
 
+type TaskStatus string
+
+const (
+	TaskStatusPending TaskStatus = "pending"
+	TaskStatusRunning TaskStatus = "running"
+	// TaskStatusCompleted and TaskStatusFailed tasks are started again by the runner.
+	TaskStatusCompleted TaskStatus = "completed"
+	TaskStatusFailed    TaskStatus = "failed"
+	TaskStatusStopped   TaskStatus = "stopped"
+)
+
+type TaskState struct {
+	Name      string     `json:"name"`
+	Status    TaskStatus `json:"status"`
+	Starts    int        `json:"starts"`
+	LastStart time.Time  `json:"last_start"`
+	LastError string     `json:"last_error,omitempty"`
+}
+
 type TaskError struct {
 	Task *Task
 	Err  error
@@ -31,6 +60,9 @@ type TaskRunner struct {
 	tasks   errgroup.Group
 	errCh   chan TaskError
 	tasksCh chan Task
+
+	statesMu sync.Mutex
+	states   []*TaskState
 }
 
 func NewRunner() *TaskRunner {
@@ -66,12 +98,58 @@ func (tr *TaskRunner) sendError(te TaskError) {
 	}
 }
 
+// TaskStates returns the state of the tasks added to the runner, in the order they were started.
+func (tr *TaskRunner) TaskStates() []TaskState {
+	tr.statesMu.Lock()
+	defer tr.statesMu.Unlock()
+
+	states := make([]TaskState, len(tr.states))
+	for i, state := range tr.states {
+		states[i] = *state
+	}
+	return states
+}
+
+func (tr *TaskRunner) trackTask(task Task) *TaskState {
+	if _, ok := task.(*StartTask); ok {
+		return nil
+	}
+
+	state := &TaskState{Name: task.Name(), Status: TaskStatusPending}
+	tr.statesMu.Lock()
+	tr.states = append(tr.states, state)
+	tr.statesMu.Unlock()
+	return state
+}
+
+func (tr *TaskRunner) updateTaskState(state *TaskState, update func(state *TaskState)) {
+	if state == nil {
+		return
+	}
+
+	tr.statesMu.Lock()
+	defer tr.statesMu.Unlock()
+	update(state)
+}
+
 func (tr *TaskRunner) runTask(task Task) {
+	state := tr.trackTask(task)
+
+	stopTask := sync.OnceFunc(func() { _ = task.Stop() })
+	if _, ok := task.(BlockingTask); ok {
+		// Start only returns once the task is stopped, so Stop cannot wait for it.
+		go func() {
+			<-tr.ctx.Done()
+			stopTask()
+		}()
+	}
+
 	tr.tasks.Go(func() error {
 		for {
 			select {
 			case <-tr.ctx.Done():
-				_ = task.Stop()
+				stopTask()
+				tr.updateTaskState(state, func(state *TaskState) { state.Status = TaskStatusStopped })
 				return tr.ctx.Err()
 
 			case <-time.After(1 * time.Second):
@@ -79,16 +157,32 @@ func (tr *TaskRunner) runTask(task Task) {
 					defer func() {
 						if r := recover(); r != nil {
 							err := fmt.Errorf("[PANIC] %s: %v", task.Name(), r)
+							tr.updateTaskState(state, func(state *TaskState) {
+								state.Status = TaskStatusFailed
+								state.LastError = err.Error()
+							})
 							tr.sendError(TaskError{Task: &task, Err: err})
 						}
 					}()
 
+					tr.updateTaskState(state, func(state *TaskState) {
+						state.Status = TaskStatusRunning
+						state.Starts++
+						state.LastStart = time.Now()
+					})
+
 					// FIXME: are we sure we want to loop and keep retrying?
 					// Send some event so we are aware of this?
 					err := task.Start()
 					if err != nil {
+						tr.updateTaskState(state, func(state *TaskState) {
+							state.Status = TaskStatusFailed
+							state.LastError = err.Error()
+						})
 						tr.sendError(TaskError{Task: &task, Err: err})
+						return
 					}
+					tr.updateTaskState(state, func(state *TaskState) { state.Status = TaskStatusCompleted })
 				}()
 			}
 		}
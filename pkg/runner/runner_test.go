@@ -0,0 +1,103 @@
+package runner
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// serverTask serves until Stop is called, like a server.
+type serverTask struct {
+	started chan struct{}
+	stop    chan struct{}
+}
+
+func newServerTask() *serverTask {
+	return &serverTask{started: make(chan struct{}), stop: make(chan struct{})}
+}
+
+func (s *serverTask) Name() string { return "server" }
+
+func (s *serverTask) Start() error {
+	close(s.started)
+	<-s.stop
+	return nil
+}
+
+func (s *serverTask) Stop() error {
+	close(s.stop)
+	return nil
+}
+
+func (s *serverTask) BlocksUntilStopped() {}
+
+// jobTask records whether Stop was called while Start was running.
+type jobTask struct {
+	started     chan struct{}
+	running     atomic.Bool
+	stopRunning atomic.Bool
+	stops       atomic.Int32
+}
+
+func (j *jobTask) Name() string { return "job" }
+
+func (j *jobTask) Start() error {
+	j.running.Store(true)
+	defer j.running.Store(false)
+	select {
+	case <-j.started:
+	default:
+		close(j.started)
+	}
+	time.Sleep(100 * time.Millisecond)
+	return nil
+}
+
+func (j *jobTask) Stop() error {
+	j.stopRunning.Store(j.running.Load())
+	j.stops.Add(1)
+	return nil
+}
+
+func waitTasks(t *testing.T, tr *TaskRunner) {
+	done := make(chan error)
+	go func() { done <- tr.tasks.Wait() }()
+
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("the tasks did not stop")
+	}
+}
+
+func TestRunnerStopsBlockingTask(t *testing.T) {
+	tr := NewRunner()
+	task := newServerTask()
+	tr.AddTask(task)
+	tr.Start()
+
+	<-task.started
+	tr.Shutdown()
+	waitTasks(t, tr)
+
+	states := tr.TaskStates()
+	require.Len(t, states, 1)
+	assert.Equal(t, TaskStatusStopped, states[0].Status)
+}
+
+func TestRunnerStopsTaskAfterStart(t *testing.T) {
+	tr := NewRunner()
+	task := &jobTask{started: make(chan struct{})}
+	tr.AddTask(task)
+	tr.Start()
+
+	<-task.started
+	tr.Shutdown()
+	waitTasks(t, tr)
+
+	assert.Equal(t, int32(1), task.stops.Load())
+	assert.False(t, task.stopRunning.Load())
+}
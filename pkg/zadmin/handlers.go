@@ -0,0 +1,208 @@
+package zadmin
+
+import (
+	"github.com/zondax/golem/pkg/logger"
+	"github.com/zondax/golem/pkg/runner"
+	"github.com/zondax/golem/pkg/zcache"
+	"github.com/zondax/golem/pkg/zrouter"
+	"github.com/zondax/golem/pkg/zrouter/domain"
+	"net/http"
+	"runtime"
+)
+
+const invalidLogLevelErrorCode = "invalid_log_level"
+
+type routeResponse struct {
+	Method      string                 `json:"method"`
+	Path        string                 `json:"path"`
+	Name        string                 `json:"name,omitempty"`
+	Tags        []string               `json:"tags,omitempty"`
+	Metadata    map[string]interface{} `json:"metadata,omitempty"`
+	Versions    []string               `json:"versions,omitempty"`
+	Middlewares []string               `json:"middlewares,omitempty"`
+}
+
+type buildInfoResponse struct {
+	AppVersion  string `json:"app_version"`
+	AppRevision string `json:"app_revision"`
+	GoVersion   string `json:"go_version"`
+}
+
+type localCacheStats struct {
+	Hits         uint64  `json:"hits"`
+	Misses       uint64  `json:"misses"`
+	Ratio        float64 `json:"ratio"`
+	KeysAdded    uint64  `json:"keys_added"`
+	KeysUpdated  uint64  `json:"keys_updated"`
+	KeysEvicted  uint64  `json:"keys_evicted"`
+	CostAdded    uint64  `json:"cost_added"`
+	CostEvicted  uint64  `json:"cost_evicted"`
+	SetsDropped  uint64  `json:"sets_dropped"`
+	SetsRejected uint64  `json:"sets_rejected"`
+	GetsKept     uint64  `json:"gets_kept"`
+	GetsDropped  uint64  `json:"gets_dropped"`
+}
+
+type remoteCacheStats struct {
+	Hits       uint32 `json:"hits"`
+	Misses     uint32 `json:"misses"`
+	Timeouts   uint32 `json:"timeouts"`
+	TotalConns uint32 `json:"total_conns"`
+	IdleConns  uint32 `json:"idle_conns"`
+	StaleConns uint32 `json:"stale_conns"`
+}
+
+type cacheStatsResponse struct {
+	Local  *localCacheStats  `json:"local,omitempty"`
+	Remote *remoteCacheStats `json:"remote,omitempty"`
+}
+
+type dbStatsResponse struct {
+	MaxOpenConnections int    `json:"max_open_connections"`
+	OpenConnections    int    `json:"open_connections"`
+	InUse              int    `json:"in_use"`
+	Idle               int    `json:"idle"`
+	WaitCount          int64  `json:"wait_count"`
+	WaitDuration       string `json:"wait_duration"`
+	MaxIdleClosed      int64  `json:"max_idle_closed"`
+	MaxIdleTimeClosed  int64  `json:"max_idle_time_closed"`
+	MaxLifetimeClosed  int64  `json:"max_lifetime_closed"`
+	Error              string `json:"error,omitempty"`
+}
+
+type logLevelRequest struct {
+	Level string `json:"level"`
+}
+
+func (a *zadmin) routes(_ zrouter.Context) (domain.ServiceResponse, error) {
+	var descriptions []zrouter.RouteDescription
+	switch provider := a.config.Routes.(type) {
+	case nil:
+	case interface {
+		DescribeRoutes() []zrouter.RouteDescription
+	}:
+		descriptions = provider.DescribeRoutes()
+	default:
+		for _, route := range provider.GetRegisteredRoutes() {
+			descriptions = append(descriptions, zrouter.RouteDescription{RegisteredRoute: route})
+		}
+	}
+
+	routes := make([]routeResponse, len(descriptions))
+	for i, route := range descriptions {
+		routes[i] = routeResponse{
+			Method:      route.Method,
+			Path:        route.Path,
+			Name:        route.Name,
+			Tags:        route.Tags,
+			Metadata:    route.Metadata,
+			Versions:    route.Versions,
+			Middlewares: route.Middlewares,
+		}
+	}
+	return domain.NewServiceResponse(http.StatusOK, routes), nil
+}
+
+func (a *zadmin) effectiveConfig(_ zrouter.Context) (domain.ServiceResponse, error) {
+	return domain.NewServiceResponse(http.StatusOK, Redact(a.config.EffectiveConfig, a.config.RedactKeys)), nil
+}
+
+func (a *zadmin) buildInfo(_ zrouter.Context) (domain.ServiceResponse, error) {
+	return domain.NewServiceResponse(http.StatusOK, buildInfoResponse{
+		AppVersion:  a.config.AppVersion,
+		AppRevision: a.config.AppRevision,
+		GoVersion:   runtime.Version(),
+	}), nil
+}
+
+func (a *zadmin) caches(_ zrouter.Context) (domain.ServiceResponse, error) {
+	caches := make(map[string]cacheStatsResponse, len(a.config.Caches))
+	for name, cache := range a.config.Caches {
+		caches[name] = newCacheStatsResponse(cache.GetStats())
+	}
+	return domain.NewServiceResponse(http.StatusOK, caches), nil
+}
+
+func (a *zadmin) databases(_ zrouter.Context) (domain.ServiceResponse, error) {
+	databases := make(map[string]dbStatsResponse, len(a.config.Databases))
+	for name, db := range a.config.Databases {
+		stats, err := db.GetDBStats()
+		if err != nil {
+			databases[name] = dbStatsResponse{Error: err.Error()}
+			continue
+		}
+
+		databases[name] = dbStatsResponse{
+			MaxOpenConnections: stats.MaxOpenConnections,
+			OpenConnections:    stats.OpenConnections,
+			InUse:              stats.InUse,
+			Idle:               stats.Idle,
+			WaitCount:          stats.WaitCount,
+			WaitDuration:       stats.WaitDuration.String(),
+			MaxIdleClosed:      stats.MaxIdleClosed,
+			MaxIdleTimeClosed:  stats.MaxIdleTimeClosed,
+			MaxLifetimeClosed:  stats.MaxLifetimeClosed,
+		}
+	}
+	return domain.NewServiceResponse(http.StatusOK, databases), nil
+}
+
+func (a *zadmin) tasks(_ zrouter.Context) (domain.ServiceResponse, error) {
+	tasks := []runner.TaskState{}
+	if a.config.Runner != nil {
+		tasks = append(tasks, a.config.Runner.TaskStates()...)
+	}
+	return domain.NewServiceResponse(http.StatusOK, tasks), nil
+}
+
+func (a *zadmin) logLevel(_ zrouter.Context) (domain.ServiceResponse, error) {
+	return domain.NewServiceResponse(http.StatusOK, logLevelRequest{Level: logger.GetLevel()}), nil
+}
+
+func (a *zadmin) setLogLevel(ctx zrouter.Context) (domain.ServiceResponse, error) {
+	var request logLevelRequest
+	if err := ctx.BindJSON(&request); err != nil {
+		return nil, domain.NewAPIErrorResponse(http.StatusBadRequest, invalidLogLevelErrorCode, "invalid request body", err.Error())
+	}
+
+	previous := logger.GetLevel()
+	if err := logger.SetLevel(request.Level); err != nil {
+		return nil, domain.NewAPIErrorResponse(http.StatusBadRequest, invalidLogLevelErrorCode, "invalid log level", err.Error())
+	}
+
+	logger.GetLoggerFromContext(ctx.Context()).Infof("Log level changed from %s to %s", previous, logger.GetLevel())
+	return domain.NewServiceResponse(http.StatusOK, logLevelRequest{Level: logger.GetLevel()}), nil
+}
+
+func newCacheStatsResponse(stats zcache.ZCacheStats) cacheStatsResponse {
+	var response cacheStatsResponse
+	if local := stats.Local; local != nil {
+		response.Local = &localCacheStats{
+			Hits:         local.Hits(),
+			Misses:       local.Misses(),
+			Ratio:        local.Ratio(),
+			KeysAdded:    local.KeysAdded(),
+			KeysUpdated:  local.KeysUpdated(),
+			KeysEvicted:  local.KeysEvicted(),
+			CostAdded:    local.CostAdded(),
+			CostEvicted:  local.CostEvicted(),
+			SetsDropped:  local.SetsDropped(),
+			SetsRejected: local.SetsRejected(),
+			GetsKept:     local.GetsKept(),
+			GetsDropped:  local.GetsDropped(),
+		}
+	}
+
+	if stats.Remote != nil && stats.Remote.Pool != nil {
+		pool := stats.Remote.Pool
+		response.Remote = &remoteCacheStats{
+			Hits:       pool.Hits,
+			Misses:     pool.Misses,
+			Timeouts:   pool.Timeouts,
+			TotalConns: pool.TotalConns,
+			IdleConns:  pool.IdleConns,
+			StaleConns: pool.StaleConns,
+		}
+	}
+	return response
+}
@@ -0,0 +1,179 @@
+package zadmin
+
+import (
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"reflect"
+	"strings"
+	"time"
+)
+
+const (
+	RedactedValue  = "[REDACTED]"
+	maxRedactDepth = 32
+)
+
+// DefaultRedactKeys are matched against config keys in lower case, ignoring "_" and "-".
+var DefaultRedactKeys = []string{
+	"password", "passwd", "secret", "token", "apikey", "privatekey", "accesskey", "credential", "authorization", "dsn",
+}
+
+var (
+	durationType       = reflect.TypeOf(time.Duration(0))
+	jsonMarshalerType  = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshalerType  = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+	redactedValueTypes = []reflect.Type{jsonMarshalerType, textMarshalerType}
+)
+
+// Redact converts a config value to maps, slices and scalars, replacing the values of keys that contain
+// any of the redactKeys and the passwords of URLs. Functions, channels and unexported fields are dropped.
+func Redact(value interface{}, redactKeys []string) interface{} {
+	keys := make([]string, len(redactKeys))
+	for i, key := range redactKeys {
+		keys[i] = normalizeKey(key)
+	}
+	return redactValue(reflect.ValueOf(value), keys, 0)
+}
+
+func redactValue(v reflect.Value, keys []string, depth int) interface{} {
+	if !v.IsValid() || depth > maxRedactDepth {
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			return nil
+		}
+		if marshaled, ok := marshaledValue(v, keys, depth); ok {
+			return marshaled
+		}
+		return redactValue(v.Elem(), keys, depth+1)
+	case reflect.Func, reflect.Chan, reflect.UnsafePointer:
+		return nil
+	case reflect.String:
+		return redactURL(v.String())
+	}
+
+	if v.Type() == durationType {
+		return time.Duration(v.Int()).String()
+	}
+	if marshaled, ok := marshaledValue(v, keys, depth); ok {
+		return marshaled
+	}
+
+	switch v.Kind() {
+	case reflect.Struct:
+		fields := make(map[string]interface{})
+		for i := 0; i < v.NumField(); i++ {
+			field := v.Type().Field(i)
+			if !field.IsExported() {
+				continue
+			}
+
+			name := field.Name
+			if tag, _, _ := strings.Cut(field.Tag.Get("json"), ","); tag == "-" {
+				continue
+			} else if tag != "" {
+				name = tag
+			}
+			addRedactedField(fields, name, v.Field(i), keys, depth)
+		}
+		return fields
+	case reflect.Map:
+		if v.IsNil() {
+			return nil
+		}
+		entries := make(map[string]interface{}, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			addRedactedField(entries, fmt.Sprint(iter.Key().Interface()), iter.Value(), keys, depth)
+		}
+		return entries
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			return nil
+		}
+		items := make([]interface{}, v.Len())
+		for i := range items {
+			items[i] = redactValue(v.Index(i), keys, depth+1)
+		}
+		return items
+	default:
+		return v.Interface()
+	}
+}
+
+func addRedactedField(fields map[string]interface{}, name string, value reflect.Value, keys []string, depth int) {
+	switch value.Kind() {
+	case reflect.Func, reflect.Chan, reflect.UnsafePointer:
+		return
+	}
+
+	if isSecretKey(name, keys) && !value.IsZero() {
+		fields[name] = RedactedValue
+		return
+	}
+	fields[name] = redactValue(value, keys, depth+1)
+}
+
+// marshaledValue keeps the JSON representation of types such as time.Time. Objects in that representation
+// are redacted like any other map.
+func marshaledValue(v reflect.Value, keys []string, depth int) (interface{}, bool) {
+	for _, marshalerType := range redactedValueTypes {
+		if !v.Type().Implements(marshalerType) {
+			continue
+		}
+
+		encoded, err := json.Marshal(v.Interface())
+		if err != nil {
+			return nil, false
+		}
+
+		var decoded interface{}
+		if err := json.Unmarshal(encoded, &decoded); err != nil {
+			return nil, false
+		}
+		return redactValue(reflect.ValueOf(decoded), keys, depth+1), true
+	}
+	return nil, false
+}
+
+func isSecretKey(name string, keys []string) bool {
+	name = normalizeKey(name)
+	for _, key := range keys {
+		if strings.Contains(name, key) {
+			return true
+		}
+	}
+	return false
+}
+
+func normalizeKey(key string) string {
+	return strings.NewReplacer("_", "", "-", "").Replace(strings.ToLower(key))
+}
+
+func redactURL(value string) string {
+	if !strings.Contains(value, "://") {
+		return value
+	}
+
+	parsed, err := url.Parse(value)
+	if err != nil || parsed.User == nil {
+		return value
+	}
+	if _, ok := parsed.User.Password(); !ok {
+		return value
+	}
+
+	username := url.User(parsed.User.Username()).String()
+	redacted := strings.Replace(value, parsed.User.String()+"@", username+":"+RedactedValue+"@", 1)
+	if redacted == value {
+		// The password was encoded differently, drop it.
+		parsed.User = url.User(parsed.User.Username())
+		return parsed.String()
+	}
+	return redacted
+}
@@ -0,0 +1,163 @@
+package zadmin
+
+import (
+	"database/sql"
+	"github.com/zondax/golem/pkg/logger"
+	"github.com/zondax/golem/pkg/metrics"
+	"github.com/zondax/golem/pkg/runner"
+	"github.com/zondax/golem/pkg/zcache"
+	"github.com/zondax/golem/pkg/zrouter"
+	"github.com/zondax/golem/pkg/zrouter/zmiddlewares"
+	"net/http"
+	"time"
+)
+
+const (
+	defaultAddress = ":8889"
+	defaultTimeOut = 5000
+
+	RoutesPath    = "/admin/routes"
+	ConfigPath    = "/admin/config"
+	BuildInfoPath = "/admin/build"
+	CachesPath    = "/admin/caches"
+	DatabasesPath = "/admin/databases"
+	TasksPath     = "/admin/tasks"
+	LogLevelPath  = "/admin/log-level"
+)
+
+// RoutesProvider is usually the service zrouter. When it also describes its routes, the middleware
+// chain of each route is reported.
+type RoutesProvider interface {
+	GetRegisteredRoutes() []zrouter.RegisteredRoute
+}
+
+type CacheStatsProvider interface {
+	GetStats() zcache.ZCacheStats
+}
+
+type DBStatsProvider interface {
+	GetDBStats() (sql.DBStats, error)
+}
+
+type TaskStatesProvider interface {
+	TaskStates() []runner.TaskState
+}
+
+type Config struct {
+	ReadTimeOut  time.Duration
+	WriteTimeOut time.Duration
+	Logger       *logger.Logger
+	AppVersion   string
+	AppRevision  string
+	// Auth protects every admin endpoint, e.g. with zmiddlewares.APIKeyAuth. Without it the admin
+	// listener must only be reachable from trusted networks.
+	Auth zmiddlewares.Middleware
+
+	Routes RoutesProvider
+	// EffectiveConfig is reported with the values of secret-like keys and URL passwords redacted.
+	EffectiveConfig interface{}
+	// RedactKeys are the key fragments considered secret. Defaults to DefaultRedactKeys.
+	RedactKeys []string
+	Caches     map[string]CacheStatsProvider
+	Databases  map[string]DBStatsProvider
+	Runner     TaskStatesProvider
+}
+
+func (c *Config) setDefaultValues() {
+	if c.ReadTimeOut == 0 {
+		c.ReadTimeOut = time.Duration(defaultTimeOut) * time.Millisecond
+	}
+
+	if c.WriteTimeOut == 0 {
+		c.WriteTimeOut = time.Duration(defaultTimeOut) * time.Millisecond
+	}
+
+	if c.Logger == nil {
+		l := logger.NewLogger()
+		c.Logger = l
+	}
+
+	if c.RedactKeys == nil {
+		c.RedactKeys = DefaultRedactKeys
+	}
+}
+
+type ZAdmin interface {
+	Run(addr ...string) error
+	// Register adds the admin endpoints to another router, to serve them from the service listener.
+	// Config.Auth should be set, otherwise the endpoints are public on that listener and a warning is logged.
+	Register(routes zrouter.Routes)
+	GetHandler() http.Handler
+}
+
+type zadmin struct {
+	router zrouter.ZRouter
+	config *Config
+}
+
+func New(metricsServer metrics.TaskMetrics, config *Config) ZAdmin {
+	if config == nil {
+		config = &Config{}
+	}
+
+	config.setDefaultValues()
+	za := &zadmin{
+		config: config,
+		router: zrouter.New(metricsServer, &zrouter.Config{
+			ReadTimeOut:  config.ReadTimeOut,
+			WriteTimeOut: config.WriteTimeOut,
+			Logger:       config.Logger,
+			AppVersion:   config.AppVersion,
+			AppRevision:  config.AppRevision,
+		}),
+	}
+	za.register(za.router)
+
+	return za
+}
+
+func (a *zadmin) Register(routes zrouter.Routes) {
+	if a.config.Auth == nil {
+		a.config.Logger.Warnf("Admin endpoints registered without auth, they are public on the service listener")
+	}
+
+	a.register(routes)
+}
+
+func (a *zadmin) register(routes zrouter.Routes) {
+	var middlewares []zmiddlewares.Middleware
+	if a.config.Auth != nil {
+		middlewares = append(middlewares, a.config.Auth)
+	}
+
+	routes.GET(RoutesPath, a.routes, middlewares...)
+	routes.GET(ConfigPath, a.effectiveConfig, middlewares...)
+	routes.GET(BuildInfoPath, a.buildInfo, middlewares...)
+	routes.GET(CachesPath, a.caches, middlewares...)
+	routes.GET(DatabasesPath, a.databases, middlewares...)
+	routes.GET(TasksPath, a.tasks, middlewares...)
+	routes.GET(LogLevelPath, a.logLevel, middlewares...)
+	routes.PUT(LogLevelPath, a.setLogLevel, middlewares...)
+}
+
+func (a *zadmin) GetHandler() http.Handler {
+	return a.router.GetHandler()
+}
+
+func (a *zadmin) Run(addr ...string) error {
+	address := defaultAddress
+	if len(addr) > 0 {
+		address = addr[0]
+	}
+
+	a.config.Logger.Infof("Start admin server at %v", address)
+
+	server := &http.Server{
+		Addr:         address,
+		Handler:      a.router.GetHandler(),
+		ReadTimeout:  a.config.ReadTimeOut,
+		WriteTimeout: a.config.WriteTimeOut,
+	}
+
+	return server.ListenAndServe()
+}
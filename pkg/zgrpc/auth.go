@@ -0,0 +1,100 @@
+package zgrpc
+
+import (
+	"context"
+	"github.com/zondax/golem/pkg/logger"
+	"github.com/zondax/golem/pkg/zrouter/auth"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"strings"
+)
+
+const (
+	authorizationMetadataKey = "authorization"
+	bearerPrefix             = "Bearer "
+)
+
+// AuthFunc authenticates a call. It returns the context to continue with, usually carrying the
+// principal (auth.ContextWithPrincipal), or a status error such as Unauthenticated.
+type AuthFunc func(ctx context.Context, fullMethod string) (context.Context, error)
+
+type AuthOptions struct {
+	Func AuthFunc
+	// ExcludeMethods are full method names that are not authenticated. Health and reflection calls never are.
+	ExcludeMethods []string
+}
+
+// Auth runs the AuthFunc before the handler. Errors that are not status errors become Unauthenticated.
+func Auth(options AuthOptions) Interceptor {
+	excluded := make(map[string]struct{}, len(options.ExcludeMethods))
+	for _, method := range options.ExcludeMethods {
+		excluded[method] = struct{}{}
+	}
+
+	return newInterceptor(func(ctx context.Context, fullMethod string, call callFunc) error {
+		if _, ok := excluded[fullMethod]; ok || isInfrastructureMethod(fullMethod) {
+			return call(ctx)
+		}
+
+		authCtx, err := options.Func(ctx, fullMethod)
+		if err != nil {
+			if _, ok := status.FromError(err); !ok {
+				err = status.Error(codes.Unauthenticated, err.Error())
+			}
+			return err
+		}
+		return call(authCtx)
+	})
+}
+
+// JWTAuth verifies the bearer token of the authorization metadata and stores its claims and principal
+// in the context, like zmiddlewares.JWTAuth. With optional set, calls without a token are let through.
+func JWTAuth(verifier *auth.Verifier, optional bool) AuthFunc {
+	return func(ctx context.Context, _ string) (context.Context, error) {
+		authorization := firstMetadataValue(ctx, authorizationMetadataKey)
+		if authorization == "" && optional {
+			return ctx, nil
+		}
+
+		if !strings.HasPrefix(authorization, bearerPrefix) {
+			return nil, status.Error(codes.Unauthenticated, "missing bearer token")
+		}
+
+		claims, err := verifier.Verify(ctx, strings.TrimPrefix(authorization, bearerPrefix))
+		if err != nil {
+			logger.GetLoggerFromContext(ctx).Debugf("Rejected JWT: %v", err)
+			return nil, status.Error(codes.Unauthenticated, "invalid token")
+		}
+
+		ctx = auth.ContextWithClaims(ctx, claims)
+		return auth.ContextWithPrincipal(ctx, claims.Principal()), nil
+	}
+}
+
+// RequireScopes returns an AuthFunc that rejects calls whose principal was not granted every given scope.
+// It must be chained after an authentication function with ChainAuth.
+func RequireScopes(scopes ...string) AuthFunc {
+	return func(ctx context.Context, _ string) (context.Context, error) {
+		principal, ok := auth.PrincipalFromContext(ctx)
+		if !ok {
+			return nil, status.Error(codes.Unauthenticated, "authentication required")
+		}
+		if !principal.HasScopes(scopes...) {
+			return nil, status.Error(codes.PermissionDenied, "insufficient scope")
+		}
+		return ctx, nil
+	}
+}
+
+// ChainAuth runs the given functions in order, passing along the context.
+func ChainAuth(funcs ...AuthFunc) AuthFunc {
+	return func(ctx context.Context, fullMethod string) (context.Context, error) {
+		for _, fn := range funcs {
+			var err error
+			if ctx, err = fn(ctx, fullMethod); err != nil {
+				return nil, err
+			}
+		}
+		return ctx, nil
+	}
+}
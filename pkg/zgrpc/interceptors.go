@@ -0,0 +1,145 @@
+package zgrpc
+
+import (
+	"context"
+	"github.com/google/uuid"
+	"github.com/zondax/golem/pkg/logger"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"runtime/debug"
+	"strings"
+	"time"
+)
+
+const (
+	// RequestIDMetadataKey is the metadata equivalent of the X-Request-ID header.
+	RequestIDMetadataKey = "x-request-id"
+
+	healthServicePrefix     = "/grpc.health.v1.Health/"
+	reflectionServicePrefix = "/grpc.reflection."
+)
+
+// Interceptor pairs the unary and stream forms of a server interceptor.
+type Interceptor struct {
+	Unary  grpc.UnaryServerInterceptor
+	Stream grpc.StreamServerInterceptor
+}
+
+// callFunc runs the rest of the chain with the given context.
+type callFunc func(ctx context.Context) error
+
+// newInterceptor builds both forms of an interceptor from a function wrapping a call.
+func newInterceptor(wrap func(ctx context.Context, fullMethod string, call callFunc) error) Interceptor {
+	return Interceptor{
+		Unary: func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+			var resp interface{}
+			err := wrap(ctx, info.FullMethod, func(ctx context.Context) error {
+				var err error
+				resp, err = handler(ctx, req)
+				return err
+			})
+			return resp, err
+		},
+		Stream: func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+			return wrap(ss.Context(), info.FullMethod, func(ctx context.Context) error {
+				if ctx == ss.Context() {
+					return handler(srv, ss)
+				}
+				return handler(srv, &serverStream{ServerStream: ss, ctx: ctx})
+			})
+		},
+	}
+}
+
+// serverStream replaces the context of a stream.
+type serverStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *serverStream) Context() context.Context {
+	return s.ctx
+}
+
+type LoggingOptions struct {
+	// Enable logs a line per call. The request ID and the request logger are always set.
+	Enable bool
+	// ExcludeMethods are full method names, e.g. "/grpc.health.v1.Health/Check", that are not logged.
+	ExcludeMethods []string
+}
+
+// Logging reads the request ID from the x-request-id metadata, or generates one, sends it back in the
+// response header and stores the request logger in the context.
+func Logging(options LoggingOptions) Interceptor {
+	excluded := make(map[string]struct{}, len(options.ExcludeMethods))
+	for _, method := range options.ExcludeMethods {
+		excluded[method] = struct{}{}
+	}
+
+	return newInterceptor(func(ctx context.Context, fullMethod string, call callFunc) error {
+		requestID := firstMetadataValue(ctx, RequestIDMetadataKey)
+		if requestID == "" {
+			requestID = uuid.New().String()
+		}
+		_ = grpc.SetHeader(ctx, metadata.Pairs(RequestIDMetadataKey, requestID))
+
+		log := newRequestLogger(ctx, requestID)
+		ctx = logger.ContextWithLogger(ctx, log)
+
+		start := time.Now()
+		err := call(ctx)
+
+		if _, ok := excluded[fullMethod]; options.Enable && !ok {
+			log.Infof("Method: %s | Code: %s - Duration: %s", fullMethod, status.Code(err), time.Since(start))
+		}
+		return err
+	})
+}
+
+// Recovery turns panics into Internal errors, logging the stack.
+func Recovery() Interceptor {
+	return newInterceptor(func(ctx context.Context, fullMethod string, call callFunc) (err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				logger.GetLoggerFromContext(ctx).Errorf("Internal error in %s: %v\n%s", fullMethod, r, debug.Stack())
+				err = status.Error(codes.Internal, "an internal error occurred")
+			}
+		}()
+		return call(ctx)
+	})
+}
+
+// newRequestLogger tags the call logger with the request ID and, when the call is traced, the trace and span IDs.
+func newRequestLogger(ctx context.Context, requestID string) *logger.Logger {
+	fields := []interface{}{logger.Field{Key: logger.RequestIDKey, Value: requestID}}
+	if spanContext := trace.SpanContextFromContext(ctx); spanContext.IsValid() {
+		fields = append(fields,
+			logger.Field{Key: logger.TraceIDKey, Value: spanContext.TraceID().String()},
+			logger.Field{Key: logger.SpanIDKey, Value: spanContext.SpanID().String()},
+		)
+	}
+	return logger.NewLogger(fields...)
+}
+
+func firstMetadataValue(ctx context.Context, key string) string {
+	if values := metadata.ValueFromIncomingContext(ctx, key); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// splitMethod splits "/package.Service/Method" into its service and method names.
+func splitMethod(fullMethod string) (string, string) {
+	service, method, found := strings.Cut(strings.TrimPrefix(fullMethod, "/"), "/")
+	if !found {
+		return "unknown", service
+	}
+	return service, method
+}
+
+func isInfrastructureMethod(fullMethod string) bool {
+	return strings.HasPrefix(fullMethod, healthServicePrefix) || strings.HasPrefix(fullMethod, reflectionServicePrefix)
+}
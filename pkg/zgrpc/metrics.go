@@ -0,0 +1,67 @@
+package zgrpc
+
+import (
+	"context"
+	"github.com/zondax/golem/pkg/logger"
+	"github.com/zondax/golem/pkg/metrics"
+	"github.com/zondax/golem/pkg/metrics/collectors"
+	"github.com/zondax/golem/pkg/zrouter/zmiddlewares"
+	"google.golang.org/grpc/status"
+	"time"
+)
+
+const (
+	totalRequestsMetricName        = "grpc_requests_total"
+	durationMillisecondsMetricName = "grpc_request_duration_ms"
+	inFlightRequestsMetricName     = "grpc_requests_in_flight"
+	serviceLabel                   = "service"
+	methodLabel                    = "method"
+	codeLabel                      = "code"
+)
+
+// RegisterMetrics registers the metrics recorded by the Metrics interceptor.
+func RegisterMetrics(metricsServer metrics.TaskMetrics) []error {
+	var errs []error
+	register := func(name, help string, labels []string, handler metrics.MetricHandler) {
+		if err := metricsServer.RegisterMetric(name, help, labels, handler); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	register(totalRequestsMetricName, "Total number of gRPC calls.", []string{serviceLabel, methodLabel, codeLabel}, &collectors.Counter{})
+	register(durationMillisecondsMetricName, "Duration of gRPC calls in milliseconds.", []string{serviceLabel, methodLabel, codeLabel},
+		&collectors.Histogram{Buckets: zmiddlewares.DefaultDurationBuckets})
+	register(inFlightRequestsMetricName, "Number of gRPC calls being served.", []string{serviceLabel, methodLabel}, &collectors.Gauge{})
+
+	return errs
+}
+
+// Metrics records the call count and duration by service, method and status code, and the in-flight calls.
+// The metrics must be registered first with RegisterMetrics.
+func Metrics(metricsServer metrics.TaskMetrics) Interceptor {
+	return newInterceptor(func(ctx context.Context, fullMethod string, call callFunc) error {
+		service, method := splitMethod(fullMethod)
+		log := logger.GetLoggerFromContext(ctx)
+
+		if err := metricsServer.IncrementMetric(inFlightRequestsMetricName, service, method); err != nil {
+			log.Errorf("error updating gRPC in-flight calls metric: %v", err.Error())
+		}
+
+		start := time.Now()
+		callErr := call(ctx)
+		duration := float64(time.Since(start)) / float64(time.Millisecond)
+
+		if err := metricsServer.DecrementMetric(inFlightRequestsMetricName, service, method); err != nil {
+			log.Errorf("error updating gRPC in-flight calls metric: %v", err.Error())
+		}
+
+		code := status.Code(callErr).String()
+		if err := metricsServer.UpdateMetric(durationMillisecondsMetricName, duration, service, method, code); err != nil {
+			log.Errorf("error updating gRPC call duration metric: %v", err.Error())
+		}
+		if err := metricsServer.UpdateMetric(totalRequestsMetricName, 1, service, method, code); err != nil {
+			log.Errorf("error updating gRPC total calls metric: %v", err.Error())
+		}
+		return callErr
+	})
+}
@@ -0,0 +1,119 @@
+package zgrpc
+
+import (
+	"context"
+	"github.com/zondax/golem/pkg/logger"
+	"github.com/zondax/golem/pkg/zrouter/auth"
+	"github.com/zondax/golem/pkg/zrouter/zmiddlewares"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"net"
+	"strconv"
+	"time"
+)
+
+const (
+	retryAfterMetadataKey  = "retry-after"
+	defaultRateLimitPolicy = "default"
+)
+
+// RateLimitKeyFunc identifies the client a call is counted against. Returning false skips limiting.
+type RateLimitKeyFunc func(ctx context.Context) (string, bool)
+
+func KeyByPeer(ctx context.Context) (string, bool) {
+	p, ok := peer.FromContext(ctx)
+	if !ok || p.Addr == nil {
+		return "", false
+	}
+
+	host, _, err := net.SplitHostPort(p.Addr.String())
+	if err != nil {
+		host = p.Addr.String()
+	}
+	return "ip:" + host, host != ""
+}
+
+// KeyBySubject uses the authenticated principal: the JWT subject or the API key owner.
+func KeyBySubject(ctx context.Context) (string, bool) {
+	principal, ok := auth.PrincipalFromContext(ctx)
+	if !ok || principal.ID == "" {
+		return "", false
+	}
+	return "sub:" + principal.ID, true
+}
+
+type RateLimitOptions struct {
+	// Policy applies to every call without a more specific policy.
+	Policy zmiddlewares.RateLimitPolicy
+	// Methods overrides the policy by full method name. Each method has its own counters.
+	Methods map[string]zmiddlewares.RateLimitPolicy
+	// Tiers overrides the policy by the rate tier of the authenticated principal.
+	Tiers map[string]zmiddlewares.RateLimitPolicy
+	// KeyFunc defaults to KeyByPeer.
+	KeyFunc RateLimitKeyFunc
+	// Store defaults to an in-memory store. zmiddlewares.NewRemoteRateLimitStore shares the limits with zrouter.
+	Store zmiddlewares.RateLimitStore
+}
+
+func (o *RateLimitOptions) setDefaultValues() {
+	if o.KeyFunc == nil {
+		o.KeyFunc = KeyByPeer
+	}
+
+	if o.Store == nil {
+		o.Store = zmiddlewares.NewMemoryRateLimitStore()
+	}
+}
+
+// RateLimit limits calls per client using fixed windows, rejecting them with ResourceExhausted and a
+// retry-after header. Calls are let through when the store is unavailable.
+func RateLimit(options RateLimitOptions) Interceptor {
+	options.setDefaultValues()
+
+	return newInterceptor(func(ctx context.Context, fullMethod string, call callFunc) error {
+		name, policy := options.policyFor(ctx, fullMethod)
+		if policy.Limit <= 0 || policy.Window <= 0 {
+			return call(ctx)
+		}
+
+		clientKey, ok := options.KeyFunc(ctx)
+		if !ok {
+			return call(ctx)
+		}
+
+		count, reset, err := options.Store.Take(ctx, name+":"+clientKey, policy.Window)
+		if err != nil {
+			logger.GetLoggerFromContext(ctx).Errorf("Error checking rate limit: %v", err)
+			return call(ctx)
+		}
+
+		if count > int64(policy.Limit) {
+			resetSeconds := int64(time.Until(reset).Round(time.Second).Seconds())
+			if resetSeconds < 1 {
+				resetSeconds = 1
+			}
+			_ = grpc.SetHeader(ctx, metadata.Pairs(retryAfterMetadataKey, strconv.FormatInt(resetSeconds, 10)))
+			return status.Error(codes.ResourceExhausted, "rate limit exceeded")
+		}
+		return call(ctx)
+	})
+}
+
+func (o *RateLimitOptions) policyFor(ctx context.Context, fullMethod string) (string, zmiddlewares.RateLimitPolicy) {
+	if policy, ok := o.Methods[fullMethod]; ok {
+		return fullMethod, policy
+	}
+
+	if len(o.Tiers) > 0 {
+		if principal, ok := auth.PrincipalFromContext(ctx); ok {
+			if policy, ok := o.Tiers[principal.RateTier]; ok {
+				return "tier:" + principal.RateTier, policy
+			}
+		}
+	}
+
+	return defaultRateLimitPolicy, o.Policy
+}
@@ -0,0 +1,115 @@
+package zgrpc
+
+import (
+	"context"
+	"github.com/zondax/golem/pkg/zobservability"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	otelcodes "go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"net"
+)
+
+const tracerName = "github.com/zondax/golem/pkg/zgrpc"
+
+type TracingOptions struct {
+	// TracerProvider defaults to the global provider, which is the one configured by the SigNoz observer.
+	TracerProvider trace.TracerProvider
+	// Propagation selects the formats extracted from the incoming metadata. When empty, the global
+	// propagator is used.
+	Propagation zobservability.PropagationConfig
+	// ExcludeMethods are full method names that are not traced.
+	ExcludeMethods []string
+}
+
+// Tracing starts a server span per call, named after the full method and continuing the trace found in
+// the incoming metadata.
+func Tracing(options TracingOptions) Interceptor {
+	tracerProvider := options.TracerProvider
+	if tracerProvider == nil {
+		tracerProvider = otel.GetTracerProvider()
+	}
+	tracer := tracerProvider.Tracer(tracerName)
+
+	propagator := otel.GetTextMapPropagator()
+	if len(options.Propagation.Formats) > 0 {
+		propagator = zobservability.NewPropagator(options.Propagation)
+	}
+
+	excluded := make(map[string]struct{}, len(options.ExcludeMethods))
+	for _, method := range options.ExcludeMethods {
+		excluded[method] = struct{}{}
+	}
+
+	return newInterceptor(func(ctx context.Context, fullMethod string, call callFunc) error {
+		if _, ok := excluded[fullMethod]; ok {
+			return call(ctx)
+		}
+
+		md, _ := metadata.FromIncomingContext(ctx)
+		ctx = propagator.Extract(ctx, metadataCarrier(md))
+
+		service, method := splitMethod(fullMethod)
+		attributes := []attribute.KeyValue{
+			semconv.RPCSystemGRPC,
+			semconv.RPCService(service),
+			semconv.RPCMethod(method),
+		}
+		if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+			if host, _, err := net.SplitHostPort(p.Addr.String()); err == nil {
+				attributes = append(attributes, semconv.ClientAddress(host))
+			}
+		}
+
+		ctx, span := tracer.Start(ctx, fullMethod, trace.WithSpanKind(trace.SpanKindServer), trace.WithAttributes(attributes...))
+		defer span.End()
+
+		err := call(ctx)
+		code := status.Code(err)
+		span.SetAttributes(attribute.Int(zobservability.SpanAttributeRPCGRPCStatusCode, int(code)))
+		if isServerError(code) {
+			span.SetStatus(otelcodes.Error, code.String())
+			span.RecordError(err)
+		}
+		return err
+	})
+}
+
+// isServerError follows the OpenTelemetry conventions for gRPC server spans.
+func isServerError(code codes.Code) bool {
+	switch code {
+	case codes.Unknown, codes.DeadlineExceeded, codes.Unimplemented, codes.Internal, codes.Unavailable, codes.DataLoss:
+		return true
+	}
+	return false
+}
+
+// metadataCarrier adapts gRPC metadata to the OpenTelemetry propagators.
+type metadataCarrier metadata.MD
+
+func (c metadataCarrier) Get(key string) string {
+	if values := metadata.MD(c).Get(key); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+func (c metadataCarrier) Set(key, value string) {
+	metadata.MD(c).Set(key, value)
+}
+
+func (c metadataCarrier) Keys() []string {
+	keys := make([]string, 0, len(c))
+	for key := range c {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+var _ propagation.TextMapCarrier = metadataCarrier{}
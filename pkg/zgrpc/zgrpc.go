@@ -0,0 +1,229 @@
+package zgrpc
+
+import (
+	"errors"
+	"github.com/zondax/golem/pkg/logger"
+	"github.com/zondax/golem/pkg/metrics"
+	"github.com/zondax/golem/pkg/runner"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	taskName                   = "zgrpc"
+	defaultAddress             = ":50051"
+	defaultGracefulStopTimeout = 30 * time.Second
+)
+
+var ErrServerStopped = errors.New("grpc server stopped")
+
+type TracingConfig struct {
+	// Enable starts a server span per call around the other interceptors.
+	Enable  bool
+	Options TracingOptions
+}
+
+type RateLimitConfig struct {
+	Enable  bool
+	Options RateLimitOptions
+}
+
+type Config struct {
+	// Address is the listen address of Start and Run without arguments. Defaults to ":50051".
+	Address string
+	Logger  *logger.Logger
+	Logging LoggingOptions
+	Tracing TracingConfig
+	// Auth authenticates every call but the health and reflection ones. Disabled when Auth.Func is nil.
+	Auth      AuthOptions
+	RateLimit RateLimitConfig
+	// EnableReflection registers the server reflection service, used by tools such as grpcurl.
+	EnableReflection bool
+	// GracefulStopTimeout bounds the time Stop waits for in-flight calls. Defaults to 30s.
+	GracefulStopTimeout time.Duration
+	ServerOptions       []grpc.ServerOption
+	// Interceptors run after the built-in ones, in order.
+	Interceptors []Interceptor
+}
+
+func (c *Config) setDefaultValues() {
+	if c.Address == "" {
+		c.Address = defaultAddress
+	}
+
+	if c.Logger == nil {
+		l := logger.NewLogger()
+		c.Logger = l
+	}
+
+	if c.GracefulStopTimeout == 0 {
+		c.GracefulStopTimeout = defaultGracefulStopTimeout
+	}
+}
+
+// ZGRPC is a gRPC server with the interceptor stack of zrouter. It can be added to a runner.TaskRunner,
+// which stops it on shutdown while Start is serving. Stop marks the server as not serving and waits
+// for the in-flight calls, up to GracefulStopTimeout.
+type ZGRPC interface {
+	runner.BlockingTask
+	grpc.ServiceRegistrar
+	Run(addr ...string) error
+	Serve(listener net.Listener) error
+	Health() *health.Server
+	GetServer() *grpc.Server
+}
+
+type zgrpc struct {
+	server *grpc.Server
+	health *health.Server
+	config *Config
+
+	mutex   sync.Mutex
+	stopped bool
+}
+
+// New builds the server. Interceptors run in the order of zrouter's default middlewares: tracing,
+// logging with request IDs, metrics (when metricsServer is set), panic recovery, auth and rate limiting.
+func New(metricsServer metrics.TaskMetrics, config *Config) ZGRPC {
+	if config == nil {
+		config = &Config{}
+	}
+
+	config.setDefaultValues()
+
+	var interceptors []Interceptor
+	if config.Tracing.Enable {
+		interceptors = append(interceptors, Tracing(config.Tracing.Options))
+	}
+	interceptors = append(interceptors, Logging(config.Logging))
+	if metricsServer != nil {
+		if errs := RegisterMetrics(metricsServer); len(errs) > 0 {
+			config.Logger.Errorf("Error registering gRPC metrics %v", errs)
+		}
+		interceptors = append(interceptors, Metrics(metricsServer))
+	}
+	interceptors = append(interceptors, Recovery())
+	if config.Auth.Func != nil {
+		interceptors = append(interceptors, Auth(config.Auth))
+	}
+	if config.RateLimit.Enable {
+		interceptors = append(interceptors, RateLimit(config.RateLimit.Options))
+	}
+	interceptors = append(interceptors, config.Interceptors...)
+
+	unary := make([]grpc.UnaryServerInterceptor, len(interceptors))
+	stream := make([]grpc.StreamServerInterceptor, len(interceptors))
+	for i, interceptor := range interceptors {
+		unary[i] = interceptor.Unary
+		stream[i] = interceptor.Stream
+	}
+
+	options := append([]grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(unary...),
+		grpc.ChainStreamInterceptor(stream...),
+	}, config.ServerOptions...)
+
+	zg := &zgrpc{
+		server: grpc.NewServer(options...),
+		health: health.NewServer(),
+		config: config,
+	}
+
+	healthpb.RegisterHealthServer(zg.server, zg.health)
+	if config.EnableReflection {
+		reflection.Register(zg.server)
+	}
+
+	return zg
+}
+
+func (z *zgrpc) RegisterService(desc *grpc.ServiceDesc, impl interface{}) {
+	z.server.RegisterService(desc, impl)
+	z.health.SetServingStatus(desc.ServiceName, healthpb.HealthCheckResponse_SERVING)
+}
+
+func (z *zgrpc) Health() *health.Server {
+	return z.health
+}
+
+func (z *zgrpc) GetServer() *grpc.Server {
+	return z.server
+}
+
+func (z *zgrpc) Name() string {
+	return taskName
+}
+
+func (z *zgrpc) Start() error {
+	return z.Run()
+}
+
+func (z *zgrpc) Run(addr ...string) error {
+	address := z.config.Address
+	if len(addr) > 0 {
+		address = addr[0]
+		if !strings.Contains(address, ":") {
+			address = ":" + address
+		}
+	}
+
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return err
+	}
+
+	z.config.Logger.Infof("Start gRPC server at %v", address)
+	return z.Serve(listener)
+}
+
+// Serve blocks until the server is stopped. A stopped server cannot be served again.
+func (z *zgrpc) Serve(listener net.Listener) error {
+	z.mutex.Lock()
+	stopped := z.stopped
+	z.mutex.Unlock()
+	if stopped {
+		_ = listener.Close()
+		return ErrServerStopped
+	}
+
+	if err := z.server.Serve(listener); err != nil && !errors.Is(err, grpc.ErrServerStopped) {
+		return err
+	}
+	return nil
+}
+
+// BlocksUntilStopped marks the server as a runner.BlockingTask, Start serves until Stop is called.
+func (z *zgrpc) BlocksUntilStopped() {}
+
+func (z *zgrpc) Stop() error {
+	z.mutex.Lock()
+	if z.stopped {
+		z.mutex.Unlock()
+		return nil
+	}
+	z.stopped = true
+	z.mutex.Unlock()
+
+	z.health.Shutdown()
+
+	done := make(chan struct{})
+	go func() {
+		z.server.GracefulStop()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(z.config.GracefulStopTimeout):
+		z.config.Logger.Warnf("gRPC server did not stop within %s, closing the remaining calls", z.config.GracefulStopTimeout)
+		z.server.Stop()
+		<-done
+	}
+	return nil
+}
@@ -0,0 +1,183 @@
+package zgrpc
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+	"github.com/zondax/golem/pkg/logger"
+	"github.com/zondax/golem/pkg/metrics"
+	"github.com/zondax/golem/pkg/zrouter/auth"
+	"github.com/zondax/golem/pkg/zrouter/zmiddlewares"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+	"net"
+	"testing"
+	"time"
+)
+
+const (
+	echoMethod = "/test.Echo/Say"
+	tokenValue = "secret"
+)
+
+type echoServer interface {
+	Say(ctx context.Context, in *wrapperspb.StringValue) (*wrapperspb.StringValue, error)
+}
+
+type echo struct{}
+
+func (echo) Say(ctx context.Context, in *wrapperspb.StringValue) (*wrapperspb.StringValue, error) {
+	if in.GetValue() == "panic" {
+		panic("boom")
+	}
+
+	subject := ""
+	if principal, ok := auth.PrincipalFromContext(ctx); ok {
+		subject = principal.ID
+	}
+	return wrapperspb.String(in.GetValue() + ":" + subject), nil
+}
+
+func sayHandler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(wrapperspb.StringValue)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	info := &grpc.UnaryServerInfo{Server: srv, FullMethod: echoMethod}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(echoServer).Say(ctx, req.(*wrapperspb.StringValue))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+var echoServiceDesc = grpc.ServiceDesc{
+	ServiceName: "test.Echo",
+	HandlerType: (*echoServer)(nil),
+	Methods:     []grpc.MethodDesc{{MethodName: "Say", Handler: sayHandler}},
+}
+
+type ZGRPCSuite struct {
+	suite.Suite
+	metrics  *metrics.MockTaskMetrics
+	server   ZGRPC
+	listener *bufconn.Listener
+	conn     *grpc.ClientConn
+}
+
+func (suite *ZGRPCSuite) SetupTest() {
+	logger.InitLogger(logger.Config{})
+	suite.metrics = new(metrics.MockTaskMetrics)
+	suite.metrics.On("RegisterMetric", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	suite.metrics.On("UpdateMetric", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	suite.metrics.On("IncrementMetric", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	suite.metrics.On("DecrementMetric", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+
+	suite.server = New(suite.metrics, &Config{
+		Logging: LoggingOptions{Enable: true},
+		Tracing: TracingConfig{Enable: true},
+		Auth: AuthOptions{Func: func(ctx context.Context, _ string) (context.Context, error) {
+			if firstMetadataValue(ctx, authorizationMetadataKey) != bearerPrefix+tokenValue {
+				return nil, errors.New("invalid token")
+			}
+			return auth.ContextWithPrincipal(ctx, &auth.Principal{ID: "golem"}), nil
+		}},
+		RateLimit: RateLimitConfig{Enable: true, Options: RateLimitOptions{
+			Methods: map[string]zmiddlewares.RateLimitPolicy{echoMethod: {Limit: 3, Window: time.Minute}},
+			KeyFunc: KeyBySubject,
+		}},
+		EnableReflection:    true,
+		GracefulStopTimeout: time.Second,
+	})
+	suite.server.RegisterService(&echoServiceDesc, echo{})
+
+	suite.listener = bufconn.Listen(1 << 20)
+	go func() { _ = suite.server.Serve(suite.listener) }()
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return suite.listener.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	suite.Require().NoError(err)
+	suite.conn = conn
+}
+
+func (suite *ZGRPCSuite) TearDownTest() {
+	_ = suite.conn.Close()
+	_ = suite.server.Stop()
+}
+
+func (suite *ZGRPCSuite) say(ctx context.Context, value string, opts ...grpc.CallOption) (*wrapperspb.StringValue, error) {
+	out := new(wrapperspb.StringValue)
+	err := suite.conn.Invoke(ctx, echoMethod, wrapperspb.String(value), out, opts...)
+	return out, err
+}
+
+func (suite *ZGRPCSuite) authorized() context.Context {
+	return metadata.AppendToOutgoingContext(context.Background(), authorizationMetadataKey, bearerPrefix+tokenValue)
+}
+
+func (suite *ZGRPCSuite) TestUnaryCall() {
+	var header metadata.MD
+	ctx := metadata.AppendToOutgoingContext(suite.authorized(), RequestIDMetadataKey, "request-1")
+	out, err := suite.say(ctx, "hello", grpc.Header(&header))
+	suite.Require().NoError(err)
+	suite.Equal("hello:golem", out.GetValue())
+	suite.Equal([]string{"request-1"}, header.Get(RequestIDMetadataKey))
+
+	suite.metrics.AssertCalled(suite.T(), "UpdateMetric", totalRequestsMetricName, float64(1), "test.Echo", "Say", codes.OK.String())
+	suite.metrics.AssertCalled(suite.T(), "IncrementMetric", inFlightRequestsMetricName, "test.Echo", "Say")
+}
+
+func (suite *ZGRPCSuite) TestAuthAndRecovery() {
+	_, err := suite.say(context.Background(), "hello")
+	suite.Equal(codes.Unauthenticated, status.Code(err))
+
+	_, err = suite.say(suite.authorized(), "panic")
+	suite.Equal(codes.Internal, status.Code(err))
+	suite.metrics.AssertCalled(suite.T(), "UpdateMetric", totalRequestsMetricName, float64(1), "test.Echo", "Say", codes.Internal.String())
+
+	// Health checks are never authenticated.
+	response, err := healthpb.NewHealthClient(suite.conn).Check(context.Background(), &healthpb.HealthCheckRequest{Service: "test.Echo"})
+	suite.Require().NoError(err)
+	suite.Equal(healthpb.HealthCheckResponse_SERVING, response.GetStatus())
+}
+
+func (suite *ZGRPCSuite) TestRateLimit() {
+	for i := 0; i < 3; i++ {
+		_, err := suite.say(suite.authorized(), "hello")
+		suite.Require().NoError(err)
+	}
+
+	var header metadata.MD
+	_, err := suite.say(suite.authorized(), "hello", grpc.Header(&header))
+	suite.Equal(codes.ResourceExhausted, status.Code(err))
+	suite.NotEmpty(header.Get(retryAfterMetadataKey))
+}
+
+func (suite *ZGRPCSuite) TestGracefulStop() {
+	health := healthpb.NewHealthClient(suite.conn)
+	stream, err := health.Watch(context.Background(), &healthpb.HealthCheckRequest{Service: "test.Echo"})
+	suite.Require().NoError(err)
+
+	update, err := stream.Recv()
+	suite.Require().NoError(err)
+	suite.Equal(healthpb.HealthCheckResponse_SERVING, update.GetStatus())
+
+	suite.NoError(suite.server.Stop())
+	update, err = stream.Recv()
+	suite.Require().NoError(err)
+	suite.Equal(healthpb.HealthCheckResponse_NOT_SERVING, update.GetStatus())
+
+	suite.ErrorIs(suite.server.Serve(bufconn.Listen(1)), ErrServerStopped)
+	suite.Equal(taskName, suite.server.Name())
+}
+
+func TestZGRPCSuite(t *testing.T) {
+	suite.Run(t, new(ZGRPCSuite))
+}
@@ -14,6 +14,7 @@ const (
 type BackOff struct {
 	maxAttempts     int
 	maxDuration     time.Duration
+	maxInterval     time.Duration
 	initialDuration time.Duration
 }
 
@@ -29,13 +30,23 @@ func (b *BackOff) WithMaxDuration(max time.Duration) *BackOff {
 	b.maxDuration = max
 	return b
 }
+
+// WithMaxInterval caps the delay between exponential attempts. Defaults to a minute.
+func (b *BackOff) WithMaxInterval(max time.Duration) *BackOff {
+	b.maxInterval = max
+	return b
+}
 func (b *BackOff) WithInitialDuration(initial time.Duration) *BackOff {
 	b.initialDuration = initial
 	return b
 }
 
 func (b *BackOff) Exponential() backoff.BackOff {
-	tmp := backoff.NewExponentialBackOff(backoff.WithInitialInterval(b.initialDuration), backoff.WithMaxElapsedTime(b.maxDuration), backoff.WithMultiplier(exponentialMultiplier))
+	opts := []backoff.ExponentialBackOffOpts{backoff.WithInitialInterval(b.initialDuration), backoff.WithMaxElapsedTime(b.maxDuration), backoff.WithMultiplier(exponentialMultiplier)}
+	if b.maxInterval > 0 {
+		opts = append(opts, backoff.WithMaxInterval(b.maxInterval))
+	}
+	tmp := backoff.NewExponentialBackOff(opts...)
 	maxAttempts, _ := zconverters.IntToUInt64(b.maxAttempts)
 	return backoff.WithMaxRetries(tmp, maxAttempts)
 }
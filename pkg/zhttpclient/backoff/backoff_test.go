@@ -89,6 +89,27 @@ func TestBackOff_WithMaxDuration(t *testing.T) {
 	})
 }
 
+func TestBackOff_WithMaxInterval(t *testing.T) {
+	t.Run("ShouldSetMaxInterval", func(t *testing.T) {
+		backOff := New().WithMaxInterval(time.Hour)
+
+		assert.Equal(t, time.Hour, backOff.maxInterval)
+	})
+
+	t.Run("ShouldCapExponentialIntervals", func(t *testing.T) {
+		backOff := New().
+			WithMaxAttempts(10).
+			WithInitialDuration(100 * time.Millisecond).
+			WithMaxInterval(200 * time.Millisecond).
+			Exponential()
+
+		for i := 0; i < 10; i++ {
+			// The randomization factor allows up to 50% above the cap.
+			assert.LessOrEqual(t, backOff.NextBackOff(), 300*time.Millisecond)
+		}
+	})
+}
+
 func TestBackOff_WithInitialDuration(t *testing.T) {
 	t.Run("ShouldSetInitialDuration", func(t *testing.T) {
 		duration := 100 * time.Millisecond
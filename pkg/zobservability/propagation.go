@@ -0,0 +1,51 @@
+package zobservability
+
+import (
+	"go.opentelemetry.io/contrib/propagators/b3"
+	"go.opentelemetry.io/contrib/propagators/jaeger"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+// NewPropagator creates a composite propagator for the configured formats.
+// It falls back to W3C trace context and baggage when no valid format is configured.
+func NewPropagator(config PropagationConfig) propagation.TextMapPropagator {
+	var propagators []propagation.TextMapPropagator
+	for _, format := range config.Formats {
+		propagators = append(propagators, propagatorsByFormat(format)...)
+	}
+
+	if len(propagators) == 0 {
+		return newW3CPropagator()
+	}
+
+	return propagation.NewCompositeTextMapPropagator(propagators...)
+}
+
+// newW3CPropagator creates the default W3C propagator
+func newW3CPropagator() propagation.TextMapPropagator {
+	return propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	)
+}
+
+// propagatorsByFormat creates propagators for a specific format
+func propagatorsByFormat(format string) []propagation.TextMapPropagator {
+	switch format {
+	case PropagationW3C:
+		return []propagation.TextMapPropagator{
+			propagation.TraceContext{},
+			propagation.Baggage{},
+		}
+	case PropagationB3:
+		return []propagation.TextMapPropagator{b3.New()}
+	case PropagationB3Single:
+		return []propagation.TextMapPropagator{
+			b3.New(b3.WithInjectEncoding(b3.B3SingleHeader)),
+		}
+	case PropagationJaeger:
+		return []propagation.TextMapPropagator{jaeger.Jaeger{}}
+	default:
+		return nil
+	}
+}
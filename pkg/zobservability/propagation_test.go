@@ -0,0 +1,106 @@
+package zobservability
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewPropagator(t *testing.T) {
+	tests := []struct {
+		name           string
+		config         PropagationConfig
+		expectedFields []string
+	}{
+		{
+			name:           "defaults to W3C when no formats specified",
+			config:         PropagationConfig{},
+			expectedFields: []string{"traceparent", "tracestate", "baggage"},
+		},
+		{
+			name:           "invalid format falls back to W3C",
+			config:         PropagationConfig{Formats: []string{"invalid-format"}},
+			expectedFields: []string{"traceparent", "tracestate", "baggage"},
+		},
+		{
+			name:           "B3 and W3C formats",
+			config:         PropagationConfig{Formats: []string{PropagationB3, "invalid-format", PropagationW3C}},
+			expectedFields: []string{"x-b3-traceid", "x-b3-spanid", "x-b3-sampled", "x-b3-flags", "traceparent", "tracestate", "baggage"},
+		},
+		{
+			name:           "Jaeger format",
+			config:         PropagationConfig{Formats: []string{PropagationJaeger}},
+			expectedFields: []string{"uber-trace-id"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			propagator := NewPropagator(tt.config)
+			require.NotNil(t, propagator)
+			assert.ElementsMatch(t, tt.expectedFields, propagator.Fields())
+		})
+	}
+}
+
+func TestNewW3CPropagator(t *testing.T) {
+	propagator := newW3CPropagator()
+	require.NotNil(t, propagator)
+
+	assert.ElementsMatch(t, []string{"traceparent", "tracestate", "baggage"}, propagator.Fields())
+}
+
+func TestPropagatorsByFormat(t *testing.T) {
+	tests := []struct {
+		name        string
+		format      string
+		shouldBeNil bool
+		expectCount int
+	}{
+		{
+			name:        "W3C format",
+			format:      PropagationW3C,
+			shouldBeNil: false,
+			expectCount: 2, // TraceContext + Baggage
+		},
+		{
+			name:        "B3 format",
+			format:      PropagationB3,
+			shouldBeNil: false,
+			expectCount: 1, // B3
+		},
+		{
+			name:        "B3 single header format",
+			format:      PropagationB3Single,
+			shouldBeNil: false,
+			expectCount: 1, // B3
+		},
+		{
+			name:        "Jaeger format",
+			format:      PropagationJaeger,
+			shouldBeNil: false,
+			expectCount: 1, // Jaeger
+		},
+		{
+			name:        "unknown format",
+			format:      "unknown",
+			shouldBeNil: true,
+			expectCount: 0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			propagators := propagatorsByFormat(tt.format)
+
+			if tt.shouldBeNil {
+				assert.Nil(t, propagators)
+				return
+			}
+
+			require.NotNil(t, propagators)
+			assert.Len(t, propagators, tt.expectCount)
+		})
+	}
+}
@@ -119,68 +119,6 @@ func TestCreatePropagator(t *testing.T) {
 	}
 }
 
-func TestCreateW3CPropagator(t *testing.T) {
-	propagator := createW3CPropagator()
-	require.NotNil(t, propagator)
-
-	// Test that the propagator works (smoke test)
-	validatePropagatorTypes(t, propagator, []string{"TraceContext", "Baggage"})
-}
-
-func TestCreatePropagatorByFormat(t *testing.T) {
-	tests := []struct {
-		name        string
-		format      string
-		shouldBeNil bool
-		expectCount int
-	}{
-		{
-			name:        "W3C format",
-			format:      zobservability.PropagationW3C,
-			shouldBeNil: false,
-			expectCount: 2, // TraceContext + Baggage
-		},
-		{
-			name:        "B3 format",
-			format:      zobservability.PropagationB3,
-			shouldBeNil: false,
-			expectCount: 1, // B3
-		},
-		{
-			name:        "B3 single header format",
-			format:      zobservability.PropagationB3Single,
-			shouldBeNil: false,
-			expectCount: 1, // B3
-		},
-		{
-			name:        "Jaeger format",
-			format:      zobservability.PropagationJaeger,
-			shouldBeNil: false,
-			expectCount: 1, // Jaeger
-		},
-		{
-			name:        "unknown format",
-			format:      "unknown",
-			shouldBeNil: true,
-			expectCount: 0,
-		},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			propagators := createPropagatorByFormat(tt.format)
-
-			if tt.shouldBeNil {
-				assert.Nil(t, propagators)
-				return
-			}
-
-			require.NotNil(t, propagators)
-			assert.Len(t, propagators, tt.expectCount)
-		})
-	}
-}
-
 func TestConfigGetPropagationConfig(t *testing.T) {
 	tests := []struct {
 		name           string
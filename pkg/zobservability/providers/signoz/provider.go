@@ -5,8 +5,6 @@ import (
 	"fmt"
 	"net/http"
 
-	"go.opentelemetry.io/contrib/propagators/b3"
-	"go.opentelemetry.io/contrib/propagators/jaeger"
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/codes"
@@ -471,56 +469,7 @@ func createTracerProvider(cfg *Config) (*sdktrace.TracerProvider, trace.Tracer,
 
 // createPropagator creates a composite propagator based on the configuration
 func createPropagator(cfg *Config) propagation.TextMapPropagator {
-	propagationConfig := cfg.GetPropagationConfig()
-	formats := propagationConfig.Formats
-
-	// Default to W3C
-	if len(formats) == 0 {
-		return createW3CPropagator()
-	}
-
-	var propagators []propagation.TextMapPropagator
-	for _, format := range formats {
-		if prop := createPropagatorByFormat(format); prop != nil {
-			propagators = append(propagators, prop...)
-		}
-	}
-
-	// Fallback to W3C if no valid propagators were created
-	if len(propagators) == 0 {
-		return createW3CPropagator()
-	}
-
-	return propagation.NewCompositeTextMapPropagator(propagators...)
-}
-
-// createW3CPropagator creates the default W3C propagator
-func createW3CPropagator() propagation.TextMapPropagator {
-	return propagation.NewCompositeTextMapPropagator(
-		propagation.TraceContext{},
-		propagation.Baggage{},
-	)
-}
-
-// createPropagatorByFormat creates propagators for a specific format
-func createPropagatorByFormat(format string) []propagation.TextMapPropagator {
-	switch format {
-	case zobservability.PropagationW3C:
-		return []propagation.TextMapPropagator{
-			propagation.TraceContext{},
-			propagation.Baggage{},
-		}
-	case zobservability.PropagationB3:
-		return []propagation.TextMapPropagator{b3.New()}
-	case zobservability.PropagationB3Single:
-		return []propagation.TextMapPropagator{
-			b3.New(b3.WithInjectEncoding(b3.B3SingleHeader)),
-		}
-	case zobservability.PropagationJaeger:
-		return []propagation.TextMapPropagator{jaeger.Jaeger{}}
-	default:
-		return nil
-	}
+	return zobservability.NewPropagator(cfg.GetPropagationConfig())
 }
 
 // createTraceExporter creates an OTLP trace exporter using either gRPC or HTTP protocol
@@ -0,0 +1,137 @@
+// Package authtest provides signing keys and a local JWKS stand-in server to test JWT authentication
+// without a real identity provider.
+package authtest
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/zondax/golem/pkg/zrouter/auth"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+)
+
+const (
+	rsaKeyBits = 2048
+	hmacSize   = 32
+)
+
+// KeyPair is a signing key together with its public JWK.
+type KeyPair struct {
+	Kid        string
+	Alg        string
+	PrivateKey interface{}
+	JWK        auth.JSONWebKey
+}
+
+// NewKeyPair generates a key for HS256, RS256, ES256 or EdDSA.
+func NewKeyPair(alg, kid string) (*KeyPair, error) {
+	pair := &KeyPair{Kid: kid, Alg: alg}
+	jwk := auth.JSONWebKey{Kid: kid, Alg: alg, Use: "sig"}
+
+	switch alg {
+	case auth.AlgHS256:
+		secret := make([]byte, hmacSize)
+		if _, err := rand.Read(secret); err != nil {
+			return nil, err
+		}
+		pair.PrivateKey = secret
+		jwk.Kty = auth.KeyTypeOct
+		jwk.K = base64.RawURLEncoding.EncodeToString(secret)
+	case auth.AlgRS256:
+		key, err := rsa.GenerateKey(rand.Reader, rsaKeyBits)
+		if err != nil {
+			return nil, err
+		}
+		pair.PrivateKey = key
+		jwk.Kty = auth.KeyTypeRSA
+		jwk.N = base64.RawURLEncoding.EncodeToString(key.N.Bytes())
+		jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes())
+	case auth.AlgES256:
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+		point, err := key.PublicKey.Bytes()
+		if err != nil {
+			return nil, err
+		}
+		size := (len(point) - 1) / 2
+		pair.PrivateKey = key
+		jwk.Kty = auth.KeyTypeEC
+		jwk.Crv = "P-256"
+		jwk.X = base64.RawURLEncoding.EncodeToString(point[1 : 1+size])
+		jwk.Y = base64.RawURLEncoding.EncodeToString(point[1+size:])
+	case auth.AlgEdDSA:
+		public, private, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+		pair.PrivateKey = private
+		jwk.Kty = auth.KeyTypeOKP
+		jwk.Crv = "Ed25519"
+		jwk.X = base64.RawURLEncoding.EncodeToString(public)
+	default:
+		return nil, fmt.Errorf("unsupported algorithm %q", alg)
+	}
+
+	pair.JWK = jwk
+	return pair, nil
+}
+
+// Sign issues a token with the "kid" header set to the key ID.
+func (k *KeyPair) Sign(claims jwt.Claims) (string, error) {
+	token := jwt.NewWithClaims(jwt.GetSigningMethod(k.Alg), claims)
+	if k.Kid != "" {
+		token.Header["kid"] = k.Kid
+	}
+	return token.SignedString(k.PrivateKey)
+}
+
+// JWKSServer serves the public keys of a set of KeyPairs. Keys can be replaced to simulate rotation.
+type JWKSServer struct {
+	*httptest.Server
+	mu       sync.RWMutex
+	keys     []*KeyPair
+	requests atomic.Int64
+}
+
+func NewJWKSServer(keys ...*KeyPair) *JWKSServer {
+	s := &JWKSServer{keys: keys}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serveKeys))
+	return s
+}
+
+func (s *JWKSServer) SetKeys(keys ...*KeyPair) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.keys = keys
+}
+
+// Requests returns how many times the key set was fetched.
+func (s *JWKSServer) Requests() int64 {
+	return s.requests.Load()
+}
+
+func (s *JWKSServer) serveKeys(w http.ResponseWriter, _ *http.Request) {
+	s.requests.Add(1)
+
+	s.mu.RLock()
+	set := auth.JSONWebKeySet{Keys: make([]auth.JSONWebKey, 0, len(s.keys))}
+	for _, key := range s.keys {
+		set.Keys = append(set.Keys, key.JWK)
+	}
+	s.mu.RUnlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(set)
+}
@@ -0,0 +1,71 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/golang-jwt/jwt/v5"
+	"strings"
+)
+
+type claimsKey struct{}
+
+// ScopeList accepts both the OAuth2 space-delimited string form and the JSON array form.
+type ScopeList []string
+
+func (s *ScopeList) UnmarshalJSON(data []byte) error {
+	var scopes []string
+	if err := json.Unmarshal(data, &scopes); err == nil {
+		*s = scopes
+		return nil
+	}
+
+	var scope string
+	if err := json.Unmarshal(data, &scope); err != nil {
+		return err
+	}
+	*s = strings.Fields(scope)
+	return nil
+}
+
+// Claims are the verified claims of a JWT. Raw holds every claim, including the custom ones.
+type Claims struct {
+	jwt.RegisteredClaims
+	Scope ScopeList              `json:"scope,omitempty"`
+	Scp   ScopeList              `json:"scp,omitempty"`
+	Roles []string               `json:"roles,omitempty"`
+	Raw   map[string]interface{} `json:"-"`
+}
+
+func (c *Claims) Scopes() []string {
+	scopes := make([]string, 0, len(c.Scope)+len(c.Scp))
+	scopes = append(scopes, c.Scope...)
+	return append(scopes, c.Scp...)
+}
+
+// HasScopes reports whether every given scope was granted.
+func (c *Claims) HasScopes(scopes ...string) bool {
+	return containsAll(c.Scopes(), scopes)
+}
+
+// HasAnyRole reports whether at least one of the given roles was granted.
+func (c *Claims) HasAnyRole(roles ...string) bool {
+	return c.Principal().HasAnyRole(roles...)
+}
+
+func (c *Claims) Principal() *Principal {
+	return &Principal{
+		ID:     c.Subject,
+		Method: MethodJWT,
+		Scopes: c.Scopes(),
+		Roles:  c.Roles,
+	}
+}
+
+func ContextWithClaims(ctx context.Context, claims *Claims) context.Context {
+	return context.WithValue(ctx, claimsKey{}, claims)
+}
+
+func ClaimsFromContext(ctx context.Context) (*Claims, bool) {
+	claims, ok := ctx.Value(claimsKey{}).(*Claims)
+	return claims, ok && claims != nil
+}
@@ -0,0 +1,83 @@
+package auth
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	APIKeyHeader             = "X-API-Key"
+	APIKeyIDHeader           = "X-API-Key-ID"
+	SignatureHeader          = "X-Signature"
+	SignatureTimestampHeader = "X-Signature-Timestamp"
+	SignatureNonceHeader     = "X-Signature-Nonce"
+
+	nonceSize = 16
+)
+
+// HashAPIKey returns the SHA-256 hex digest under which API keys are stored.
+func HashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// StringToSign builds the canonical form of a request covered by the HMAC signature:
+// method, request URI, unix timestamp, nonce and the SHA-256 hex digest of the body, joined by newlines.
+func StringToSign(method, requestURI, timestamp, nonce string, body []byte) string {
+	bodyHash := sha256.Sum256(body)
+	return strings.Join([]string{
+		strings.ToUpper(method),
+		requestURI,
+		timestamp,
+		nonce,
+		hex.EncodeToString(bodyHash[:]),
+	}, "\n")
+}
+
+func ComputeSignature(secret, stringToSign string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(stringToSign))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifySignature compares signatures in constant time.
+func VerifySignature(secret, stringToSign, signature string) bool {
+	expected := ComputeSignature(secret, stringToSign)
+	return hmac.Equal([]byte(expected), []byte(strings.ToLower(signature)))
+}
+
+// SignRequest sets the HMAC signature headers on req using a fresh timestamp and nonce.
+// The body is read and replaced so the request can still be sent.
+func SignRequest(req *http.Request, keyID, secret string) error {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		if body, err = io.ReadAll(req.Body); err != nil {
+			return err
+		}
+		_ = req.Body.Close()
+		req.Body = io.NopCloser(bytes.NewReader(body))
+	}
+
+	nonce := make([]byte, nonceSize)
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	nonceValue := hex.EncodeToString(nonce)
+
+	req.Header.Set(APIKeyIDHeader, keyID)
+	req.Header.Set(SignatureTimestampHeader, timestamp)
+	req.Header.Set(SignatureNonceHeader, nonceValue)
+	req.Header.Set(SignatureHeader, ComputeSignature(secret, StringToSign(req.Method, req.URL.RequestURI(), timestamp, nonceValue, body)))
+	return nil
+}
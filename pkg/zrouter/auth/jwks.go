@@ -0,0 +1,283 @@
+package auth
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/zondax/golem/pkg/logger"
+	"golang.org/x/sync/singleflight"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	defaultJWKSRefreshInterval    = time.Hour
+	defaultJWKSMinRefreshInterval = time.Minute
+	defaultJWKSHTTPTimeout        = 10 * time.Second
+
+	KeyTypeRSA = "RSA"
+	KeyTypeEC  = "EC"
+	KeyTypeOKP = "OKP"
+	KeyTypeOct = "oct"
+)
+
+var ErrKeyNotFound = errors.New("signing key not found")
+
+// JSONWebKey is the subset of RFC 7517 needed to verify HS256, RS256, ES256 and EdDSA signatures.
+type JSONWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid,omitempty"`
+	Alg string `json:"alg,omitempty"`
+	Use string `json:"use,omitempty"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+	K   string `json:"k,omitempty"`
+}
+
+type JSONWebKeySet struct {
+	Keys []JSONWebKey `json:"keys"`
+}
+
+// Key returns the verification key: *rsa.PublicKey, *ecdsa.PublicKey, ed25519.PublicKey or []byte.
+func (k JSONWebKey) Key() (interface{}, error) {
+	switch k.Kty {
+	case KeyTypeRSA:
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return nil, fmt.Errorf("invalid RSA modulus: %w", err)
+		}
+		e, err := decodeBigInt(k.E)
+		if err != nil {
+			return nil, fmt.Errorf("invalid RSA exponent: %w", err)
+		}
+		if !e.IsInt64() || e.Int64() > int64(^uint32(0)>>1) {
+			return nil, errors.New("invalid RSA exponent")
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case KeyTypeEC:
+		curve, err := ellipticCurve(k.Crv)
+		if err != nil {
+			return nil, err
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			return nil, fmt.Errorf("invalid EC x coordinate: %w", err)
+		}
+		y, err := base64.RawURLEncoding.DecodeString(k.Y)
+		if err != nil {
+			return nil, fmt.Errorf("invalid EC y coordinate: %w", err)
+		}
+		point := append([]byte{4}, append(x, y...)...)
+		return ecdsa.ParseUncompressedPublicKey(curve, point)
+	case KeyTypeOKP:
+		if k.Crv != "Ed25519" {
+			return nil, fmt.Errorf("unsupported OKP curve %q", k.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil || len(x) != ed25519.PublicKeySize {
+			return nil, errors.New("invalid Ed25519 public key")
+		}
+		return ed25519.PublicKey(x), nil
+	case KeyTypeOct:
+		secret, err := base64.RawURLEncoding.DecodeString(k.K)
+		if err != nil {
+			return nil, fmt.Errorf("invalid symmetric key: %w", err)
+		}
+		return secret, nil
+	default:
+		return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+	}
+}
+
+type JWKSOptions struct {
+	// RefreshInterval is how long a fetched key set is considered fresh. Defaults to 1h.
+	RefreshInterval time.Duration
+	// MinRefreshInterval bounds the refetches triggered by tokens signed with an unknown key ID,
+	// which is how key rotation is picked up before RefreshInterval. Defaults to 1m.
+	MinRefreshInterval time.Duration
+	// FetchTimeout bounds a refresh, which is shared by the concurrent callers and outlives any of
+	// their contexts. Defaults to 10s.
+	FetchTimeout time.Duration
+	HTTPClient   *http.Client
+}
+
+func (o *JWKSOptions) setDefaultValues() {
+	if o.RefreshInterval == 0 {
+		o.RefreshInterval = defaultJWKSRefreshInterval
+	}
+
+	if o.MinRefreshInterval == 0 {
+		o.MinRefreshInterval = defaultJWKSMinRefreshInterval
+	}
+
+	if o.FetchTimeout == 0 {
+		o.FetchTimeout = defaultJWKSHTTPTimeout
+	}
+
+	if o.HTTPClient == nil {
+		o.HTTPClient = &http.Client{Timeout: defaultJWKSHTTPTimeout}
+	}
+}
+
+// JWKSProvider fetches and caches a remote JSON Web Key Set. Keys are fetched lazily and concurrent
+// refreshes are collapsed into a single request.
+type JWKSProvider struct {
+	url       string
+	options   JWKSOptions
+	mu        sync.RWMutex
+	keys      map[string]interface{}
+	fetchedAt time.Time
+	group     singleflight.Group
+}
+
+func NewJWKSProvider(url string, options JWKSOptions) *JWKSProvider {
+	options.setDefaultValues()
+	return &JWKSProvider{
+		url:     url,
+		options: options,
+		keys:    make(map[string]interface{}),
+	}
+}
+
+// Key returns the key identified by kid. An empty kid is only accepted when the set holds a single key.
+func (p *JWKSProvider) Key(ctx context.Context, kid string) (interface{}, error) {
+	p.mu.RLock()
+	age := time.Since(p.fetchedAt)
+	key, found := p.lookup(kid)
+	p.mu.RUnlock()
+
+	switch {
+	case found && age < p.options.RefreshInterval:
+		return key, nil
+	case found:
+		// Stale but usable: keep serving the cached key if the provider is unreachable
+		if err := p.Refresh(ctx); err != nil {
+			logger.GetLoggerFromContext(ctx).Errorf("Error refreshing JWKS from %s: %v", p.url, err)
+			return key, nil
+		}
+	case age >= p.options.MinRefreshInterval:
+		if err := p.Refresh(ctx); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, ErrKeyNotFound
+	}
+
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if key, found = p.lookup(kid); !found {
+		return nil, ErrKeyNotFound
+	}
+	return key, nil
+}
+
+// Refresh fetches the key set. Concurrent calls share a single fetch, which is not cancelled with the
+// context of the caller that started it; a cancelled caller stops waiting and the others keep theirs.
+func (p *JWKSProvider) Refresh(ctx context.Context) error {
+	result := p.group.DoChan(p.url, func() (interface{}, error) {
+		fetchCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), p.options.FetchTimeout)
+		defer cancel()
+
+		keys, err := p.fetch(fetchCtx)
+		if err != nil {
+			return nil, err
+		}
+
+		p.mu.Lock()
+		p.keys = keys
+		p.fetchedAt = time.Now()
+		p.mu.Unlock()
+		return nil, nil
+	})
+
+	select {
+	case res := <-result:
+		return res.Err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (p *JWKSProvider) fetch(ctx context.Context) (map[string]interface{}, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := p.options.HTTPClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching JWKS: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("error fetching JWKS: unexpected status %d", resp.StatusCode)
+	}
+
+	var set JSONWebKeySet
+	if err = json.NewDecoder(resp.Body).Decode(&set); err != nil {
+		return nil, fmt.Errorf("error decoding JWKS: %w", err)
+	}
+
+	keys := make(map[string]interface{}, len(set.Keys))
+	for _, jwk := range set.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+
+		key, err := jwk.Key()
+		if err != nil {
+			logger.GetLoggerFromContext(ctx).Warnf("Skipping JWKS key %q: %v", jwk.Kid, err)
+			continue
+		}
+		keys[jwk.Kid] = key
+	}
+
+	return keys, nil
+}
+
+func (p *JWKSProvider) lookup(kid string) (interface{}, bool) {
+	if kid == "" && len(p.keys) == 1 {
+		for _, key := range p.keys {
+			return key, true
+		}
+	}
+
+	key, ok := p.keys[kid]
+	return key, ok
+}
+
+func decodeBigInt(value string) (*big.Int, error) {
+	decoded, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, err
+	}
+	if len(decoded) == 0 {
+		return nil, errors.New("empty value")
+	}
+	return new(big.Int).SetBytes(decoded), nil
+}
+
+func ellipticCurve(crv string) (elliptic.Curve, error) {
+	switch crv {
+	case "P-256":
+		return elliptic.P256(), nil
+	case "P-384":
+		return elliptic.P384(), nil
+	case "P-521":
+		return elliptic.P521(), nil
+	default:
+		return nil, fmt.Errorf("unsupported EC curve %q", crv)
+	}
+}
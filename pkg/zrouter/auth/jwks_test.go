@@ -0,0 +1,111 @@
+package auth_test
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zondax/golem/pkg/zrouter/auth"
+	"github.com/zondax/golem/pkg/zrouter/auth/authtest"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestJWKSProviderCachesKeys(t *testing.T) {
+	pair := newKeyPair(t, auth.AlgES256, "es")
+	server := authtest.NewJWKSServer(pair)
+	defer server.Close()
+
+	provider := auth.NewJWKSProvider(server.URL, auth.JWKSOptions{})
+	for i := 0; i < 3; i++ {
+		_, err := provider.Key(context.Background(), "es")
+		require.NoError(t, err)
+	}
+
+	assert.Equal(t, int64(1), server.Requests())
+}
+
+func TestJWKSProviderPicksUpRotatedKeys(t *testing.T) {
+	oldKey := newKeyPair(t, auth.AlgRS256, "old")
+	newKey := newKeyPair(t, auth.AlgRS256, "new")
+	server := authtest.NewJWKSServer(oldKey)
+	defer server.Close()
+
+	provider := auth.NewJWKSProvider(server.URL, auth.JWKSOptions{MinRefreshInterval: 10 * time.Millisecond})
+	_, err := provider.Key(context.Background(), "old")
+	require.NoError(t, err)
+
+	server.SetKeys(oldKey, newKey)
+
+	// Unknown kids do not trigger a refetch before MinRefreshInterval
+	_, err = provider.Key(context.Background(), "new")
+	assert.ErrorIs(t, err, auth.ErrKeyNotFound)
+
+	time.Sleep(20 * time.Millisecond)
+	_, err = provider.Key(context.Background(), "new")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), server.Requests())
+}
+
+func TestJWKSProviderServesStaleKeysWhenUnreachable(t *testing.T) {
+	pair := newKeyPair(t, auth.AlgEdDSA, "ed")
+	server := authtest.NewJWKSServer(pair)
+
+	provider := auth.NewJWKSProvider(server.URL, auth.JWKSOptions{RefreshInterval: 10 * time.Millisecond})
+	_, err := provider.Key(context.Background(), "ed")
+	require.NoError(t, err)
+
+	server.Close()
+	time.Sleep(20 * time.Millisecond)
+
+	_, err = provider.Key(context.Background(), "ed")
+	assert.NoError(t, err)
+}
+
+func TestJWKSProviderRefreshOutlivesCancelledCaller(t *testing.T) {
+	pair := newKeyPair(t, auth.AlgES256, "es")
+	keys := authtest.NewJWKSServer(pair)
+	defer keys.Close()
+
+	entered := make(chan struct{})
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(entered)
+		<-release
+		keys.Config.Handler.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+
+	provider := auth.NewJWKSProvider(server.URL, auth.JWKSOptions{})
+	ctx, cancel := context.WithCancel(context.Background())
+	first := make(chan error)
+	go func() { first <- provider.Refresh(ctx) }()
+
+	<-entered
+	cancel()
+	assert.ErrorIs(t, <-first, context.Canceled)
+
+	second := make(chan error)
+	go func() {
+		_, err := provider.Key(context.Background(), "es")
+		second <- err
+	}()
+
+	// Let the second caller join the fetch in flight before it completes
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	assert.NoError(t, <-second)
+	assert.Equal(t, int64(1), keys.Requests())
+}
+
+func TestJSONWebKeyRejectsUnsupportedKeys(t *testing.T) {
+	_, err := auth.JSONWebKey{Kty: "unknown"}.Key()
+	assert.Error(t, err)
+
+	_, err = auth.JSONWebKey{Kty: auth.KeyTypeEC, Crv: "P-192"}.Key()
+	assert.Error(t, err)
+
+	_, err = auth.JSONWebKey{Kty: auth.KeyTypeOKP, Crv: "Ed25519", X: "c2hvcnQ"}.Key()
+	assert.Error(t, err)
+}
@@ -0,0 +1,61 @@
+package auth
+
+import "context"
+
+const (
+	MethodJWT    = "jwt"
+	MethodAPIKey = "api_key"
+	MethodHMAC   = "hmac"
+)
+
+type principalKey struct{}
+
+// Principal is the authenticated caller, whatever the authentication method was.
+type Principal struct {
+	// ID is the token subject or the API key owner.
+	ID string
+	// KeyID identifies the API key used, empty for JWT authentication.
+	KeyID    string
+	Method   string
+	Scopes   []string
+	Roles    []string
+	RateTier string
+}
+
+// HasScopes reports whether every given scope was granted.
+func (p *Principal) HasScopes(scopes ...string) bool {
+	return containsAll(p.Scopes, scopes)
+}
+
+// HasAnyRole reports whether at least one of the given roles was granted.
+func (p *Principal) HasAnyRole(roles ...string) bool {
+	for _, role := range roles {
+		if containsAll(p.Roles, []string{role}) {
+			return true
+		}
+	}
+	return false
+}
+
+func ContextWithPrincipal(ctx context.Context, principal *Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, principal)
+}
+
+func PrincipalFromContext(ctx context.Context) (*Principal, bool) {
+	principal, ok := ctx.Value(principalKey{}).(*Principal)
+	return principal, ok && principal != nil
+}
+
+func containsAll(granted, required []string) bool {
+	set := make(map[string]struct{}, len(granted))
+	for _, value := range granted {
+		set[value] = struct{}{}
+	}
+
+	for _, value := range required {
+		if _, ok := set[value]; !ok {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,119 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/golang-jwt/jwt/v5"
+	"time"
+)
+
+const (
+	AlgHS256 = "HS256"
+	AlgRS256 = "RS256"
+	AlgES256 = "ES256"
+	AlgEdDSA = "EdDSA"
+)
+
+var supportedAlgorithms = []string{AlgHS256, AlgRS256, AlgES256, AlgEdDSA}
+
+type VerifierConfig struct {
+	// Algorithms restricts the accepted "alg" header values. Defaults to HS256, RS256, ES256 and EdDSA.
+	Algorithms []string
+	// HMACSecret verifies HS256 tokens.
+	HMACSecret []byte
+	// PublicKey verifies asymmetric tokens when no JWKS is configured or the token has no "kid".
+	PublicKey interface{}
+	// JWKS resolves keys by the token "kid" header.
+	JWKS      *JWKSProvider
+	Issuer    string
+	Audience  []string
+	ClockSkew time.Duration
+	// RequireExpiration rejects tokens without an "exp" claim.
+	RequireExpiration bool
+}
+
+// Verifier checks JWT signatures and the registered claims (exp, nbf, iss, aud).
+type Verifier struct {
+	config VerifierConfig
+	parser *jwt.Parser
+}
+
+func NewVerifier(config VerifierConfig) (*Verifier, error) {
+	if len(config.Algorithms) == 0 {
+		config.Algorithms = supportedAlgorithms
+	}
+
+	for _, alg := range config.Algorithms {
+		if !isSupportedAlgorithm(alg) {
+			return nil, fmt.Errorf("unsupported JWT algorithm %q", alg)
+		}
+	}
+
+	if config.HMACSecret == nil && config.PublicKey == nil && config.JWKS == nil {
+		return nil, errors.New("a HMAC secret, a public key or a JWKS provider is required")
+	}
+
+	options := []jwt.ParserOption{
+		jwt.WithValidMethods(config.Algorithms),
+		jwt.WithLeeway(config.ClockSkew),
+	}
+	if config.Issuer != "" {
+		options = append(options, jwt.WithIssuer(config.Issuer))
+	}
+	if len(config.Audience) > 0 {
+		options = append(options, jwt.WithAudience(config.Audience...))
+	}
+	if config.RequireExpiration {
+		options = append(options, jwt.WithExpirationRequired())
+	}
+
+	return &Verifier{
+		config: config,
+		parser: jwt.NewParser(options...),
+	}, nil
+}
+
+func (v *Verifier) Verify(ctx context.Context, token string) (*Claims, error) {
+	claims := &Claims{}
+	if _, err := v.parser.ParseWithClaims(token, claims, v.keyFunc(ctx)); err != nil {
+		return nil, err
+	}
+
+	raw, err := DecodeJWT(token)
+	if err != nil {
+		return nil, err
+	}
+	claims.Raw = raw
+
+	return claims, nil
+}
+
+func (v *Verifier) keyFunc(ctx context.Context) jwt.Keyfunc {
+	return func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+
+		if token.Method.Alg() == AlgHS256 && v.config.HMACSecret != nil && (kid == "" || v.config.JWKS == nil) {
+			return v.config.HMACSecret, nil
+		}
+
+		if v.config.JWKS != nil && (kid != "" || v.config.PublicKey == nil) {
+			return v.config.JWKS.Key(ctx, kid)
+		}
+
+		if v.config.PublicKey != nil {
+			return v.config.PublicKey, nil
+		}
+
+		return nil, ErrKeyNotFound
+	}
+}
+
+func isSupportedAlgorithm(alg string) bool {
+	for _, supported := range supportedAlgorithms {
+		if alg == supported {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,155 @@
+package auth_test
+
+import (
+	"context"
+	"crypto/rsa"
+	"crypto/x509"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zondax/golem/pkg/zrouter/auth"
+	"github.com/zondax/golem/pkg/zrouter/auth/authtest"
+	"testing"
+	"time"
+)
+
+func validClaims() jwt.MapClaims {
+	return jwt.MapClaims{
+		"sub":   "user-1",
+		"jti":   "token-1",
+		"iss":   "https://issuer.example.com",
+		"aud":   "api",
+		"exp":   time.Now().Add(time.Hour).Unix(),
+		"nbf":   time.Now().Add(-time.Minute).Unix(),
+		"scope": "read write",
+		"roles": []string{"admin"},
+		"org":   "zondax",
+	}
+}
+
+func newKeyPair(t *testing.T, alg, kid string) *authtest.KeyPair {
+	pair, err := authtest.NewKeyPair(alg, kid)
+	require.NoError(t, err)
+	return pair
+}
+
+func TestVerifierWithJWKS(t *testing.T) {
+	for _, alg := range []string{auth.AlgHS256, auth.AlgRS256, auth.AlgES256, auth.AlgEdDSA} {
+		t.Run(alg, func(t *testing.T) {
+			pair := newKeyPair(t, alg, "key-"+alg)
+			server := authtest.NewJWKSServer(pair)
+			defer server.Close()
+
+			verifier, err := auth.NewVerifier(auth.VerifierConfig{
+				JWKS:     auth.NewJWKSProvider(server.URL, auth.JWKSOptions{}),
+				Issuer:   "https://issuer.example.com",
+				Audience: []string{"api"},
+			})
+			require.NoError(t, err)
+
+			token, err := pair.Sign(validClaims())
+			require.NoError(t, err)
+
+			claims, err := verifier.Verify(context.Background(), token)
+			require.NoError(t, err)
+			assert.Equal(t, "user-1", claims.Subject)
+			assert.Equal(t, "token-1", claims.ID)
+			assert.True(t, claims.HasScopes("read", "write"))
+			assert.False(t, claims.HasScopes("delete"))
+			assert.True(t, claims.HasAnyRole("viewer", "admin"))
+			assert.Equal(t, "zondax", claims.Raw["org"])
+		})
+	}
+}
+
+func TestVerifierRejectsInvalidTokens(t *testing.T) {
+	pair := newKeyPair(t, auth.AlgRS256, "current")
+	other := newKeyPair(t, auth.AlgRS256, "current")
+	server := authtest.NewJWKSServer(pair)
+	defer server.Close()
+
+	verifier, err := auth.NewVerifier(auth.VerifierConfig{
+		JWKS:      auth.NewJWKSProvider(server.URL, auth.JWKSOptions{}),
+		Issuer:    "https://issuer.example.com",
+		Audience:  []string{"api"},
+		ClockSkew: 30 * time.Second,
+	})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name   string
+		signer *authtest.KeyPair
+		modify func(claims jwt.MapClaims)
+	}{
+		{"Wrong signature", other, func(jwt.MapClaims) {}},
+		{"Expired", pair, func(c jwt.MapClaims) { c["exp"] = time.Now().Add(-time.Minute).Unix() }},
+		{"Not yet valid", pair, func(c jwt.MapClaims) { c["nbf"] = time.Now().Add(time.Minute).Unix() }},
+		{"Wrong issuer", pair, func(c jwt.MapClaims) { c["iss"] = "https://evil.example.com" }},
+		{"Wrong audience", pair, func(c jwt.MapClaims) { c["aud"] = "other" }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims := validClaims()
+			tt.modify(claims)
+			token, err := tt.signer.Sign(claims)
+			require.NoError(t, err)
+
+			_, err = verifier.Verify(context.Background(), token)
+			assert.Error(t, err)
+		})
+	}
+
+	t.Run("Within clock skew", func(t *testing.T) {
+		claims := validClaims()
+		claims["exp"] = time.Now().Add(-10 * time.Second).Unix()
+		token, err := pair.Sign(claims)
+		require.NoError(t, err)
+
+		_, err = verifier.Verify(context.Background(), token)
+		assert.NoError(t, err)
+	})
+}
+
+func TestVerifierRejectsAlgorithmConfusion(t *testing.T) {
+	pair := newKeyPair(t, auth.AlgRS256, "")
+	publicKey := &pair.PrivateKey.(*rsa.PrivateKey).PublicKey
+
+	verifier, err := auth.NewVerifier(auth.VerifierConfig{PublicKey: publicKey})
+	require.NoError(t, err)
+
+	// HS256 token using the public key as the HMAC secret
+	secret, err := x509.MarshalPKIXPublicKey(publicKey)
+	require.NoError(t, err)
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, validClaims()).SignedString(secret)
+	require.NoError(t, err)
+
+	_, err = verifier.Verify(context.Background(), token)
+	assert.Error(t, err)
+}
+
+func TestVerifierWithStaticKeys(t *testing.T) {
+	secret := []byte("super-secret")
+	verifier, err := auth.NewVerifier(auth.VerifierConfig{HMACSecret: secret, RequireExpiration: true})
+	require.NoError(t, err)
+
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, validClaims()).SignedString(secret)
+	require.NoError(t, err)
+	_, err = verifier.Verify(context.Background(), token)
+	assert.NoError(t, err)
+
+	claims := validClaims()
+	delete(claims, "exp")
+	token, err = jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(secret)
+	require.NoError(t, err)
+	_, err = verifier.Verify(context.Background(), token)
+	assert.Error(t, err)
+}
+
+func TestNewVerifierValidation(t *testing.T) {
+	_, err := auth.NewVerifier(auth.VerifierConfig{})
+	assert.Error(t, err)
+
+	_, err = auth.NewVerifier(auth.VerifierConfig{HMACSecret: []byte("s"), Algorithms: []string{"none"}})
+	assert.Error(t, err)
+}
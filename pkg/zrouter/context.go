@@ -3,10 +3,27 @@ package zrouter
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"github.com/go-chi/chi/v5"
+	"github.com/zondax/golem/pkg/zrouter/auth"
+	"github.com/zondax/golem/pkg/zrouter/domain"
+	"github.com/zondax/golem/pkg/zrouter/zmiddlewares"
+	"mime/multipart"
 	"net/http"
 )
 
+const (
+	locationHeader = "Location"
+
+	// defaultMultipartMemory is the part of a multipart form kept in memory, as in net/http. Larger files
+	// are stored in temporary files, removed by the server at the end of the request.
+	defaultMultipartMemory = 32 << 20
+)
+
+type contextValueKey string
+
+// Context is what handlers get from the router. Methods are added to it over time (see CHANGELOG.md),
+// so tests should use MockContext rather than their own implementation.
 type Context interface {
 	Request() *http.Request
 	BindJSON(obj interface{}) error
@@ -15,11 +32,46 @@ type Context interface {
 	Query(key string) string
 	DefaultQuery(key, defaultValue string) string
 	Context() context.Context
+	Principal() (*auth.Principal, bool)
+	ClientIP() string
+	Set(key string, value interface{})
+	Get(key string) (interface{}, bool)
+	SetStatus(status int)
+	Cookie(name string) (string, error)
+	SetCookie(cookie *http.Cookie)
+	FormValue(key string) string
+	FormFile(key string) (*multipart.FileHeader, error)
+	MultipartForm() (*multipart.Form, error)
+	Redirect(status int, location string) (domain.ServiceResponse, error)
 }
 
 type chiContextAdapter struct {
-	ctx http.ResponseWriter
-	req *http.Request
+	ctx    http.ResponseWriter
+	req    *http.Request
+	values map[string]interface{}
+	status int
+	// cleanups run once the response is written.
+	cleanups []func()
+}
+
+// WithContextValue stores a value for the handlers, which read it with Context.Get or ContextValue.
+// Middlewares use it to pass request-scoped data such as the tenant or feature flags.
+func WithContextValue(ctx context.Context, key string, value interface{}) context.Context {
+	return context.WithValue(ctx, contextValueKey(key), value)
+}
+
+// ContextValue returns the value stored under key with Context.Set or WithContextValue, if it is a T.
+func ContextValue[T any](ctx Context, key string) (T, bool) {
+	var zero T
+	value, ok := ctx.Get(key)
+	if !ok {
+		return zero, false
+	}
+	typed, ok := value.(T)
+	if !ok {
+		return zero, false
+	}
+	return typed, true
 }
 
 func (c *chiContextAdapter) Request() *http.Request {
@@ -54,3 +106,95 @@ func (c *chiContextAdapter) DefaultQuery(key, defaultValue string) string {
 func (c *chiContextAdapter) Context() context.Context {
 	return c.req.Context()
 }
+
+// Principal returns the caller authenticated by JWTAuth or APIKeyAuth.
+func (c *chiContextAdapter) Principal() (*auth.Principal, bool) {
+	return auth.PrincipalFromContext(c.req.Context())
+}
+
+// ClientIP returns the client address resolved from trusted proxy headers by the ClientIP middleware,
+// or the peer address when it is not installed.
+func (c *chiContextAdapter) ClientIP() string {
+	return zmiddlewares.ClientIPFromRequest(c.req)
+}
+
+// Set stores a value for the rest of the handler. It takes precedence over WithContextValue.
+func (c *chiContextAdapter) Set(key string, value interface{}) {
+	if c.values == nil {
+		c.values = make(map[string]interface{})
+	}
+	c.values[key] = value
+}
+
+func (c *chiContextAdapter) Get(key string) (interface{}, bool) {
+	if value, ok := c.values[key]; ok {
+		return value, true
+	}
+	value := c.req.Context().Value(contextValueKey(key))
+	return value, value != nil
+}
+
+// SetStatus overrides the status of the response returned by the handler. Handlers returning a nil
+// response write only the status and the headers.
+func (c *chiContextAdapter) SetStatus(status int) {
+	c.status = status
+}
+
+func (c *chiContextAdapter) Cookie(name string) (string, error) {
+	cookie, err := c.req.Cookie(name)
+	if err != nil {
+		return "", err
+	}
+	return cookie.Value, nil
+}
+
+func (c *chiContextAdapter) SetCookie(cookie *http.Cookie) {
+	http.SetCookie(c.ctx, cookie)
+}
+
+// FormValue returns the first value of a query, urlencoded or multipart form field.
+func (c *chiContextAdapter) FormValue(key string) string {
+	return c.req.FormValue(key)
+}
+
+// FormFile returns the first file of a multipart form field, or http.ErrMissingFile.
+func (c *chiContextAdapter) FormFile(key string) (*multipart.FileHeader, error) {
+	form, err := c.MultipartForm()
+	if err != nil {
+		return nil, err
+	}
+	if files := form.File[key]; len(files) > 0 {
+		return files[0], nil
+	}
+	return nil, http.ErrMissingFile
+}
+
+func (c *chiContextAdapter) MultipartForm() (*multipart.Form, error) {
+	if err := c.req.ParseMultipartForm(defaultMultipartMemory); err != nil {
+		return nil, err
+	}
+	return c.req.MultipartForm, nil
+}
+
+// Redirect sends the client to location with a 3xx status. Handlers return its result:
+//
+//	return ctx.Redirect(http.StatusFound, "/login")
+func (c *chiContextAdapter) Redirect(status int, location string) (domain.ServiceResponse, error) {
+	if status < http.StatusMultipleChoices || status > http.StatusPermanentRedirect {
+		return nil, fmt.Errorf("invalid redirect status %d", status)
+	}
+
+	c.Header(locationHeader, location)
+	c.SetStatus(status)
+	return nil, nil
+}
+
+func (c *chiContextAdapter) addCleanup(cleanup func()) {
+	c.cleanups = append(c.cleanups, cleanup)
+}
+
+func (c *chiContextAdapter) runCleanups() {
+	for i := len(c.cleanups) - 1; i >= 0; i-- {
+		c.cleanups[i]()
+	}
+}
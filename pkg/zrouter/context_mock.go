@@ -3,6 +3,9 @@ package zrouter
 import (
 	"context"
 	"github.com/stretchr/testify/mock"
+	"github.com/zondax/golem/pkg/zrouter/auth"
+	"github.com/zondax/golem/pkg/zrouter/domain"
+	"mime/multipart"
 	"net/http"
 )
 
@@ -43,3 +46,59 @@ func (m *MockContext) Context() context.Context {
 	args := m.Called()
 	return args.Get(0).(context.Context)
 }
+
+func (m *MockContext) Principal() (*auth.Principal, bool) {
+	args := m.Called()
+	principal, _ := args.Get(0).(*auth.Principal)
+	return principal, args.Bool(1)
+}
+
+func (m *MockContext) ClientIP() string {
+	args := m.Called()
+	return args.String(0)
+}
+
+func (m *MockContext) Set(key string, value interface{}) {
+	m.Called(key, value)
+}
+
+func (m *MockContext) Get(key string) (interface{}, bool) {
+	args := m.Called(key)
+	return args.Get(0), args.Bool(1)
+}
+
+func (m *MockContext) SetStatus(status int) {
+	m.Called(status)
+}
+
+func (m *MockContext) Cookie(name string) (string, error) {
+	args := m.Called(name)
+	return args.String(0), args.Error(1)
+}
+
+func (m *MockContext) SetCookie(cookie *http.Cookie) {
+	m.Called(cookie)
+}
+
+func (m *MockContext) FormValue(key string) string {
+	args := m.Called(key)
+	return args.String(0)
+}
+
+func (m *MockContext) FormFile(key string) (*multipart.FileHeader, error) {
+	args := m.Called(key)
+	file, _ := args.Get(0).(*multipart.FileHeader)
+	return file, args.Error(1)
+}
+
+func (m *MockContext) MultipartForm() (*multipart.Form, error) {
+	args := m.Called()
+	form, _ := args.Get(0).(*multipart.Form)
+	return form, args.Error(1)
+}
+
+func (m *MockContext) Redirect(status int, location string) (domain.ServiceResponse, error) {
+	args := m.Called(status, location)
+	response, _ := args.Get(0).(domain.ServiceResponse)
+	return response, args.Error(1)
+}
@@ -6,6 +6,11 @@ import (
 	"github.com/go-chi/chi/v5"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/suite"
+	"github.com/zondax/golem/pkg/zrouter/auth"
+	"github.com/zondax/golem/pkg/zrouter/domain"
+	"github.com/zondax/golem/pkg/zrouter/zmiddlewares"
+	"io"
+	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -51,6 +56,118 @@ func (suite *ChiContextAdapterSuite) TestChiContextAdapter() {
 	assert.Equal(suite.T(), "CustomValue", rec.Header().Get("Custom-Header"))
 }
 
+func (suite *ChiContextAdapterSuite) TestPrincipal() {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	adapter := &chiContextAdapter{req: req}
+
+	_, ok := adapter.Principal()
+	suite.False(ok)
+
+	principal := &auth.Principal{ID: "acme", KeyID: "key-1", Method: auth.MethodAPIKey}
+	adapter.req = req.WithContext(auth.ContextWithPrincipal(req.Context(), principal))
+
+	got, ok := adapter.Principal()
+	suite.True(ok)
+	suite.Equal(principal, got)
+}
+
+func (suite *ChiContextAdapterSuite) TestClientIP() {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(zmiddlewares.XForwardedForHeader, "203.0.113.7")
+	adapter := &chiContextAdapter{req: req}
+	suite.Equal("192.0.2.1", adapter.ClientIP())
+
+	adapter.req = req.WithContext(zmiddlewares.ContextWithClientInfo(req.Context(), &zmiddlewares.ClientInfo{IP: "203.0.113.7"}))
+	suite.Equal("203.0.113.7", adapter.ClientIP())
+}
+
+func (suite *ChiContextAdapterSuite) TestValues() {
+	type tenant struct{ ID string }
+
+	handler := getChiHandler(func(ctx Context) (domain.ServiceResponse, error) {
+		current, ok := ContextValue[*tenant](ctx, "tenant")
+		suite.True(ok)
+		suite.Equal("acme", current.ID)
+
+		_, ok = ContextValue[string](ctx, "tenant")
+		suite.False(ok)
+		_, ok = ctx.Get("missing")
+		suite.False(ok)
+
+		ctx.Set("flags", []string{"beta"})
+		flags, ok := ContextValue[[]string](ctx, "flags")
+		suite.True(ok)
+		suite.Equal([]string{"beta"}, flags)
+
+		ctx.SetStatus(http.StatusAccepted)
+		return domain.NewServiceResponse(http.StatusOK, "queued"), nil
+	}, responseSettings{})
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req = req.WithContext(WithContextValue(req.Context(), "tenant", &tenant{ID: "acme"}))
+	rec := httptest.NewRecorder()
+	handler(rec, req)
+	suite.Equal(http.StatusAccepted, rec.Code)
+	suite.Equal(`"queued"`, rec.Body.String())
+}
+
+func (suite *ChiContextAdapterSuite) TestCookiesAndRedirect() {
+	handler := getChiHandler(func(ctx Context) (domain.ServiceResponse, error) {
+		session, err := ctx.Cookie("session")
+		suite.NoError(err)
+		suite.Equal("abc", session)
+
+		_, err = ctx.Cookie("missing")
+		suite.ErrorIs(err, http.ErrNoCookie)
+
+		ctx.SetCookie(&http.Cookie{Name: "session", Value: "def", HttpOnly: true})
+		return ctx.Redirect(http.StatusFound, "/login")
+	}, responseSettings{})
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.AddCookie(&http.Cookie{Name: "session", Value: "abc"})
+	rec := httptest.NewRecorder()
+	handler(rec, req)
+	suite.Equal(http.StatusFound, rec.Code)
+	suite.Equal("/login", rec.Header().Get(locationHeader))
+	suite.Equal("session=def; HttpOnly", rec.Header().Get("Set-Cookie"))
+	suite.Empty(rec.Body.String())
+
+	_, err := (&chiContextAdapter{ctx: httptest.NewRecorder(), req: req}).Redirect(http.StatusOK, "/login")
+	suite.Error(err)
+}
+
+func (suite *ChiContextAdapterSuite) TestMultipartForm() {
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	suite.Require().NoError(writer.WriteField("title", "report"))
+	part, err := writer.CreateFormFile("file", "report.txt")
+	suite.Require().NoError(err)
+	_, err = part.Write([]byte("content"))
+	suite.Require().NoError(err)
+	suite.Require().NoError(writer.Close())
+
+	req := httptest.NewRequest(http.MethodPost, "/?lang=en", &body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	adapter := &chiContextAdapter{ctx: httptest.NewRecorder(), req: req}
+
+	suite.Equal("report", adapter.FormValue("title"))
+	suite.Equal("en", adapter.FormValue("lang"))
+
+	header, err := adapter.FormFile("file")
+	suite.Require().NoError(err)
+	suite.Equal("report.txt", header.Filename)
+	file, err := header.Open()
+	suite.Require().NoError(err)
+	content, err := io.ReadAll(file)
+	suite.NoError(err)
+	suite.Equal("content", string(content))
+	suite.NoError(file.Close())
+
+	_, err = adapter.FormFile("missing")
+	suite.ErrorIs(err, http.ErrMissingFile)
+}
+
 func TestChiContextAdapterSuite(t *testing.T) {
 	suite.Run(t, new(ChiContextAdapterSuite))
 }
@@ -3,10 +3,17 @@ package domain
 import "fmt"
 
 type APIError struct {
-	HTTPStatus int    `json:"-"`
-	ErrorCode  string `json:"error_code"`
-	Message    string `json:"message"`
-	Details    string `json:"details,omitempty"`
+	HTTPStatus int          `json:"-"`
+	ErrorCode  string       `json:"error_code"`
+	Message    string       `json:"message"`
+	Details    string       `json:"details,omitempty"`
+	Fields     []FieldError `json:"fields,omitempty"`
+}
+
+// FieldError is a validation error of a request field, located by a JSON pointer (RFC 6901).
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
 }
 
 func (ae *APIError) Error() string {
@@ -26,3 +33,8 @@ func NewAPIErrorResponse(httpStatus int, errorCode, message string, details ...s
 
 	return apiError
 }
+
+func (ae *APIError) WithFields(fields ...FieldError) *APIError {
+	ae.Fields = append(ae.Fields, fields...)
+	return ae
+}
@@ -4,4 +4,10 @@ import "time"
 
 type CacheConfig struct {
 	Paths map[string]time.Duration
+	// VaryHeaders adds the values of these request headers to the cache key. Responses that vary on
+	// other headers, e.g. Vary: Accept, are not cached.
+	VaryHeaders []string
+	// VaryByPrincipal keys entries by the authenticated principal, which also allows caching the
+	// responses marked as Cache-Control: private of authenticated requests.
+	VaryByPrincipal bool
 }
@@ -0,0 +1,234 @@
+package domain
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"github.com/vmihailenco/msgpack/v5"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"reflect"
+	"strings"
+)
+
+const (
+	ContentTypeProtobuf = "application/x-protobuf"
+	ContentTypeMsgPack  = "application/msgpack"
+	ContentTypeCSV      = "text/csv; charset=utf-8"
+
+	FormatProtobuf = "protobuf"
+	FormatMsgPack  = "msgpack"
+	FormatCSV      = "csv"
+	FormatText     = "text"
+
+	csvTag = "csv"
+)
+
+// Encoder serializes the contents of a ServiceResponse into a specific media type.
+type Encoder interface {
+	ContentType() string
+	Format() string
+	Encode(v interface{}) ([]byte, error)
+}
+
+// CSVMarshaler can be implemented by response contents that know how to render themselves as CSV rows.
+type CSVMarshaler interface {
+	MarshalCSV() ([][]string, error)
+}
+
+type JSONEncoder struct{}
+
+func (JSONEncoder) ContentType() string {
+	return ContentTypeApplicationJSON
+}
+
+func (JSONEncoder) Format() string {
+	return ContentTypeJSON
+}
+
+func (JSONEncoder) Encode(v interface{}) ([]byte, error) {
+	if v == nil {
+		return []byte{}, nil
+	}
+	return json.Marshal(v)
+}
+
+type TextEncoder struct{}
+
+func (TextEncoder) ContentType() string {
+	return ContentTypePlainText
+}
+
+func (TextEncoder) Format() string {
+	return FormatText
+}
+
+func (TextEncoder) Encode(v interface{}) ([]byte, error) {
+	switch value := v.(type) {
+	case nil:
+		return []byte{}, nil
+	case []byte:
+		return value, nil
+	case string:
+		return []byte(value), nil
+	case fmt.Stringer:
+		return []byte(value.String()), nil
+	case error:
+		return []byte(value.Error()), nil
+	default:
+		return []byte(fmt.Sprint(value)), nil
+	}
+}
+
+type ProtobufEncoder struct{}
+
+func (ProtobufEncoder) ContentType() string {
+	return ContentTypeProtobuf
+}
+
+func (ProtobufEncoder) Format() string {
+	return FormatProtobuf
+}
+
+func (ProtobufEncoder) Encode(v interface{}) ([]byte, error) {
+	msg, ok := v.(proto.Message)
+	if !ok {
+		return nil, fmt.Errorf("protobuf encoder: %T does not implement proto.Message", v)
+	}
+	return proto.Marshal(msg)
+}
+
+// ProtoJSONEncoder renders protobuf messages with the canonical JSON mapping, e.g. camelCase field
+// names and enums by name, as gRPC-Gateway does.
+type ProtoJSONEncoder struct {
+	Options protojson.MarshalOptions
+}
+
+func (ProtoJSONEncoder) ContentType() string {
+	return ContentTypeApplicationJSON
+}
+
+func (ProtoJSONEncoder) Format() string {
+	return ContentTypeJSON
+}
+
+func (e ProtoJSONEncoder) Encode(v interface{}) ([]byte, error) {
+	msg, ok := v.(proto.Message)
+	if !ok {
+		return nil, fmt.Errorf("protojson encoder: %T does not implement proto.Message", v)
+	}
+	return e.Options.Marshal(msg)
+}
+
+type MsgPackEncoder struct{}
+
+func (MsgPackEncoder) ContentType() string {
+	return ContentTypeMsgPack
+}
+
+func (MsgPackEncoder) Format() string {
+	return FormatMsgPack
+}
+
+func (MsgPackEncoder) Encode(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := msgpack.NewEncoder(&buf)
+	enc.SetCustomStructTag("json")
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// CSVEncoder renders tabular contents: [][]string, a CSVMarshaler or a slice of structs.
+// For slices of structs the header row is built from the `csv` tag, falling back to the field name.
+type CSVEncoder struct{}
+
+func (CSVEncoder) ContentType() string {
+	return ContentTypeCSV
+}
+
+func (CSVEncoder) Format() string {
+	return FormatCSV
+}
+
+func (CSVEncoder) Encode(v interface{}) ([]byte, error) {
+	rows, err := toCSVRows(v)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	writer := csv.NewWriter(&buf)
+	if err = writer.WriteAll(rows); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func toCSVRows(v interface{}) ([][]string, error) {
+	switch value := v.(type) {
+	case nil:
+		return nil, nil
+	case [][]string:
+		return value, nil
+	case CSVMarshaler:
+		return value.MarshalCSV()
+	}
+
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		return nil, fmt.Errorf("csv encoder: unsupported type %T", v)
+	}
+
+	elemType := rv.Type().Elem()
+	for elemType.Kind() == reflect.Ptr {
+		elemType = elemType.Elem()
+	}
+	if elemType.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("csv encoder: unsupported element type %s", elemType)
+	}
+
+	var fields []int
+	var header []string
+	for i := 0; i < elemType.NumField(); i++ {
+		field := elemType.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		name := field.Name
+		if tag, ok := field.Tag.Lookup(csvTag); ok {
+			tagName := strings.Split(tag, ",")[0]
+			if tagName == "-" {
+				continue
+			}
+			if tagName != "" {
+				name = tagName
+			}
+		}
+
+		fields = append(fields, i)
+		header = append(header, name)
+	}
+
+	rows := make([][]string, 0, rv.Len()+1)
+	rows = append(rows, header)
+	for i := 0; i < rv.Len(); i++ {
+		elem := rv.Index(i)
+		for elem.Kind() == reflect.Ptr {
+			elem = elem.Elem()
+		}
+
+		row := make([]string, len(fields))
+		if elem.IsValid() {
+			for j, fieldIndex := range fields {
+				row[j] = fmt.Sprint(elem.Field(fieldIndex).Interface())
+			}
+		}
+		rows = append(rows, row)
+	}
+
+	return rows, nil
+}
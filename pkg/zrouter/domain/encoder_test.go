@@ -0,0 +1,118 @@
+package domain
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vmihailenco/msgpack/v5"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+	"testing"
+)
+
+type csvRow struct {
+	ID     int    `csv:"id"`
+	Name   string `csv:"name"`
+	Secret string `csv:"-"`
+	Plain  bool
+	hidden string
+}
+
+type csvTable struct{}
+
+func (csvTable) MarshalCSV() ([][]string, error) {
+	return [][]string{{"a", "b"}, {"1", "2"}}, nil
+}
+
+func TestJSONEncoder(t *testing.T) {
+	body, err := JSONEncoder{}.Encode(map[string]string{"message": "hello"})
+	require.NoError(t, err)
+	assert.Equal(t, `{"message":"hello"}`, string(body))
+
+	body, err = JSONEncoder{}.Encode(nil)
+	require.NoError(t, err)
+	assert.Empty(t, body)
+}
+
+func TestTextEncoder(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    interface{}
+		expected string
+	}{
+		{"String", "hello", "hello"},
+		{"Bytes", []byte("raw"), "raw"},
+		{"Error", errors.New("boom"), "boom"},
+		{"Number", 42, "42"},
+		{"Nil", nil, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body, err := TextEncoder{}.Encode(tt.input)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, string(body))
+		})
+	}
+}
+
+func TestCSVEncoder(t *testing.T) {
+	rows := []*csvRow{{ID: 1, Name: "alice", Secret: "x", Plain: true}, {ID: 2, Name: "bob, jr"}}
+	body, err := CSVEncoder{}.Encode(rows)
+	require.NoError(t, err)
+	assert.Equal(t, "id,name,Plain\n1,alice,true\n2,\"bob, jr\",false\n", string(body))
+
+	body, err = CSVEncoder{}.Encode(csvTable{})
+	require.NoError(t, err)
+	assert.Equal(t, "a,b\n1,2\n", string(body))
+
+	body, err = CSVEncoder{}.Encode([][]string{{"x"}})
+	require.NoError(t, err)
+	assert.Equal(t, "x\n", string(body))
+
+	_, err = CSVEncoder{}.Encode(map[string]string{})
+	assert.Error(t, err)
+
+	_, err = CSVEncoder{}.Encode([]int{1})
+	assert.Error(t, err)
+}
+
+func TestMsgPackEncoder(t *testing.T) {
+	body, err := MsgPackEncoder{}.Encode(APIError{ErrorCode: "code", Message: "msg"})
+	require.NoError(t, err)
+
+	var decoded map[string]interface{}
+	require.NoError(t, msgpack.Unmarshal(body, &decoded))
+	assert.Equal(t, "code", decoded["error_code"])
+	assert.Equal(t, "msg", decoded["message"])
+}
+
+func TestProtobufEncoder(t *testing.T) {
+	body, err := ProtobufEncoder{}.Encode(wrapperspb.String("hello"))
+	require.NoError(t, err)
+
+	var decoded wrapperspb.StringValue
+	require.NoError(t, proto.Unmarshal(body, &decoded))
+	assert.Equal(t, "hello", decoded.GetValue())
+
+	_, err = ProtobufEncoder{}.Encode("not a message")
+	assert.Error(t, err)
+}
+
+func TestProtoJSONEncoder(t *testing.T) {
+	body, err := ProtoJSONEncoder{}.Encode(wrapperspb.String("hello"))
+	require.NoError(t, err)
+	assert.Equal(t, `"hello"`, string(body))
+
+	_, err = ProtoJSONEncoder{}.Encode("not a message")
+	assert.Error(t, err)
+}
+
+func TestServiceResponseWithEncoder(t *testing.T) {
+	response := NewServiceResponseWithEncoder(200, "hello", TextEncoder{})
+	body, err := response.ResponseBytes()
+	require.NoError(t, err)
+	assert.Equal(t, "hello", string(body))
+	assert.Equal(t, FormatText, response.ResponseFormat())
+	assert.Equal(t, ContentTypePlainText, response.Header().Get(ContentTypeHeader))
+}
@@ -0,0 +1,87 @@
+package domain
+
+import (
+	"errors"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"net/http"
+	"strings"
+)
+
+const statusClientClosedRequest = 499
+
+// HTTPStatusFromGRPCCode maps a gRPC status code to the HTTP status used by gRPC-Gateway.
+func HTTPStatusFromGRPCCode(code codes.Code) int {
+	switch code {
+	case codes.OK:
+		return http.StatusOK
+	case codes.Canceled:
+		return statusClientClosedRequest
+	case codes.InvalidArgument, codes.FailedPrecondition, codes.OutOfRange:
+		return http.StatusBadRequest
+	case codes.DeadlineExceeded:
+		return http.StatusGatewayTimeout
+	case codes.NotFound:
+		return http.StatusNotFound
+	case codes.AlreadyExists, codes.Aborted:
+		return http.StatusConflict
+	case codes.PermissionDenied:
+		return http.StatusForbidden
+	case codes.Unauthenticated:
+		return http.StatusUnauthorized
+	case codes.ResourceExhausted:
+		return http.StatusTooManyRequests
+	case codes.Unimplemented:
+		return http.StatusNotImplemented
+	case codes.Unavailable:
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusInternalServerError
+}
+
+// NewAPIErrorFromGRPC converts a gRPC error to an APIError. The error code is the snake case name of
+// the status code, e.g. "not_found", or the reason of an ErrorInfo detail. BadRequest field
+// violations become the Fields of the error.
+func NewAPIErrorFromGRPC(err error) *APIError {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr
+	}
+
+	st := status.Convert(err)
+	apiError := NewAPIErrorResponse(HTTPStatusFromGRPCCode(st.Code()), grpcErrorCode(st.Code()), st.Message())
+
+	for _, detail := range st.Details() {
+		switch detail := detail.(type) {
+		case *errdetails.ErrorInfo:
+			if detail.GetReason() != "" {
+				apiError.ErrorCode = strings.ToLower(detail.GetReason())
+			}
+		case *errdetails.BadRequest:
+			for _, violation := range detail.GetFieldViolations() {
+				apiError.Fields = append(apiError.Fields, FieldError{
+					Field:   "/" + strings.ReplaceAll(violation.GetField(), ".", "/"),
+					Message: violation.GetDescription(),
+				})
+			}
+		}
+	}
+
+	return apiError
+}
+
+// grpcErrorCode turns names such as "NotFound" into "not_found".
+func grpcErrorCode(code codes.Code) string {
+	var b strings.Builder
+	for i, c := range code.String() {
+		if c >= 'A' && c <= 'Z' {
+			if i > 0 {
+				b.WriteByte('_')
+			}
+			c += 'a' - 'A'
+		}
+		b.WriteRune(c)
+	}
+	return b.String()
+}
@@ -0,0 +1,58 @@
+package domain
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"net/http"
+	"testing"
+)
+
+func TestHTTPStatusFromGRPCCode(t *testing.T) {
+	tests := map[codes.Code]int{
+		codes.OK:                http.StatusOK,
+		codes.Canceled:          statusClientClosedRequest,
+		codes.InvalidArgument:   http.StatusBadRequest,
+		codes.NotFound:          http.StatusNotFound,
+		codes.AlreadyExists:     http.StatusConflict,
+		codes.PermissionDenied:  http.StatusForbidden,
+		codes.Unauthenticated:   http.StatusUnauthorized,
+		codes.ResourceExhausted: http.StatusTooManyRequests,
+		codes.Unimplemented:     http.StatusNotImplemented,
+		codes.Unavailable:       http.StatusServiceUnavailable,
+		codes.DeadlineExceeded:  http.StatusGatewayTimeout,
+		codes.Internal:          http.StatusInternalServerError,
+		codes.DataLoss:          http.StatusInternalServerError,
+	}
+
+	for code, expected := range tests {
+		assert.Equal(t, expected, HTTPStatusFromGRPCCode(code), code.String())
+	}
+}
+
+func TestNewAPIErrorFromGRPC(t *testing.T) {
+	apiError := NewAPIErrorFromGRPC(status.Error(codes.NotFound, "user not found"))
+	assert.Equal(t, http.StatusNotFound, apiError.HTTPStatus)
+	assert.Equal(t, "not_found", apiError.ErrorCode)
+	assert.Equal(t, "user not found", apiError.Message)
+
+	st, err := status.New(codes.InvalidArgument, "invalid user").WithDetails(
+		&errdetails.ErrorInfo{Reason: "INVALID_USER"},
+		&errdetails.BadRequest{FieldViolations: []*errdetails.BadRequest_FieldViolation{
+			{Field: "user.email", Description: "must be an email"},
+		}},
+	)
+	require.NoError(t, err)
+
+	apiError = NewAPIErrorFromGRPC(st.Err())
+	assert.Equal(t, http.StatusBadRequest, apiError.HTTPStatus)
+	assert.Equal(t, "invalid_user", apiError.ErrorCode)
+	assert.Equal(t, []FieldError{{Field: "/user/email", Message: "must be an email"}}, apiError.Fields)
+
+	apiError = NewAPIErrorFromGRPC(errors.New("connection reset"))
+	assert.Equal(t, http.StatusInternalServerError, apiError.HTTPStatus)
+	assert.Equal(t, "unknown", apiError.ErrorCode)
+}
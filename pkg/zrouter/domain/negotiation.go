@@ -0,0 +1,122 @@
+package domain
+
+import (
+	"context"
+	"mime"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	AcceptHeader = "Accept"
+)
+
+type encodersKey struct{}
+
+type acceptedMediaRange struct {
+	mediaType string
+	quality   float64
+}
+
+// DefaultEncoders is the set used when neither the router nor the route configure any encoder.
+func DefaultEncoders() []Encoder {
+	return []Encoder{JSONEncoder{}}
+}
+
+func ContextWithEncoders(ctx context.Context, encoders ...Encoder) context.Context {
+	return context.WithValue(ctx, encodersKey{}, encoders)
+}
+
+func EncodersFromContext(ctx context.Context) []Encoder {
+	encoders, _ := ctx.Value(encodersKey{}).([]Encoder)
+	return encoders
+}
+
+// NegotiateEncoder picks the encoder that best satisfies the Accept header. The first encoder is the
+// default and is returned when the header is empty, malformed or matches none of the encoders.
+func NegotiateEncoder(accept string, encoders []Encoder) Encoder {
+	if len(encoders) == 0 {
+		return JSONEncoder{}
+	}
+
+	ranges := parseAccept(accept)
+
+	for _, mediaRange := range ranges {
+		if mediaRange.quality <= 0 {
+			continue
+		}
+
+		for _, encoder := range encoders {
+			if matchMediaRange(mediaRange.mediaType, encoderMediaType(encoder)) {
+				return encoder
+			}
+		}
+	}
+
+	return encoders[0]
+}
+
+func parseAccept(accept string) []acceptedMediaRange {
+	var ranges []acceptedMediaRange
+	for _, part := range strings.Split(accept, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		mediaType, params, err := mime.ParseMediaType(part)
+		if err != nil {
+			continue
+		}
+
+		quality := 1.0
+		if q, ok := params["q"]; ok {
+			if parsed, err := strconv.ParseFloat(q, 64); err == nil {
+				quality = parsed
+			}
+		}
+
+		ranges = append(ranges, acceptedMediaRange{mediaType: mediaType, quality: quality})
+	}
+
+	sort.SliceStable(ranges, func(i, j int) bool {
+		if ranges[i].quality != ranges[j].quality {
+			return ranges[i].quality > ranges[j].quality
+		}
+		return specificity(ranges[i].mediaType) > specificity(ranges[j].mediaType)
+	})
+
+	return ranges
+}
+
+func specificity(mediaType string) int {
+	switch {
+	case mediaType == "*/*":
+		return 0
+	case strings.HasSuffix(mediaType, "/*"):
+		return 1
+	default:
+		return 2
+	}
+}
+
+func matchMediaRange(mediaRange, mediaType string) bool {
+	if mediaRange == "*/*" || mediaRange == mediaType {
+		return true
+	}
+
+	if prefix, ok := strings.CutSuffix(mediaRange, "/*"); ok {
+		return strings.HasPrefix(mediaType, prefix+"/")
+	}
+
+	return false
+}
+
+func encoderMediaType(encoder Encoder) string {
+	mediaType, _, err := mime.ParseMediaType(encoder.ContentType())
+	if err != nil {
+		return strings.ToLower(encoder.ContentType())
+	}
+	return mediaType
+}
@@ -0,0 +1,43 @@
+package domain
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestNegotiateEncoder(t *testing.T) {
+	encoders := []Encoder{JSONEncoder{}, MsgPackEncoder{}, CSVEncoder{}, TextEncoder{}}
+
+	tests := []struct {
+		name     string
+		accept   string
+		expected string
+	}{
+		{"Empty header uses default", "", ContentTypeJSON},
+		{"Wildcard uses default", "*/*", ContentTypeJSON},
+		{"Exact match", "application/msgpack", FormatMsgPack},
+		{"Highest quality wins", "text/plain;q=0.5, text/csv;q=0.9", FormatCSV},
+		{"Specific beats wildcard with same quality", "*/*, text/plain", FormatText},
+		{"Subtype wildcard", "text/*", FormatCSV},
+		{"Excluded types fall back to default", "application/xml, text/csv;q=0", ContentTypeJSON},
+		{"Malformed header uses default", ";;;", ContentTypeJSON},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, NegotiateEncoder(tt.accept, encoders).Format())
+		})
+	}
+}
+
+func TestNegotiateEncoder_NoEncoders(t *testing.T) {
+	assert.Equal(t, JSONEncoder{}, NegotiateEncoder("text/csv", nil))
+}
+
+func TestEncodersFromContext(t *testing.T) {
+	assert.Nil(t, EncodersFromContext(context.Background()))
+
+	ctx := ContextWithEncoders(context.Background(), TextEncoder{})
+	assert.Equal(t, []Encoder{TextEncoder{}}, EncodersFromContext(ctx))
+}
@@ -0,0 +1,214 @@
+package domain
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+)
+
+const (
+	ContentTypeProblemJSON = "application/problem+json"
+	DefaultProblemType     = "about:blank"
+
+	problemTypeMember      = "type"
+	problemTitleMember     = "title"
+	problemStatusMember    = "status"
+	problemDetailMember    = "detail"
+	problemInstanceMember  = "instance"
+	problemRequestIDMember = "request_id"
+	problemErrorCodeMember = "error_code"
+	problemDetailsMember   = "details"
+	problemFieldsMember    = "fields"
+
+	internalProblemDetail = "An internal error occurred"
+)
+
+// ProblemDetails is the RFC 7807 (application/problem+json) representation of an error.
+// Extensions are serialized as top-level members next to the standard ones.
+type ProblemDetails struct {
+	Type       string
+	Title      string
+	Status     int
+	Detail     string
+	Instance   string
+	RequestID  string
+	Extensions map[string]interface{}
+}
+
+func NewProblemDetails(status int, detail string) *ProblemDetails {
+	return &ProblemDetails{
+		Type:   DefaultProblemType,
+		Title:  http.StatusText(status),
+		Status: status,
+		Detail: detail,
+	}
+}
+
+func (p *ProblemDetails) Error() string {
+	return fmt.Sprintf("HTTP Status: %d, Type: %s, Title: %s, Detail: %s", p.Status, p.Type, p.Title, p.Detail)
+}
+
+func (p *ProblemDetails) WithType(problemType string) *ProblemDetails {
+	p.Type = problemType
+	return p
+}
+
+func (p *ProblemDetails) WithTitle(title string) *ProblemDetails {
+	p.Title = title
+	return p
+}
+
+func (p *ProblemDetails) WithExtension(key string, value interface{}) *ProblemDetails {
+	if p.Extensions == nil {
+		p.Extensions = make(map[string]interface{})
+	}
+	p.Extensions[key] = value
+	return p
+}
+
+func (p *ProblemDetails) MarshalJSON() ([]byte, error) {
+	members := make(map[string]interface{}, len(p.Extensions)+6)
+	for key, value := range p.Extensions {
+		members[key] = value
+	}
+
+	problemType := p.Type
+	if problemType == "" {
+		problemType = DefaultProblemType
+	}
+	members[problemTypeMember] = problemType
+	members[problemStatusMember] = p.Status
+
+	if p.Title != "" {
+		members[problemTitleMember] = p.Title
+	}
+	if p.Detail != "" {
+		members[problemDetailMember] = p.Detail
+	}
+	if p.Instance != "" {
+		members[problemInstanceMember] = p.Instance
+	}
+	if p.RequestID != "" {
+		members[problemRequestIDMember] = p.RequestID
+	}
+
+	return json.Marshal(members)
+}
+
+func (p *ProblemDetails) UnmarshalJSON(data []byte) error {
+	var members map[string]interface{}
+	if err := json.Unmarshal(data, &members); err != nil {
+		return err
+	}
+
+	*p = ProblemDetails{}
+	for key, value := range members {
+		switch key {
+		case problemTypeMember:
+			p.Type, _ = value.(string)
+		case problemTitleMember:
+			p.Title, _ = value.(string)
+		case problemStatusMember:
+			if status, ok := value.(float64); ok {
+				p.Status = int(status)
+			}
+		case problemDetailMember:
+			p.Detail, _ = value.(string)
+		case problemInstanceMember:
+			p.Instance, _ = value.(string)
+		case problemRequestIDMember:
+			p.RequestID, _ = value.(string)
+		default:
+			p.WithExtension(key, value)
+		}
+	}
+
+	return nil
+}
+
+// ToProblemDetails converts an APIError keeping its error code and details as extension members,
+// so clients relying on the legacy fields keep working.
+func (ae *APIError) ToProblemDetails() *ProblemDetails {
+	problem := NewProblemDetails(ae.HTTPStatus, ae.Message)
+	if ae.ErrorCode != "" {
+		problem.WithExtension(problemErrorCodeMember, ae.ErrorCode)
+	}
+	if ae.Details != "" {
+		problem.WithExtension(problemDetailsMember, ae.Details)
+	}
+	if len(ae.Fields) > 0 {
+		problem.WithExtension(problemFieldsMember, ae.Fields)
+	}
+	return problem
+}
+
+// ProblemMapper translates an error into a problem. It returns nil when the error is not handled.
+type ProblemMapper func(err error) *ProblemDetails
+
+// ProblemRegistry maps (possibly wrapped) domain errors to problems. Mappers are evaluated in
+// registration order; errors that no mapper handles fall back to APIError conversion and then to a
+// generic 500 problem that does not leak the error message.
+type ProblemRegistry struct {
+	mu      sync.RWMutex
+	mappers []ProblemMapper
+}
+
+func NewProblemRegistry() *ProblemRegistry {
+	return &ProblemRegistry{}
+}
+
+// Register maps every error matching target (errors.Is) to a problem with the given status and title.
+func (r *ProblemRegistry) Register(target error, status int, title string) {
+	r.RegisterMapper(func(err error) *ProblemDetails {
+		if !errors.Is(err, target) {
+			return nil
+		}
+		return NewProblemDetails(status, target.Error()).WithTitle(title)
+	})
+}
+
+func (r *ProblemRegistry) RegisterMapper(mapper ProblemMapper) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.mappers = append(r.mappers, mapper)
+}
+
+// RegisterProblemType maps every error assignable to T (errors.As) using the given function.
+func RegisterProblemType[T error](r *ProblemRegistry, mapper func(T) *ProblemDetails) {
+	r.RegisterMapper(func(err error) *ProblemDetails {
+		var target T
+		if !errors.As(err, &target) {
+			return nil
+		}
+		return mapper(target)
+	})
+}
+
+func (r *ProblemRegistry) Resolve(err error) *ProblemDetails {
+	if r != nil {
+		r.mu.RLock()
+		mappers := r.mappers
+		r.mu.RUnlock()
+
+		for _, mapper := range mappers {
+			if problem := mapper(err); problem != nil {
+				return problem
+			}
+		}
+	}
+
+	var problem *ProblemDetails
+	if errors.As(err, &problem) {
+		copied := *problem
+		return &copied
+	}
+
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.ToProblemDetails()
+	}
+
+	return NewProblemDetails(http.StatusInternalServerError, internalProblemDetail)
+}
@@ -0,0 +1,102 @@
+package domain
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"testing"
+)
+
+var errAccountNotFound = errors.New("account not found")
+
+type quotaError struct {
+	Limit int
+}
+
+func (e *quotaError) Error() string {
+	return fmt.Sprintf("quota of %d exceeded", e.Limit)
+}
+
+func TestProblemDetailsJSONRoundTrip(t *testing.T) {
+	problem := NewProblemDetails(http.StatusConflict, "Balance is too low").
+		WithType("https://example.com/probs/out-of-credit").
+		WithExtension("balance", 30)
+	problem.Instance = "/accounts/1"
+	problem.RequestID = "req-1"
+
+	body, err := json.Marshal(problem)
+	require.NoError(t, err)
+
+	var members map[string]interface{}
+	require.NoError(t, json.Unmarshal(body, &members))
+	assert.Equal(t, "https://example.com/probs/out-of-credit", members["type"])
+	assert.Equal(t, "Conflict", members["title"])
+	assert.Equal(t, float64(http.StatusConflict), members["status"])
+	assert.Equal(t, "Balance is too low", members["detail"])
+	assert.Equal(t, "/accounts/1", members["instance"])
+	assert.Equal(t, "req-1", members["request_id"])
+	assert.Equal(t, float64(30), members["balance"])
+
+	var decoded ProblemDetails
+	require.NoError(t, json.Unmarshal(body, &decoded))
+	assert.Equal(t, problem.Type, decoded.Type)
+	assert.Equal(t, problem.Status, decoded.Status)
+	assert.Equal(t, problem.RequestID, decoded.RequestID)
+	assert.Equal(t, float64(30), decoded.Extensions["balance"])
+}
+
+func TestProblemDetailsExtensionsCannotOverrideStandardMembers(t *testing.T) {
+	problem := NewProblemDetails(http.StatusBadRequest, "bad").WithExtension("status", 200)
+
+	body, err := json.Marshal(problem)
+	require.NoError(t, err)
+	assert.Contains(t, string(body), `"status":400`)
+}
+
+func TestAPIErrorToProblemDetails(t *testing.T) {
+	problem := NewAPIErrorResponse(http.StatusNotFound, "not_found", "Missing", "id=1").ToProblemDetails()
+
+	assert.Equal(t, http.StatusNotFound, problem.Status)
+	assert.Equal(t, "Missing", problem.Detail)
+	assert.Equal(t, "not_found", problem.Extensions["error_code"])
+	assert.Equal(t, "id=1", problem.Extensions["details"])
+	assert.NotContains(t, problem.Extensions, "fields")
+
+	fields := []FieldError{{Field: "/email", Message: "missing"}}
+	problem = NewAPIErrorResponse(http.StatusBadRequest, "validation_failed", "Invalid").WithFields(fields...).ToProblemDetails()
+	assert.Equal(t, fields, problem.Extensions["fields"])
+}
+
+func TestProblemRegistryResolve(t *testing.T) {
+	registry := NewProblemRegistry()
+	registry.Register(errAccountNotFound, http.StatusNotFound, "Account Not Found")
+	RegisterProblemType(registry, func(err *quotaError) *ProblemDetails {
+		return NewProblemDetails(http.StatusTooManyRequests, err.Error()).WithExtension("limit", err.Limit)
+	})
+
+	problem := registry.Resolve(fmt.Errorf("loading: %w", errAccountNotFound))
+	assert.Equal(t, http.StatusNotFound, problem.Status)
+	assert.Equal(t, "Account Not Found", problem.Title)
+	assert.Equal(t, "account not found", problem.Detail)
+
+	problem = registry.Resolve(fmt.Errorf("wrapped: %w", &quotaError{Limit: 5}))
+	assert.Equal(t, http.StatusTooManyRequests, problem.Status)
+	assert.Equal(t, 5, problem.Extensions["limit"])
+
+	problem = registry.Resolve(NewAPIErrorResponse(http.StatusBadRequest, "bad_request", "Invalid"))
+	assert.Equal(t, http.StatusBadRequest, problem.Status)
+
+	problem = registry.Resolve(errors.New("db password is hunter2"))
+	assert.Equal(t, http.StatusInternalServerError, problem.Status)
+	assert.NotContains(t, problem.Detail, "hunter2")
+}
+
+func TestProblemRegistryResolve_NilRegistry(t *testing.T) {
+	var registry *ProblemRegistry
+
+	problem := registry.Resolve(NewProblemDetails(http.StatusGone, "gone"))
+	assert.Equal(t, http.StatusGone, problem.Status)
+}
@@ -21,10 +21,17 @@ type ServiceResponse interface {
 	Contents() interface{}
 }
 
+// EncoderBoundResponse is implemented by responses that were built with an explicit Encoder.
+// A non-nil Encoder disables content negotiation for that response.
+type EncoderBoundResponse interface {
+	Encoder() Encoder
+}
+
 type defaultServiceResponse struct {
 	status        int
 	header        http.Header
 	response      interface{}
+	encoder       Encoder
 	once          sync.Once
 	responseBytes []byte
 	marshalError  error
@@ -40,19 +47,19 @@ func (d *defaultServiceResponse) Header() http.Header {
 		h = http.Header{}
 	}
 	if h.Get(ContentTypeHeader) == "" {
-		h.Set(ContentTypeHeader, ContentTypeApplicationJSON)
+		h.Set(ContentTypeHeader, d.getEncoder().ContentType())
 	}
 	return h
 }
 
 func (d *defaultServiceResponse) ResponseFormat() string {
-	return ContentTypeJSON
+	return d.getEncoder().Format()
 }
 
 func (d *defaultServiceResponse) ResponseBytes() ([]byte, error) {
 	d.once.Do(func() {
 		if d.response != nil {
-			d.responseBytes, d.marshalError = json.Marshal(d.response)
+			d.responseBytes, d.marshalError = d.getEncoder().Encode(d.response)
 		} else {
 			d.responseBytes = []byte{}
 		}
@@ -60,6 +67,17 @@ func (d *defaultServiceResponse) ResponseBytes() ([]byte, error) {
 	return d.responseBytes, d.marshalError
 }
 
+func (d *defaultServiceResponse) Encoder() Encoder {
+	return d.encoder
+}
+
+func (d *defaultServiceResponse) getEncoder() Encoder {
+	if d.encoder == nil {
+		return JSONEncoder{}
+	}
+	return d.encoder
+}
+
 func (d *defaultServiceResponse) Contents() interface{} {
 	return d.response
 }
@@ -80,6 +98,14 @@ func NewServiceResponseWithHeader(status int, response interface{}, header http.
 	}
 }
 
+func NewServiceResponseWithEncoder(status int, response interface{}, encoder Encoder) ServiceResponse {
+	return &defaultServiceResponse{
+		status:   status,
+		response: response,
+		encoder:  encoder,
+	}
+}
+
 func NewErrorResponse(status int, errorCode, errMsg string) ServiceResponse {
 	apiError := NewAPIErrorResponse(status, errorCode, errMsg)
 	apiErrorBytes, err := json.Marshal(apiError)
@@ -0,0 +1,194 @@
+package domain
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	ContentTypeEventStream = "text/event-stream"
+	LastEventIDHeader      = "Last-Event-ID"
+	CacheControlHeader     = "Cache-Control"
+
+	FormatSSE = "sse"
+
+	heartbeatComment = "heartbeat"
+)
+
+type SSEEvent struct {
+	ID    string
+	Event string
+	// Data is written verbatim when it is a string or []byte and JSON encoded otherwise.
+	Data  interface{}
+	Retry time.Duration
+}
+
+type SSEOptions struct {
+	// HeartbeatInterval sends a comment line periodically so proxies keep the connection open.
+	// Zero disables heartbeats.
+	HeartbeatInterval time.Duration
+	// Retry is sent once at the beginning of the stream as the client reconnection delay.
+	Retry time.Duration
+}
+
+// SSEStream sends Server-Sent Events to a single client. It is safe for concurrent use.
+type SSEStream struct {
+	ctx context.Context
+	w   StreamWriter
+	mu  sync.Mutex
+}
+
+func (s *SSEStream) Context() context.Context {
+	return s.ctx
+}
+
+// Done is closed when the client disconnects or the request is cancelled.
+func (s *SSEStream) Done() <-chan struct{} {
+	return s.ctx.Done()
+}
+
+func (s *SSEStream) Send(event SSEEvent) error {
+	if err := s.ctx.Err(); err != nil {
+		return err
+	}
+
+	data, err := sseData(event.Data)
+	if err != nil {
+		return err
+	}
+
+	var b strings.Builder
+	if event.ID != "" {
+		writeSSEField(&b, "id", event.ID)
+	}
+	if event.Event != "" {
+		writeSSEField(&b, "event", event.Event)
+	}
+	if event.Retry > 0 {
+		writeSSEField(&b, "retry", fmt.Sprint(event.Retry.Milliseconds()))
+	}
+	for _, line := range strings.Split(data, "\n") {
+		writeSSEField(&b, "data", line)
+	}
+	b.WriteString("\n")
+
+	return s.write(b.String())
+}
+
+func (s *SSEStream) Comment(text string) error {
+	var b strings.Builder
+	for _, line := range strings.Split(text, "\n") {
+		b.WriteString(": ")
+		b.WriteString(line)
+		b.WriteString("\n")
+	}
+	b.WriteString("\n")
+
+	return s.write(b.String())
+}
+
+func (s *SSEStream) write(payload string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.w.Write([]byte(payload)); err != nil {
+		return err
+	}
+	return s.w.Flush()
+}
+
+func (s *SSEStream) heartbeat(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := s.Comment(heartbeatComment); err != nil {
+				return
+			}
+		case <-stop:
+			return
+		case <-s.ctx.Done():
+			return
+		}
+	}
+}
+
+type sseServiceResponse struct {
+	*streamServiceResponse
+}
+
+func (s *sseServiceResponse) ResponseFormat() string {
+	return FormatSSE
+}
+
+// NewSSEResponse builds a text/event-stream response driven by fn. The stream ends when fn returns;
+// a client disconnection is not reported as an error.
+func NewSSEResponse(fn func(stream *SSEStream) error, options SSEOptions) ServiceResponse {
+	header := http.Header{}
+	header.Set(CacheControlHeader, "no-cache")
+	header.Set("Connection", "keep-alive")
+	header.Set("X-Accel-Buffering", "no")
+
+	stream := func(ctx context.Context, w StreamWriter) error {
+		sse := &SSEStream{ctx: ctx, w: w}
+
+		if options.Retry > 0 {
+			if err := sse.write(fmt.Sprintf("retry: %d\n\n", options.Retry.Milliseconds())); err != nil {
+				return err
+			}
+		}
+
+		if options.HeartbeatInterval > 0 {
+			stop, done := make(chan struct{}), make(chan struct{})
+			go func() {
+				defer close(done)
+				sse.heartbeat(options.HeartbeatInterval, stop)
+			}()
+			// The writer must not be used once the handler returns, so wait for the heartbeat to exit.
+			defer func() {
+				close(stop)
+				<-done
+			}()
+		}
+
+		err := fn(sse)
+		if ctx.Err() != nil {
+			return nil
+		}
+		return err
+	}
+
+	response := NewStreamResponseWithHeader(http.StatusOK, ContentTypeEventStream, stream, header).(*streamServiceResponse)
+	return &sseServiceResponse{streamServiceResponse: response}
+}
+
+func sseData(data interface{}) (string, error) {
+	switch value := data.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return value, nil
+	case []byte:
+		return string(value), nil
+	default:
+		encoded, err := json.Marshal(value)
+		if err != nil {
+			return "", err
+		}
+		return string(encoded), nil
+	}
+}
+
+func writeSSEField(b *strings.Builder, field, value string) {
+	b.WriteString(field)
+	b.WriteString(": ")
+	b.WriteString(strings.TrimSuffix(value, "\r"))
+	b.WriteString("\n")
+}
@@ -0,0 +1,92 @@
+package domain
+
+import (
+	"bytes"
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type bufferStreamWriter struct {
+	mu      sync.Mutex
+	buf     bytes.Buffer
+	flushes int
+}
+
+func (b *bufferStreamWriter) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *bufferStreamWriter) Flush() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.flushes++
+	return nil
+}
+
+func (b *bufferStreamWriter) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestSSEResponse(t *testing.T) {
+	response := NewSSEResponse(func(stream *SSEStream) error {
+		if err := stream.Send(SSEEvent{ID: "1", Event: "greeting", Data: "hello\nworld"}); err != nil {
+			return err
+		}
+		return stream.Send(SSEEvent{ID: "2", Data: map[string]int{"count": 2}})
+	}, SSEOptions{Retry: 3 * time.Second})
+
+	assert.Equal(t, ContentTypeEventStream, response.Header().Get(ContentTypeHeader))
+	assert.Equal(t, "no-cache", response.Header().Get(CacheControlHeader))
+	assert.Equal(t, FormatSSE, response.ResponseFormat())
+
+	_, err := response.ResponseBytes()
+	assert.ErrorIs(t, err, ErrStreamingResponse)
+
+	streaming, ok := response.(StreamingResponse)
+	require.True(t, ok)
+
+	writer := &bufferStreamWriter{}
+	require.NoError(t, streaming.Stream(context.Background(), writer))
+
+	expected := "retry: 3000\n\n" +
+		"id: 1\nevent: greeting\ndata: hello\ndata: world\n\n" +
+		"id: 2\ndata: {\"count\":2}\n\n"
+	assert.Equal(t, expected, writer.String())
+	assert.Equal(t, 3, writer.flushes)
+}
+
+func TestSSEResponse_HeartbeatAndDisconnect(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	writer := &bufferStreamWriter{}
+
+	response := NewSSEResponse(func(stream *SSEStream) error {
+		time.Sleep(50 * time.Millisecond)
+		cancel()
+		<-stream.Done()
+		return stream.Send(SSEEvent{Data: "too late"})
+	}, SSEOptions{HeartbeatInterval: 10 * time.Millisecond})
+
+	err := response.(StreamingResponse).Stream(ctx, writer)
+	assert.NoError(t, err)
+	assert.Contains(t, writer.String(), ": heartbeat\n\n")
+	assert.NotContains(t, writer.String(), "too late")
+}
+
+func TestReaderResponse(t *testing.T) {
+	response := NewReaderResponse(200, ContentTypePlainText, strings.NewReader("streamed body"))
+
+	writer := &bufferStreamWriter{}
+	require.NoError(t, response.(StreamingResponse).Stream(context.Background(), writer))
+	assert.Equal(t, "streamed body", writer.String())
+	assert.Equal(t, ContentTypePlainText, response.Header().Get(ContentTypeHeader))
+	assert.Equal(t, FormatStream, response.ResponseFormat())
+}
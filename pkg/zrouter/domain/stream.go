@@ -0,0 +1,116 @@
+package domain
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+)
+
+const (
+	FormatStream = "stream"
+
+	streamCopyBufferSize = 32 * 1024
+)
+
+var ErrStreamingResponse = errors.New("streaming responses cannot be materialized")
+
+// StreamWriter is the sink handed to streaming responses. Flush pushes the buffered bytes to the client.
+type StreamWriter interface {
+	io.Writer
+	Flush() error
+}
+
+// StreamingResponse is a ServiceResponse whose body is produced incrementally. The router writes the
+// status and headers first and then calls Stream with the request context, which is cancelled when
+// the client disconnects.
+type StreamingResponse interface {
+	ServiceResponse
+	Stream(ctx context.Context, w StreamWriter) error
+}
+
+type StreamFunc func(ctx context.Context, w StreamWriter) error
+
+type streamServiceResponse struct {
+	status int
+	header http.Header
+	stream StreamFunc
+}
+
+func (s *streamServiceResponse) Status() int {
+	return s.status
+}
+
+func (s *streamServiceResponse) Header() http.Header {
+	return s.header
+}
+
+func (s *streamServiceResponse) ResponseBytes() ([]byte, error) {
+	return nil, ErrStreamingResponse
+}
+
+func (s *streamServiceResponse) ResponseFormat() string {
+	return FormatStream
+}
+
+func (s *streamServiceResponse) Contents() interface{} {
+	return nil
+}
+
+func (s *streamServiceResponse) Stream(ctx context.Context, w StreamWriter) error {
+	return s.stream(ctx, w)
+}
+
+// NewStreamResponse builds a chunked response written by fn.
+func NewStreamResponse(status int, contentType string, fn StreamFunc) ServiceResponse {
+	return NewStreamResponseWithHeader(status, contentType, fn, nil)
+}
+
+func NewStreamResponseWithHeader(status int, contentType string, fn StreamFunc, header http.Header) ServiceResponse {
+	if header == nil {
+		header = http.Header{}
+	}
+	if contentType != "" {
+		header.Set(ContentTypeHeader, contentType)
+	}
+
+	return &streamServiceResponse{
+		status: status,
+		header: header,
+		stream: fn,
+	}
+}
+
+// NewReaderResponse streams body to the client, flushing after every chunk read. The body is closed
+// when it implements io.Closer.
+func NewReaderResponse(status int, contentType string, body io.Reader) ServiceResponse {
+	return NewStreamResponse(status, contentType, func(ctx context.Context, w StreamWriter) error {
+		if closer, ok := body.(io.Closer); ok {
+			defer closer.Close()
+		}
+
+		buf := make([]byte, streamCopyBufferSize)
+		for {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+
+			n, readErr := body.Read(buf)
+			if n > 0 {
+				if _, err := w.Write(buf[:n]); err != nil {
+					return err
+				}
+				if err := w.Flush(); err != nil {
+					return err
+				}
+			}
+
+			if errors.Is(readErr, io.EOF) {
+				return nil
+			}
+			if readErr != nil {
+				return readErr
+			}
+		}
+	})
+}
@@ -1,14 +1,25 @@
 package zrouter
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
+	"github.com/zondax/golem/pkg/logger"
 	"github.com/zondax/golem/pkg/zrouter/domain"
+	"github.com/zondax/golem/pkg/zrouter/zmiddlewares"
 	"net/http"
+	"time"
 )
 
 type HandlerFunc func(ctx Context) (domain.ServiceResponse, error)
 
+// responseSettings carries the router configuration that shapes how handler results are written.
+type responseSettings struct {
+	encoders       []domain.Encoder
+	problemDetails bool
+	problems       *domain.ProblemRegistry
+}
+
 func NotFoundHandler(_ Context) (domain.ServiceResponse, error) {
 	msg := "Route not found"
 	return domain.NewErrorNotFound(msg), nil
@@ -26,50 +37,178 @@ func ToHandlerFunc(h http.Handler) HandlerFunc {
 	}
 }
 
-func getChiHandler(handler HandlerFunc) http.HandlerFunc {
+// getChiHandler adapts a HandlerFunc to net/http. When encoders are configured (or attached to the request
+// by WithEncoders) the response body is encoded with the one negotiated from the Accept header.
+func getChiHandler(handler HandlerFunc, settings responseSettings) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		adaptedContext := &chiContextAdapter{ctx: w, req: r}
+		// The server only removes the multipart files of its own request, not of the copies made by middlewares.
+		defer func() {
+			if r.MultipartForm != nil {
+				_ = r.MultipartForm.RemoveAll()
+			}
+			adaptedContext.runCleanups()
+		}()
+
+		encoders := domain.EncodersFromContext(r.Context())
+		if len(encoders) == 0 {
+			encoders = settings.encoders
+		}
 
 		serviceResponse, err := handler(adaptedContext)
 		if err != nil {
-			handleError(w, err)
+			handleError(w, r, err, encoders, settings)
 			return
 		}
 
-		handleServiceResponse(w, serviceResponse)
+		handleServiceResponse(w, r, serviceResponse, encoders, adaptedContext.status)
 	}
 }
 
-func handleError(w http.ResponseWriter, err error) {
+func handleError(w http.ResponseWriter, r *http.Request, err error, encoders []domain.Encoder, settings responseSettings) {
+	err = translateRequestError(r, err)
+
+	if settings.problemDetails {
+		problem := settings.problems.Resolve(err)
+		if problem.Status >= http.StatusInternalServerError {
+			logger.GetLoggerFromContext(r.Context()).Errorf("Internal error handling request: %v", err)
+		}
+		zmiddlewares.WriteProblemDetails(w, r, problem)
+		return
+	}
+
 	var apiErr *domain.APIError
 
 	if errors.As(err, &apiErr) {
-		writeAPIErrorResponse(w, apiErr)
+		writeAPIErrorResponse(w, r, apiErr, encoders)
 		return
 	}
 
+	logger.GetLoggerFromContext(r.Context()).Errorf("Internal error handling request: %v", err)
 	writeInternalServerError(w)
 }
 
-func handleServiceResponse(w http.ResponseWriter, serviceResponse domain.ServiceResponse) {
+// translateRequestError turns the errors raised by the body size and timeout middlewares into API errors.
+// Deadlines are only translated when the Timeout middleware fired, not those of the handler's own calls.
+func translateRequestError(r *http.Request, err error) error {
+	var maxBytesErr *http.MaxBytesError
+	switch {
+	case errors.As(err, &maxBytesErr):
+		return domain.NewAPIErrorResponse(http.StatusRequestEntityTooLarge, "request_too_large", "request body too large")
+	case errors.Is(err, context.DeadlineExceeded) && errors.Is(context.Cause(r.Context()), zmiddlewares.ErrRequestTimeout):
+		return domain.NewAPIErrorResponse(http.StatusGatewayTimeout, "request_timeout", "request timed out")
+	default:
+		return err
+	}
+}
+
+// handleServiceResponse writes the response with the given status or, when it is zero, with the status
+// of the response.
+func handleServiceResponse(w http.ResponseWriter, r *http.Request, serviceResponse domain.ServiceResponse, encoders []domain.Encoder, status int) {
 	if serviceResponse == nil {
+		if status != 0 {
+			w.WriteHeader(status)
+		}
 		return
 	}
 
-	body, err := serviceResponse.ResponseBytes()
+	if status == 0 {
+		status = serviceResponse.Status()
+	}
+
+	if streaming, ok := serviceResponse.(domain.StreamingResponse); ok {
+		writeStreamingResponse(w, r, streaming, status)
+		return
+	}
+
+	body, contentType, err := encodeServiceResponse(r, serviceResponse, encoders)
 	if err != nil {
 		http.Error(w, "Failed to process response.", http.StatusInternalServerError)
 		return
 	}
 
-	contentType := serviceResponse.Header().Get(domain.ContentTypeHeader)
+	if len(encoders) > 0 {
+		zmiddlewares.AddVaryHeader(w.Header(), domain.AcceptHeader)
+	}
 	w.Header().Set(domain.ContentTypeHeader, contentType)
-	w.WriteHeader(serviceResponse.Status())
+	w.WriteHeader(status)
 	_, _ = w.Write(body)
 }
 
-func writeAPIErrorResponse(w http.ResponseWriter, apiErr *domain.APIError) {
-	w.Header().Set(domain.ContentTypeHeader, domain.ContentTypeJSON)
+func encodeServiceResponse(r *http.Request, serviceResponse domain.ServiceResponse, encoders []domain.Encoder) ([]byte, string, error) {
+	if bound, ok := serviceResponse.(domain.EncoderBoundResponse); len(encoders) == 0 || (ok && bound.Encoder() != nil) {
+		body, err := serviceResponse.ResponseBytes()
+		return body, serviceResponse.Header().Get(domain.ContentTypeHeader), err
+	}
+
+	return negotiateAndEncode(r, serviceResponse.Contents(), encoders)
+}
+
+// negotiateAndEncode encodes v with the encoder negotiated for the request, falling back to the default
+// encoder (the first one) when the negotiated one cannot represent v.
+func negotiateAndEncode(r *http.Request, v interface{}, encoders []domain.Encoder) ([]byte, string, error) {
+	encoder := domain.NegotiateEncoder(r.Header.Get(domain.AcceptHeader), encoders)
+	body, err := encoder.Encode(v)
+	if err != nil {
+		encoder = encoders[0]
+		body, err = encoder.Encode(v)
+	}
+
+	return body, encoder.ContentType(), err
+}
+
+// writeStreamingResponse sends the headers straight away and hands the writer to the response. Errors
+// raised once the body has started can only be logged.
+func writeStreamingResponse(w http.ResponseWriter, r *http.Request, streaming domain.StreamingResponse, status int) {
+	for key, values := range streaming.Header() {
+		for _, value := range values {
+			w.Header().Add(key, value)
+		}
+	}
+
+	controller := http.NewResponseController(w)
+	if streaming.ResponseFormat() == domain.FormatSSE {
+		// Event streams are long-lived, the server WriteTimeOut must not cut them.
+		_ = controller.SetWriteDeadline(time.Time{})
+	}
+
+	w.WriteHeader(status)
+	writer := &streamWriter{w: w, controller: controller}
+	if err := writer.Flush(); err != nil {
+		logger.GetLoggerFromContext(r.Context()).Errorf("Streaming is not supported by the response writer: %v", err)
+		return
+	}
+
+	if err := streaming.Stream(r.Context(), writer); err != nil && r.Context().Err() == nil {
+		logger.GetLoggerFromContext(r.Context()).Errorf("Error streaming response: %v", err)
+	}
+}
+
+type streamWriter struct {
+	w          http.ResponseWriter
+	controller *http.ResponseController
+}
+
+func (s *streamWriter) Write(p []byte) (int, error) {
+	return s.w.Write(p)
+}
+
+func (s *streamWriter) Flush() error {
+	return s.controller.Flush()
+}
+
+func writeAPIErrorResponse(w http.ResponseWriter, r *http.Request, apiErr *domain.APIError, encoders []domain.Encoder) {
+	if len(encoders) > 0 {
+		if body, contentType, err := negotiateAndEncode(r, apiErr, encoders); err == nil {
+			zmiddlewares.AddVaryHeader(w.Header(), domain.AcceptHeader)
+			w.Header().Set(domain.ContentTypeHeader, contentType)
+			w.WriteHeader(apiErr.HTTPStatus)
+			_, _ = w.Write(body)
+			return
+		}
+	}
+
+	w.Header().Set(domain.ContentTypeHeader, domain.ContentTypeApplicationJSON)
 	w.WriteHeader(apiErr.HTTPStatus)
 	responseBody, _ := json.Marshal(apiErr)
 	_, _ = w.Write(responseBody)
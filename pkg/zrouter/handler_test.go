@@ -1,12 +1,20 @@
 package zrouter
 
 import (
+	"bufio"
 	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"github.com/stretchr/testify/suite"
 	"github.com/zondax/golem/pkg/zrouter/domain"
+	"github.com/zondax/golem/pkg/zrouter/zmiddlewares"
+	"io"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 )
 
 type ChiHandlerAdapterSuite struct {
@@ -21,7 +29,7 @@ func (suite *ChiHandlerAdapterSuite) TestChiHandlerAdapter() {
 		return domain.NewServiceResponseWithHeader(http.StatusOK, "Hello", h), nil
 	}
 
-	httpHandlerFunc := getChiHandler(handlerFunc)
+	httpHandlerFunc := getChiHandler(handlerFunc, responseSettings{})
 
 	req, err := http.NewRequest("GET", "/test", bytes.NewBuffer(nil))
 	suite.Require().NoError(err)
@@ -40,7 +48,7 @@ func (suite *ChiHandlerAdapterSuite) TestChiHandlerAdapter_ShouldReturnNotFoundE
 	h.Add(domain.ContentTypeHeader, domain.ContentTypeApplicationJSON)
 
 	expected := `{"error_code":"ROUTE_NOT_FOUND","message":"Route not found"}`
-	httpHandlerFunc := getChiHandler(NotFoundHandler)
+	httpHandlerFunc := getChiHandler(NotFoundHandler, responseSettings{})
 
 	req, err := http.NewRequest("GET", "/test", bytes.NewBuffer(nil))
 	suite.Require().NoError(err)
@@ -53,6 +61,210 @@ func (suite *ChiHandlerAdapterSuite) TestChiHandlerAdapter_ShouldReturnNotFoundE
 	suite.Equal(expected, recorder.Body.String())
 }
 
+func (suite *ChiHandlerAdapterSuite) TestChiHandlerAdapter_NegotiatesEncoder() {
+	handlerFunc := func(ctx Context) (domain.ServiceResponse, error) {
+		return domain.NewServiceResponse(http.StatusOK, "Hello"), nil
+	}
+
+	httpHandlerFunc := getChiHandler(handlerFunc, responseSettings{encoders: []domain.Encoder{domain.JSONEncoder{}, domain.TextEncoder{}}})
+
+	req, err := http.NewRequest("GET", "/test", nil)
+	suite.Require().NoError(err)
+	req.Header.Set(domain.AcceptHeader, "text/plain")
+
+	recorder := httptest.NewRecorder()
+	httpHandlerFunc(recorder, req)
+
+	suite.Equal(http.StatusOK, recorder.Code)
+	suite.Equal("Hello", recorder.Body.String())
+	suite.Equal(domain.ContentTypePlainText, recorder.Header().Get(domain.ContentTypeHeader))
+	suite.Equal(domain.AcceptHeader, recorder.Header().Get("Vary"))
+
+	// The Accept header versioning already varies the response on Accept.
+	recorder = httptest.NewRecorder()
+	recorder.Header().Set("Vary", domain.AcceptHeader)
+	httpHandlerFunc(recorder, req)
+	suite.Equal([]string{domain.AcceptHeader}, recorder.Header().Values("Vary"))
+
+	// No encoder matches, the default one is used instead of answering 406.
+	req.Header.Set(domain.AcceptHeader, "image/png")
+	recorder = httptest.NewRecorder()
+	httpHandlerFunc(recorder, req)
+	suite.Equal(http.StatusOK, recorder.Code)
+	suite.Equal(domain.ContentTypeApplicationJSON, recorder.Header().Get(domain.ContentTypeHeader))
+}
+
+func (suite *ChiHandlerAdapterSuite) TestChiHandlerAdapter_FallsBackToDefaultEncoder() {
+	handlerFunc := func(ctx Context) (domain.ServiceResponse, error) {
+		return domain.NewServiceResponse(http.StatusOK, map[string]string{"message": "Hello"}), nil
+	}
+
+	httpHandlerFunc := getChiHandler(handlerFunc, responseSettings{encoders: []domain.Encoder{domain.JSONEncoder{}, domain.ProtobufEncoder{}}})
+
+	req, err := http.NewRequest("GET", "/test", nil)
+	suite.Require().NoError(err)
+	req.Header.Set(domain.AcceptHeader, domain.ContentTypeProtobuf)
+
+	recorder := httptest.NewRecorder()
+	httpHandlerFunc(recorder, req)
+
+	suite.Equal(http.StatusOK, recorder.Code)
+	suite.Equal(`{"message":"Hello"}`, recorder.Body.String())
+	suite.Equal(domain.ContentTypeApplicationJSON, recorder.Header().Get(domain.ContentTypeHeader))
+}
+
+func (suite *ChiHandlerAdapterSuite) TestChiHandlerAdapter_BoundEncoderSkipsNegotiation() {
+	handlerFunc := func(ctx Context) (domain.ServiceResponse, error) {
+		return domain.NewServiceResponseWithEncoder(http.StatusOK, "Hello", domain.TextEncoder{}), nil
+	}
+
+	httpHandlerFunc := getChiHandler(handlerFunc, responseSettings{encoders: []domain.Encoder{domain.JSONEncoder{}}})
+
+	req, err := http.NewRequest("GET", "/test", nil)
+	suite.Require().NoError(err)
+	req.Header.Set(domain.AcceptHeader, domain.ContentTypeApplicationJSON)
+
+	recorder := httptest.NewRecorder()
+	httpHandlerFunc(recorder, req)
+
+	suite.Equal("Hello", recorder.Body.String())
+	suite.Equal(domain.ContentTypePlainText, recorder.Header().Get(domain.ContentTypeHeader))
+}
+
+func (suite *ChiHandlerAdapterSuite) TestChiHandlerAdapter_RouteEncodersOverrideRouter() {
+	handlerFunc := func(ctx Context) (domain.ServiceResponse, error) {
+		return nil, domain.NewAPIErrorResponse(http.StatusBadRequest, "bad_request", "Bad request")
+	}
+
+	handler := WithEncoders(domain.TextEncoder{})(getChiHandler(handlerFunc, responseSettings{encoders: []domain.Encoder{domain.JSONEncoder{}}}))
+
+	req, err := http.NewRequest("GET", "/test", nil)
+	suite.Require().NoError(err)
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, req)
+
+	suite.Equal(http.StatusBadRequest, recorder.Code)
+	suite.Equal(domain.ContentTypePlainText, recorder.Header().Get(domain.ContentTypeHeader))
+	suite.Contains(recorder.Body.String(), "Bad request")
+}
+
+func (suite *ChiHandlerAdapterSuite) TestChiHandlerAdapter_ProblemDetails() {
+	errNotFound := errors.New("account not found")
+	registry := domain.NewProblemRegistry()
+	registry.Register(errNotFound, http.StatusNotFound, "Account Not Found")
+
+	tests := []struct {
+		name           string
+		err            error
+		expectedStatus int
+		expectedDetail string
+	}{
+		{"Registered error", fmt.Errorf("service: %w", errNotFound), http.StatusNotFound, "account not found"},
+		{"APIError", domain.NewAPIErrorResponse(http.StatusBadRequest, "bad_request", "Invalid"), http.StatusBadRequest, "Invalid"},
+		{"Unknown error", errors.New("boom"), http.StatusInternalServerError, "An internal error occurred"},
+	}
+
+	for _, tt := range tests {
+		suite.Run(tt.name, func() {
+			handlerFunc := func(ctx Context) (domain.ServiceResponse, error) {
+				return nil, tt.err
+			}
+
+			httpHandlerFunc := getChiHandler(handlerFunc, responseSettings{problemDetails: true, problems: registry})
+
+			req, err := http.NewRequest("GET", "/accounts/1", nil)
+			suite.Require().NoError(err)
+			req.Header.Set(zmiddlewares.RequestIDHeader, "req-1")
+
+			recorder := httptest.NewRecorder()
+			httpHandlerFunc(recorder, req)
+
+			suite.Equal(tt.expectedStatus, recorder.Code)
+			suite.Equal(domain.ContentTypeProblemJSON, recorder.Header().Get(domain.ContentTypeHeader))
+
+			var problem domain.ProblemDetails
+			suite.Require().NoError(json.Unmarshal(recorder.Body.Bytes(), &problem))
+			suite.Equal(tt.expectedStatus, problem.Status)
+			suite.Equal(tt.expectedDetail, problem.Detail)
+			suite.Equal("/accounts/1", problem.Instance)
+			suite.Equal("req-1", problem.RequestID)
+		})
+	}
+}
+
+func (suite *ChiHandlerAdapterSuite) TestChiHandlerAdapter_StreamsThroughMiddlewares() {
+	release := make(chan struct{})
+	handlerFunc := func(ctx Context) (domain.ServiceResponse, error) {
+		return domain.NewSSEResponse(func(stream *domain.SSEStream) error {
+			if err := stream.Send(domain.SSEEvent{ID: "1", Data: "first"}); err != nil {
+				return err
+			}
+			<-release
+			return stream.Send(domain.SSEEvent{ID: "2", Data: "second"})
+		}, domain.SSEOptions{}), nil
+	}
+
+	handler := zmiddlewares.RequestID()(getChiHandler(handlerFunc, responseSettings{}))
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	resp, err := http.Get(server.URL)
+	suite.Require().NoError(err)
+	defer resp.Body.Close()
+
+	suite.Equal(domain.ContentTypeEventStream, resp.Header.Get(domain.ContentTypeHeader))
+
+	reader := bufio.NewReader(resp.Body)
+	suite.Equal("id: 1\n", readLine(suite, reader))
+	suite.Equal("data: first\n", readLine(suite, reader))
+
+	close(release)
+	suite.Equal("\n", readLine(suite, reader))
+	suite.Equal("id: 2\n", readLine(suite, reader))
+}
+
+func (suite *ChiHandlerAdapterSuite) TestChiHandlerAdapter_TranslatesLimitErrors() {
+	bindHandler := getChiHandler(func(ctx Context) (domain.ServiceResponse, error) {
+		var payload map[string]string
+		if err := ctx.BindJSON(&payload); err != nil {
+			return nil, err
+		}
+		return domain.NewServiceResponse(http.StatusOK, payload), nil
+	}, responseSettings{})
+
+	recorder := httptest.NewRecorder()
+	zmiddlewares.MaxBodySize(4)(bindHandler).ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/test", io.NopCloser(bytes.NewBufferString(`{"key":"value"}`))))
+	suite.Equal(http.StatusRequestEntityTooLarge, recorder.Code)
+
+	timeoutHandler := getChiHandler(func(ctx Context) (domain.ServiceResponse, error) {
+		<-ctx.Context().Done()
+		return nil, fmt.Errorf("querying upstream: %w", ctx.Context().Err())
+	}, responseSettings{})
+
+	req := httptest.NewRequest(http.MethodGet, "/test", nil)
+	reqCtx, cancel := context.WithTimeoutCause(req.Context(), time.Millisecond, zmiddlewares.ErrRequestTimeout)
+	defer cancel()
+
+	recorder = httptest.NewRecorder()
+	timeoutHandler(recorder, req.WithContext(reqCtx))
+	suite.Equal(http.StatusGatewayTimeout, recorder.Code)
+
+	// Deadlines of the handler's own calls are internal errors.
+	reqCtx, cancel = context.WithTimeout(req.Context(), time.Millisecond)
+	defer cancel()
+
+	recorder = httptest.NewRecorder()
+	timeoutHandler(recorder, req.WithContext(reqCtx))
+	suite.Equal(http.StatusInternalServerError, recorder.Code)
+}
+
+func readLine(suite *ChiHandlerAdapterSuite, reader *bufio.Reader) string {
+	line, err := reader.ReadString('\n')
+	suite.Require().NoError(err)
+	return line
+}
+
 func TestChiHandlerAdapterSuite(t *testing.T) {
 	suite.Run(t, new(ChiHandlerAdapterSuite))
 }
@@ -0,0 +1,18 @@
+package zrouter
+
+import (
+	"github.com/zondax/golem/pkg/zrouter/domain"
+	"github.com/zondax/golem/pkg/zrouter/zmiddlewares"
+	"net/http"
+)
+
+// WithEncoders overrides the router encoders for a single route, e.g.
+// router.GET("/report", handler, zrouter.WithEncoders(domain.CSVEncoder{}, domain.JSONEncoder{})).
+func WithEncoders(encoders ...domain.Encoder) zmiddlewares.Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := domain.ContextWithEncoders(r.Context(), encoders...)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
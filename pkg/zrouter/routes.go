@@ -0,0 +1,180 @@
+package zrouter
+
+import (
+	"errors"
+	"fmt"
+	"github.com/zondax/golem/pkg/zobservability"
+	"github.com/zondax/golem/pkg/zrouter/zmiddlewares"
+	"net/url"
+	"reflect"
+	"regexp"
+	"runtime"
+	"strings"
+)
+
+var (
+	ErrRouteNotFound     = errors.New("route not found")
+	ErrMissingRouteParam = errors.New("missing route parameter")
+
+	closureSuffixRegexp = regexp.MustCompile(`(\.func\d+|\.\d+)+$`)
+)
+
+// RouteOptions describes a route for URLFor and introspection. Names must be unique in a router.
+type RouteOptions struct {
+	Name     string
+	Tags     []string
+	Metadata map[string]interface{}
+	// Versions are the API versions served by the handler. Requests for a newer version fall back to
+	// the nearest older one. Requires Config.Versioning.
+	Versions []string
+	// RequestSchema and ResponseSchema are JSON Schema documents for the request and response bodies.
+	// Requests that do not match are rejected with 400. Responses are only validated when
+	// Config.SchemaValidation enables it.
+	RequestSchema  []byte
+	ResponseSchema []byte
+}
+
+// RouteDescription is a registered route with the middlewares it runs through, outermost first.
+type RouteDescription struct {
+	RegisteredRoute
+	Middlewares []string
+}
+
+type mountedRouter struct {
+	prefix string
+	router *zrouter
+}
+
+func (r *zrouter) RouteWithOptions(method, path string, handler HandlerFunc, options RouteOptions, middlewares ...zmiddlewares.Middleware) Routes {
+	r.handleWithOptions(method, path, getChiHandler(handler, r.responseSettings()), options, middlewares...)
+	return r
+}
+
+// DescribeRoutes returns the routes of the router and of the routers mounted on it, with their full paths.
+func (r *zrouter) DescribeRoutes() []RouteDescription {
+	r.mutex.Lock()
+	routes := make([]RouteDescription, len(r.routes))
+	copy(routes, r.routes)
+	mounts := make([]mountedRouter, len(r.mounts))
+	copy(mounts, r.mounts)
+	r.mutex.Unlock()
+
+	for _, mount := range mounts {
+		for _, route := range mount.router.DescribeRoutes() {
+			route.Path = joinRoutePath(mount.prefix, route.Path)
+			routes = append(routes, route)
+		}
+	}
+	return routes
+}
+
+// URLFor builds the path of the route registered with the given name, filling its URL parameters.
+// The "*" parameter fills a trailing wildcard and the "version" parameter of a versioned route
+// defaults to its latest version.
+func (r *zrouter) URLFor(name string, params map[string]string) (string, error) {
+	for _, route := range r.DescribeRoutes() {
+		if route.Name != name {
+			continue
+		}
+
+		if _, ok := params[versionParam]; !ok && len(route.Versions) > 0 {
+			withVersion := map[string]string{versionParam: route.Versions[len(route.Versions)-1]}
+			for key, value := range params {
+				withVersion[key] = value
+			}
+			params = withVersion
+		}
+		return buildRoutePath(route.Path, params)
+	}
+	return "", fmt.Errorf("%w: %s", ErrRouteNotFound, name)
+}
+
+// schemaValidation builds the validation middleware of a route. It panics if a schema is not valid.
+func (r *zrouter) schemaValidation(options RouteOptions) zmiddlewares.Middleware {
+	if len(options.RequestSchema) == 0 && len(options.ResponseSchema) == 0 {
+		return nil
+	}
+
+	config := r.config.SchemaValidation
+	validation := zmiddlewares.SchemaValidationOptions{
+		ValidateResponses: config.ValidateResponses && config.Environment != zobservability.EnvironmentProduction,
+		MaxResponseBytes:  config.MaxResponseBytes,
+	}
+	if len(options.RequestSchema) > 0 {
+		validation.Request = zmiddlewares.MustCompileJSONSchema(options.RequestSchema)
+	}
+	if len(options.ResponseSchema) > 0 {
+		validation.Response = zmiddlewares.MustCompileJSONSchema(options.ResponseSchema)
+	}
+	return zmiddlewares.SchemaValidation(validation)
+}
+
+func (r *zrouter) mount(prefix string, subRouter *zrouter) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.mounts = append(r.mounts, mountedRouter{prefix: prefix, router: subRouter})
+}
+
+// middlewareChain lists the middlewares wrapping a route handler in execution order.
+func (r *zrouter) middlewareChain(middlewares []zmiddlewares.Middleware) []string {
+	chain := append([]string{}, r.inheritedMiddlewares...)
+
+	applied := make([]zmiddlewares.Middleware, 0, len(middlewares)+len(r.middlewares)+len(r.defaultMiddlewares))
+	applied = append(applied, middlewares...)
+	applied = append(applied, r.middlewares...)
+	applied = append(applied, r.defaultMiddlewares...)
+	for i := len(applied) - 1; i >= 0; i-- {
+		chain = append(chain, middlewareName(applied[i]))
+	}
+	return chain
+}
+
+// middlewareName returns the name of the function that built the middleware, e.g. "zmiddlewares.RequestID".
+func middlewareName(mw zmiddlewares.Middleware) string {
+	fn := runtime.FuncForPC(reflect.ValueOf(mw).Pointer())
+	if fn == nil {
+		return "unknown"
+	}
+
+	name := fn.Name()
+	name = name[strings.LastIndex(name, "/")+1:]
+	return closureSuffixRegexp.ReplaceAllString(name, "")
+}
+
+func joinRoutePath(prefix, path string) string {
+	return strings.TrimSuffix(prefix, "/") + path
+}
+
+func buildRoutePath(pattern string, params map[string]string) (string, error) {
+	var path strings.Builder
+	for i := 0; i < len(pattern); i++ {
+		switch pattern[i] {
+		case '{':
+			// Regexp parameters such as {id:[0-9]+} may contain braces themselves.
+			depth, end := 0, i
+			for ; end < len(pattern); end++ {
+				if pattern[end] == '{' {
+					depth++
+				} else if pattern[end] == '}' {
+					depth--
+					if depth == 0 {
+						break
+					}
+				}
+			}
+
+			name, _, _ := strings.Cut(pattern[i+1:end], ":")
+			value, ok := params[name]
+			if !ok {
+				return "", fmt.Errorf("%w: %s", ErrMissingRouteParam, name)
+			}
+			path.WriteString(url.PathEscape(value))
+			i = end
+		case '*':
+			path.WriteString(params["*"])
+		default:
+			path.WriteByte(pattern[i])
+		}
+	}
+	return path.String(), nil
+}
@@ -0,0 +1,177 @@
+package zrouter
+
+import (
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+	"github.com/zondax/golem/pkg/logger"
+	"github.com/zondax/golem/pkg/metrics"
+	"github.com/zondax/golem/pkg/zrouter/domain"
+	"github.com/zondax/golem/pkg/zrouter/zmiddlewares"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type RoutesSuite struct {
+	suite.Suite
+	metrics *metrics.MockTaskMetrics
+	router  ZRouter
+}
+
+func (suite *RoutesSuite) SetupTest() {
+	logger.InitLogger(logger.Config{})
+	suite.metrics = new(metrics.MockTaskMetrics)
+	suite.metrics.On("RegisterMetric", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	suite.metrics.On("UpdateMetric", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	suite.metrics.On("IncrementMetric", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	suite.metrics.On("DecrementMetric", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+
+	suite.router = New(suite.metrics, &Config{
+		AppVersion:      "app_version",
+		AppRevision:     "app_revision",
+		ProblemDetails:  ProblemDetailsConfig{Enable: true},
+		EnableRequestID: true,
+	})
+	suite.router.SetDefaultMiddlewares(zmiddlewares.LoggingMiddlewareOptions{Enable: true})
+}
+
+func okHandler(ctx Context) (domain.ServiceResponse, error) {
+	return domain.NewServiceResponse(http.StatusOK, ctx.Param("id")), nil
+}
+
+func (suite *RoutesSuite) TestNamedRoutesAndURLFor() {
+	suite.router.RouteWithOptions(http.MethodGet, "/users/{id}", okHandler, RouteOptions{
+		Name:     "user",
+		Tags:     []string{"users"},
+		Metadata: map[string]interface{}{"public": true},
+	})
+	suite.router.RouteWithOptions(http.MethodGet, "/files/{bucket:[a-z]{3,}}/*", okHandler, RouteOptions{Name: "file"})
+
+	api := suite.router.Group("/api/v1")
+	api.RouteWithOptions(http.MethodGet, "/orders/{id}", okHandler, RouteOptions{Name: "order"})
+
+	path, err := suite.router.URLFor("user", map[string]string{"id": "a b"})
+	suite.NoError(err)
+	suite.Equal("/users/a%20b", path)
+
+	path, err = suite.router.URLFor("file", map[string]string{"bucket": "docs", "*": "2024/report.pdf"})
+	suite.NoError(err)
+	suite.Equal("/files/docs/2024/report.pdf", path)
+
+	path, err = suite.router.URLFor("order", map[string]string{"id": "42"})
+	suite.NoError(err)
+	suite.Equal("/api/v1/orders/42", path)
+
+	_, err = suite.router.URLFor("user", nil)
+	suite.ErrorIs(err, ErrMissingRouteParam)
+
+	_, err = suite.router.URLFor("missing", nil)
+	suite.ErrorIs(err, ErrRouteNotFound)
+
+	suite.Panics(func() {
+		suite.router.RouteWithOptions(http.MethodPost, "/users", okHandler, RouteOptions{Name: "user"})
+	})
+
+	suite.Contains(suite.router.GetRegisteredRoutes(), RegisteredRoute{
+		Method:   http.MethodGet,
+		Path:     "/users/{id}",
+		Name:     "user",
+		Tags:     []string{"users"},
+		Metadata: map[string]interface{}{"public": true},
+	})
+}
+
+func (suite *RoutesSuite) TestDescribeRoutes() {
+	suite.router.GET("/health", okHandler, zmiddlewares.MaxBodySize(1024))
+	suite.router.Group("/api").GET("/users/{id}", okHandler)
+
+	routes := suite.router.DescribeRoutes()
+	suite.Require().Len(routes, 2)
+
+	suite.Equal("/health", routes[0].Path)
+	suite.Equal([]string{
+		"zmiddlewares.LoggingMiddleware",
+		"zmiddlewares.RequestMetricsWithOptions",
+		"zmiddlewares.ErrorHandlerMiddlewareWithOptions",
+		"zmiddlewares.MaxBodySize",
+	}, routes[0].Middlewares)
+
+	// The request ID middleware is installed once the first route is registered.
+	suite.Equal("/api/users/{id}", routes[1].Path)
+	suite.Equal([]string{
+		"zmiddlewares.LoggingMiddleware",
+		"zmiddlewares.RequestMetricsWithOptions",
+		"zmiddlewares.ErrorHandlerMiddlewareWithOptions",
+		"zmiddlewares.requestIDMiddleware",
+	}, routes[1].Middlewares)
+}
+
+func (suite *RoutesSuite) TestGroupPreservesConfigAndMiddlewares() {
+	suite.router.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set(headerCustomMiddleware, testValue)
+			next.ServeHTTP(w, r)
+		})
+	})
+
+	api := suite.router.Group("/api")
+	api.GET("/users/{id}", func(ctx Context) (domain.ServiceResponse, error) {
+		return nil, domain.NewAPIErrorResponse(http.StatusNotFound, "not_found", "user not found")
+	})
+
+	rec := httptest.NewRecorder()
+	suite.router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/users/1", nil))
+
+	suite.Equal(http.StatusNotFound, rec.Code)
+	suite.Equal(testValue, rec.Header().Get(headerCustomMiddleware))
+	suite.Equal("application/problem+json", rec.Header().Get("Content-Type"))
+	suite.metrics.AssertCalled(suite.T(), "UpdateMetric", "total_requests", float64(1), "/api/*", http.MethodGet, "/api/users/{id}", "404")
+}
+
+func (suite *RoutesSuite) TestGroupCopiesMiddlewaresWhenCreated() {
+	api := suite.router.Group("/api")
+	suite.router.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set(headerCustomMiddleware, testValue)
+			next.ServeHTTP(w, r)
+		})
+	})
+	api.GET("/users/{id}", okHandler)
+	suite.router.GET("/health", okHandler)
+
+	rec := httptest.NewRecorder()
+	suite.router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/users/1", nil))
+	suite.Equal(http.StatusOK, rec.Code)
+	suite.Empty(rec.Header().Get(headerCustomMiddleware))
+
+	rec = httptest.NewRecorder()
+	suite.router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/health", nil))
+	suite.Equal(testValue, rec.Header().Get(headerCustomMiddleware))
+}
+
+func (suite *RoutesSuite) TestSchemaValidation() {
+	schema := []byte(`{"type": "object", "required": ["id"], "properties": {"id": {"type": "integer"}}}`)
+	suite.router.RouteWithOptions(http.MethodPost, "/orders", func(ctx Context) (domain.ServiceResponse, error) {
+		return domain.NewServiceResponse(http.StatusCreated, nil), nil
+	}, RouteOptions{RequestSchema: schema, ResponseSchema: schema})
+
+	rec := httptest.NewRecorder()
+	suite.router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/orders", strings.NewReader(`{"id": 1}`)))
+	suite.Equal(http.StatusCreated, rec.Code)
+
+	rec = httptest.NewRecorder()
+	suite.router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/orders", strings.NewReader(`{"id": "1"}`)))
+	suite.Equal(http.StatusBadRequest, rec.Code)
+	suite.Contains(rec.Body.String(), `"field":"/id"`)
+
+	chain := suite.router.DescribeRoutes()[0].Middlewares
+	suite.Equal("zmiddlewares.SchemaValidation", chain[len(chain)-1])
+	suite.Panics(func() {
+		suite.router.RouteWithOptions(http.MethodPut, "/orders", okHandler, RouteOptions{RequestSchema: []byte(`{"type": 1}`)})
+	})
+}
+
+func TestRoutesSuite(t *testing.T) {
+	suite.Run(t, new(RoutesSuite))
+}
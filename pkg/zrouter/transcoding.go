@@ -0,0 +1,339 @@
+package zrouter
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"github.com/zondax/golem/pkg/zrouter/auth"
+	"github.com/zondax/golem/pkg/zrouter/domain"
+	"github.com/zondax/golem/pkg/zrouter/zmiddlewares"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"io"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	// TranscodeBodyAll binds the whole JSON body to the request message.
+	TranscodeBodyAll = "*"
+
+	invalidArgumentErrorCode = "invalid_argument"
+)
+
+var (
+	// DefaultTranscodingForwardHeaders are passed as gRPC metadata, with the trace context of the request.
+	DefaultTranscodingForwardHeaders = []string{
+		"Authorization", zmiddlewares.RequestIDHeader, auth.APIKeyHeader, "Accept-Language",
+		"Traceparent", "Tracestate", "Baggage",
+	}
+	// DefaultTranscodingResponseHeaders are the gRPC header and trailer metadata returned as HTTP headers.
+	DefaultTranscodingResponseHeaders = []string{"Retry-After"}
+
+	pathParamRegexp = regexp.MustCompile(`\{([^}:]+)[^}]*}`)
+)
+
+// TranscodingBinding maps an HTTP route to a unary gRPC method, like a google.api.http annotation.
+type TranscodingBinding struct {
+	Method string
+	// Path is a route pattern whose parameters are request field paths, e.g. "/v1/users/{user.id}".
+	Path string
+	// FullMethod is the gRPC method, e.g. "/users.v1.Users/GetUser".
+	FullMethod string
+	// Body is the request field set from the JSON body: TranscodeBodyAll, the name of a message field,
+	// or empty when the request has no body. Query parameters set the remaining fields, unless Body is
+	// TranscodeBodyAll.
+	Body        string
+	NewRequest  func() proto.Message
+	NewResponse func() proto.Message
+	Options     RouteOptions
+}
+
+type TranscodingOptions struct {
+	Conn     grpc.ClientConnInterface
+	Bindings []TranscodingBinding
+	// ForwardHeaders defaults to DefaultTranscodingForwardHeaders. X-Forwarded-For, X-Forwarded-Host and
+	// X-Forwarded-Proto are always set from the resolved client.
+	ForwardHeaders []string
+	// ResponseHeaders defaults to DefaultTranscodingResponseHeaders.
+	ResponseHeaders  []string
+	MarshalOptions   protojson.MarshalOptions
+	UnmarshalOptions protojson.UnmarshalOptions
+	CallOptions      []grpc.CallOption
+}
+
+func (o *TranscodingOptions) setDefaultValues() {
+	if o.ForwardHeaders == nil {
+		o.ForwardHeaders = DefaultTranscodingForwardHeaders
+	}
+
+	if o.ResponseHeaders == nil {
+		o.ResponseHeaders = DefaultTranscodingResponseHeaders
+	}
+}
+
+// Transcode registers a route per binding that calls the gRPC method with the request message built
+// from the path parameters, query parameters and JSON body, and writes the response message as JSON.
+// gRPC errors are returned as APIErrors, with the HTTP status of domain.HTTPStatusFromGRPCCode.
+// It panics if a binding does not match its request message.
+func (r *zrouter) Transcode(options TranscodingOptions, middlewares ...zmiddlewares.Middleware) Routes {
+	if options.Conn == nil {
+		panic("transcoding requires a gRPC connection")
+	}
+	options.setDefaultValues()
+
+	for _, binding := range options.Bindings {
+		r.RouteWithOptions(binding.Method, binding.Path, newTranscodingHandler(options, binding), binding.Options, middlewares...)
+	}
+	return r
+}
+
+func newTranscodingHandler(options TranscodingOptions, binding TranscodingBinding) HandlerFunc {
+	descriptor := binding.NewRequest().ProtoReflect().Descriptor()
+
+	var pathParams []string
+	for _, match := range pathParamRegexp.FindAllStringSubmatch(binding.Path, -1) {
+		if _, ok := findFieldPath(descriptor, match[1]); !ok {
+			panic(fmt.Sprintf("path parameter %q of %s is not a field of %s", match[1], binding.Path, descriptor.FullName()))
+		}
+		pathParams = append(pathParams, match[1])
+	}
+
+	if binding.Body != "" && binding.Body != TranscodeBodyAll {
+		fields, ok := findFieldPath(descriptor, binding.Body)
+		if !ok || fields[len(fields)-1].Message() == nil || fields[len(fields)-1].IsList() || fields[len(fields)-1].IsMap() {
+			panic(fmt.Sprintf("body %q of %s is not a message field of %s", binding.Body, binding.Path, descriptor.FullName()))
+		}
+	}
+
+	return func(ctx Context) (domain.ServiceResponse, error) {
+		req := ctx.Request()
+		in := binding.NewRequest()
+
+		if err := bindTranscodingBody(req, in, binding.Body, options.UnmarshalOptions); err != nil {
+			return nil, domain.NewAPIErrorResponse(http.StatusBadRequest, invalidArgumentErrorCode, "invalid request body", err.Error())
+		}
+
+		bound := make(map[string]struct{}, len(pathParams))
+		for _, param := range pathParams {
+			if err := setFieldPath(in.ProtoReflect(), param, []string{ctx.Param(param)}); err != nil {
+				return nil, domain.NewAPIErrorResponse(http.StatusBadRequest, invalidArgumentErrorCode, fmt.Sprintf("invalid path parameter %q", param), err.Error())
+			}
+			bound[param] = struct{}{}
+		}
+
+		if binding.Body != TranscodeBodyAll {
+			for key, values := range req.URL.Query() {
+				if _, ok := bound[key]; ok {
+					continue
+				}
+				// Unknown query parameters, such as cache busters, are ignored.
+				if _, ok := findFieldPath(descriptor, key); !ok {
+					continue
+				}
+				if err := setFieldPath(in.ProtoReflect(), key, values); err != nil {
+					return nil, domain.NewAPIErrorResponse(http.StatusBadRequest, invalidArgumentErrorCode, fmt.Sprintf("invalid query parameter %q", key), err.Error())
+				}
+			}
+		}
+
+		callCtx := metadata.NewOutgoingContext(req.Context(), transcodingMetadata(req, options.ForwardHeaders))
+
+		var header, trailer metadata.MD
+		callOptions := append([]grpc.CallOption{grpc.Header(&header), grpc.Trailer(&trailer)}, options.CallOptions...)
+
+		out := binding.NewResponse()
+		err := options.Conn.Invoke(callCtx, binding.FullMethod, in, out, callOptions...)
+
+		for _, key := range options.ResponseHeaders {
+			values := header.Get(key)
+			if len(values) == 0 {
+				values = trailer.Get(key)
+			}
+			if len(values) > 0 {
+				ctx.Header(key, values[0])
+			}
+		}
+
+		if err != nil {
+			return nil, domain.NewAPIErrorFromGRPC(err)
+		}
+		return domain.NewServiceResponseWithEncoder(http.StatusOK, out, domain.ProtoJSONEncoder{Options: options.MarshalOptions}), nil
+	}
+}
+
+// transcodingMetadata builds the outgoing metadata from the forwarded headers, the resolved client and
+// the trace context of the request, which takes precedence over the forwarded trace headers.
+func transcodingMetadata(req *http.Request, forwardHeaders []string) metadata.MD {
+	header := http.Header{}
+	for _, key := range forwardHeaders {
+		if values := req.Header.Values(key); len(values) > 0 {
+			header[http.CanonicalHeaderKey(key)] = values
+		}
+	}
+
+	header.Set(zmiddlewares.XForwardedForHeader, zmiddlewares.ClientIPFromRequest(req))
+	if info, ok := zmiddlewares.ClientInfoFromContext(req.Context()); ok {
+		header.Set(zmiddlewares.XForwardedHostHeader, info.Host)
+		header.Set(zmiddlewares.XForwardedProtoHeader, info.Scheme)
+	} else {
+		header.Set(zmiddlewares.XForwardedHostHeader, req.Host)
+	}
+
+	otel.GetTextMapPropagator().Inject(req.Context(), propagation.HeaderCarrier(header))
+
+	md := metadata.MD{}
+	for key, values := range header {
+		if len(values) > 0 && values[0] != "" {
+			md.Append(strings.ToLower(key), values...)
+		}
+	}
+	return md
+}
+
+func bindTranscodingBody(req *http.Request, in proto.Message, body string, unmarshalOptions protojson.UnmarshalOptions) error {
+	if body == "" || req.Body == nil {
+		return nil
+	}
+
+	data, err := io.ReadAll(req.Body)
+	if err != nil {
+		return err
+	}
+	if len(data) == 0 {
+		return nil
+	}
+
+	target := in
+	if body != TranscodeBodyAll {
+		target = mutableFieldPath(in.ProtoReflect(), body).Interface()
+	}
+	return unmarshalOptions.Unmarshal(data, target)
+}
+
+// findFieldPath resolves a dotted path of proto or JSON field names, e.g. "user.id".
+func findFieldPath(descriptor protoreflect.MessageDescriptor, path string) ([]protoreflect.FieldDescriptor, bool) {
+	names := strings.Split(path, ".")
+	fields := make([]protoreflect.FieldDescriptor, 0, len(names))
+	for i, name := range names {
+		if descriptor == nil {
+			return nil, false
+		}
+
+		field := descriptor.Fields().ByName(protoreflect.Name(name))
+		if field == nil {
+			field = descriptor.Fields().ByJSONName(name)
+		}
+		if field == nil || (i < len(names)-1 && (field.Message() == nil || field.IsList() || field.IsMap())) {
+			return nil, false
+		}
+
+		fields = append(fields, field)
+		descriptor = field.Message()
+	}
+	return fields, true
+}
+
+// mutableFieldPath returns the message at a path found by findFieldPath, allocating the missing ones.
+func mutableFieldPath(msg protoreflect.Message, path string) protoreflect.Message {
+	fields, _ := findFieldPath(msg.Descriptor(), path)
+	for _, field := range fields {
+		msg = msg.Mutable(field).Message()
+	}
+	return msg
+}
+
+func setFieldPath(msg protoreflect.Message, path string, values []string) error {
+	fields, ok := findFieldPath(msg.Descriptor(), path)
+	if !ok {
+		return fmt.Errorf("unknown field %q", path)
+	}
+
+	for _, field := range fields[:len(fields)-1] {
+		msg = msg.Mutable(field).Message()
+	}
+
+	field := fields[len(fields)-1]
+	switch {
+	case field.IsMap():
+		return fmt.Errorf("map field %q cannot be set from a parameter", path)
+	case field.IsList():
+		list := msg.Mutable(field).List()
+		for _, value := range values {
+			v, err := parseFieldValue(field, list.NewElement, value)
+			if err != nil {
+				return err
+			}
+			list.Append(v)
+		}
+	case len(values) > 0:
+		v, err := parseFieldValue(field, func() protoreflect.Value { return msg.NewField(field) }, values[len(values)-1])
+		if err != nil {
+			return err
+		}
+		msg.Set(field, v)
+	}
+	return nil
+}
+
+// parseFieldValue parses a parameter as the type of field. Message fields, such as timestamps or
+// wrappers, are parsed with their JSON mapping.
+func parseFieldValue(field protoreflect.FieldDescriptor, newValue func() protoreflect.Value, value string) (protoreflect.Value, error) {
+	switch field.Kind() {
+	case protoreflect.StringKind:
+		return protoreflect.ValueOfString(value), nil
+	case protoreflect.BoolKind:
+		b, err := strconv.ParseBool(value)
+		return protoreflect.ValueOfBool(b), err
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
+		i, err := strconv.ParseInt(value, 10, 32)
+		return protoreflect.ValueOfInt32(int32(i)), err
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		i, err := strconv.ParseInt(value, 10, 64)
+		return protoreflect.ValueOfInt64(i), err
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		u, err := strconv.ParseUint(value, 10, 32)
+		return protoreflect.ValueOfUint32(uint32(u)), err
+	case protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		u, err := strconv.ParseUint(value, 10, 64)
+		return protoreflect.ValueOfUint64(u), err
+	case protoreflect.FloatKind:
+		f, err := strconv.ParseFloat(value, 32)
+		return protoreflect.ValueOfFloat32(float32(f)), err
+	case protoreflect.DoubleKind:
+		f, err := strconv.ParseFloat(value, 64)
+		return protoreflect.ValueOfFloat64(f), err
+	case protoreflect.BytesKind:
+		b, err := base64.StdEncoding.DecodeString(value)
+		if err != nil {
+			b, err = base64.URLEncoding.DecodeString(value)
+		}
+		return protoreflect.ValueOfBytes(b), err
+	case protoreflect.EnumKind:
+		if enumValue := field.Enum().Values().ByName(protoreflect.Name(value)); enumValue != nil {
+			return protoreflect.ValueOfEnum(enumValue.Number()), nil
+		}
+		i, err := strconv.ParseInt(value, 10, 32)
+		if err != nil {
+			return protoreflect.Value{}, fmt.Errorf("invalid value %q for enum %s", value, field.Enum().FullName())
+		}
+		return protoreflect.ValueOfEnum(protoreflect.EnumNumber(i)), nil
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		v := newValue()
+		quoted, _ := json.Marshal(value)
+		if err := protojson.Unmarshal(quoted, v.Message().Interface()); err != nil {
+			if err = protojson.Unmarshal([]byte(value), v.Message().Interface()); err != nil {
+				return protoreflect.Value{}, err
+			}
+		}
+		return v, nil
+	}
+	return protoreflect.Value{}, fmt.Errorf("unsupported field kind %s", field.Kind())
+}
@@ -0,0 +1,204 @@
+package zrouter
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+	"github.com/zondax/golem/pkg/logger"
+	"github.com/zondax/golem/pkg/metrics"
+	"github.com/zondax/golem/pkg/zrouter/zmiddlewares"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+const fieldsMethod = "/test.Fields/Echo"
+
+type fieldsServer interface {
+	Echo(ctx context.Context, in *descriptorpb.FieldDescriptorProto) (*descriptorpb.FieldDescriptorProto, error)
+}
+
+// fieldsService echoes the request and records the incoming metadata.
+type fieldsService struct {
+	metadata metadata.MD
+}
+
+func (s *fieldsService) Echo(ctx context.Context, in *descriptorpb.FieldDescriptorProto) (*descriptorpb.FieldDescriptorProto, error) {
+	s.metadata, _ = metadata.FromIncomingContext(ctx)
+
+	switch in.GetName() {
+	case "missing":
+		return nil, status.Error(codes.NotFound, "field not found")
+	case "limited":
+		_ = grpc.SetHeader(ctx, metadata.Pairs("retry-after", "30"))
+		return nil, status.Error(codes.ResourceExhausted, "rate limit exceeded")
+	}
+	return in, nil
+}
+
+var fieldsServiceDesc = grpc.ServiceDesc{
+	ServiceName: "test.Fields",
+	HandlerType: (*fieldsServer)(nil),
+	Methods: []grpc.MethodDesc{{
+		MethodName: "Echo",
+		Handler: func(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+			in := new(descriptorpb.FieldDescriptorProto)
+			if err := dec(in); err != nil {
+				return nil, err
+			}
+			return srv.(fieldsServer).Echo(ctx, in)
+		},
+	}},
+}
+
+type TranscodingSuite struct {
+	suite.Suite
+	service *fieldsService
+	server  *grpc.Server
+	conn    *grpc.ClientConn
+	router  ZRouter
+}
+
+func (suite *TranscodingSuite) SetupTest() {
+	logger.InitLogger(logger.Config{})
+	metricsServer := new(metrics.MockTaskMetrics)
+	metricsServer.On("RegisterMetric", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	metricsServer.On("UpdateMetric", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	metricsServer.On("IncrementMetric", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	metricsServer.On("DecrementMetric", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+
+	suite.service = &fieldsService{}
+	suite.server = grpc.NewServer()
+	suite.server.RegisterService(&fieldsServiceDesc, suite.service)
+
+	listener := bufconn.Listen(1 << 20)
+	go func() { _ = suite.server.Serve(listener) }()
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return listener.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	suite.Require().NoError(err)
+	suite.conn = conn
+
+	newField := func() proto.Message { return new(descriptorpb.FieldDescriptorProto) }
+	suite.router = New(metricsServer, &Config{AppVersion: "app_version", AppRevision: "app_revision"})
+	suite.router.SetDefaultMiddlewares(zmiddlewares.LoggingMiddlewareOptions{})
+	suite.router.Transcode(TranscodingOptions{
+		Conn: conn,
+		Bindings: []TranscodingBinding{
+			{Method: http.MethodGet, Path: "/fields/{name}", FullMethod: fieldsMethod, NewRequest: newField, NewResponse: newField},
+			{Method: http.MethodPost, Path: "/fields/{name}", FullMethod: fieldsMethod, Body: TranscodeBodyAll, NewRequest: newField, NewResponse: newField},
+			{Method: http.MethodPut, Path: "/fields/{name}/options", FullMethod: fieldsMethod, Body: "options", NewRequest: newField, NewResponse: newField},
+		},
+	})
+}
+
+func (suite *TranscodingSuite) TearDownTest() {
+	_ = suite.conn.Close()
+	suite.server.Stop()
+}
+
+func (suite *TranscodingSuite) serve(method, path, body string, headers map[string]string) (*httptest.ResponseRecorder, map[string]interface{}) {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+	rec := httptest.NewRecorder()
+	suite.router.ServeHTTP(rec, req)
+
+	var response map[string]interface{}
+	suite.Require().NoError(json.Unmarshal(rec.Body.Bytes(), &response), rec.Body.String())
+	return rec, response
+}
+
+func (suite *TranscodingSuite) TestPathAndQueryParameters() {
+	rec, response := suite.serve(http.MethodGet, "/fields/id?number=3&type=TYPE_STRING&options.deprecated=true&cache=1", "", nil)
+	suite.Equal(http.StatusOK, rec.Code)
+	suite.Equal(map[string]interface{}{
+		"name":    "id",
+		"number":  float64(3),
+		"type":    "TYPE_STRING",
+		"options": map[string]interface{}{"deprecated": true},
+	}, response)
+
+	rec, response = suite.serve(http.MethodGet, "/fields/id?number=three", "", nil)
+	suite.Equal(http.StatusBadRequest, rec.Code)
+	suite.Equal(invalidArgumentErrorCode, response["error_code"])
+}
+
+func (suite *TranscodingSuite) TestBody() {
+	rec, response := suite.serve(http.MethodPost, "/fields/id?number=9", `{"name":"ignored","number":4,"jsonName":"identifier"}`, nil)
+	suite.Equal(http.StatusOK, rec.Code)
+	suite.Equal("id", response["name"])
+	suite.Equal(float64(4), response["number"])
+	suite.Equal("identifier", response["jsonName"])
+
+	rec, response = suite.serve(http.MethodPut, "/fields/id/options", `{"packed":true}`, nil)
+	suite.Equal(http.StatusOK, rec.Code)
+	suite.Equal(map[string]interface{}{"packed": true}, response["options"])
+
+	rec, response = suite.serve(http.MethodPost, "/fields/id", `{"unknown":1}`, nil)
+	suite.Equal(http.StatusBadRequest, rec.Code)
+	suite.Equal(invalidArgumentErrorCode, response["error_code"])
+}
+
+func (suite *TranscodingSuite) TestMetadata() {
+	rec, _ := suite.serve(http.MethodGet, "/fields/id", "", map[string]string{
+		"Authorization":                  "Bearer token",
+		zmiddlewares.RequestIDHeader:     "request-1",
+		"Traceparent":                    "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"X-Not-Forwarded":                "value",
+		zmiddlewares.XForwardedForHeader: "203.0.113.1",
+	})
+	suite.Equal(http.StatusOK, rec.Code)
+
+	md := suite.service.metadata
+	suite.Equal([]string{"Bearer token"}, md.Get("authorization"))
+	suite.Equal([]string{"request-1"}, md.Get("x-request-id"))
+	suite.Equal([]string{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"}, md.Get("traceparent"))
+	suite.Empty(md.Get("x-not-forwarded"))
+	// Without the ClientIP middleware the forwarding headers are not trusted.
+	suite.Equal([]string{"192.0.2.1"}, md.Get("x-forwarded-for"))
+	suite.Equal([]string{"example.com"}, md.Get("x-forwarded-host"))
+}
+
+func (suite *TranscodingSuite) TestErrors() {
+	rec, response := suite.serve(http.MethodGet, "/fields/missing", "", nil)
+	suite.Equal(http.StatusNotFound, rec.Code)
+	suite.Equal("not_found", response["error_code"])
+	suite.Equal("field not found", response["message"])
+
+	rec, response = suite.serve(http.MethodGet, "/fields/limited", "", nil)
+	suite.Equal(http.StatusTooManyRequests, rec.Code)
+	suite.Equal("resource_exhausted", response["error_code"])
+	suite.Equal("30", rec.Header().Get("Retry-After"))
+}
+
+func (suite *TranscodingSuite) TestInvalidBinding() {
+	newField := func() proto.Message { return new(descriptorpb.FieldDescriptorProto) }
+	suite.Panics(func() {
+		suite.router.Transcode(TranscodingOptions{Conn: suite.conn, Bindings: []TranscodingBinding{
+			{Method: http.MethodGet, Path: "/fields/{unknown}", FullMethod: fieldsMethod, NewRequest: newField, NewResponse: newField},
+		}})
+	})
+	suite.Panics(func() {
+		suite.router.Transcode(TranscodingOptions{Conn: suite.conn, Bindings: []TranscodingBinding{
+			{Method: http.MethodPut, Path: "/fields/{name}", FullMethod: fieldsMethod, Body: "name", NewRequest: newField, NewResponse: newField},
+		}})
+	})
+}
+
+func TestTranscodingSuite(t *testing.T) {
+	suite.Run(t, new(TranscodingSuite))
+}
@@ -0,0 +1,372 @@
+package zrouter
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/zondax/golem/pkg/logger"
+	"github.com/zondax/golem/pkg/zrouter/domain"
+	"hash"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+)
+
+const (
+	defaultUploadMaxFileSize  = 10 << 20
+	defaultUploadMaxTotalSize = 32 << 20
+	defaultUploadMaxFiles     = 10
+	defaultUploadMaxFieldSize = 1 << 20
+	defaultUploadTempPattern  = "upload-*"
+
+	// sniffLen is the number of bytes http.DetectContentType looks at.
+	sniffLen = 512
+
+	multipartFormData = "multipart/form-data"
+
+	uploadTooLargeErrorCode         = "request_too_large"
+	uploadFileTooLargeErrorCode     = "file_too_large"
+	uploadTooManyFilesErrorCode     = "too_many_files"
+	uploadUnsupportedMediaErrorCode = "unsupported_media_type"
+	uploadUnsupportedFileErrorCode  = "unsupported_file_type"
+	uploadInvalidMultipartErrorCode = "invalid_multipart"
+)
+
+var (
+	errUploadTotalLimit = errors.New("upload exceeds the total size limit")
+	errUploadFileLimit  = errors.New("file exceeds the size limit")
+	errUploadFieldLimit = errors.New("form field exceeds the size limit")
+)
+
+// UploadedFile describes a file part once it is stored.
+type UploadedFile struct {
+	Field    string
+	Filename string
+	// ContentType is sniffed from the content, the type declared by the client is DeclaredType.
+	ContentType  string
+	DeclaredType string
+	Size         int64
+	// Checksum is the hex encoded hash of the content, when UploadOptions.Checksum is set.
+	Checksum string
+	// Path is the file written by TempFileSink.
+	Path string
+}
+
+// UploadSink stores the files of an upload, e.g. in temporary files or an object store.
+type UploadSink interface {
+	// Store consumes the content of a file. It must discard what it wrote when content returns an error.
+	Store(ctx context.Context, file *UploadedFile, content io.Reader) error
+	// Cleanup releases the stored files when the upload fails and at the end of the request.
+	Cleanup(ctx context.Context, files []*UploadedFile) error
+}
+
+// TempFileSink writes every file to a temporary file, removed at the end of the request. Handlers that
+// keep a file must move it elsewhere.
+type TempFileSink struct {
+	// Dir defaults to os.TempDir.
+	Dir string
+}
+
+func (s TempFileSink) Store(_ context.Context, file *UploadedFile, content io.Reader) error {
+	tmp, err := os.CreateTemp(s.Dir, defaultUploadTempPattern)
+	if err != nil {
+		return err
+	}
+
+	if _, err = io.Copy(tmp, content); err == nil {
+		err = tmp.Close()
+	} else {
+		_ = tmp.Close()
+	}
+	if err != nil {
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+
+	file.Path = tmp.Name()
+	return nil
+}
+
+func (s TempFileSink) Cleanup(_ context.Context, files []*UploadedFile) error {
+	var errs []error
+	for _, file := range files {
+		if file.Path == "" {
+			continue
+		}
+		if err := os.Remove(file.Path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+type UploadOptions struct {
+	// MaxFileSize is the limit of every file. Defaults to 10MB.
+	MaxFileSize int64
+	// MaxTotalSize is the limit of the whole body. Defaults to 32MB.
+	MaxTotalSize int64
+	// MaxFiles defaults to 10.
+	MaxFiles int
+	// MaxFieldSize is the limit of every non-file field. Defaults to 1MB.
+	MaxFieldSize int64
+	// AllowedTypes are the sniffed media types accepted, such as "image/png" or "image/*". When empty,
+	// every type is accepted.
+	AllowedTypes []string
+	// Checksum computes the hash of every file, e.g. sha256.New.
+	Checksum func() hash.Hash
+	// Sink defaults to TempFileSink.
+	Sink UploadSink
+}
+
+func (o *UploadOptions) setDefaultValues() {
+	if o.MaxFileSize == 0 {
+		o.MaxFileSize = defaultUploadMaxFileSize
+	}
+
+	if o.MaxTotalSize == 0 {
+		o.MaxTotalSize = defaultUploadMaxTotalSize
+	}
+
+	if o.MaxFiles == 0 {
+		o.MaxFiles = defaultUploadMaxFiles
+	}
+
+	if o.MaxFieldSize == 0 {
+		o.MaxFieldSize = defaultUploadMaxFieldSize
+	}
+
+	if o.Sink == nil {
+		o.Sink = TempFileSink{}
+	}
+}
+
+// Upload is a parsed multipart form whose files were streamed to the sink.
+type Upload struct {
+	Fields map[string][]string
+	Files  []*UploadedFile
+
+	ctx     context.Context
+	sink    UploadSink
+	cleanup sync.Once
+}
+
+// File returns the first file of a field.
+func (u *Upload) File(field string) (*UploadedFile, bool) {
+	for _, file := range u.Files {
+		if file.Field == field {
+			return file, true
+		}
+	}
+	return nil, false
+}
+
+// Value returns the first value of a non-file field.
+func (u *Upload) Value(field string) string {
+	if values := u.Fields[field]; len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// Cleanup releases the files through the sink. It runs at the end of the request, handlers only call it
+// when they parse uploads outside a zrouter handler.
+func (u *Upload) Cleanup() error {
+	var err error
+	u.cleanup.Do(func() {
+		err = u.sink.Cleanup(u.ctx, u.Files)
+	})
+	return err
+}
+
+// ParseUpload streams the parts of a multipart/form-data request to the sink, without buffering the files
+// in memory. Limit violations return an APIError with 413, and requests that are not multipart or files of
+// a type that is not allowed return 415.
+func ParseUpload(ctx Context, options UploadOptions) (*Upload, error) {
+	options.setDefaultValues()
+	req := ctx.Request()
+
+	mediaType, params, err := mime.ParseMediaType(req.Header.Get(domain.ContentTypeHeader))
+	if err != nil || mediaType != multipartFormData || params["boundary"] == "" {
+		return nil, domain.NewAPIErrorResponse(http.StatusUnsupportedMediaType, uploadUnsupportedMediaErrorCode, "request must be multipart/form-data")
+	}
+
+	upload := &Upload{Fields: map[string][]string{}, ctx: req.Context(), sink: options.Sink}
+	body := &limitedReader{r: req.Body, remaining: options.MaxTotalSize, err: errUploadTotalLimit}
+	reader := multipart.NewReader(body, params["boundary"])
+
+	if err = upload.readParts(reader, options); err != nil {
+		if cleanupErr := upload.Cleanup(); cleanupErr != nil {
+			logger.GetLoggerFromContext(req.Context()).Errorf("Error cleaning up upload: %v", cleanupErr)
+		}
+		return nil, uploadError(err)
+	}
+
+	if adapter, ok := ctx.(interface{ addCleanup(func()) }); ok {
+		adapter.addCleanup(func() {
+			if err := upload.Cleanup(); err != nil {
+				logger.GetLoggerFromContext(upload.ctx).Errorf("Error cleaning up upload: %v", err)
+			}
+		})
+	}
+	return upload, nil
+}
+
+func (u *Upload) readParts(reader *multipart.Reader, options UploadOptions) error {
+	for {
+		part, err := reader.NextPart()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if part.FileName() == "" {
+			err = u.readField(part, options.MaxFieldSize)
+		} else {
+			err = u.readFile(part, options)
+		}
+		_ = part.Close()
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func (u *Upload) readField(part *multipart.Part, maxSize int64) error {
+	value, err := io.ReadAll(&limitedReader{r: part, remaining: maxSize, err: errUploadFieldLimit})
+	if err != nil {
+		return err
+	}
+	u.Fields[part.FormName()] = append(u.Fields[part.FormName()], string(value))
+	return nil
+}
+
+func (u *Upload) readFile(part *multipart.Part, options UploadOptions) error {
+	if len(u.Files) >= options.MaxFiles {
+		return domain.NewAPIErrorResponse(http.StatusRequestEntityTooLarge, uploadTooManyFilesErrorCode, fmt.Sprintf("at most %d files are accepted", options.MaxFiles))
+	}
+
+	content := &limitedReader{r: part, remaining: options.MaxFileSize, err: errUploadFileLimit}
+
+	head := make([]byte, sniffLen)
+	n, err := io.ReadFull(content, head)
+	if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return err
+	}
+	head = head[:n]
+
+	file := &UploadedFile{
+		Field:        part.FormName(),
+		Filename:     part.FileName(),
+		ContentType:  mediaTypeOnly(http.DetectContentType(head)),
+		DeclaredType: part.Header.Get(domain.ContentTypeHeader),
+	}
+	if !isAllowedType(file.ContentType, options.AllowedTypes) {
+		return domain.NewAPIErrorResponse(http.StatusUnsupportedMediaType, uploadUnsupportedFileErrorCode, fmt.Sprintf("file type %s is not allowed", file.ContentType))
+	}
+
+	var reader io.Reader = io.MultiReader(bytes.NewReader(head), content)
+	var hasher hash.Hash
+	if options.Checksum != nil {
+		hasher = options.Checksum()
+		reader = io.TeeReader(reader, hasher)
+	}
+	counter := &countingReader{r: reader}
+
+	if err = options.Sink.Store(u.ctx, file, counter); err != nil {
+		return err
+	}
+	// The file is only part of the upload once stored, so a failed Store is not cleaned up twice.
+	u.Files = append(u.Files, file)
+
+	// Sinks may stop reading early, the rest still counts against the limits and the checksum.
+	if _, err = io.Copy(io.Discard, counter); err != nil {
+		return err
+	}
+
+	file.Size = counter.n
+	if hasher != nil {
+		file.Checksum = hex.EncodeToString(hasher.Sum(nil))
+	}
+	return nil
+}
+
+func uploadError(err error) error {
+	var apiErr *domain.APIError
+	var maxBytesErr *http.MaxBytesError
+	switch {
+	case errors.As(err, &apiErr):
+		return apiErr
+	case errors.Is(err, errUploadFileLimit):
+		return domain.NewAPIErrorResponse(http.StatusRequestEntityTooLarge, uploadFileTooLargeErrorCode, errUploadFileLimit.Error())
+	case errors.Is(err, errUploadFieldLimit):
+		return domain.NewAPIErrorResponse(http.StatusRequestEntityTooLarge, uploadTooLargeErrorCode, errUploadFieldLimit.Error())
+	case errors.Is(err, errUploadTotalLimit), errors.As(err, &maxBytesErr):
+		return domain.NewAPIErrorResponse(http.StatusRequestEntityTooLarge, uploadTooLargeErrorCode, errUploadTotalLimit.Error())
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return err
+	}
+	return domain.NewAPIErrorResponse(http.StatusBadRequest, uploadInvalidMultipartErrorCode, "invalid multipart body", err.Error())
+}
+
+func isAllowedType(contentType string, allowed []string) bool {
+	if len(allowed) == 0 {
+		return true
+	}
+
+	for _, pattern := range allowed {
+		if pattern == contentType {
+			return true
+		}
+		if prefix, ok := strings.CutSuffix(pattern, "/*"); ok && strings.HasPrefix(contentType, prefix+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+func mediaTypeOnly(contentType string) string {
+	mediaType, _, _ := strings.Cut(contentType, ";")
+	return strings.TrimSpace(mediaType)
+}
+
+// limitedReader fails with err once more than remaining bytes are read.
+type limitedReader struct {
+	r         io.Reader
+	remaining int64
+	err       error
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+	if l.remaining < 0 {
+		return 0, l.err
+	}
+	if int64(len(p)) > l.remaining+1 {
+		p = p[:l.remaining+1]
+	}
+
+	n, err := l.r.Read(p)
+	l.remaining -= int64(n)
+	if l.remaining < 0 {
+		return n + int(l.remaining), l.err
+	}
+	return n, err
+}
+
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,181 @@
+package zrouter
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"github.com/stretchr/testify/suite"
+	"github.com/zondax/golem/pkg/zrouter/domain"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+)
+
+var pngHeader = []byte("\x89PNG\r\n\x1a\n")
+
+type memorySink struct {
+	stored  map[string][]byte
+	cleaned []*UploadedFile
+}
+
+func (s *memorySink) Store(_ context.Context, file *UploadedFile, content io.Reader) error {
+	data, err := io.ReadAll(content)
+	if err != nil {
+		return err
+	}
+	s.stored[file.Filename] = data
+	return nil
+}
+
+func (s *memorySink) Cleanup(_ context.Context, files []*UploadedFile) error {
+	s.cleaned = append(s.cleaned, files...)
+	return nil
+}
+
+type UploadSuite struct {
+	suite.Suite
+}
+
+type uploadPart struct {
+	field    string
+	filename string
+	content  []byte
+}
+
+func (suite *UploadSuite) newRequest(parts ...uploadPart) *http.Request {
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	for _, part := range parts {
+		if part.filename == "" {
+			suite.Require().NoError(writer.WriteField(part.field, string(part.content)))
+			continue
+		}
+		w, err := writer.CreateFormFile(part.field, part.filename)
+		suite.Require().NoError(err)
+		_, err = w.Write(part.content)
+		suite.Require().NoError(err)
+	}
+	suite.Require().NoError(writer.Close())
+
+	req := httptest.NewRequest(http.MethodPost, "/upload", &body)
+	req.Header.Set(domain.ContentTypeHeader, writer.FormDataContentType())
+	return req
+}
+
+func (suite *UploadSuite) serve(options UploadOptions, req *http.Request, inspect func(upload *Upload)) *httptest.ResponseRecorder {
+	handler := getChiHandler(func(ctx Context) (domain.ServiceResponse, error) {
+		upload, err := ParseUpload(ctx, options)
+		if err != nil {
+			return nil, err
+		}
+		inspect(upload)
+		return domain.NewServiceResponse(http.StatusCreated, len(upload.Files)), nil
+	}, responseSettings{})
+
+	rec := httptest.NewRecorder()
+	handler(rec, req)
+	return rec
+}
+
+func (suite *UploadSuite) errorCode(rec *httptest.ResponseRecorder) string {
+	var apiError domain.APIError
+	suite.Require().NoError(json.Unmarshal(rec.Body.Bytes(), &apiError), rec.Body.String())
+	return apiError.ErrorCode
+}
+
+func (suite *UploadSuite) TestStreamsToTempFiles() {
+	image := append(append([]byte{}, pngHeader...), bytes.Repeat([]byte{1}, 1024)...)
+	checksum := sha256.Sum256(image)
+
+	var path string
+	req := suite.newRequest(uploadPart{field: "title", content: []byte("avatar")}, uploadPart{field: "image", filename: "avatar.png", content: image})
+	rec := suite.serve(UploadOptions{AllowedTypes: []string{"image/*"}, Checksum: sha256.New}, req, func(upload *Upload) {
+		suite.Equal("avatar", upload.Value("title"))
+
+		file, ok := upload.File("image")
+		suite.Require().True(ok)
+		suite.Equal("avatar.png", file.Filename)
+		suite.Equal("image/png", file.ContentType)
+		suite.Equal("application/octet-stream", file.DeclaredType)
+		suite.Equal(int64(len(image)), file.Size)
+		suite.Equal(hex.EncodeToString(checksum[:]), file.Checksum)
+
+		stored, err := os.ReadFile(file.Path)
+		suite.Require().NoError(err)
+		suite.Equal(image, stored)
+		path = file.Path
+	})
+	suite.Equal(http.StatusCreated, rec.Code)
+
+	_, err := os.Stat(path)
+	suite.True(os.IsNotExist(err), "temporary file is removed at the end of the request")
+}
+
+func (suite *UploadSuite) TestLimits() {
+	image := append(append([]byte{}, pngHeader...), bytes.Repeat([]byte{1}, 2048)...)
+	noop := func(*Upload) { suite.Fail("upload should be rejected") }
+
+	rec := suite.serve(UploadOptions{MaxFileSize: 1024}, suite.newRequest(uploadPart{field: "image", filename: "a.png", content: image}), noop)
+	suite.Equal(http.StatusRequestEntityTooLarge, rec.Code)
+	suite.Equal(uploadFileTooLargeErrorCode, suite.errorCode(rec))
+
+	rec = suite.serve(UploadOptions{MaxTotalSize: 3000}, suite.newRequest(
+		uploadPart{field: "image", filename: "a.png", content: image},
+		uploadPart{field: "image", filename: "b.png", content: image},
+	), noop)
+	suite.Equal(http.StatusRequestEntityTooLarge, rec.Code)
+	suite.Equal(uploadTooLargeErrorCode, suite.errorCode(rec))
+
+	rec = suite.serve(UploadOptions{MaxFiles: 1}, suite.newRequest(
+		uploadPart{field: "image", filename: "a.png", content: image},
+		uploadPart{field: "image", filename: "b.png", content: image},
+	), noop)
+	suite.Equal(http.StatusRequestEntityTooLarge, rec.Code)
+	suite.Equal(uploadTooManyFilesErrorCode, suite.errorCode(rec))
+
+	rec = suite.serve(UploadOptions{MaxFieldSize: 4}, suite.newRequest(uploadPart{field: "title", content: []byte("too long")}), noop)
+	suite.Equal(http.StatusRequestEntityTooLarge, rec.Code)
+	suite.Equal(uploadTooLargeErrorCode, suite.errorCode(rec))
+}
+
+func (suite *UploadSuite) TestMediaTypes() {
+	noop := func(*Upload) { suite.Fail("upload should be rejected") }
+
+	rec := suite.serve(UploadOptions{AllowedTypes: []string{"image/png"}}, suite.newRequest(uploadPart{field: "file", filename: "a.png", content: []byte("plain text")}), noop)
+	suite.Equal(http.StatusUnsupportedMediaType, rec.Code)
+	suite.Equal(uploadUnsupportedFileErrorCode, suite.errorCode(rec))
+
+	req := httptest.NewRequest(http.MethodPost, "/upload", bytes.NewReader([]byte(`{}`)))
+	req.Header.Set(domain.ContentTypeHeader, "application/json")
+	rec = suite.serve(UploadOptions{}, req, noop)
+	suite.Equal(http.StatusUnsupportedMediaType, rec.Code)
+	suite.Equal(uploadUnsupportedMediaErrorCode, suite.errorCode(rec))
+}
+
+func (suite *UploadSuite) TestCustomSink() {
+	sink := &memorySink{stored: map[string][]byte{}}
+	req := suite.newRequest(uploadPart{field: "a", filename: "a.txt", content: []byte("first")}, uploadPart{field: "b", filename: "b.txt", content: []byte("second")})
+	rec := suite.serve(UploadOptions{Sink: sink}, req, func(upload *Upload) {
+		suite.Len(upload.Files, 2)
+		suite.Empty(sink.cleaned)
+	})
+	suite.Equal(http.StatusCreated, rec.Code)
+	suite.Equal(map[string][]byte{"a.txt": []byte("first"), "b.txt": []byte("second")}, sink.stored)
+	suite.Len(sink.cleaned, 2)
+
+	// Files stored before a limit violation are cleaned up straight away.
+	sink = &memorySink{stored: map[string][]byte{}}
+	req = suite.newRequest(uploadPart{field: "a", filename: "a.txt", content: []byte("first")}, uploadPart{field: "b", filename: "b.txt", content: []byte("second")})
+	rec = suite.serve(UploadOptions{Sink: sink, MaxFiles: 1}, req, func(*Upload) {})
+	suite.Equal(http.StatusRequestEntityTooLarge, rec.Code)
+	suite.Len(sink.cleaned, 1)
+}
+
+func TestUploadSuite(t *testing.T) {
+	suite.Run(t, new(UploadSuite))
+}
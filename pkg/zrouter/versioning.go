@@ -0,0 +1,113 @@
+package zrouter
+
+import (
+	"fmt"
+	"github.com/go-chi/chi/v5"
+	"github.com/zondax/golem/pkg/zrouter/zmiddlewares"
+	"net/http"
+	"sort"
+	"sync"
+)
+
+const (
+	versionParam      = "version"
+	versionPathPrefix = "/{" + versionParam + "}"
+)
+
+// versionedRoute serves a method and path with the handler of the nearest version, declared by the
+// route, that is not newer than the requested one.
+type versionedRoute struct {
+	mutex    sync.RWMutex
+	router   *chi.Mux
+	versions []string
+	handlers map[string]http.Handler
+}
+
+func (v *versionedRoute) add(version string, handler http.Handler) {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+
+	if _, ok := v.handlers[version]; ok {
+		panic(fmt.Sprintf("version %s of the route is already registered", version))
+	}
+	v.handlers[version] = handler
+	v.versions = append(v.versions, version)
+	sort.Slice(v.versions, func(i, j int) bool {
+		return zmiddlewares.CompareAPIVersions(v.versions[i], v.versions[j]) < 0
+	})
+}
+
+func (v *versionedRoute) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	var handler http.Handler
+	if requested, ok := zmiddlewares.APIVersionFromContext(req.Context()); ok {
+		v.mutex.RLock()
+		if version, found := zmiddlewares.ResolveAPIVersion(requested, v.versions); found {
+			handler = v.handlers[version]
+		}
+		v.mutex.RUnlock()
+	}
+
+	if handler == nil {
+		v.router.NotFoundHandler().ServeHTTP(w, req)
+		return
+	}
+	handler.ServeHTTP(w, req)
+}
+
+// handleVersioned registers a handler for the given versions of a route. With the URL prefix strategy
+// the path is prefixed with a {version} parameter. It returns the registered path and the normalized
+// versions in ascending order.
+func (r *zrouter) handleVersioned(method, path string, handler http.HandlerFunc, versions []string, middlewares ...zmiddlewares.Middleware) (string, []string) {
+	options := r.config.Versioning.Options
+	if !r.config.Versioning.Enable {
+		panic("route versions require Config.Versioning to be enabled")
+	}
+
+	supported := make(map[string]struct{}, len(options.Versions))
+	for _, version := range options.Versions {
+		supported[zmiddlewares.NormalizeAPIVersion(version)] = struct{}{}
+	}
+
+	normalized := make([]string, len(versions))
+	for i, version := range versions {
+		normalized[i] = zmiddlewares.NormalizeAPIVersion(version)
+		if _, ok := supported[normalized[i]]; !ok {
+			panic(fmt.Sprintf("route version %q is not a supported API version", version))
+		}
+	}
+	sort.Slice(normalized, func(i, j int) bool {
+		return zmiddlewares.CompareAPIVersions(normalized[i], normalized[j]) < 0
+	})
+
+	if options.Strategy == "" || options.Strategy == zmiddlewares.VersionStrategyURLPrefix {
+		path = joinRoutePath(versionPathPrefix, path)
+	}
+
+	key := method + " " + path
+	r.mutex.Lock()
+	if r.versionedRoutes == nil {
+		r.versionedRoutes = make(map[string]*versionedRoute)
+	}
+	route, found := r.versionedRoutes[key]
+	if !found {
+		route = &versionedRoute{router: r.router, handlers: make(map[string]http.Handler)}
+		r.versionedRoutes[key] = route
+	}
+	r.mutex.Unlock()
+
+	// The version is resolved by the default middlewares, so the dispatcher runs inside them and the
+	// route middlewares inside the dispatcher.
+	if !found {
+		r.router.Method(method, path, r.applyMiddlewares(route.ServeHTTP))
+	}
+
+	var versionHandler http.Handler = handler
+	for _, mw := range middlewares {
+		versionHandler = mw(versionHandler)
+	}
+	for _, version := range normalized {
+		route.add(version, versionHandler)
+	}
+
+	return path, normalized
+}
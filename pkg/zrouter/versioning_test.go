@@ -0,0 +1,119 @@
+package zrouter
+
+import (
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+	"github.com/zondax/golem/pkg/logger"
+	"github.com/zondax/golem/pkg/metrics"
+	"github.com/zondax/golem/pkg/zrouter/domain"
+	"github.com/zondax/golem/pkg/zrouter/zmiddlewares"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+type VersioningSuite struct {
+	suite.Suite
+	metrics *metrics.MockTaskMetrics
+}
+
+func (suite *VersioningSuite) SetupTest() {
+	logger.InitLogger(logger.Config{})
+	suite.metrics = new(metrics.MockTaskMetrics)
+	suite.metrics.On("RegisterMetric", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	suite.metrics.On("UpdateMetric", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	suite.metrics.On("IncrementMetric", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	suite.metrics.On("DecrementMetric", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+}
+
+func (suite *VersioningSuite) newRouter(options zmiddlewares.VersioningOptions) ZRouter {
+	router := New(suite.metrics, &Config{
+		AppVersion:  "app_version",
+		AppRevision: "app_revision",
+		Versioning:  VersioningConfig{Enable: true, Options: options},
+	})
+	router.SetDefaultMiddlewares(zmiddlewares.LoggingMiddlewareOptions{})
+	return router
+}
+
+func versionHandler(version string) HandlerFunc {
+	return func(ctx Context) (domain.ServiceResponse, error) {
+		return domain.NewServiceResponse(http.StatusOK, version+":"+ctx.Param("id")), nil
+	}
+}
+
+func (suite *VersioningSuite) serve(router ZRouter, path string, headers map[string]string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	return rec
+}
+
+func (suite *VersioningSuite) TestURLPrefixFallsBackToOlderVersion() {
+	router := suite.newRouter(zmiddlewares.VersioningOptions{
+		Versions:     []string{"v1", "v2", "v3"},
+		Deprecations: map[string]zmiddlewares.VersionDeprecation{"v1": {Sunset: time.Date(2026, time.January, 1, 0, 0, 0, 0, time.UTC)}},
+	})
+	router.RouteWithOptions(http.MethodGet, "/users/{id}", versionHandler("v1"), RouteOptions{Name: "user", Versions: []string{"v1"}})
+	router.RouteWithOptions(http.MethodGet, "/users/{id}", versionHandler("v3"), RouteOptions{Versions: []string{"3"}})
+	router.GET("/health", versionHandler("none"))
+
+	rec := suite.serve(router, "/v1/users/7", nil)
+	suite.Equal(http.StatusOK, rec.Code)
+	suite.Contains(rec.Body.String(), "v1:7")
+	suite.Equal("true", rec.Header().Get(zmiddlewares.DeprecationHeader))
+	suite.Equal("Thu, 01 Jan 2026 00:00:00 GMT", rec.Header().Get(zmiddlewares.SunsetHeader))
+
+	rec = suite.serve(router, "/v2/users/7", nil)
+	suite.Contains(rec.Body.String(), "v1:7")
+	suite.Empty(rec.Header().Get(zmiddlewares.DeprecationHeader))
+
+	rec = suite.serve(router, "/v3/users/7", nil)
+	suite.Contains(rec.Body.String(), "v3:7")
+
+	suite.Equal(http.StatusNotFound, suite.serve(router, "/v4/users/7", nil).Code)
+	suite.Equal(http.StatusOK, suite.serve(router, "/health", nil).Code)
+
+	path, err := router.URLFor("user", map[string]string{"id": "7"})
+	suite.NoError(err)
+	suite.Equal("/v1/users/7", path)
+
+	suite.Contains(router.GetRegisteredRoutes(), RegisteredRoute{
+		Method:   http.MethodGet,
+		Path:     "/{version}/users/{id}",
+		Versions: []string{"v3"},
+	})
+	suite.metrics.AssertCalled(suite.T(), "UpdateMetric", "total_requests", float64(1), "/{version}/*", http.MethodGet, "/{version}/users/{id}", "200", "v2")
+}
+
+func (suite *VersioningSuite) TestHeaderStrategy() {
+	router := suite.newRouter(zmiddlewares.VersioningOptions{
+		Strategy: zmiddlewares.VersionStrategyHeader,
+		Versions: []string{"v1", "v2"},
+		Default:  "v1",
+	})
+	api := router.Group("/api")
+	api.RouteWithOptions(http.MethodGet, "/users/{id}", versionHandler("v1"), RouteOptions{Versions: []string{"v1"}})
+	api.RouteWithOptions(http.MethodGet, "/users/{id}", versionHandler("v2"), RouteOptions{Versions: []string{"v2"}})
+	api.RouteWithOptions(http.MethodGet, "/orders", versionHandler("v2"), RouteOptions{Versions: []string{"v2"}})
+
+	suite.Contains(suite.serve(router, "/api/users/1", nil).Body.String(), "v1:1")
+	suite.Contains(suite.serve(router, "/api/users/1", map[string]string{zmiddlewares.APIVersionHeader: "2"}).Body.String(), "v2:1")
+	suite.Equal(http.StatusBadRequest, suite.serve(router, "/api/users/1", map[string]string{zmiddlewares.APIVersionHeader: "v9"}).Code)
+	suite.Equal(http.StatusNotFound, suite.serve(router, "/api/orders", nil).Code)
+
+	suite.Panics(func() {
+		api.RouteWithOptions(http.MethodGet, "/users/{id}", versionHandler("v2"), RouteOptions{Versions: []string{"v2"}})
+	})
+	suite.Panics(func() {
+		api.RouteWithOptions(http.MethodGet, "/teams", versionHandler("v5"), RouteOptions{Versions: []string{"v5"}})
+	})
+}
+
+func TestVersioningSuite(t *testing.T) {
+	suite.Run(t, new(VersioningSuite))
+}
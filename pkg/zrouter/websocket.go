@@ -0,0 +1,331 @@
+package zrouter
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/gorilla/websocket"
+	"github.com/zondax/golem/pkg/logger"
+	"github.com/zondax/golem/pkg/zrouter/zmiddlewares"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	TextMessage   = websocket.TextMessage
+	BinaryMessage = websocket.BinaryMessage
+
+	defaultWSReadLimit  = 1 << 20
+	defaultWSPongWait   = 60 * time.Second
+	defaultWSWriteWait  = 10 * time.Second
+	defaultWSBufferSize = 1024
+)
+
+type WebSocketConfig struct {
+	// ReadLimit is the maximum size in bytes of an incoming message. Defaults to 1MiB.
+	ReadLimit int64
+	// PongWait is how long to wait for a pong before considering the peer gone. Defaults to 60s.
+	PongWait time.Duration
+	// PingInterval defaults to 90% of PongWait and must be lower than it.
+	PingInterval time.Duration
+	// WriteWait bounds every write, including broadcasts. Defaults to 10s.
+	WriteWait       time.Duration
+	ReadBufferSize  int
+	WriteBufferSize int
+	// CheckOrigin defaults to accepting only same-origin requests.
+	CheckOrigin func(r *http.Request) bool
+}
+
+func (c *WebSocketConfig) setDefaultValues() {
+	if c.ReadLimit == 0 {
+		c.ReadLimit = defaultWSReadLimit
+	}
+
+	if c.PongWait == 0 {
+		c.PongWait = defaultWSPongWait
+	}
+
+	if c.PingInterval == 0 || c.PingInterval >= c.PongWait {
+		c.PingInterval = c.PongWait * 9 / 10
+	}
+
+	if c.WriteWait == 0 {
+		c.WriteWait = defaultWSWriteWait
+	}
+
+	if c.ReadBufferSize == 0 {
+		c.ReadBufferSize = defaultWSBufferSize
+	}
+
+	if c.WriteBufferSize == 0 {
+		c.WriteBufferSize = defaultWSBufferSize
+	}
+}
+
+type WSHandlerFunc func(conn *WSConn) error
+
+// WSConn is an upgraded WebSocket connection. Its context derives from the request one, so it carries
+// the request-scoped logger, and it is cancelled when the connection is closed. Writes are safe for
+// concurrent use; reads must happen from a single goroutine.
+type WSConn struct {
+	conn      *websocket.Conn
+	ctx       context.Context
+	cancel    context.CancelFunc
+	request   *http.Request
+	config    WebSocketConfig
+	writeMu   sync.Mutex
+	closeOnce sync.Once
+}
+
+func newWSConn(conn *websocket.Conn, req *http.Request, config WebSocketConfig) *WSConn {
+	ctx, cancel := context.WithCancel(req.Context())
+	wsConn := &WSConn{
+		conn:    conn,
+		ctx:     ctx,
+		cancel:  cancel,
+		request: req,
+		config:  config,
+	}
+
+	conn.SetReadLimit(config.ReadLimit)
+	_ = conn.SetReadDeadline(time.Now().Add(config.PongWait))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(config.PongWait))
+	})
+
+	return wsConn
+}
+
+func (c *WSConn) Context() context.Context {
+	return c.ctx
+}
+
+func (c *WSConn) Request() *http.Request {
+	return c.request
+}
+
+// Done is closed when the connection is closed.
+func (c *WSConn) Done() <-chan struct{} {
+	return c.ctx.Done()
+}
+
+func (c *WSConn) ReadMessage() (int, []byte, error) {
+	return c.conn.ReadMessage()
+}
+
+func (c *WSConn) ReadJSON(v interface{}) error {
+	return c.conn.ReadJSON(v)
+}
+
+func (c *WSConn) WriteMessage(messageType int, data []byte) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	if err := c.conn.SetWriteDeadline(time.Now().Add(c.config.WriteWait)); err != nil {
+		return err
+	}
+	return c.conn.WriteMessage(messageType, data)
+}
+
+func (c *WSConn) WriteJSON(v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return c.WriteMessage(TextMessage, data)
+}
+
+// Listen reads messages until the peer closes the connection, passing them to onMessage when not nil.
+// Push-only handlers must still call it, since control frames (pongs, close) are processed while reading.
+func (c *WSConn) Listen(onMessage func(messageType int, data []byte) error) error {
+	for {
+		messageType, data, err := c.conn.ReadMessage()
+		if err != nil {
+			if isExpectedWSClose(err) || c.ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+
+		if onMessage == nil {
+			continue
+		}
+
+		if err = onMessage(messageType, data); err != nil {
+			return err
+		}
+	}
+}
+
+func (c *WSConn) Close() error {
+	return c.closeWithCode(websocket.CloseNormalClosure, "")
+}
+
+func (c *WSConn) closeWithCode(code int, text string) error {
+	var err error
+	c.closeOnce.Do(func() {
+		c.cancel()
+
+		deadline := time.Now().Add(c.config.WriteWait)
+		_ = c.conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, text), deadline)
+		err = c.conn.Close()
+	})
+	return err
+}
+
+func (c *WSConn) keepAlive() {
+	ticker := time.NewTicker(c.config.PingInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := c.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(c.config.WriteWait)); err != nil {
+				_ = c.closeWithCode(websocket.CloseGoingAway, "")
+				return
+			}
+		case <-c.ctx.Done():
+			return
+		}
+	}
+}
+
+func isExpectedWSClose(err error) bool {
+	return websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway, websocket.CloseNoStatusReceived, websocket.CloseAbnormalClosure) ||
+		errors.Is(err, websocket.ErrCloseSent)
+}
+
+// WSHub keeps track of a set of connections and broadcasts messages to all of them.
+type WSHub struct {
+	mu    sync.RWMutex
+	conns map[*WSConn]struct{}
+}
+
+func NewWSHub() *WSHub {
+	return &WSHub{conns: make(map[*WSConn]struct{})}
+}
+
+// Add registers the connection until it is closed.
+func (h *WSHub) Add(conn *WSConn) {
+	h.mu.Lock()
+	h.conns[conn] = struct{}{}
+	h.mu.Unlock()
+
+	go func() {
+		<-conn.Done()
+		h.Remove(conn)
+	}()
+}
+
+func (h *WSHub) Remove(conn *WSConn) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.conns, conn)
+}
+
+func (h *WSHub) Count() int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return len(h.conns)
+}
+
+// Broadcast sends the message to every connection and returns how many received it.
+// Connections that fail to receive it are closed and removed.
+func (h *WSHub) Broadcast(messageType int, data []byte) int {
+	h.mu.RLock()
+	conns := make([]*WSConn, 0, len(h.conns))
+	for conn := range h.conns {
+		conns = append(conns, conn)
+	}
+	h.mu.RUnlock()
+
+	delivered := 0
+	for _, conn := range conns {
+		if err := conn.WriteMessage(messageType, data); err != nil {
+			logger.GetLoggerFromContext(conn.Context()).Debugf("Dropping WebSocket connection after failed broadcast: %v", err)
+			_ = conn.closeWithCode(websocket.CloseGoingAway, "")
+			h.Remove(conn)
+			continue
+		}
+		delivered++
+	}
+
+	return delivered
+}
+
+func (h *WSHub) BroadcastJSON(v interface{}) (int, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return 0, err
+	}
+	return h.Broadcast(TextMessage, data), nil
+}
+
+func (r *zrouter) WS(path string, handler WSHandlerFunc, middlewares ...zmiddlewares.Middleware) Routes {
+	config := WebSocketConfig{}
+	if r.config != nil {
+		config = r.config.WebSocket
+	}
+	config.setDefaultValues()
+
+	upgrader := websocket.Upgrader{
+		ReadBufferSize:  config.ReadBufferSize,
+		WriteBufferSize: config.WriteBufferSize,
+		CheckOrigin:     config.CheckOrigin,
+	}
+
+	wsHandler := func(w http.ResponseWriter, req *http.Request) {
+		conn, err := upgrader.Upgrade(w, req, upgradeResponseHeader(w.Header()))
+		if err != nil {
+			// The upgrader already replied to the client
+			logger.GetLoggerFromContext(req.Context()).Debugf("WebSocket upgrade failed: %v", err)
+			return
+		}
+
+		wsConn := newWSConn(conn, req, config)
+		r.trackWebSocketConnection(req.Context(), path, true)
+		defer r.trackWebSocketConnection(req.Context(), path, false)
+
+		go wsConn.keepAlive()
+
+		if err = handler(wsConn); err != nil {
+			logger.GetLoggerFromContext(req.Context()).Errorf("WebSocket handler error: %v", err)
+			_ = wsConn.closeWithCode(websocket.CloseInternalServerErr, "")
+			return
+		}
+
+		_ = wsConn.Close()
+	}
+
+	r.handle(http.MethodGet, path, wsHandler, middlewares...)
+	return r
+}
+
+// upgradeResponseHeader keeps the headers set by middlewares (request ID, CORS...) in the handshake
+// response, which the upgrader writes directly on the hijacked connection.
+func upgradeResponseHeader(header http.Header) http.Header {
+	responseHeader := header.Clone()
+	responseHeader.Del("Sec-Websocket-Extensions")
+	return responseHeader
+}
+
+func (r *zrouter) trackWebSocketConnection(ctx context.Context, path string, opened bool) {
+	if r.metricsServer == nil {
+		return
+	}
+
+	if !opened {
+		if err := r.metricsServer.DecrementMetric(zmiddlewares.WebSocketConnectionsMetricName, path); err != nil {
+			logger.GetLoggerFromContext(ctx).Errorf("error updating websocket connections metric: %v", err.Error())
+		}
+		return
+	}
+
+	if err := r.metricsServer.IncrementMetric(zmiddlewares.WebSocketConnectionsMetricName, path); err != nil {
+		logger.GetLoggerFromContext(ctx).Errorf("error updating websocket connections metric: %v", err.Error())
+	}
+	if err := r.metricsServer.IncrementMetric(zmiddlewares.WebSocketConnectionsTotalMetricName, path); err != nil {
+		logger.GetLoggerFromContext(ctx).Errorf("error updating websocket connections total metric: %v", err.Error())
+	}
+}
@@ -0,0 +1,133 @@
+package zrouter
+
+import (
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+	"github.com/zondax/golem/pkg/logger"
+	"github.com/zondax/golem/pkg/metrics"
+	"github.com/zondax/golem/pkg/zrouter/zmiddlewares"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+type WebSocketSuite struct {
+	suite.Suite
+	metrics *metrics.MockTaskMetrics
+	router  ZRouter
+	server  *httptest.Server
+}
+
+func (suite *WebSocketSuite) SetupTest() {
+	logger.InitLogger(logger.Config{})
+	suite.metrics = &metrics.MockTaskMetrics{}
+	suite.metrics.On("IncrementMetric", mock.Anything, mock.Anything).Return(nil)
+	suite.metrics.On("DecrementMetric", mock.Anything, mock.Anything).Return(nil)
+
+	suite.router = New(suite.metrics, &Config{
+		AppVersion:      "app_version",
+		AppRevision:     "app_revision",
+		EnableRequestID: true,
+		WebSocket:       WebSocketConfig{ReadLimit: 16, PongWait: time.Second},
+	})
+	suite.router.Use(zmiddlewares.RequestID())
+}
+
+func (suite *WebSocketSuite) TearDownTest() {
+	if suite.server != nil {
+		suite.server.Close()
+		suite.server = nil
+	}
+}
+
+func (suite *WebSocketSuite) dial(path string) *websocket.Conn {
+	if suite.server == nil {
+		suite.server = httptest.NewServer(suite.router.GetHandler())
+	}
+
+	url := "ws" + strings.TrimPrefix(suite.server.URL, "http") + path
+	conn, resp, err := websocket.DefaultDialer.Dial(url, nil)
+	suite.Require().NoError(err)
+	suite.Require().NoError(resp.Body.Close())
+	suite.NotEmpty(resp.Header.Get(zmiddlewares.RequestIDHeader))
+	return conn
+}
+
+func (suite *WebSocketSuite) TestEcho() {
+	suite.router.WS("/echo", func(conn *WSConn) error {
+		suite.NotNil(logger.GetLoggerFromContext(conn.Context()))
+		return conn.Listen(func(messageType int, data []byte) error {
+			return conn.WriteMessage(messageType, data)
+		})
+	})
+
+	conn := suite.dial("/echo")
+	defer conn.Close()
+
+	suite.Require().NoError(conn.WriteMessage(websocket.TextMessage, []byte("hello")))
+	_, data, err := conn.ReadMessage()
+	suite.Require().NoError(err)
+	suite.Equal("hello", string(data))
+
+	suite.Contains(suite.router.GetRegisteredRoutes(), RegisteredRoute{Method: "GET", Path: "/echo"})
+	suite.metrics.AssertCalled(suite.T(), "IncrementMetric", zmiddlewares.WebSocketConnectionsMetricName, "/echo")
+	suite.metrics.AssertCalled(suite.T(), "IncrementMetric", zmiddlewares.WebSocketConnectionsTotalMetricName, "/echo")
+}
+
+func (suite *WebSocketSuite) TestReadLimit() {
+	suite.router.WS("/limited", func(conn *WSConn) error {
+		return conn.Listen(nil)
+	})
+
+	conn := suite.dial("/limited")
+	defer conn.Close()
+
+	suite.Require().NoError(conn.WriteMessage(websocket.TextMessage, []byte(strings.Repeat("x", 64))))
+	_, _, err := conn.ReadMessage()
+	suite.True(websocket.IsCloseError(err, websocket.CloseMessageTooBig, websocket.CloseInternalServerErr))
+}
+
+func (suite *WebSocketSuite) TestHubBroadcast() {
+	hub := NewWSHub()
+	suite.router.WS("/feed", func(conn *WSConn) error {
+		hub.Add(conn)
+		return conn.Listen(nil)
+	})
+
+	first := suite.dial("/feed")
+	defer first.Close()
+	second := suite.dial("/feed")
+
+	suite.Eventually(func() bool { return hub.Count() == 2 }, time.Second, 10*time.Millisecond)
+
+	delivered, err := hub.BroadcastJSON(map[string]string{"event": "update"})
+	suite.Require().NoError(err)
+	suite.Equal(2, delivered)
+
+	for _, conn := range []*websocket.Conn{first, second} {
+		_, data, err := conn.ReadMessage()
+		suite.Require().NoError(err)
+		suite.JSONEq(`{"event":"update"}`, string(data))
+	}
+
+	suite.Require().NoError(second.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, "")))
+	suite.Eventually(func() bool { return hub.Count() == 1 }, time.Second, 10*time.Millisecond)
+	suite.Eventually(func() bool {
+		// Polled with a silent TestingT, so a miss does not fail the test.
+		return suite.metrics.AssertCalled(silentT{}, "DecrementMetric", zmiddlewares.WebSocketConnectionsMetricName, "/feed")
+	}, time.Second, 10*time.Millisecond)
+	suite.metrics.AssertCalled(suite.T(), "DecrementMetric", zmiddlewares.WebSocketConnectionsMetricName, "/feed")
+}
+
+// silentT discards assertion failures.
+type silentT struct{}
+
+func (silentT) Logf(string, ...interface{})   {}
+func (silentT) Errorf(string, ...interface{}) {}
+func (silentT) FailNow()                      {}
+
+func TestWebSocketSuite(t *testing.T) {
+	suite.Run(t, new(WebSocketSuite))
+}
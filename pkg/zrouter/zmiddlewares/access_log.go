@@ -0,0 +1,307 @@
+package zmiddlewares
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/zondax/golem/pkg/logger"
+	"github.com/zondax/golem/pkg/zrouter/auth"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	AccessLogFieldMethod    = "method"
+	AccessLogFieldRoute     = "route"
+	AccessLogFieldPath      = "path"
+	AccessLogFieldStatus    = "status"
+	AccessLogFieldLatency   = "latency_ms"
+	AccessLogFieldBytes     = "bytes"
+	AccessLogFieldClientIP  = "client_ip"
+	AccessLogFieldUserAgent = "user_agent"
+	AccessLogFieldRequestID = logger.RequestIDKey
+	AccessLogFieldTraceID   = logger.TraceIDKey
+
+	AccessLogFormatStructured = "structured"
+	// AccessLogFormatCombined writes the Apache/NCSA combined log format as the log message.
+	AccessLogFormatCombined = "combined"
+
+	accessLogMessage         = "HTTP request"
+	combinedLogTimeLayout    = "02/Jan/2006:15:04:05 -0700"
+	defaultAccessLogBodySize = 4 * 1024
+	redactedHeaderValue      = "[REDACTED]"
+)
+
+var (
+	defaultAccessLogFields = []string{
+		AccessLogFieldMethod, AccessLogFieldRoute, AccessLogFieldPath, AccessLogFieldStatus, AccessLogFieldLatency,
+		AccessLogFieldBytes, AccessLogFieldClientIP, AccessLogFieldUserAgent, AccessLogFieldRequestID, AccessLogFieldTraceID,
+	}
+
+	defaultRedactedHeaders = []string{
+		"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie", auth.APIKeyHeader, auth.SignatureHeader,
+	}
+)
+
+type AccessLogOptions struct {
+	ExcludePaths []string
+	// Fields selects the AccessLogField* values to log. Defaults to all of them.
+	Fields []string
+	// SampleRates maps status codes ("404") or classes ("2xx") to the fraction of requests logged,
+	// e.g. {"2xx": 0.1} logs one in ten successful requests. Statuses not listed are always logged.
+	SampleRates map[string]float64
+	// RequestHeaders and ResponseHeaders are logged when present.
+	RequestHeaders  []string
+	ResponseHeaders []string
+	// RedactHeaders are logged as "[REDACTED]". Defaults to the credential headers (Authorization,
+	// Cookie, Set-Cookie, API keys...).
+	RedactHeaders []string
+	// LogRequestBody logs the part of the request body read by the handler, up to MaxBodySize.
+	LogRequestBody  bool
+	LogResponseBody bool
+	// MaxBodySize defaults to 4KiB.
+	MaxBodySize int
+	// Format is AccessLogFormatStructured (default) or AccessLogFormatCombined.
+	Format string
+	// Logger defaults to the global logger.
+	Logger *logger.Logger
+}
+
+func (o *AccessLogOptions) setDefaultValues() {
+	if len(o.Fields) == 0 {
+		o.Fields = defaultAccessLogFields
+	}
+
+	if o.RedactHeaders == nil {
+		o.RedactHeaders = defaultRedactedHeaders
+	}
+
+	if o.MaxBodySize <= 0 {
+		o.MaxBodySize = defaultAccessLogBodySize
+	}
+
+	if o.Format == "" {
+		o.Format = AccessLogFormatStructured
+	}
+}
+
+type accessLogger struct {
+	options        AccessLogOptions
+	excludeRegexps []*regexp.Regexp
+	fields         map[string]bool
+	redact         map[string]bool
+	sampleRates    map[string]float64
+}
+
+// AccessLog writes one log entry per request, with structured fields or in the combined log format.
+// Server errors are logged at error level and client errors at warn level.
+func AccessLog(options AccessLogOptions) Middleware {
+	options.setDefaultValues()
+
+	l := &accessLogger{
+		options:        options,
+		excludeRegexps: make([]*regexp.Regexp, len(options.ExcludePaths)),
+		fields:         make(map[string]bool, len(options.Fields)),
+		redact:         make(map[string]bool, len(options.RedactHeaders)),
+		sampleRates:    make(map[string]float64, len(options.SampleRates)),
+	}
+	for i, path := range options.ExcludePaths {
+		l.excludeRegexps[i] = PathToRegexp(path)
+	}
+	for _, field := range options.Fields {
+		l.fields[field] = true
+	}
+	for _, header := range options.RedactHeaders {
+		l.redact[http.CanonicalHeaderKey(header)] = true
+	}
+	for status, rate := range options.SampleRates {
+		l.sampleRates[strings.ToLower(status)] = rate
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			for _, re := range l.excludeRegexps {
+				if re.MatchString(r.URL.Path) {
+					next.ServeHTTP(w, r)
+					return
+				}
+			}
+
+			var requestBody *cappedBodyReader
+			if options.LogRequestBody && r.Body != nil && r.Body != http.NoBody {
+				requestBody = &cappedBodyReader{ReadCloser: r.Body, limit: options.MaxBodySize}
+				r.Body = requestBody
+			}
+
+			rw := &responseWriter{ResponseWriter: w}
+			if options.LogResponseBody {
+				rw.body = new(bytes.Buffer)
+				rw.bodyLimit = options.MaxBodySize
+			}
+
+			start := time.Now()
+			next.ServeHTTP(rw, r)
+			latency := time.Since(start)
+
+			status := rw.status
+			if status == 0 {
+				status = http.StatusOK
+			}
+			if !l.sampled(status) {
+				return
+			}
+
+			log := options.Logger
+			if log == nil {
+				log = logger.NewLogger()
+			}
+
+			if options.Format == AccessLogFormatCombined {
+				log.Info(combinedLogLine(r, rw, status, start))
+				return
+			}
+
+			log = log.WithFields(l.zapFields(r, rw, status, latency, requestBody)...)
+			switch {
+			case status >= http.StatusInternalServerError:
+				log.Error(accessLogMessage)
+			case status >= http.StatusBadRequest:
+				log.Warn(accessLogMessage)
+			default:
+				log.Info(accessLogMessage)
+			}
+		})
+	}
+}
+
+func (l *accessLogger) sampled(status int) bool {
+	rate, ok := l.sampleRates[strconv.Itoa(status)]
+	if !ok {
+		rate, ok = l.sampleRates[strconv.Itoa(status/100)+"xx"]
+	}
+	if !ok || rate >= 1 {
+		return true
+	}
+	return rand.Float64() < rate
+}
+
+func (l *accessLogger) zapFields(r *http.Request, rw *responseWriter, status int, latency time.Duration, requestBody *cappedBodyReader) []zap.Field {
+	var fields []zap.Field
+	add := func(name string, value func() zap.Field) {
+		if l.fields[name] {
+			fields = append(fields, value())
+		}
+	}
+
+	add(AccessLogFieldMethod, func() zap.Field { return zap.String(AccessLogFieldMethod, r.Method) })
+	add(AccessLogFieldRoute, func() zap.Field { return zap.String(AccessLogFieldRoute, GetRoutePattern(r)) })
+	add(AccessLogFieldPath, func() zap.Field { return zap.String(AccessLogFieldPath, r.URL.Path) })
+	add(AccessLogFieldStatus, func() zap.Field { return zap.Int(AccessLogFieldStatus, status) })
+	add(AccessLogFieldLatency, func() zap.Field {
+		return zap.Float64(AccessLogFieldLatency, float64(latency)/float64(time.Millisecond))
+	})
+	add(AccessLogFieldBytes, func() zap.Field { return zap.Int64(AccessLogFieldBytes, rw.written) })
+	add(AccessLogFieldClientIP, func() zap.Field { return zap.String(AccessLogFieldClientIP, ClientIPFromRequest(r)) })
+	add(AccessLogFieldUserAgent, func() zap.Field { return zap.String(AccessLogFieldUserAgent, r.UserAgent()) })
+
+	if requestID := requestIDOf(r, rw); requestID != "" {
+		add(AccessLogFieldRequestID, func() zap.Field { return zap.String(AccessLogFieldRequestID, requestID) })
+	}
+	if spanContext := trace.SpanContextFromContext(r.Context()); spanContext.HasTraceID() {
+		add(AccessLogFieldTraceID, func() zap.Field { return zap.String(AccessLogFieldTraceID, spanContext.TraceID().String()) })
+	}
+
+	if headers := l.headers(r.Header, l.options.RequestHeaders); len(headers) > 0 {
+		fields = append(fields, zap.Any("request_headers", headers))
+	}
+	if headers := l.headers(rw.Header(), l.options.ResponseHeaders); len(headers) > 0 {
+		fields = append(fields, zap.Any("response_headers", headers))
+	}
+
+	if requestBody != nil {
+		fields = append(fields, zap.String("request_body", requestBody.buf.String()))
+		if requestBody.read > int64(requestBody.buf.Len()) {
+			fields = append(fields, zap.Bool("request_body_truncated", true))
+		}
+	}
+	if l.options.LogResponseBody {
+		fields = append(fields, zap.String("response_body", string(rw.Body())))
+		if rw.written > int64(len(rw.Body())) {
+			fields = append(fields, zap.Bool("response_body_truncated", true))
+		}
+	}
+
+	return fields
+}
+
+func (l *accessLogger) headers(header http.Header, names []string) map[string]string {
+	values := make(map[string]string, len(names))
+	for _, name := range names {
+		name = http.CanonicalHeaderKey(name)
+		value, ok := header[name]
+		if !ok {
+			continue
+		}
+
+		if l.redact[name] {
+			values[name] = redactedHeaderValue
+			continue
+		}
+		values[name] = strings.Join(value, ", ")
+	}
+	return values
+}
+
+// requestIDOf reads the ID set by the RequestID middleware, which may run inside this one.
+func requestIDOf(r *http.Request, w http.ResponseWriter) string {
+	if requestID := w.Header().Get(RequestIDHeader); requestID != "" {
+		return requestID
+	}
+	return r.Header.Get(RequestIDHeader)
+}
+
+func combinedLogLine(r *http.Request, rw *responseWriter, status int, start time.Time) string {
+	user := "-"
+	if username, _, ok := r.BasicAuth(); ok && username != "" {
+		user = username
+	}
+
+	size := "-"
+	if rw.written > 0 {
+		size = strconv.FormatInt(rw.written, 10)
+	}
+
+	return fmt.Sprintf("%s - %s [%s] %q %d %s %q %q",
+		ClientIPFromRequest(r), user, start.Format(combinedLogTimeLayout),
+		r.Method+" "+r.URL.RequestURI()+" "+r.Proto, status, size, combinedLogValue(r.Referer()), combinedLogValue(r.UserAgent()))
+}
+
+func combinedLogValue(value string) string {
+	if value == "" {
+		return "-"
+	}
+	return value
+}
+
+// cappedBodyReader keeps the first limit bytes read from the body.
+type cappedBodyReader struct {
+	io.ReadCloser
+	buf   bytes.Buffer
+	limit int
+	read  int64
+}
+
+func (c *cappedBodyReader) Read(p []byte) (int, error) {
+	n, err := c.ReadCloser.Read(p)
+	if remaining := c.limit - c.buf.Len(); remaining > 0 && n > 0 {
+		c.buf.Write(p[:min(n, remaining)])
+	}
+	c.read += int64(n)
+	return n, err
+}
@@ -0,0 +1,204 @@
+package zmiddlewares
+
+import (
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type AccessLogSuite struct {
+	suite.Suite
+	observed      *observer.ObservedLogs
+	restoreLogger func()
+}
+
+func (s *AccessLogSuite) SetupTest() {
+	var core zapcore.Core
+	core, s.observed = observer.New(zapcore.DebugLevel)
+	s.restoreLogger = zap.ReplaceGlobals(zap.New(core))
+}
+
+func (s *AccessLogSuite) TearDownTest() {
+	s.restoreLogger()
+}
+
+func (s *AccessLogSuite) newRouter(options AccessLogOptions) *chi.Mux {
+	r := chi.NewRouter()
+	r.Use(AccessLog(options))
+	r.Use(RequestID())
+	r.Post("/users/{id}", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.ReadAll(r.Body)
+		w.Header().Set("Set-Cookie", "session=secret")
+		w.Header().Set("X-Version", "1")
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte(`{"id":"1","name":"golem"}`))
+	})
+	r.Get("/fail", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	})
+	r.Get("/missing", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	})
+	r.Get("/health", func(w http.ResponseWriter, r *http.Request) {})
+	return r
+}
+
+func (s *AccessLogSuite) TestStructuredFields() {
+	r := s.newRouter(AccessLogOptions{
+		RequestHeaders:  []string{"authorization", "X-Client"},
+		ResponseHeaders: []string{"Set-Cookie", "X-Version"},
+		LogRequestBody:  true,
+		LogResponseBody: true,
+		MaxBodySize:     10,
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/users/1", strings.NewReader(`{"name":"golem"}`))
+	req.Header.Set("Authorization", "Bearer token")
+	req.Header.Set("X-Client", "cli")
+	req.Header.Set("User-Agent", "golem-test")
+	req.Header.Set(RequestIDHeader, "request-1")
+	r.ServeHTTP(httptest.NewRecorder(), req)
+
+	entries := s.observed.FilterMessage(accessLogMessage).All()
+	s.Require().Len(entries, 1)
+	s.Equal(zapcore.InfoLevel, entries[0].Level)
+
+	fields := entries[0].ContextMap()
+	s.Equal(http.MethodPost, fields[AccessLogFieldMethod])
+	s.Equal("/users/{id}", fields[AccessLogFieldRoute])
+	s.Equal("/users/1", fields[AccessLogFieldPath])
+	s.Equal(int64(http.StatusCreated), fields[AccessLogFieldStatus])
+	s.Equal(int64(len(`{"id":"1","name":"golem"}`)), fields[AccessLogFieldBytes])
+	s.Equal("192.0.2.1", fields[AccessLogFieldClientIP])
+	s.Equal("golem-test", fields[AccessLogFieldUserAgent])
+	s.Equal("request-1", fields[AccessLogFieldRequestID])
+	s.Contains(fields, AccessLogFieldLatency)
+	s.NotContains(fields, AccessLogFieldTraceID)
+
+	s.Equal(map[string]string{"Authorization": redactedHeaderValue, "X-Client": "cli"}, fields["request_headers"])
+	s.Equal(map[string]string{"Set-Cookie": redactedHeaderValue, "X-Version": "1"}, fields["response_headers"])
+
+	s.Equal(`{"name":"g`, fields["request_body"])
+	s.Equal(true, fields["request_body_truncated"])
+	s.Equal(`{"id":"1",`, fields["response_body"])
+	s.Equal(true, fields["response_body_truncated"])
+}
+
+func (s *AccessLogSuite) TestFieldSelectionAndLevels() {
+	r := s.newRouter(AccessLogOptions{
+		Fields:       []string{AccessLogFieldStatus, AccessLogFieldRoute},
+		ExcludePaths: []string{"/health"},
+	})
+
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/health", nil))
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/missing", nil))
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/fail", nil))
+
+	entries := s.observed.FilterMessage(accessLogMessage).All()
+	s.Require().Len(entries, 2)
+	s.Equal(zapcore.WarnLevel, entries[0].Level)
+	s.Equal(map[string]interface{}{AccessLogFieldStatus: int64(http.StatusNotFound), AccessLogFieldRoute: "/missing"}, entries[0].ContextMap())
+	s.Equal(zapcore.ErrorLevel, entries[1].Level)
+}
+
+func (s *AccessLogSuite) TestSampling() {
+	r := s.newRouter(AccessLogOptions{SampleRates: map[string]float64{"2XX": 0, "404": 0}})
+
+	for i := 0; i < 10; i++ {
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/health", nil))
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/missing", nil))
+	}
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/fail", nil))
+
+	entries := s.observed.FilterMessage(accessLogMessage).All()
+	s.Require().Len(entries, 1)
+	s.Equal(int64(http.StatusInternalServerError), entries[0].ContextMap()[AccessLogFieldStatus])
+}
+
+func (s *AccessLogSuite) TestCombinedFormat() {
+	r := s.newRouter(AccessLogOptions{Format: AccessLogFormatCombined})
+
+	req := httptest.NewRequest(http.MethodPost, "/users/1?full=true", strings.NewReader("{}"))
+	req.SetBasicAuth("alice", "secret")
+	req.Header.Set("User-Agent", "golem-test")
+	r.ServeHTTP(httptest.NewRecorder(), req)
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/health", nil))
+
+	entries := s.observed.All()
+	s.Require().Len(entries, 2)
+	s.Regexp(`^192\.0\.2\.1 - alice \[\d{2}/\w{3}/\d{4}:\d{2}:\d{2}:\d{2} [+-]\d{4}\] "POST /users/1\?full=true HTTP/1\.1" 201 25 "-" "golem-test"$`, entries[0].Message)
+	s.Regexp(`"GET /health HTTP/1\.1" 200 - "-" "-"$`, entries[1].Message)
+}
+
+func (s *AccessLogSuite) TestLoggingMiddlewareDelegates() {
+	r := chi.NewRouter()
+	r.Use(LoggingMiddleware(LoggingMiddlewareOptions{
+		Enable:       true,
+		ExcludePaths: []string{"/health"},
+		AccessLog:    &AccessLogOptions{},
+	}))
+	r.Get("/health", func(w http.ResponseWriter, r *http.Request) {})
+	r.Get("/users", func(w http.ResponseWriter, r *http.Request) {})
+
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/health", nil))
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/users", nil))
+
+	entries := s.observed.FilterMessage(accessLogMessage).All()
+	s.Require().Len(entries, 1)
+	s.Equal("/users", entries[0].ContextMap()[AccessLogFieldPath])
+}
+
+func (s *AccessLogSuite) TestLoggingMiddlewareDoesNotBufferStreams() {
+	r := chi.NewRouter()
+	r.Use(LoggingMiddleware(LoggingMiddlewareOptions{Enable: true}))
+	r.Get("/events", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		_, _ = w.Write([]byte("data: hello\n\n"))
+	})
+	r.Get("/large", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(strings.Repeat("x", 2*defaultAccessLogBodySize)))
+	})
+
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/events", nil))
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/large", nil))
+
+	entries := s.observed.FilterMessageSnippet("Response Body").All()
+	s.Require().Len(entries, 2)
+	s.True(strings.HasSuffix(entries[0].Message, "Response Body: "))
+	s.True(strings.HasSuffix(entries[1].Message, "Response Body: "+strings.Repeat("x", defaultAccessLogBodySize)))
+}
+
+func TestAccessLogSuite(t *testing.T) {
+	suite.Run(t, new(AccessLogSuite))
+}
+
+func TestResponseWriterBodyLimit(t *testing.T) {
+	rec := httptest.NewRecorder()
+	rw := &responseWriter{ResponseWriter: rec, captureBody: true, bodyLimit: 4}
+
+	_, err := rw.Write([]byte("abc"))
+	require.NoError(t, err)
+	_, err = rw.Write([]byte("defg"))
+	require.NoError(t, err)
+
+	assert.Equal(t, "abcd", string(rw.Body()))
+	assert.Equal(t, "abcdefg", rec.Body.String())
+	assert.Equal(t, int64(7), rw.written)
+
+	rec = httptest.NewRecorder()
+	rw = &responseWriter{ResponseWriter: rec, captureBody: true}
+	rw.Header().Set("Content-Type", "text/event-stream; charset=utf-8")
+	_, err = rw.Write([]byte("data: hello\n\n"))
+	require.NoError(t, err)
+	assert.Empty(t, rw.Body())
+	assert.Equal(t, int64(13), rw.written)
+}
@@ -0,0 +1,179 @@
+package zmiddlewares
+
+import (
+	"errors"
+	"fmt"
+	"github.com/zondax/golem/pkg/logger"
+	"github.com/zondax/golem/pkg/metrics"
+	"github.com/zondax/golem/pkg/zcache"
+	"github.com/zondax/golem/pkg/zrouter/auth"
+	"github.com/zondax/golem/pkg/zrouter/domain"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	defaultSignatureMaxClockSkew = 5 * time.Minute
+
+	hmacNonceCacheKeyPrefix = "zrouter_hmac_nonce"
+	apiKeyRequestsMetric    = "api_key_requests"
+	apiKeyLabel             = "api_key"
+	authMethodLabel         = "auth_method"
+
+	apiKeyChallenge = `APIKey`
+)
+
+type APIKeyAuthOptions struct {
+	Store APIKeyStore
+	// Header carries the raw API key. Defaults to X-API-Key.
+	Header string
+	// NonceCache enables HMAC-signed requests (see auth.SignRequest); each nonce is accepted once.
+	NonceCache zcache.RemoteCache
+	// MaxClockSkew bounds the distance between the signature timestamp and the server clock. Defaults to 5m.
+	MaxClockSkew time.Duration
+	// MetricsServer, when set, receives per-key usage counters.
+	MetricsServer metrics.TaskMetrics
+	// Optional lets requests without credentials through unauthenticated. Invalid credentials are always rejected.
+	Optional bool
+}
+
+func (o *APIKeyAuthOptions) setDefaultValues() {
+	if o.Header == "" {
+		o.Header = auth.APIKeyHeader
+	}
+
+	if o.MaxClockSkew == 0 {
+		o.MaxClockSkew = defaultSignatureMaxClockSkew
+	}
+}
+
+var (
+	errInvalidAPIKey    = errors.New("invalid api key")
+	errInvalidSignature = errors.New("invalid signature")
+	errDisabledAPIKey   = errors.New("api key disabled")
+)
+
+// APIKeyAuth authenticates requests carrying either an API key or an HMAC signature and stores the
+// principal in the request context (auth.PrincipalFromContext).
+func APIKeyAuth(options APIKeyAuthOptions) Middleware {
+	options.setDefaultValues()
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var (
+				principal *auth.Principal
+				err       error
+			)
+
+			switch {
+			case r.Header.Get(auth.SignatureHeader) != "" && options.NonceCache != nil:
+				principal, err = authenticateSignature(r, options)
+			case r.Header.Get(options.Header) != "":
+				principal, err = authenticateAPIKey(r, options)
+			case options.Optional:
+				next.ServeHTTP(w, r)
+				return
+			default:
+				writeUnauthorized(w, apiKeyChallenge, "authentication required")
+				return
+			}
+
+			switch {
+			case errors.Is(err, errDisabledAPIKey):
+				writeAPIError(w, domain.NewAPIErrorResponse(http.StatusForbidden, forbiddenErrorCode, err.Error()))
+				return
+			case errors.Is(err, errInvalidAPIKey), errors.Is(err, errInvalidSignature):
+				logger.GetLoggerFromContext(r.Context()).Debugf("Rejected request credentials: %v", err)
+				writeUnauthorized(w, apiKeyChallenge, "invalid credentials")
+				return
+			case err != nil:
+				logger.GetLoggerFromContext(r.Context()).Errorf("Error authenticating request: %v", err)
+				writeAPIError(w, domain.NewAPIErrorResponse(http.StatusInternalServerError, "internal_error", "authentication unavailable"))
+				return
+			}
+
+			recordAPIKeyUsage(r, options.MetricsServer, principal)
+			next.ServeHTTP(w, r.WithContext(auth.ContextWithPrincipal(r.Context(), principal)))
+		})
+	}
+}
+
+func authenticateAPIKey(r *http.Request, options APIKeyAuthOptions) (*auth.Principal, error) {
+	key, err := options.Store.FindByHash(r.Context(), auth.HashAPIKey(r.Header.Get(options.Header)))
+	if errors.Is(err, ErrAPIKeyNotFound) {
+		return nil, errInvalidAPIKey
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if key.Disabled {
+		return nil, errDisabledAPIKey
+	}
+	return key.Principal(auth.MethodAPIKey), nil
+}
+
+func authenticateSignature(r *http.Request, options APIKeyAuthOptions) (*auth.Principal, error) {
+	keyID := r.Header.Get(auth.APIKeyIDHeader)
+	timestamp := r.Header.Get(auth.SignatureTimestampHeader)
+	nonce := r.Header.Get(auth.SignatureNonceHeader)
+	if keyID == "" || timestamp == "" || nonce == "" {
+		return nil, fmt.Errorf("%w: missing signature headers", errInvalidSignature)
+	}
+
+	unixTime, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("%w: malformed timestamp", errInvalidSignature)
+	}
+	if skew := time.Since(time.Unix(unixTime, 0)); skew > options.MaxClockSkew || skew < -options.MaxClockSkew {
+		return nil, fmt.Errorf("%w: timestamp outside the accepted window", errInvalidSignature)
+	}
+
+	key, err := options.Store.FindByID(r.Context(), keyID)
+	if errors.Is(err, ErrAPIKeyNotFound) {
+		return nil, errInvalidSignature
+	}
+	if err != nil {
+		return nil, err
+	}
+	if key.Secret == "" {
+		return nil, fmt.Errorf("%w: key %s has no signing secret", errInvalidSignature, keyID)
+	}
+
+	body, err := getRequestBody(r)
+	if err != nil {
+		return nil, err
+	}
+
+	stringToSign := auth.StringToSign(r.Method, r.URL.RequestURI(), timestamp, nonce, body)
+	if !auth.VerifySignature(key.Secret, stringToSign, r.Header.Get(auth.SignatureHeader)) {
+		return nil, errInvalidSignature
+	}
+	if key.Disabled {
+		return nil, errDisabledAPIKey
+	}
+
+	// The nonce is only consumed once the signature is valid and the key enabled, so forged requests and
+	// requests for disabled keys cannot burn it. It must outlive the timestamp window, after which the
+	// request is rejected anyway.
+	nonceKey := fmt.Sprintf("%s:%s:%s", hmacNonceCacheKeyPrefix, keyID, nonce)
+	fresh, err := options.NonceCache.SetNX(r.Context(), nonceKey, timestamp, 2*options.MaxClockSkew)
+	if err != nil {
+		return nil, err
+	}
+	if !fresh {
+		return nil, fmt.Errorf("%w: nonce already used", errInvalidSignature)
+	}
+	return key.Principal(auth.MethodHMAC), nil
+}
+
+func recordAPIKeyUsage(r *http.Request, metricsServer metrics.TaskMetrics, principal *auth.Principal) {
+	if metricsServer == nil {
+		return
+	}
+
+	if err := metricsServer.IncrementMetric(apiKeyRequestsMetric, principal.KeyID, principal.Method, GetRoutePattern(r)); err != nil {
+		logger.GetLoggerFromContext(r.Context()).Errorf("error updating api key usage metric: %v", err.Error())
+	}
+}
@@ -0,0 +1,209 @@
+package zmiddlewares
+
+import (
+	"context"
+	"fmt"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+	"github.com/zondax/golem/pkg/logger"
+	"github.com/zondax/golem/pkg/metrics"
+	"github.com/zondax/golem/pkg/zcache"
+	"github.com/zondax/golem/pkg/zdb"
+	"github.com/zondax/golem/pkg/zrouter/auth"
+	"gorm.io/gorm"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+const (
+	testAPIKey    = "sk_test_123"
+	testAPISecret = "signing-secret"
+)
+
+type APIKeyAuthSuite struct {
+	suite.Suite
+	mr      *miniredis.Miniredis
+	cache   zcache.RemoteCache
+	metrics *metrics.MockTaskMetrics
+	router  *chi.Mux
+}
+
+func (s *APIKeyAuthSuite) SetupTest() {
+	logger.InitLogger(logger.Config{})
+
+	mr, err := miniredis.Run()
+	s.Require().NoError(err)
+	s.mr = mr
+
+	s.cache, err = zcache.NewRemoteCache(&zcache.RemoteConfig{Addr: mr.Addr()})
+	s.Require().NoError(err)
+
+	store := NewCacheAPIKeyStore(s.cache)
+	s.Require().NoError(store.Save(context.Background(), APIKey{
+		ID:       "key-1",
+		KeyHash:  auth.HashAPIKey(testAPIKey),
+		Owner:    "acme",
+		Scopes:   []string{"reports:read"},
+		RateTier: "gold",
+		Secret:   testAPISecret,
+	}, 0))
+	s.Require().NoError(store.Save(context.Background(), APIKey{
+		ID:       "key-2",
+		KeyHash:  auth.HashAPIKey("sk_disabled"),
+		Owner:    "acme",
+		Secret:   testAPISecret,
+		Disabled: true,
+	}, 0))
+
+	s.metrics = new(metrics.MockTaskMetrics)
+	s.metrics.On("IncrementMetric", apiKeyRequestsMetric, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+
+	s.router = chi.NewRouter()
+	s.router.Use(APIKeyAuth(APIKeyAuthOptions{
+		Store:         store,
+		NonceCache:    s.cache,
+		MetricsServer: s.metrics,
+	}))
+
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		principal, ok := auth.PrincipalFromContext(r.Context())
+		s.Require().True(ok)
+		_, _ = w.Write([]byte(principal.ID + "/" + principal.KeyID + "/" + principal.Method + "/" + principal.RateTier))
+	}
+	s.router.Get("/reports", handler)
+	s.router.Post("/reports", handler)
+	s.router.With(RequireScopes("admin")).Get("/admin", handler)
+}
+
+func (s *APIKeyAuthSuite) TearDownTest() {
+	s.mr.Close()
+}
+
+func (s *APIKeyAuthSuite) serve(req *http.Request) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	s.router.ServeHTTP(rec, req)
+	return rec
+}
+
+func (s *APIKeyAuthSuite) signedRequest(method, target, body string) *http.Request {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	s.Require().NoError(auth.SignRequest(req, "key-1", testAPISecret))
+	return req
+}
+
+func (s *APIKeyAuthSuite) TestValidAPIKey() {
+	req := httptest.NewRequest(http.MethodGet, "/reports", nil)
+	req.Header.Set(auth.APIKeyHeader, testAPIKey)
+
+	rec := s.serve(req)
+	s.Equal(http.StatusOK, rec.Code)
+	s.Equal("acme/key-1/api_key/gold", rec.Body.String())
+	s.metrics.AssertCalled(s.T(), "IncrementMetric", apiKeyRequestsMetric, "key-1", auth.MethodAPIKey, "/reports")
+}
+
+func (s *APIKeyAuthSuite) TestMissingCredentials() {
+	rec := s.serve(httptest.NewRequest(http.MethodGet, "/reports", nil))
+	s.Equal(http.StatusUnauthorized, rec.Code)
+	s.Equal(apiKeyChallenge, rec.Header().Get(wwwAuthenticateHeader))
+}
+
+func (s *APIKeyAuthSuite) TestUnknownAPIKey() {
+	req := httptest.NewRequest(http.MethodGet, "/reports", nil)
+	req.Header.Set(auth.APIKeyHeader, "sk_unknown")
+
+	s.Equal(http.StatusUnauthorized, s.serve(req).Code)
+	s.metrics.AssertNotCalled(s.T(), "IncrementMetric", apiKeyRequestsMetric, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func (s *APIKeyAuthSuite) TestDisabledAPIKey() {
+	req := httptest.NewRequest(http.MethodGet, "/reports", nil)
+	req.Header.Set(auth.APIKeyHeader, "sk_disabled")
+
+	s.Equal(http.StatusForbidden, s.serve(req).Code)
+
+	// Signed requests for a disabled key do not consume their nonce
+	req = httptest.NewRequest(http.MethodGet, "/reports", nil)
+	s.Require().NoError(auth.SignRequest(req, "key-2", testAPISecret))
+	s.Equal(http.StatusForbidden, s.serve(req).Code)
+	nonceKey := fmt.Sprintf("%s:%s:%s", hmacNonceCacheKeyPrefix, "key-2", req.Header.Get(auth.SignatureNonceHeader))
+	s.False(s.mr.Exists(nonceKey))
+}
+
+func (s *APIKeyAuthSuite) TestScopeGuard() {
+	req := httptest.NewRequest(http.MethodGet, "/admin", nil)
+	req.Header.Set(auth.APIKeyHeader, testAPIKey)
+
+	s.Equal(http.StatusForbidden, s.serve(req).Code)
+}
+
+func (s *APIKeyAuthSuite) TestSignedRequest() {
+	rec := s.serve(s.signedRequest(http.MethodPost, "/reports?format=csv", `{"range":"7d"}`))
+	s.Equal(http.StatusOK, rec.Code)
+	s.Equal("acme/key-1/hmac/gold", rec.Body.String())
+}
+
+func (s *APIKeyAuthSuite) TestSignedRequestReplay() {
+	req := s.signedRequest(http.MethodPost, "/reports", `{"range":"7d"}`)
+	replay := httptest.NewRequest(http.MethodPost, "/reports", strings.NewReader(`{"range":"7d"}`))
+	replay.Header = req.Header.Clone()
+
+	s.Equal(http.StatusOK, s.serve(req).Code)
+	s.Equal(http.StatusUnauthorized, s.serve(replay).Code)
+}
+
+func (s *APIKeyAuthSuite) TestSignedRequestTamperedBody() {
+	req := s.signedRequest(http.MethodPost, "/reports", `{"range":"7d"}`)
+	tampered := httptest.NewRequest(http.MethodPost, "/reports", strings.NewReader(`{"range":"365d"}`))
+	tampered.Header = req.Header.Clone()
+
+	s.Equal(http.StatusUnauthorized, s.serve(tampered).Code)
+
+	// A rejected request must not consume the nonce
+	s.Equal(http.StatusOK, s.serve(req).Code)
+}
+
+func (s *APIKeyAuthSuite) TestSignedRequestExpiredTimestamp() {
+	timestamp := strconv.FormatInt(time.Now().Add(-10*time.Minute).Unix(), 10)
+	req := httptest.NewRequest(http.MethodGet, "/reports", nil)
+	req.Header.Set(auth.APIKeyIDHeader, "key-1")
+	req.Header.Set(auth.SignatureTimestampHeader, timestamp)
+	req.Header.Set(auth.SignatureNonceHeader, "nonce")
+	req.Header.Set(auth.SignatureHeader, auth.ComputeSignature(testAPISecret, auth.StringToSign(http.MethodGet, "/reports", timestamp, "nonce", nil)))
+
+	s.Equal(http.StatusUnauthorized, s.serve(req).Code)
+}
+
+func TestAPIKeyAuthSuite(t *testing.T) {
+	suite.Run(t, new(APIKeyAuthSuite))
+}
+
+func TestDBAPIKeyStore(t *testing.T) {
+	ctx := context.Background()
+	db := new(zdb.MockZDatabase)
+	db.On("WithContext", ctx).Return(db)
+	db.On("Table", "api_keys").Return(db)
+	db.On("Where", "key_hash = ?", "found").Return(db).Once()
+	db.On("First", mock.AnythingOfType("*zmiddlewares.APIKey")).Run(func(args mock.Arguments) {
+		args.Get(0).(*APIKey).ID = "key-1"
+	}).Return(db).Once()
+	db.On("Error").Return(nil).Once()
+
+	store := NewDBAPIKeyStore(db, "")
+	key, err := store.FindByHash(ctx, "found")
+	assert.NoError(t, err)
+	assert.Equal(t, "key-1", key.ID)
+
+	db.On("Where", "id = ?", "missing").Return(db).Once()
+	db.On("First", mock.AnythingOfType("*zmiddlewares.APIKey")).Return(db).Once()
+	db.On("Error").Return(gorm.ErrRecordNotFound).Once()
+
+	_, err = store.FindByID(ctx, "missing")
+	assert.ErrorIs(t, err, ErrAPIKeyNotFound)
+}
@@ -0,0 +1,145 @@
+package zmiddlewares
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/zondax/golem/pkg/zcache"
+	"github.com/zondax/golem/pkg/zdb"
+	"github.com/zondax/golem/pkg/zrouter/auth"
+	"gorm.io/gorm"
+	"time"
+)
+
+const (
+	apiKeyCacheKeyPrefix   = "zrouter_api_key"
+	defaultAPIKeyTableName = "api_keys"
+)
+
+var ErrAPIKeyNotFound = errors.New("api key not found")
+
+// APIKey is the stored representation of an API key. The key value itself is never stored, only its
+// SHA-256 digest (auth.HashAPIKey). Secret is the HMAC signing secret, kept in clear since it is needed
+// to verify signatures.
+type APIKey struct {
+	ID       string   `json:"id" gorm:"column:id;primaryKey"`
+	KeyHash  string   `json:"key_hash" gorm:"column:key_hash;uniqueIndex"`
+	Owner    string   `json:"owner" gorm:"column:owner"`
+	Scopes   []string `json:"scopes" gorm:"column:scopes;serializer:json"`
+	RateTier string   `json:"rate_tier" gorm:"column:rate_tier"`
+	Secret   string   `json:"secret,omitempty" gorm:"column:secret"`
+	Disabled bool     `json:"disabled" gorm:"column:disabled"`
+}
+
+func (k *APIKey) Principal(method string) *auth.Principal {
+	return &auth.Principal{
+		ID:       k.Owner,
+		KeyID:    k.ID,
+		Method:   method,
+		Scopes:   k.Scopes,
+		RateTier: k.RateTier,
+	}
+}
+
+// APIKeyStore looks up API keys. Both methods return ErrAPIKeyNotFound when there is no match.
+type APIKeyStore interface {
+	FindByHash(ctx context.Context, keyHash string) (*APIKey, error)
+	FindByID(ctx context.Context, id string) (*APIKey, error)
+}
+
+// CacheAPIKeyStore keeps API keys in a remote cache, indexed by ID and by key hash.
+type CacheAPIKeyStore struct {
+	cache zcache.RemoteCache
+}
+
+func NewCacheAPIKeyStore(cache zcache.RemoteCache) *CacheAPIKeyStore {
+	return &CacheAPIKeyStore{cache: cache}
+}
+
+// Save stores the key; a zero ttl keeps it until deleted.
+func (s *CacheAPIKeyStore) Save(ctx context.Context, key APIKey, ttl time.Duration) error {
+	if key.ID == "" || key.KeyHash == "" {
+		return errors.New("api key ID and hash are required")
+	}
+
+	if err := s.cache.Set(ctx, apiKeyIDCacheKey(key.ID), key, ttl); err != nil {
+		return err
+	}
+	return s.cache.Set(ctx, apiKeyHashCacheKey(key.KeyHash), key.ID, ttl)
+}
+
+func (s *CacheAPIKeyStore) Delete(ctx context.Context, id string) error {
+	key, err := s.FindByID(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	if err = s.cache.Delete(ctx, apiKeyHashCacheKey(key.KeyHash)); err != nil {
+		return err
+	}
+	return s.cache.Delete(ctx, apiKeyIDCacheKey(id))
+}
+
+func (s *CacheAPIKeyStore) FindByHash(ctx context.Context, keyHash string) (*APIKey, error) {
+	var id string
+	if err := s.cache.Get(ctx, apiKeyHashCacheKey(keyHash), &id); err != nil {
+		if s.cache.IsNotFoundError(err) {
+			return nil, ErrAPIKeyNotFound
+		}
+		return nil, err
+	}
+	return s.FindByID(ctx, id)
+}
+
+func (s *CacheAPIKeyStore) FindByID(ctx context.Context, id string) (*APIKey, error) {
+	var key APIKey
+	if err := s.cache.Get(ctx, apiKeyIDCacheKey(id), &key); err != nil {
+		if s.cache.IsNotFoundError(err) {
+			return nil, ErrAPIKeyNotFound
+		}
+		return nil, err
+	}
+	return &key, nil
+}
+
+func apiKeyIDCacheKey(id string) string {
+	return fmt.Sprintf("%s:id:%s", apiKeyCacheKeyPrefix, id)
+}
+
+func apiKeyHashCacheKey(keyHash string) string {
+	return fmt.Sprintf("%s:hash:%s", apiKeyCacheKeyPrefix, keyHash)
+}
+
+// DBAPIKeyStore reads API keys from a table with the columns of APIKey, scopes being a JSON array.
+type DBAPIKeyStore struct {
+	db    zdb.ZDatabase
+	table string
+}
+
+// NewDBAPIKeyStore uses the api_keys table when table is empty.
+func NewDBAPIKeyStore(db zdb.ZDatabase, table string) *DBAPIKeyStore {
+	if table == "" {
+		table = defaultAPIKeyTableName
+	}
+	return &DBAPIKeyStore{db: db, table: table}
+}
+
+func (s *DBAPIKeyStore) FindByHash(ctx context.Context, keyHash string) (*APIKey, error) {
+	return s.find(ctx, "key_hash = ?", keyHash)
+}
+
+func (s *DBAPIKeyStore) FindByID(ctx context.Context, id string) (*APIKey, error) {
+	return s.find(ctx, "id = ?", id)
+}
+
+func (s *DBAPIKeyStore) find(ctx context.Context, query string, value string) (*APIKey, error) {
+	var key APIKey
+	err := s.db.WithContext(ctx).Table(s.table).Where(query, value).First(&key).Error()
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrAPIKeyNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
@@ -0,0 +1,28 @@
+package zmiddlewares
+
+import (
+	"github.com/zondax/golem/pkg/zrouter/domain"
+	"net/http"
+)
+
+const (
+	requestTooLargeErrorCode = "request_too_large"
+)
+
+// MaxBodySize rejects requests whose declared Content-Length exceeds limit with 413 and caps the
+// body of the others, so reading past the limit fails with *http.MaxBytesError.
+func MaxBodySize(limit int64) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.ContentLength > limit {
+				writeAPIError(w, domain.NewAPIErrorResponse(http.StatusRequestEntityTooLarge, requestTooLargeErrorCode, "request body too large"))
+				return
+			}
+
+			if r.Body != nil {
+				r.Body = http.MaxBytesReader(w, r.Body, limit)
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -1,14 +1,20 @@
 package zmiddlewares
 
 import (
+	"bufio"
+	"bytes"
+	"errors"
 	"fmt"
 	"github.com/zondax/golem/pkg/logger"
 	"github.com/zondax/golem/pkg/metrics"
 	"github.com/zondax/golem/pkg/zcache"
+	"github.com/zondax/golem/pkg/zrouter/auth"
 	"github.com/zondax/golem/pkg/zrouter/domain"
+	"net"
 	"net/http"
 	"regexp"
 	"runtime/debug"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -26,6 +32,26 @@ type CacheProcessedPath struct {
 	TTL   time.Duration
 }
 
+// cachedResponse is the stored form of a response; Status is zero for empty entries.
+type cachedResponse struct {
+	Status   int         `json:"status"`
+	Header   http.Header `json:"header"`
+	Body     []byte      `json:"body"`
+	StoredAt time.Time   `json:"stored_at"`
+}
+
+var cacheableStatuses = map[int]struct{}{
+	http.StatusOK:                   {},
+	http.StatusNonAuthoritativeInfo: {},
+	http.StatusNoContent:            {},
+	http.StatusMultipleChoices:      {},
+	http.StatusMovedPermanently:     {},
+	http.StatusPermanentRedirect:    {},
+}
+
+// CacheMiddleware caches the responses of the configured paths, honouring the Cache-Control
+// directives of requests (no-store, no-cache, max-age) and responses (no-store, private, max-age,
+// s-maxage). Responses get an ETag, and conditional requests are answered with 304 Not Modified.
 func CacheMiddleware(metricServer metrics.TaskMetrics, cache zcache.ZCache, config domain.CacheConfig) func(next http.Handler) http.Handler {
 	processedPaths := processCachePaths(config.Paths)
 
@@ -34,27 +60,42 @@ func CacheMiddleware(metricServer metrics.TaskMetrics, cache zcache.ZCache, conf
 			path := r.URL.Path
 			fullURL := constructFullURL(r)
 
-			rw := &responseWriter{ResponseWriter: w}
 			for _, pPath := range processedPaths {
-				if pPath.Regex.MatchString(path) {
-					key, err := constructCacheKey(fullURL, r, metricServer)
-					if err != nil {
-						logger.GetLoggerFromContext(r.Context()).Errorf("Error constructing cache key: %v", err)
-						next.ServeHTTP(rw, r)
-						return
-					}
-
-					if tryServeFromCache(rw, r, cache, key, metricServer) {
-						return
-					}
-
-					next.ServeHTTP(rw, r) // Important: this line needs to be BEFORE setting the cache.
-					cacheResponseIfNeeded(rw, r, cache, key, pPath.TTL, metricServer)
+				if !pPath.Regex.MatchString(path) {
+					continue
+				}
+
+				requestCacheControl := parseCacheControl(r.Header.Get(domain.CacheControlHeader))
+				if requestCacheControl.has(cacheDirectiveNoStore) {
+					next.ServeHTTP(w, r)
+					return
+				}
+
+				key, err := constructCacheKey(fullURL, r, metricServer)
+				if err != nil {
+					logger.GetLoggerFromContext(r.Context()).Errorf("Error constructing cache key: %v", err)
+					next.ServeHTTP(w, r)
+					return
+				}
+				key += cacheKeyVariant(r, config)
+
+				if !requestCacheControl.has(cacheDirectiveNoCache) && tryServeFromCache(w, r, cache, key, requestCacheControl, metricServer) {
 					return
 				}
+
+				rw := &cacheResponseWriter{ResponseWriter: w}
+				next.ServeHTTP(rw, r) // Important: this line needs to be BEFORE setting the cache.
+				if rw.passthrough {
+					return
+				}
+
+				response := rw.response()
+				cacheResponseIfNeeded(response, r, cache, key, pPath.TTL, config, metricServer)
+				writeCachedResponse(w, r, response)
+				return
 			}
 
-			next.ServeHTTP(rw, r)
+			next.ServeHTTP(w, r)
 		})
 	}
 }
@@ -84,18 +125,65 @@ func constructCacheKey(fullURL string, r *http.Request, metricServer metrics.Tas
 	return fmt.Sprintf("%s.%s:%s", cacheKeyPrefix, r.Method, fullURL), nil
 }
 
-func tryServeFromCache(w http.ResponseWriter, r *http.Request, cache zcache.ZCache, key string, metricServer metrics.TaskMetrics) bool {
-	var cachedResponse []byte
-	err := cache.Get(r.Context(), key, &cachedResponse)
-	if err == nil && cachedResponse != nil {
-		w.Header().Set(domain.ContentTypeHeader, domain.ContentTypeApplicationJSON)
-		_, _ = w.Write(cachedResponse)
+// cacheKeyVariant returns the key suffix for the configured Vary components, empty when there are none.
+func cacheKeyVariant(r *http.Request, config domain.CacheConfig) string {
+	var parts []string
+	for _, header := range config.VaryHeaders {
+		parts = append(parts, http.CanonicalHeaderKey(header)+"="+r.Header.Get(header))
+	}
 
-		if err = metricServer.IncrementMetric(cacheHitsMetric, GetSubRoutePattern(r), GetRoutePattern(r)); err != nil {
-			logger.GetLoggerFromContext(r.Context()).Errorf("Error incrementing cache_hits metric: %v", err)
+	if config.VaryByPrincipal {
+		principalID := ""
+		if principal, ok := auth.PrincipalFromContext(r.Context()); ok {
+			principalID = principal.ID
 		}
+		parts = append(parts, "principal="+principalID)
+	}
 
-		return true
+	if len(parts) == 0 {
+		return ""
+	}
+	return ".vary:" + generateBodyHash([]byte(strings.Join(parts, "\n")))
+}
+
+// varyCoveredByKey reports whether every header the response varies on is part of the cache key, so
+// the response is not replayed to clients that negotiated another representation. Accept-Encoding is
+// covered since encoded responses are never cached.
+func varyCoveredByKey(header http.Header, config domain.CacheConfig) bool {
+	for _, varied := range splitHeaderList(header.Values(VaryHeader)) {
+		if strings.EqualFold(varied, AcceptEncodingHeader) {
+			continue
+		}
+
+		covered := false
+		for _, keyed := range config.VaryHeaders {
+			if strings.EqualFold(varied, keyed) {
+				covered = true
+				break
+			}
+		}
+		if !covered {
+			return false
+		}
+	}
+	return true
+}
+
+func tryServeFromCache(w http.ResponseWriter, r *http.Request, cache zcache.ZCache, key string, requestCacheControl cacheControl, metricServer metrics.TaskMetrics) bool {
+	var cached cachedResponse
+	err := cache.Get(r.Context(), key, &cached)
+	if err == nil && cached.Status != 0 {
+		age := time.Since(cached.StoredAt)
+		if maxAge, ok := requestCacheControl.duration(cacheDirectiveMaxAge); !ok || age <= maxAge {
+			cached.Header.Set(AgeHeader, strconv.FormatInt(int64(age.Seconds()), 10))
+			writeCachedResponse(w, r, &cached)
+
+			if err = metricServer.IncrementMetric(cacheHitsMetric, GetSubRoutePattern(r), GetRoutePattern(r)); err != nil {
+				logger.GetLoggerFromContext(r.Context()).Errorf("Error incrementing cache_hits metric: %v", err)
+			}
+
+			return true
+		}
 	}
 
 	if err = metricServer.IncrementMetric(cacheMissesMetric, GetSubRoutePattern(r), GetRoutePattern(r)); err != nil {
@@ -105,13 +193,47 @@ func tryServeFromCache(w http.ResponseWriter, r *http.Request, cache zcache.ZCac
 	return false
 }
 
-func cacheResponseIfNeeded(rw *responseWriter, r *http.Request, cache zcache.ZCache, key string, ttl time.Duration, metricServer metrics.TaskMetrics) {
-	if rw.status != http.StatusOK {
+func cacheResponseIfNeeded(response *cachedResponse, r *http.Request, cache zcache.ZCache, key string, ttl time.Duration, config domain.CacheConfig, metricServer metrics.TaskMetrics) {
+	if _, ok := cacheableStatuses[response.Status]; !ok {
+		return
+	}
+
+	responseCacheControl := parseCacheControl(response.Header.Get(domain.CacheControlHeader))
+	if responseCacheControl.has(cacheDirectiveNoStore) || response.Header.Get(VaryHeader) == cacheDirectiveWildcard {
+		return
+	}
+	// Encoded bodies could be replayed to clients that do not accept them; Compression must wrap the cache
+	if response.Header.Get(ContentEncodingHeader) != "" {
+		return
+	}
+	if responseCacheControl.has(cacheDirectivePrivate) {
+		if _, ok := auth.PrincipalFromContext(r.Context()); !ok || !config.VaryByPrincipal {
+			return
+		}
+	}
+	if !varyCoveredByKey(response.Header, config) {
+		return
+	}
+
+	if maxAge, ok := responseCacheControl.duration(cacheDirectiveSMaxAge); ok && maxAge < ttl {
+		ttl = maxAge
+	} else if maxAge, ok = responseCacheControl.duration(cacheDirectiveMaxAge); ok && maxAge < ttl && !responseCacheControl.has(cacheDirectiveSMaxAge) {
+		ttl = maxAge
+	}
+	if ttl <= 0 {
 		return
 	}
 
-	responseBody := rw.Body()
-	if err := cache.Set(r.Context(), key, responseBody, ttl); err != nil {
+	header := response.Header.Clone()
+	header.Del(RequestIDHeader)
+	stored := cachedResponse{
+		Status:   response.Status,
+		Header:   header,
+		Body:     response.Body,
+		StoredAt: time.Now(),
+	}
+
+	if err := cache.Set(r.Context(), key, stored, ttl); err != nil {
 		logger.GetLoggerFromContext(r.Context()).Errorf("Internal error when setting cache response: %v\n%s", err, debug.Stack())
 		return
 	}
@@ -121,6 +243,108 @@ func cacheResponseIfNeeded(rw *responseWriter, r *http.Request, cache zcache.ZCa
 	}
 }
 
+// writeCachedResponse writes the response, or 304 Not Modified when the request's If-None-Match matches its ETag.
+func writeCachedResponse(w http.ResponseWriter, r *http.Request, response *cachedResponse) {
+	for key, values := range response.Header {
+		if key == RequestIDHeader {
+			continue
+		}
+		w.Header()[key] = values
+	}
+
+	isConditional := r.Method == http.MethodGet || r.Method == http.MethodHead
+	if isConditional && etagMatches(r.Header.Get(IfNoneMatchHeader), response.Header.Get(ETagHeader)) {
+		w.Header().Del(domain.ContentTypeHeader)
+		w.Header().Del("Content-Length")
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	w.WriteHeader(response.Status)
+	_, _ = w.Write(response.Body)
+}
+
+// cacheResponseWriter holds the response back so its ETag can be computed and conditional requests
+// answered. Event streams, flushed responses and hijacked connections are passed through and never cached.
+type cacheResponseWriter struct {
+	http.ResponseWriter
+	status      int
+	body        bytes.Buffer
+	passthrough bool
+}
+
+func (rw *cacheResponseWriter) WriteHeader(statusCode int) {
+	if rw.status != 0 {
+		return
+	}
+
+	rw.status = statusCode
+	if isEventStream(rw.Header().Get(domain.ContentTypeHeader)) {
+		rw.passthrough = true
+		rw.ResponseWriter.WriteHeader(statusCode)
+	}
+}
+
+func (rw *cacheResponseWriter) Write(p []byte) (int, error) {
+	if rw.status == 0 {
+		rw.WriteHeader(http.StatusOK)
+	}
+
+	if rw.passthrough {
+		return rw.ResponseWriter.Write(p)
+	}
+	return rw.body.Write(p)
+}
+
+func (rw *cacheResponseWriter) Flush() {
+	if rw.status == 0 {
+		rw.WriteHeader(http.StatusOK)
+	}
+
+	if !rw.passthrough {
+		rw.passthrough = true
+		rw.ResponseWriter.WriteHeader(rw.status)
+		_, _ = rw.ResponseWriter.Write(rw.body.Bytes())
+		rw.body.Reset()
+	}
+
+	if flusher, ok := rw.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Hijack implements http.Hijacker so connection upgrades (e.g. WebSockets) work behind the cache. Hijacked
+// connections are passed through and never cached.
+func (rw *cacheResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := rw.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("the underlying http.ResponseWriter does not implement http.Hijacker")
+	}
+
+	rw.passthrough = true
+	return hijacker.Hijack()
+}
+
+// Unwrap exposes the underlying writer to http.ResponseController.
+func (rw *cacheResponseWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
+
+func (rw *cacheResponseWriter) response() *cachedResponse {
+	status := rw.status
+	if status == 0 {
+		status = http.StatusOK
+	}
+
+	header := rw.Header().Clone()
+	body := rw.body.Bytes()
+	if header.Get(ETagHeader) == "" && status == http.StatusOK {
+		header.Set(ETagHeader, `"`+generateBodyHash(body)[:32]+`"`)
+	}
+
+	return &cachedResponse{Status: status, Header: header, Body: body}
+}
+
 func ParseCacheConfigPaths(paths map[string]string) (domain.CacheConfig, error) {
 	parsedPaths := make(map[string]time.Duration)
 
@@ -146,6 +370,10 @@ func processCachePaths(paths map[string]time.Duration) []CacheProcessedPath {
 	return processedPaths
 }
 
+func isEventStream(contentType string) bool {
+	return strings.HasPrefix(contentType, domain.ContentTypeEventStream)
+}
+
 func shouldProcessRequestBody(method string) bool {
 	return strings.EqualFold(method, http.MethodPost) || strings.EqualFold(method, http.MethodPut)
 }
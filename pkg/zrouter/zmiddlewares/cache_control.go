@@ -0,0 +1,82 @@
+package zmiddlewares
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	ETagHeader        = "ETag"
+	IfNoneMatchHeader = "If-None-Match"
+	AgeHeader         = "Age"
+	VaryHeader        = "Vary"
+
+	cacheDirectiveNoStore  = "no-store"
+	cacheDirectiveNoCache  = "no-cache"
+	cacheDirectivePrivate  = "private"
+	cacheDirectiveMaxAge   = "max-age"
+	cacheDirectiveSMaxAge  = "s-maxage"
+	cacheDirectiveWildcard = "*"
+)
+
+// AddVaryHeader adds value to the Vary header of the response unless it is already listed, or Vary is "*".
+func AddVaryHeader(header http.Header, value string) {
+	for _, varied := range splitHeaderList(header.Values(VaryHeader)) {
+		if varied == cacheDirectiveWildcard || strings.EqualFold(varied, value) {
+			return
+		}
+	}
+	header.Add(VaryHeader, value)
+}
+
+// cacheControl holds the parsed directives of a Cache-Control header, keyed in lower case.
+type cacheControl map[string]string
+
+func parseCacheControl(value string) cacheControl {
+	directives := cacheControl{}
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		name, arg, _ := strings.Cut(part, "=")
+		directives[strings.ToLower(strings.TrimSpace(name))] = strings.Trim(strings.TrimSpace(arg), `"`)
+	}
+	return directives
+}
+
+func (c cacheControl) has(directive string) bool {
+	_, ok := c[directive]
+	return ok
+}
+
+func (c cacheControl) duration(directive string) (time.Duration, bool) {
+	value, ok := c[directive]
+	if !ok {
+		return 0, false
+	}
+
+	seconds, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || seconds < 0 {
+		return 0, false
+	}
+	return time.Duration(seconds) * time.Second, true
+}
+
+// etagMatches implements the weak comparison used by If-None-Match.
+func etagMatches(ifNoneMatch, etag string) bool {
+	if etag == "" {
+		return false
+	}
+
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == cacheDirectiveWildcard || strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
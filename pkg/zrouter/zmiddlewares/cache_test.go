@@ -0,0 +1,259 @@
+package zmiddlewares
+
+import (
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-chi/chi/v5"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/suite"
+	"github.com/zondax/golem/pkg/logger"
+	"github.com/zondax/golem/pkg/metrics"
+	"github.com/zondax/golem/pkg/zcache"
+	"github.com/zondax/golem/pkg/zrouter/auth"
+	"github.com/zondax/golem/pkg/zrouter/domain"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+type HTTPCacheSuite struct {
+	suite.Suite
+	mr    *miniredis.Miniredis
+	cache zcache.RemoteCache
+	calls int
+}
+
+func (s *HTTPCacheSuite) SetupTest() {
+	logger.InitLogger(logger.Config{})
+
+	mr, err := miniredis.Run()
+	s.Require().NoError(err)
+	s.mr = mr
+
+	s.cache, err = zcache.NewRemoteCache(&zcache.RemoteConfig{Addr: mr.Addr()})
+	s.Require().NoError(err)
+	s.calls = 0
+}
+
+func (s *HTTPCacheSuite) TearDownTest() {
+	s.mr.Close()
+}
+
+func (s *HTTPCacheSuite) router(config domain.CacheConfig, middlewares ...func(http.Handler) http.Handler) *chi.Mux {
+	ms := metrics.NewTaskMetrics("", "", "appName")
+	RegisterRequestMetrics(ms)
+
+	if config.Paths == nil {
+		config.Paths = map[string]time.Duration{"/items/{id}": time.Hour}
+	}
+
+	r := chi.NewRouter()
+	r.Use(middlewares...)
+	r.Use(CacheMiddleware(ms, s.cache, config))
+	r.Get("/items/{id}", func(w http.ResponseWriter, r *http.Request) {
+		s.calls++
+		if cacheControl := r.URL.Query().Get("cc"); cacheControl != "" {
+			w.Header().Set(domain.CacheControlHeader, cacheControl)
+		}
+		w.Header().Set(domain.ContentTypeHeader, "text/csv")
+		w.Header().Set("X-Item", chi.URLParam(r, "id"))
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte("id,call\n" + strconv.Itoa(s.calls)))
+	})
+	return r
+}
+
+func (s *HTTPCacheSuite) get(r http.Handler, target string, header http.Header) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, target, nil)
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	return rec
+}
+
+func (s *HTTPCacheSuite) TestReplaysStatusAndHeaders() {
+	r := s.router(domain.CacheConfig{})
+
+	first := s.get(r, "/items/1", nil)
+	second := s.get(r, "/items/1", nil)
+
+	s.Equal(1, s.calls)
+	s.Equal(first.Body.String(), second.Body.String())
+	s.Equal("text/csv", second.Header().Get(domain.ContentTypeHeader))
+	s.Equal("1", second.Header().Get("X-Item"))
+	s.Equal("0", second.Header().Get(AgeHeader))
+	s.NotEmpty(first.Header().Get(ETagHeader))
+	s.Equal(first.Header().Get(ETagHeader), second.Header().Get(ETagHeader))
+}
+
+func (s *HTTPCacheSuite) TestIfNoneMatch() {
+	r := s.router(domain.CacheConfig{})
+
+	etag := s.get(r, "/items/1", nil).Header().Get(ETagHeader)
+
+	rec := s.get(r, "/items/1", http.Header{IfNoneMatchHeader: {`"other", ` + etag}})
+	s.Equal(http.StatusNotModified, rec.Code)
+	s.Empty(rec.Body.String())
+	s.Equal(etag, rec.Header().Get(ETagHeader))
+
+	// Revalidation on a miss is answered with 304 as well
+	rec = s.get(r, "/items/2", http.Header{IfNoneMatchHeader: {"*"}})
+	s.Equal(http.StatusNotModified, rec.Code)
+}
+
+func (s *HTTPCacheSuite) TestRequestCacheControl() {
+	r := s.router(domain.CacheConfig{})
+
+	s.get(r, "/items/1", http.Header{domain.CacheControlHeader: {"no-store"}})
+	s.Empty(s.mr.Keys())
+
+	s.get(r, "/items/1", nil)
+	s.get(r, "/items/1", http.Header{domain.CacheControlHeader: {"no-cache"}})
+	s.Equal(3, s.calls)
+
+	time.Sleep(10 * time.Millisecond)
+	s.get(r, "/items/1", http.Header{domain.CacheControlHeader: {"max-age=0"}})
+	s.Equal(4, s.calls)
+	s.get(r, "/items/1", http.Header{domain.CacheControlHeader: {"max-age=60"}})
+	s.Equal(4, s.calls)
+}
+
+func (s *HTTPCacheSuite) TestResponseCacheControl() {
+	r := s.router(domain.CacheConfig{})
+
+	s.get(r, "/items/1?cc=no-store", nil)
+	s.get(r, "/items/1?cc=private,max-age=60", nil)
+	s.Empty(s.mr.Keys())
+
+	s.get(r, "/items/1?cc=public,max-age=30", nil)
+	keys := s.mr.Keys()
+	s.Require().Len(keys, 1)
+	s.Equal(30*time.Second, s.mr.TTL(keys[0]))
+
+	s.get(r, "/items/1?cc=max-age=600,s-maxage=20", nil)
+	s.Equal(20*time.Second, s.mr.TTL(cacheKeyPrefix+".GET:/items/1?cc=max-age=600,s-maxage=20"))
+}
+
+func (s *HTTPCacheSuite) TestVaryHeaders() {
+	r := s.router(domain.CacheConfig{VaryHeaders: []string{"Accept-Language"}})
+
+	s.get(r, "/items/1", http.Header{"Accept-Language": {"en"}})
+	s.get(r, "/items/1", http.Header{"Accept-Language": {"es"}})
+	s.get(r, "/items/1", http.Header{"Accept-Language": {"en"}})
+	s.Equal(2, s.calls)
+}
+
+func (s *HTTPCacheSuite) TestVaryByPrincipal() {
+	withPrincipal := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			principal := &auth.Principal{ID: r.Header.Get("X-Test-User")}
+			next.ServeHTTP(w, r.WithContext(auth.ContextWithPrincipal(r.Context(), principal)))
+		})
+	}
+	r := s.router(domain.CacheConfig{VaryByPrincipal: true}, withPrincipal)
+
+	alice := s.get(r, "/items/1?cc=private", http.Header{"X-Test-User": {"alice"}})
+	bob := s.get(r, "/items/1?cc=private", http.Header{"X-Test-User": {"bob"}})
+	aliceAgain := s.get(r, "/items/1?cc=private", http.Header{"X-Test-User": {"alice"}})
+
+	s.Equal(2, s.calls)
+	s.NotEqual(alice.Body.String(), bob.Body.String())
+	s.Equal(alice.Body.String(), aliceAgain.Body.String())
+
+	// Anonymous requests have no principal to key private responses on
+	anonymous := s.router(domain.CacheConfig{VaryByPrincipal: true})
+	s.get(anonymous, "/items/1?cc=private", nil)
+	s.get(anonymous, "/items/1?cc=private", nil)
+	s.Equal(4, s.calls)
+}
+
+func (s *HTTPCacheSuite) TestResponseVary() {
+	negotiated := func(config domain.CacheConfig) *chi.Mux {
+		ms := metrics.NewTaskMetrics("", "", "appName")
+		RegisterRequestMetrics(ms)
+
+		config.Paths = map[string]time.Duration{"/items": time.Hour}
+		r := chi.NewRouter()
+		r.Use(CacheMiddleware(ms, s.cache, config))
+		r.Get("/items", func(w http.ResponseWriter, r *http.Request) {
+			s.calls++
+			AddVaryHeader(w.Header(), "Accept")
+			w.Header().Set(domain.ContentTypeHeader, r.Header.Get("Accept"))
+			_, _ = w.Write([]byte(r.Header.Get("Accept")))
+		})
+		return r
+	}
+
+	// Accept is not part of the key, so the responses are not stored
+	r := negotiated(domain.CacheConfig{})
+	s.Equal("application/json", s.get(r, "/items", http.Header{"Accept": {"application/json"}}).Body.String())
+	s.Equal("application/msgpack", s.get(r, "/items", http.Header{"Accept": {"application/msgpack"}}).Body.String())
+	s.Equal(2, s.calls)
+	s.Empty(s.mr.Keys())
+
+	r = negotiated(domain.CacheConfig{VaryHeaders: []string{"accept"}})
+	s.Equal("application/json", s.get(r, "/items", http.Header{"Accept": {"application/json"}}).Body.String())
+	s.Equal("application/msgpack", s.get(r, "/items", http.Header{"Accept": {"application/msgpack"}}).Body.String())
+	s.Equal("application/json", s.get(r, "/items", http.Header{"Accept": {"application/json"}}).Body.String())
+	s.Equal(4, s.calls)
+}
+
+func (s *HTTPCacheSuite) TestEventStreamsAreNotCached() {
+	ms := metrics.NewTaskMetrics("", "", "appName")
+	RegisterRequestMetrics(ms)
+
+	r := chi.NewRouter()
+	r.Use(CacheMiddleware(ms, s.cache, domain.CacheConfig{Paths: map[string]time.Duration{"/events": time.Hour}}))
+	r.Get("/events", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(domain.ContentTypeHeader, domain.ContentTypeEventStream)
+		_, _ = w.Write([]byte("data: hello\n\n"))
+		w.(http.Flusher).Flush()
+	})
+
+	rec := s.get(r, "/events", nil)
+	s.Equal("data: hello\n\n", rec.Body.String())
+	s.True(rec.Flushed)
+	s.Empty(s.mr.Keys())
+}
+
+func (s *HTTPCacheSuite) TestWebSocketUpgrade() {
+	ms := metrics.NewTaskMetrics("", "", "appName")
+	RegisterRequestMetrics(ms)
+
+	r := chi.NewRouter()
+	r.Use(CacheMiddleware(ms, s.cache, domain.CacheConfig{Paths: map[string]time.Duration{"/ws": time.Hour}}))
+	r.Get("/ws", func(w http.ResponseWriter, r *http.Request) {
+		conn, err := (&websocket.Upgrader{}).Upgrade(w, r, nil)
+		if !s.NoError(err) {
+			return
+		}
+		defer conn.Close()
+
+		messageType, data, err := conn.ReadMessage()
+		if s.NoError(err) {
+			s.NoError(conn.WriteMessage(messageType, data))
+		}
+	})
+
+	server := httptest.NewServer(r)
+	defer server.Close()
+
+	conn, resp, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/ws", nil)
+	s.Require().NoError(err)
+	defer conn.Close()
+	s.Require().NoError(resp.Body.Close())
+
+	s.Require().NoError(conn.WriteMessage(websocket.TextMessage, []byte("hello")))
+	_, data, err := conn.ReadMessage()
+	s.Require().NoError(err)
+	s.Equal("hello", string(data))
+	s.Empty(s.mr.Keys())
+}
+
+func TestHTTPCacheSuite(t *testing.T) {
+	suite.Run(t, new(HTTPCacheSuite))
+}
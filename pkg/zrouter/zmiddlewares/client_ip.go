@@ -0,0 +1,254 @@
+package zmiddlewares
+
+import (
+	"context"
+	"fmt"
+	"github.com/zondax/golem/pkg/logger"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+const (
+	ForwardedHeader       = "Forwarded"
+	XForwardedForHeader   = "X-Forwarded-For"
+	XForwardedProtoHeader = "X-Forwarded-Proto"
+	XForwardedHostHeader  = "X-Forwarded-Host"
+	XRealIPHeader         = "X-Real-IP"
+
+	schemeHTTP  = "http"
+	schemeHTTPS = "https"
+)
+
+// PrivateNetworks covers loopback and private ranges, where load balancers usually live.
+var PrivateNetworks = []string{"127.0.0.0/8", "10.0.0.0/8", "172.16.0.0/12", "192.168.0.0/16", "::1/128", "fc00::/7"}
+
+// ClientInfo describes the client as seen by the first trusted proxy.
+type ClientInfo struct {
+	IP string
+	// Scheme and Host are the ones requested by the client, before TLS termination or host rewriting.
+	Scheme string
+	Host   string
+	// Geo is only set when a GeoIP resolver is configured and knows the IP.
+	Geo *GeoLocation
+}
+
+type ClientIPOptions struct {
+	// TrustedProxies are the CIDRs or IPs allowed to set the Forwarded, X-Forwarded-* and X-Real-IP
+	// headers. When the peer is not trusted these headers are ignored.
+	TrustedProxies []string
+	GeoIP          GeoIPResolver
+}
+
+type clientInfoKey struct{}
+
+func ContextWithClientInfo(ctx context.Context, info *ClientInfo) context.Context {
+	return context.WithValue(ctx, clientInfoKey{}, info)
+}
+
+func ClientInfoFromContext(ctx context.Context) (*ClientInfo, bool) {
+	info, ok := ctx.Value(clientInfoKey{}).(*ClientInfo)
+	return info, ok && info != nil
+}
+
+// ClientIPFromRequest returns the IP resolved by the ClientIP middleware or, without it, the peer address.
+func ClientIPFromRequest(r *http.Request) string {
+	if info, ok := ClientInfoFromContext(r.Context()); ok {
+		return info.IP
+	}
+	return remoteIP(r)
+}
+
+// ClientIP resolves the client address, scheme and host from the forwarding headers set by trusted
+// proxies. The Forwarded header (RFC 7239) takes precedence over X-Forwarded-For, which takes
+// precedence over X-Real-IP. It panics if a trusted proxy is not a valid IP or CIDR.
+func ClientIP(options ClientIPOptions) Middleware {
+	resolver := &clientIPResolver{}
+	for _, proxy := range options.TrustedProxies {
+		prefix, err := parseNetwork(proxy)
+		if err != nil {
+			panic(fmt.Sprintf("invalid trusted proxy %q: %v", proxy, err))
+		}
+		resolver.trustedProxies = append(resolver.trustedProxies, prefix)
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			info := resolver.resolve(r)
+
+			if options.GeoIP != nil {
+				if addr, err := netip.ParseAddr(info.IP); err == nil {
+					geo, err := options.GeoIP.Lookup(addr)
+					if err != nil {
+						logger.GetLoggerFromContext(r.Context()).Debugf("GeoIP lookup failed for %s: %v", info.IP, err)
+					}
+					info.Geo = geo
+				}
+			}
+
+			next.ServeHTTP(w, r.WithContext(ContextWithClientInfo(r.Context(), info)))
+		})
+	}
+}
+
+type clientIPResolver struct {
+	trustedProxies []netip.Prefix
+}
+
+func (c *clientIPResolver) resolve(r *http.Request) *ClientInfo {
+	info := &ClientInfo{IP: remoteIP(r), Scheme: schemeHTTP, Host: r.Host}
+	if r.TLS != nil {
+		info.Scheme = schemeHTTPS
+	}
+
+	peer, ok := parseHop(info.IP)
+	if !ok || !c.isTrusted(peer) {
+		return info
+	}
+
+	// Scheme and host are taken from the hop that saw the client, values added before it are client controlled.
+	if values := r.Header.Values(ForwardedHeader); len(values) > 0 {
+		elements := parseForwarded(values)
+		if len(elements) == 0 {
+			return info
+		}
+
+		hops := make([]string, len(elements))
+		for i, element := range elements {
+			hops[i] = element["for"]
+		}
+		var index int
+		info.IP, index = c.clientFromHops(hops, info.IP)
+		if index < 0 {
+			// The closest element is appended by the trusted peer.
+			index = len(elements) - 1
+		}
+
+		if proto := elements[index]["proto"]; proto != "" {
+			info.Scheme = strings.ToLower(proto)
+		}
+		if host := elements[index]["host"]; host != "" {
+			info.Host = host
+		}
+		return info
+	}
+
+	if values := r.Header.Values(XForwardedForHeader); len(values) > 0 {
+		info.IP, _ = c.clientFromHops(splitHeaderList(values), info.IP)
+	} else if realIP, ok := parseHop(r.Header.Get(XRealIPHeader)); ok {
+		info.IP = realIP.String()
+	}
+
+	// Proxies append to or overwrite these headers, so the last value is the one set by the trusted peer.
+	if proto := lastHeaderValue(r.Header.Values(XForwardedProtoHeader)); proto != "" {
+		info.Scheme = strings.ToLower(proto)
+	}
+	if host := lastHeaderValue(r.Header.Values(XForwardedHostHeader)); host != "" {
+		info.Host = host
+	}
+
+	return info
+}
+
+// clientFromHops walks the proxy chain from the closest hop and returns the first untrusted address, with
+// its index in hops. When every hop is trusted, the farthest one is the client. The index is -1 when no
+// hop could be used and the client is the peer.
+func (c *clientIPResolver) clientFromHops(hops []string, peer string) (string, int) {
+	client, index := peer, -1
+	for i := len(hops) - 1; i >= 0; i-- {
+		addr, ok := parseHop(hops[i])
+		if !ok {
+			break
+		}
+
+		client, index = addr.String(), i
+		if !c.isTrusted(addr) {
+			break
+		}
+	}
+	return client, index
+}
+
+func (c *clientIPResolver) isTrusted(addr netip.Addr) bool {
+	for _, prefix := range c.trustedProxies {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+func parseNetwork(value string) (netip.Prefix, error) {
+	if strings.Contains(value, "/") {
+		prefix, err := netip.ParsePrefix(value)
+		return prefix.Masked(), err
+	}
+
+	addr, err := netip.ParseAddr(value)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+	return netip.PrefixFrom(addr, addr.BitLen()), nil
+}
+
+// parseHop parses a node as found in the forwarding headers: an IP, optionally quoted, bracketed or
+// followed by a port. Obfuscated identifiers and "unknown" are rejected.
+func parseHop(value string) (netip.Addr, bool) {
+	value = strings.Trim(strings.TrimSpace(value), `"`)
+	if value == "" {
+		return netip.Addr{}, false
+	}
+
+	if addr, err := netip.ParseAddr(value); err == nil {
+		return addr.Unmap(), true
+	}
+
+	if addrPort, err := netip.ParseAddrPort(value); err == nil {
+		return addrPort.Addr().Unmap(), true
+	}
+
+	if strings.HasPrefix(value, "[") && strings.HasSuffix(value, "]") {
+		if addr, err := netip.ParseAddr(value[1 : len(value)-1]); err == nil {
+			return addr, true
+		}
+	}
+
+	return netip.Addr{}, false
+}
+
+// parseForwarded splits RFC 7239 Forwarded values into their elements, with lower-cased parameter names.
+func parseForwarded(values []string) []map[string]string {
+	var elements []map[string]string
+	for _, element := range splitHeaderList(values) {
+		params := make(map[string]string)
+		for _, pair := range strings.Split(element, ";") {
+			key, value, found := strings.Cut(strings.TrimSpace(pair), "=")
+			if !found {
+				continue
+			}
+			params[strings.ToLower(strings.TrimSpace(key))] = strings.Trim(strings.TrimSpace(value), `"`)
+		}
+		elements = append(elements, params)
+	}
+	return elements
+}
+
+func splitHeaderList(values []string) []string {
+	var items []string
+	for _, value := range values {
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+	}
+	return items
+}
+
+func lastHeaderValue(values []string) string {
+	items := splitHeaderList(values)
+	if len(items) == 0 {
+		return ""
+	}
+	return items[len(items)-1]
+}
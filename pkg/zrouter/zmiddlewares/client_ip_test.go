@@ -0,0 +1,181 @@
+package zmiddlewares
+
+import (
+	"crypto/tls"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+)
+
+func resolveClientInfo(t *testing.T, options ClientIPOptions, req *http.Request) *ClientInfo {
+	var info *ClientInfo
+	handler := ClientIP(options)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var ok bool
+		info, ok = ClientInfoFromContext(r.Context())
+		require.True(t, ok)
+		assert.Equal(t, info.IP, ClientIPFromRequest(r))
+	}))
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	require.NotNil(t, info)
+	return info
+}
+
+func TestClientIP(t *testing.T) {
+	trusted := ClientIPOptions{TrustedProxies: []string{"10.0.0.0/8", "192.0.2.1"}}
+
+	tests := []struct {
+		name       string
+		options    ClientIPOptions
+		remoteAddr string
+		headers    map[string]string
+		tls        bool
+		wantIP     string
+		wantScheme string
+		wantHost   string
+	}{
+		{
+			name:       "untrusted peer ignores headers",
+			options:    ClientIPOptions{},
+			remoteAddr: "192.0.2.1:1234",
+			headers:    map[string]string{XForwardedForHeader: "203.0.113.7", XForwardedProtoHeader: "https"},
+			wantIP:     "192.0.2.1",
+			wantScheme: "http",
+			wantHost:   "example.com",
+		},
+		{
+			name:       "x-forwarded-for skips trusted hops",
+			options:    trusted,
+			remoteAddr: "192.0.2.1:1234",
+			headers: map[string]string{
+				XForwardedForHeader:   "198.51.100.9, 203.0.113.7, 10.1.2.3",
+				XForwardedProtoHeader: "HTTPS",
+				XForwardedHostHeader:  "spoofed.example.com, api.example.com",
+			},
+			wantIP:     "203.0.113.7",
+			wantScheme: "https",
+			wantHost:   "api.example.com",
+		},
+		{
+			name:       "all hops trusted",
+			options:    trusted,
+			remoteAddr: "192.0.2.1:1234",
+			headers:    map[string]string{XForwardedForHeader: "10.0.0.5, 10.0.0.6"},
+			wantIP:     "10.0.0.5",
+			wantScheme: "http",
+			wantHost:   "example.com",
+		},
+		{
+			name:       "invalid hop stops the walk",
+			options:    trusted,
+			remoteAddr: "192.0.2.1:1234",
+			headers:    map[string]string{XForwardedForHeader: "203.0.113.7, unknown, 10.0.0.5"},
+			wantIP:     "10.0.0.5",
+			wantScheme: "http",
+			wantHost:   "example.com",
+		},
+		{
+			name:       "forwarded header takes precedence",
+			options:    trusted,
+			remoteAddr: "192.0.2.1:1234",
+			headers: map[string]string{
+				ForwardedHeader:     `for="[2001:db8::1]:4711";proto=https;host=api.example.com, for=10.0.0.2`,
+				XForwardedForHeader: "198.51.100.9",
+			},
+			wantIP:     "2001:db8::1",
+			wantScheme: "https",
+			wantHost:   "api.example.com",
+		},
+		{
+			name:       "forwarded host and proto come from the client hop",
+			options:    trusted,
+			remoteAddr: "192.0.2.1:1234",
+			headers: map[string]string{
+				ForwardedHeader: `for=198.51.100.9;proto=http;host=evil, for=203.0.113.7;proto=https;host=api.example.com`,
+			},
+			wantIP:     "203.0.113.7",
+			wantScheme: "https",
+			wantHost:   "api.example.com",
+		},
+		{
+			name:       "x-real-ip",
+			options:    trusted,
+			remoteAddr: "192.0.2.1:1234",
+			headers:    map[string]string{XRealIPHeader: "203.0.113.7"},
+			wantIP:     "203.0.113.7",
+			wantScheme: "http",
+			wantHost:   "example.com",
+		},
+		{
+			name:       "tls without proxy",
+			options:    trusted,
+			remoteAddr: "198.51.100.9:1234",
+			tls:        true,
+			wantIP:     "198.51.100.9",
+			wantScheme: "https",
+			wantHost:   "example.com",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = tt.remoteAddr
+			for key, value := range tt.headers {
+				req.Header.Set(key, value)
+			}
+			if tt.tls {
+				req.TLS = &tls.ConnectionState{}
+			}
+
+			info := resolveClientInfo(t, tt.options, req)
+			assert.Equal(t, tt.wantIP, info.IP)
+			assert.Equal(t, tt.wantScheme, info.Scheme)
+			assert.Equal(t, tt.wantHost, info.Host)
+			assert.Nil(t, info.Geo)
+		})
+	}
+}
+
+func TestClientIP_InvalidTrustedProxy(t *testing.T) {
+	assert.Panics(t, func() { ClientIP(ClientIPOptions{TrustedProxies: []string{"not-an-ip"}}) })
+}
+
+func TestClientIPFromRequest_WithoutMiddleware(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(XForwardedForHeader, "203.0.113.7")
+	assert.Equal(t, "192.0.2.1", ClientIPFromRequest(req))
+}
+
+// out of the module requirements.
+const testGeoIPDatabase = "testdata/geoip_city.mmdb"
+
+func TestClientIP_GeoIP(t *testing.T) {
+	geoIP, err := NewMaxMindGeoIP(testGeoIPDatabase)
+	require.NoError(t, err)
+	defer geoIP.Close()
+
+	location, err := geoIP.Lookup(netip.MustParseAddr("198.51.100.9"))
+	require.NoError(t, err)
+	assert.Nil(t, location)
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(XForwardedForHeader, "203.0.113.7")
+	info := resolveClientInfo(t, ClientIPOptions{TrustedProxies: PrivateNetworks, GeoIP: geoIP}, req)
+	assert.Equal(t, "192.0.2.1", info.IP)
+	assert.Nil(t, info.Geo)
+
+	req.RemoteAddr = "10.0.0.1:1234"
+	info = resolveClientInfo(t, ClientIPOptions{TrustedProxies: PrivateNetworks, GeoIP: geoIP}, req)
+	assert.Equal(t, "203.0.113.7", info.IP)
+	assert.Equal(t, &GeoLocation{
+		CountryCode: "ES",
+		Country:     "Spain",
+		City:        "Madrid",
+		Latitude:    40.4165,
+		Longitude:   -3.7026,
+		TimeZone:    "Europe/Madrid",
+	}, info.Geo)
+}
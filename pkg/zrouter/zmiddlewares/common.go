@@ -5,9 +5,12 @@ import (
 	"context"
 	"crypto/sha256"
 	"encoding/hex"
+	"encoding/json"
 	"github.com/go-chi/chi/v5"
 	"github.com/zondax/golem/pkg/logger"
+	"github.com/zondax/golem/pkg/zrouter/domain"
 	"io"
+	"net"
 	"net/http"
 	"regexp"
 	"strings"
@@ -82,6 +85,15 @@ func getRoutePrefix(ctx context.Context, route string) string {
 	return "/*"
 }
 
+// remoteIP returns the address of the peer that sent the request.
+func remoteIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
 func getRequestBody(r *http.Request) ([]byte, error) {
 	bodyBytes, err := io.ReadAll(r.Body)
 	if err != nil {
@@ -100,3 +112,9 @@ func generateBodyHash(body []byte) string {
 
 	return fullHash
 }
+
+func writeAPIError(w http.ResponseWriter, apiErr *domain.APIError) {
+	w.Header().Set(domain.ContentTypeHeader, domain.ContentTypeApplicationJSON)
+	w.WriteHeader(apiErr.HTTPStatus)
+	_ = json.NewEncoder(w).Encode(apiErr)
+}
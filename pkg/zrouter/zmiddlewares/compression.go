@@ -0,0 +1,321 @@
+package zmiddlewares
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/gzip"
+	"github.com/klauspost/compress/zstd"
+	"github.com/zondax/golem/pkg/zrouter/domain"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	EncodingGzip   = "gzip"
+	EncodingBrotli = "br"
+	EncodingZstd   = "zstd"
+
+	AcceptEncodingHeader  = "Accept-Encoding"
+	ContentEncodingHeader = "Content-Encoding"
+
+	defaultCompressionMinSize = 1024
+)
+
+var defaultCompressibleContentTypes = []string{
+	"application/json",
+	"application/problem+json",
+	"application/xml",
+	"application/javascript",
+	"text/*",
+}
+
+type CompressionOptions struct {
+	// Encodings in server preference order, used to break ties between equally weighted client
+	// preferences. Defaults to zstd, br, gzip.
+	Encodings []string
+	// MinSize is the body size in bytes under which responses are sent uncompressed. Defaults to 1KiB.
+	MinSize int
+	// ContentTypes lists the compressible media types; "type/*" matches a whole type.
+	// Defaults to JSON, XML, JavaScript and text.
+	ContentTypes []string
+}
+
+func (o *CompressionOptions) setDefaultValues() {
+	if len(o.Encodings) == 0 {
+		o.Encodings = []string{EncodingZstd, EncodingBrotli, EncodingGzip}
+	}
+
+	if o.MinSize == 0 {
+		o.MinSize = defaultCompressionMinSize
+	}
+
+	if len(o.ContentTypes) == 0 {
+		o.ContentTypes = defaultCompressibleContentTypes
+	}
+}
+
+type compressor interface {
+	io.WriteCloser
+	Flush() error
+	Reset(w io.Writer)
+}
+
+type zstdCompressor struct {
+	*zstd.Encoder
+}
+
+func (z zstdCompressor) Reset(w io.Writer) {
+	z.Encoder.Reset(w)
+}
+
+var compressorPools = map[string]*sync.Pool{
+	EncodingGzip: {New: func() interface{} {
+		return gzip.NewWriter(io.Discard)
+	}},
+	EncodingBrotli: {New: func() interface{} {
+		return brotli.NewWriterLevel(io.Discard, brotli.DefaultCompression)
+	}},
+	EncodingZstd: {New: func() interface{} {
+		encoder, _ := zstd.NewWriter(io.Discard, zstd.WithEncoderLevel(zstd.SpeedDefault), zstd.WithEncoderConcurrency(1))
+		return zstdCompressor{Encoder: encoder}
+	}},
+}
+
+// Compression compresses responses with the best encoding accepted by the client. Bodies are held
+// back until MinSize bytes are written, so the decision can be made on the actual size; flushing
+// forces it. Compressed responses get a weak ETag, since their bytes differ from the original ones.
+// It must wrap CacheMiddleware so the cache keeps uncompressed bodies, and be wrapped by
+// RequestMetrics for it to report the uncompressed size.
+func Compression(options CompressionOptions) Middleware {
+	options.setDefaultValues()
+
+	for _, encoding := range options.Encodings {
+		if _, ok := compressorPools[encoding]; !ok {
+			panic("unsupported compression encoding " + encoding)
+		}
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("Upgrade") != "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			AddVaryHeader(w.Header(), AcceptEncodingHeader)
+
+			encoding := negotiateEncoding(r.Header.Get(AcceptEncodingHeader), options.Encodings)
+			if encoding == "" || r.Method == http.MethodHead {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			cw := &compressResponseWriter{
+				ResponseWriter: w,
+				encoding:       encoding,
+				options:        &options,
+			}
+			defer cw.close(r.Context())
+
+			next.ServeHTTP(cw, r)
+		})
+	}
+}
+
+// negotiateEncoding picks the accepted encoding with the highest weight, empty for identity.
+func negotiateEncoding(acceptEncoding string, supported []string) string {
+	if acceptEncoding == "" {
+		return ""
+	}
+
+	weights := make(map[string]float64)
+	wildcard := -1.0
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		name = strings.ToLower(strings.TrimSpace(name))
+
+		weight := 1.0
+		if value, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if parsed, err := strconv.ParseFloat(value, 64); err == nil {
+				weight = parsed
+			}
+		}
+
+		if name == "*" {
+			wildcard = weight
+			continue
+		}
+		weights[name] = weight
+	}
+
+	best, bestWeight := "", 0.0
+	for _, encoding := range supported {
+		weight, ok := weights[encoding]
+		if !ok {
+			weight = wildcard
+		}
+		if weight > bestWeight {
+			best, bestWeight = encoding, weight
+		}
+	}
+	return best
+}
+
+type compressResponseWriter struct {
+	http.ResponseWriter
+	encoding   string
+	options    *CompressionOptions
+	status     int
+	buf        []byte
+	decided    bool
+	compressor compressor
+	raw        int64
+}
+
+func (cw *compressResponseWriter) WriteHeader(statusCode int) {
+	if cw.status != 0 {
+		return
+	}
+
+	cw.status = statusCode
+	if statusCode < http.StatusOK || statusCode == http.StatusNoContent || statusCode == http.StatusNotModified {
+		cw.decide(false)
+	}
+}
+
+func (cw *compressResponseWriter) Write(p []byte) (int, error) {
+	if cw.status == 0 {
+		cw.WriteHeader(http.StatusOK)
+	}
+	cw.raw += int64(len(p))
+
+	if !cw.decided {
+		cw.buf = append(cw.buf, p...)
+		if len(cw.buf) >= cw.options.MinSize {
+			if err := cw.decide(true); err != nil {
+				return 0, err
+			}
+		}
+		return len(p), nil
+	}
+
+	if cw.compressor != nil {
+		return cw.compressor.Write(p)
+	}
+	return cw.ResponseWriter.Write(p)
+}
+
+func (cw *compressResponseWriter) Flush() {
+	if cw.status == 0 {
+		cw.WriteHeader(http.StatusOK)
+	}
+
+	if !cw.decided {
+		_ = cw.decide(true)
+	}
+	if cw.compressor != nil {
+		_ = cw.compressor.Flush()
+	}
+	if flusher, ok := cw.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (cw *compressResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := cw.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("the underlying http.ResponseWriter does not implement http.Hijacker")
+	}
+	return hijacker.Hijack()
+}
+
+func (cw *compressResponseWriter) Unwrap() http.ResponseWriter {
+	return cw.ResponseWriter
+}
+
+// decide sends the headers, compressing the body if allowed and the response qualifies, and writes
+// the held back bytes.
+func (cw *compressResponseWriter) decide(allowed bool) error {
+	cw.decided = true
+	header := cw.Header()
+
+	if allowed && header.Get(ContentEncodingHeader) == "" && cw.isCompressible(header.Get(domain.ContentTypeHeader)) {
+		header.Del("Content-Length")
+		header.Set(ContentEncodingHeader, cw.encoding)
+		if etag := header.Get(ETagHeader); etag != "" && !strings.HasPrefix(etag, "W/") {
+			header.Set(ETagHeader, "W/"+etag)
+		}
+
+		cw.compressor = compressorPools[cw.encoding].Get().(compressor)
+		cw.compressor.Reset(cw.ResponseWriter)
+	}
+
+	if cw.status == 0 {
+		cw.status = http.StatusOK
+	}
+	cw.ResponseWriter.WriteHeader(cw.status)
+
+	if len(cw.buf) == 0 {
+		return nil
+	}
+
+	var err error
+	if cw.compressor != nil {
+		_, err = cw.compressor.Write(cw.buf)
+	} else {
+		_, err = cw.ResponseWriter.Write(cw.buf)
+	}
+	cw.buf = nil
+	return err
+}
+
+func (cw *compressResponseWriter) isCompressible(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+
+	for _, allowed := range cw.options.ContentTypes {
+		if prefix, ok := strings.CutSuffix(allowed, "/*"); ok {
+			if strings.HasPrefix(mediaType, prefix+"/") {
+				return true
+			}
+			continue
+		}
+		if mediaType == allowed {
+			return true
+		}
+	}
+	return false
+}
+
+func (cw *compressResponseWriter) close(ctx context.Context) {
+	if !cw.decided {
+		if cw.status == 0 && len(cw.buf) == 0 {
+			// Nothing was written: let the server send its default response
+			return
+		}
+		// Bodies under MinSize are sent as they are
+		_ = cw.decide(false)
+	}
+
+	if cw.compressor == nil {
+		return
+	}
+
+	_ = cw.compressor.Close()
+	cw.compressor.Reset(io.Discard)
+	compressorPools[cw.encoding].Put(cw.compressor)
+
+	if stats, ok := ctx.Value(responseStatsKey{}).(*responseStats); ok {
+		stats.uncompressed = cw.raw
+		stats.compressed = true
+	}
+}
@@ -0,0 +1,238 @@
+package zmiddlewares
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/andybalholm/brotli"
+	"github.com/go-chi/chi/v5"
+	"github.com/klauspost/compress/gzip"
+	"github.com/klauspost/compress/zstd"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/zondax/golem/pkg/logger"
+	"github.com/zondax/golem/pkg/metrics"
+	"github.com/zondax/golem/pkg/zcache"
+	"github.com/zondax/golem/pkg/zrouter/domain"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+var largeJSON = []byte(`{"items":"` + strings.Repeat("compressible ", 200) + `"}`)
+
+func compressionRouter(options CompressionOptions) *chi.Mux {
+	r := chi.NewRouter()
+	r.Use(Compression(options))
+	r.Get("/json", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(domain.ContentTypeHeader, domain.ContentTypeApplicationJSON+"; charset=utf-8")
+		w.Header().Set(ETagHeader, `"v1"`)
+		_, _ = w.Write(largeJSON)
+	})
+	r.Get("/small", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(domain.ContentTypeHeader, domain.ContentTypeApplicationJSON)
+		_, _ = w.Write([]byte(`{}`))
+	})
+	r.Get("/image", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(domain.ContentTypeHeader, "image/png")
+		_, _ = w.Write(largeJSON)
+	})
+	r.Get("/not-modified", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotModified)
+	})
+	return r
+}
+
+func decompress(t *testing.T, encoding string, body []byte) []byte {
+	var reader io.Reader
+	switch encoding {
+	case EncodingGzip:
+		gz, err := gzip.NewReader(bytes.NewReader(body))
+		require.NoError(t, err)
+		reader = gz
+	case EncodingBrotli:
+		reader = brotli.NewReader(bytes.NewReader(body))
+	case EncodingZstd:
+		decoder, err := zstd.NewReader(bytes.NewReader(body))
+		require.NoError(t, err)
+		defer decoder.Close()
+		reader = decoder
+	default:
+		return body
+	}
+
+	decoded, err := io.ReadAll(reader)
+	require.NoError(t, err)
+	return decoded
+}
+
+func TestNegotiateEncoding(t *testing.T) {
+	supported := []string{EncodingZstd, EncodingBrotli, EncodingGzip}
+
+	tests := []struct {
+		acceptEncoding string
+		expected       string
+	}{
+		{"", ""},
+		{"gzip", EncodingGzip},
+		{"gzip, br", EncodingBrotli},
+		{"gzip;q=1.0, br;q=0.5", EncodingGzip},
+		{"*", EncodingZstd},
+		{"*, zstd;q=0", EncodingBrotli},
+		{"deflate, identity", ""},
+		{"GZIP;q=0.3", EncodingGzip},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.expected, negotiateEncoding(tt.acceptEncoding, supported), tt.acceptEncoding)
+	}
+}
+
+func TestCompressionEncodings(t *testing.T) {
+	r := compressionRouter(CompressionOptions{})
+
+	for _, encoding := range []string{EncodingGzip, EncodingBrotli, EncodingZstd} {
+		// Pooled compressors must be reusable
+		for i := 0; i < 2; i++ {
+			req := httptest.NewRequest(http.MethodGet, "/json", nil)
+			req.Header.Set(AcceptEncodingHeader, encoding)
+			rec := httptest.NewRecorder()
+			r.ServeHTTP(rec, req)
+
+			assert.Equal(t, http.StatusOK, rec.Code)
+			assert.Equal(t, encoding, rec.Header().Get(ContentEncodingHeader))
+			assert.Equal(t, AcceptEncodingHeader, rec.Header().Get(VaryHeader))
+			assert.Equal(t, `W/"v1"`, rec.Header().Get(ETagHeader))
+			assert.Less(t, rec.Body.Len(), len(largeJSON))
+			assert.Equal(t, largeJSON, decompress(t, encoding, rec.Body.Bytes()))
+		}
+	}
+}
+
+func TestCompressionDoesNotDuplicateVary(t *testing.T) {
+	handler := Compression(CompressionOptions{})(compressionRouter(CompressionOptions{}))
+
+	req := httptest.NewRequest(http.MethodGet, "/json", nil)
+	req.Header.Set(AcceptEncodingHeader, EncodingGzip)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	assert.Equal(t, []string{AcceptEncodingHeader}, rec.Header().Values(VaryHeader))
+}
+
+func TestCompressionSkipsIneligibleResponses(t *testing.T) {
+	r := compressionRouter(CompressionOptions{Encodings: []string{EncodingGzip}})
+
+	for _, path := range []string{"/small", "/image", "/not-modified"} {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set(AcceptEncodingHeader, EncodingGzip)
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+
+		assert.Empty(t, rec.Header().Get(ContentEncodingHeader), path)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/json", nil)
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	assert.Empty(t, rec.Header().Get(ContentEncodingHeader))
+	assert.Equal(t, largeJSON, rec.Body.Bytes())
+	assert.Equal(t, `"v1"`, rec.Header().Get(ETagHeader))
+}
+
+func TestCompressionFlushesStreams(t *testing.T) {
+	r := chi.NewRouter()
+	r.Use(Compression(CompressionOptions{Encodings: []string{EncodingGzip}}))
+	r.Get("/stream", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(domain.ContentTypeHeader, "text/plain")
+		_, _ = w.Write([]byte("first chunk"))
+		w.(http.Flusher).Flush()
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/stream", nil)
+	req.Header.Set(AcceptEncodingHeader, EncodingGzip)
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+
+	assert.True(t, rec.Flushed)
+	assert.Equal(t, EncodingGzip, rec.Header().Get(ContentEncodingHeader))
+	assert.Equal(t, "first chunk", string(decompress(t, EncodingGzip, rec.Body.Bytes())))
+}
+
+func TestCompressionReportsUncompressedSize(t *testing.T) {
+	logger.InitLogger(logger.Config{})
+
+	sizes := map[string]float64{}
+	mockMetrics := new(metrics.MockTaskMetrics)
+	mockMetrics.On("UpdateMetric", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil).Run(func(args mock.Arguments) {
+		sizes[args.String(0)] = args.Get(1).(float64)
+	})
+	mockMetrics.On("IncrementMetric", inFlightRequestsMetricName, mock.Anything, mock.Anything).Return(nil)
+	mockMetrics.On("DecrementMetric", inFlightRequestsMetricName, mock.Anything, mock.Anything).Return(nil)
+
+	r := chi.NewRouter()
+	r.Use(RequestMetrics(mockMetrics))
+	r.Use(Compression(CompressionOptions{Encodings: []string{EncodingGzip}}))
+	r.Get("/json", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(domain.ContentTypeHeader, domain.ContentTypeApplicationJSON)
+		_, _ = w.Write(largeJSON)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/json", nil)
+	req.Header.Set(AcceptEncodingHeader, EncodingGzip)
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+
+	assert.Equal(t, float64(rec.Body.Len()), sizes[responseSizeMetricName])
+	assert.Equal(t, float64(len(largeJSON)), sizes[uncompressedSizeMetricName])
+}
+
+func TestCompressionAroundCache(t *testing.T) {
+	logger.InitLogger(logger.Config{})
+
+	mr, err := miniredis.Run()
+	require.NoError(t, err)
+	defer mr.Close()
+
+	cache, err := zcache.NewRemoteCache(&zcache.RemoteConfig{Addr: mr.Addr()})
+	require.NoError(t, err)
+
+	ms := metrics.NewTaskMetrics("", "", "appName")
+	RegisterRequestMetrics(ms)
+
+	r := chi.NewRouter()
+	r.Use(Compression(CompressionOptions{Encodings: []string{EncodingGzip}}))
+	r.Use(CacheMiddleware(ms, cache, domain.CacheConfig{Paths: map[string]time.Duration{"/json": time.Minute}}))
+	r.Get("/json", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(domain.ContentTypeHeader, domain.ContentTypeApplicationJSON)
+		_, _ = w.Write(largeJSON)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/json", nil)
+	req.Header.Set(AcceptEncodingHeader, EncodingGzip)
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	assert.Equal(t, EncodingGzip, rec.Header().Get(ContentEncodingHeader))
+
+	keys := mr.Keys()
+	require.Len(t, keys, 1)
+	stored, err := mr.Get(keys[0])
+	require.NoError(t, err)
+	var entry cachedResponse
+	require.NoError(t, json.Unmarshal([]byte(stored), &entry))
+	assert.Equal(t, largeJSON, entry.Body)
+
+	// Replayed entries are compressed on the way out only for clients accepting it
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/json", nil))
+	assert.Empty(t, rec.Header().Get(ContentEncodingHeader))
+	assert.Equal(t, largeJSON, rec.Body.Bytes())
+
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	assert.Equal(t, largeJSON, decompress(t, EncodingGzip, rec.Body.Bytes()))
+}
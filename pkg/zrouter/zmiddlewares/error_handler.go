@@ -13,16 +13,33 @@ const (
 	internalErrorCode = "internal_error"
 )
 
+type ErrorHandlerOptions struct {
+	// ProblemDetails switches the recovered panic responses to RFC 7807 application/problem+json.
+	ProblemDetails bool
+	// ProblemRegistry maps panics carrying an error value to problems. Only used with ProblemDetails.
+	ProblemRegistry *domain.ProblemRegistry
+}
+
 func ErrorHandlerMiddleware() Middleware {
+	return ErrorHandlerMiddlewareWithOptions(ErrorHandlerOptions{})
+}
+
+func ErrorHandlerMiddlewareWithOptions(options ErrorHandlerOptions) Middleware {
 	return func(next http.Handler) http.Handler {
 		fn := func(w http.ResponseWriter, r *http.Request) {
 			defer func() {
 				if err := recover(); err != nil {
 					logger.GetLoggerFromContext(r.Context()).Errorf("Internal error: %v\n%s", err, debug.Stack())
+
+					if options.ProblemDetails {
+						WriteProblemDetails(w, r, recoveredProblem(err, options.ProblemRegistry))
+						return
+					}
+
 					message := fmt.Sprintf("An internal error occurred: %v", err)
 					apiError := domain.NewAPIErrorResponse(http.StatusInternalServerError, internalErrorCode, message)
 
-					w.Header().Set(domain.ContentTypeHeader, domain.ContentTypeJSON)
+					w.Header().Set(domain.ContentTypeHeader, domain.ContentTypeApplicationJSON)
 					w.WriteHeader(apiError.HTTPStatus)
 					_ = json.NewEncoder(w).Encode(apiError)
 				}
@@ -34,3 +51,11 @@ func ErrorHandlerMiddleware() Middleware {
 		return http.HandlerFunc(fn)
 	}
 }
+
+func recoveredProblem(recovered interface{}, registry *domain.ProblemRegistry) *domain.ProblemDetails {
+	if err, ok := recovered.(error); ok {
+		return registry.Resolve(err)
+	}
+
+	return registry.Resolve(fmt.Errorf("panic: %v", recovered))
+}
@@ -2,6 +2,8 @@ package zmiddlewares
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"github.com/go-chi/chi/v5"
 	"github.com/stretchr/testify/assert"
 	"github.com/zondax/golem/pkg/logger"
@@ -25,6 +27,7 @@ func TestErrorHandlerMiddleware(t *testing.T) {
 	r.ServeHTTP(rec, req)
 
 	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	assert.Equal(t, domain.ContentTypeApplicationJSON, rec.Header().Get(domain.ContentTypeHeader))
 
 	var apiError domain.APIError
 	err := json.NewDecoder(rec.Body).Decode(&apiError)
@@ -32,3 +35,41 @@ func TestErrorHandlerMiddleware(t *testing.T) {
 	assert.Equal(t, "internal_error", apiError.ErrorCode)
 	assert.Contains(t, apiError.Message, "Some unexpected error")
 }
+
+func TestErrorHandlerMiddleware_ProblemDetails(t *testing.T) {
+	logger.InitLogger(logger.Config{})
+	errUnavailable := errors.New("dependency unavailable")
+	registry := domain.NewProblemRegistry()
+	registry.Register(errUnavailable, http.StatusServiceUnavailable, "Service Unavailable")
+
+	r := chi.NewRouter()
+	r.Use(ErrorHandlerMiddlewareWithOptions(ErrorHandlerOptions{ProblemDetails: true, ProblemRegistry: registry}))
+
+	r.Get("/panic", func(w http.ResponseWriter, r *http.Request) {
+		panic("Some unexpected error")
+	})
+	r.Get("/panic-error", func(w http.ResponseWriter, r *http.Request) {
+		panic(fmt.Errorf("wrapped: %w", errUnavailable))
+	})
+
+	req := httptest.NewRequest("GET", "/panic", nil)
+	req.Header.Set(RequestIDHeader, "req-1")
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	assert.Equal(t, domain.ContentTypeProblemJSON, rec.Header().Get(domain.ContentTypeHeader))
+
+	var problem domain.ProblemDetails
+	assert.NoError(t, json.NewDecoder(rec.Body).Decode(&problem))
+	assert.Equal(t, http.StatusInternalServerError, problem.Status)
+	assert.Equal(t, "/panic", problem.Instance)
+	assert.Equal(t, "req-1", problem.RequestID)
+	assert.NotContains(t, problem.Detail, "Some unexpected error")
+
+	req = httptest.NewRequest("GET", "/panic-error", nil)
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+}
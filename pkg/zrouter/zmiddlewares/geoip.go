@@ -0,0 +1,74 @@
+package zmiddlewares
+
+import (
+	"github.com/oschwald/maxminddb-golang/v2"
+	"net/netip"
+)
+
+// GeoLocation is the location of a client IP, as found in a GeoIP database.
+type GeoLocation struct {
+	CountryCode string
+	Country     string
+	City        string
+	Latitude    float64
+	Longitude   float64
+	TimeZone    string
+}
+
+type GeoIPResolver interface {
+	// Lookup returns nil without error when the IP is not in the database.
+	Lookup(ip netip.Addr) (*GeoLocation, error)
+}
+
+// MaxMindGeoIP resolves locations from a local MaxMind (GeoLite2/GeoIP2 City or Country) database file.
+type MaxMindGeoIP struct {
+	reader *maxminddb.Reader
+}
+
+type maxMindRecord struct {
+	Country struct {
+		ISOCode string            `maxminddb:"iso_code"`
+		Names   map[string]string `maxminddb:"names"`
+	} `maxminddb:"country"`
+	City struct {
+		Names map[string]string `maxminddb:"names"`
+	} `maxminddb:"city"`
+	Location struct {
+		Latitude  float64 `maxminddb:"latitude"`
+		Longitude float64 `maxminddb:"longitude"`
+		TimeZone  string  `maxminddb:"time_zone"`
+	} `maxminddb:"location"`
+}
+
+func NewMaxMindGeoIP(path string) (*MaxMindGeoIP, error) {
+	reader, err := maxminddb.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	return &MaxMindGeoIP{reader: reader}, nil
+}
+
+func (m *MaxMindGeoIP) Lookup(ip netip.Addr) (*GeoLocation, error) {
+	result := m.reader.Lookup(ip)
+	if !result.Found() {
+		return nil, result.Err()
+	}
+
+	var record maxMindRecord
+	if err := result.Decode(&record); err != nil {
+		return nil, err
+	}
+
+	return &GeoLocation{
+		CountryCode: record.Country.ISOCode,
+		Country:     record.Country.Names["en"],
+		City:        record.City.Names["en"],
+		Latitude:    record.Location.Latitude,
+		Longitude:   record.Location.Longitude,
+		TimeZone:    record.Location.TimeZone,
+	}, nil
+}
+
+func (m *MaxMindGeoIP) Close() error {
+	return m.reader.Close()
+}
@@ -1,8 +1,10 @@
 package zmiddlewares
 
 import (
+	"context"
 	"github.com/google/uuid"
 	"github.com/zondax/golem/pkg/logger"
+	"go.opentelemetry.io/otel/trace"
 	"net/http"
 )
 
@@ -24,15 +26,29 @@ func requestIDMiddleware(next http.Handler) http.Handler {
 
 		w.Header().Set(RequestIDHeader, requestID)
 		rw := &responseWriter{ResponseWriter: w}
-		newLogger := logger.NewLogger(logger.Field{
-			Key:   logger.RequestIDKey,
-			Value: requestID,
-		})
-		ctx := logger.ContextWithLogger(r.Context(), newLogger)
+		ctx := logger.ContextWithLogger(r.Context(), newRequestLogger(r.Context(), requestID))
 		next.ServeHTTP(rw, r.WithContext(ctx))
 	})
 }
 
+// newRequestLogger builds the request-scoped logger, tagged with the request ID and, when the request
+// is traced, with the trace and span IDs so logs can be correlated with spans.
+func newRequestLogger(ctx context.Context, requestID string) *logger.Logger {
+	var fields []interface{}
+	if requestID != "" {
+		fields = append(fields, logger.Field{Key: logger.RequestIDKey, Value: requestID})
+	}
+
+	if spanContext := trace.SpanContextFromContext(ctx); spanContext.IsValid() {
+		fields = append(fields,
+			logger.Field{Key: logger.TraceIDKey, Value: spanContext.TraceID().String()},
+			logger.Field{Key: logger.SpanIDKey, Value: spanContext.SpanID().String()},
+		)
+	}
+
+	return logger.NewLogger(fields...)
+}
+
 func Logger(options LoggingMiddlewareOptions) Middleware {
 	return LoggingMiddleware(options)
 }
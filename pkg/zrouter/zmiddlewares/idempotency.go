@@ -0,0 +1,190 @@
+package zmiddlewares
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"github.com/zondax/golem/pkg/logger"
+	"github.com/zondax/golem/pkg/zcache"
+	"github.com/zondax/golem/pkg/zrouter/auth"
+	"github.com/zondax/golem/pkg/zrouter/domain"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	IdempotencyKeyHeader      = "Idempotency-Key"
+	IdempotentReplayedHeader  = "Idempotent-Replayed"
+	idempotencyCacheKeyPrefix = "zrouter_idempotency"
+	maxIdempotencyKeyLength   = 255
+
+	defaultIdempotencyRetention   = 24 * time.Hour
+	defaultIdempotencyLockTimeout = time.Minute
+
+	idempotencyKeyRequiredErrorCode = "idempotency_key_required"
+	idempotencyKeyInvalidErrorCode  = "idempotency_key_invalid"
+	idempotencyKeyReusedErrorCode   = "idempotency_key_reused"
+	idempotencyConflictErrorCode    = "idempotency_request_in_progress"
+)
+
+type IdempotencyOptions struct {
+	Cache zcache.RemoteCache
+	// Retention is how long a response is kept for replay. Defaults to 24h.
+	Retention time.Duration
+	// LockTimeout bounds how long a request holds its key while being processed. Defaults to 1m.
+	LockTimeout time.Duration
+	// Methods defaults to POST and PATCH.
+	Methods []string
+	// Required rejects requests without an Idempotency-Key header.
+	Required bool
+}
+
+func (o *IdempotencyOptions) setDefaultValues() {
+	if o.Retention == 0 {
+		o.Retention = defaultIdempotencyRetention
+	}
+
+	if o.LockTimeout == 0 {
+		o.LockTimeout = defaultIdempotencyLockTimeout
+	}
+
+	if len(o.Methods) == 0 {
+		o.Methods = []string{http.MethodPost, http.MethodPatch}
+	}
+}
+
+type idempotentResponse struct {
+	RequestHash string      `json:"request_hash"`
+	Status      int         `json:"status"`
+	Header      http.Header `json:"header"`
+	Body        []byte      `json:"body"`
+}
+
+// Idempotency stores the first response to every request carrying an Idempotency-Key and replays it
+// on retries. Keys are scoped to the authenticated principal when there is one. A retry arriving
+// while the first request is still being processed retries the key lock a few times (the redsync
+// defaults, a few seconds) and gets the stored response if the first one completed meanwhile, or 409
+// otherwise. Reusing a key with a different request is rejected with 422. Server errors are not
+// stored, so they can be retried.
+func Idempotency(options IdempotencyOptions) Middleware {
+	options.setDefaultValues()
+
+	methods := make(map[string]struct{}, len(options.Methods))
+	for _, method := range options.Methods {
+		methods[method] = struct{}{}
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if _, ok := methods[r.Method]; !ok {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			idempotencyKey := r.Header.Get(IdempotencyKeyHeader)
+			switch {
+			case idempotencyKey == "" && options.Required:
+				writeAPIError(w, domain.NewAPIErrorResponse(http.StatusBadRequest, idempotencyKeyRequiredErrorCode, "missing Idempotency-Key header"))
+				return
+			case idempotencyKey == "":
+				next.ServeHTTP(w, r)
+				return
+			case len(idempotencyKey) > maxIdempotencyKeyLength:
+				writeAPIError(w, domain.NewAPIErrorResponse(http.StatusBadRequest, idempotencyKeyInvalidErrorCode, "Idempotency-Key is too long"))
+				return
+			}
+
+			body, err := getRequestBody(r)
+			if err != nil {
+				writeAPIError(w, domain.NewAPIErrorResponse(http.StatusBadRequest, "invalid_body", "unable to read request body"))
+				return
+			}
+
+			cacheKey := idempotencyCacheKey(r, idempotencyKey)
+			requestHash := idempotencyRequestHash(r, body)
+
+			if replayIdempotentResponse(w, r, options.Cache, cacheKey, requestHash) {
+				return
+			}
+
+			mutex := options.Cache.NewMutex(cacheKey+":lock", options.LockTimeout)
+			if err = mutex.Lock(); err != nil {
+				logger.GetLoggerFromContext(r.Context()).Debugf("Idempotency key %s is locked: %v", idempotencyKey, err)
+				writeAPIError(w, domain.NewAPIErrorResponse(http.StatusConflict, idempotencyConflictErrorCode, "a request with this Idempotency-Key is being processed"))
+				return
+			}
+			defer func() {
+				if _, err := mutex.Unlock(); err != nil {
+					logger.GetLoggerFromContext(r.Context()).Errorf("Error releasing idempotency lock: %v", err)
+				}
+			}()
+
+			// A concurrent duplicate may have completed while this one was waiting for the lock
+			if replayIdempotentResponse(w, r, options.Cache, cacheKey, requestHash) {
+				return
+			}
+
+			rw := &responseWriter{ResponseWriter: w, captureBody: true}
+			next.ServeHTTP(rw, r)
+
+			if rw.status == 0 {
+				rw.status = http.StatusOK
+			}
+			if rw.status >= http.StatusInternalServerError || isEventStream(rw.Header().Get(domain.ContentTypeHeader)) {
+				return
+			}
+
+			header := rw.Header().Clone()
+			header.Del(RequestIDHeader)
+			stored := idempotentResponse{
+				RequestHash: requestHash,
+				Status:      rw.status,
+				Header:      header,
+				Body:        rw.Body(),
+			}
+			if err = options.Cache.Set(r.Context(), cacheKey, stored, options.Retention); err != nil {
+				logger.GetLoggerFromContext(r.Context()).Errorf("Error storing idempotent response: %v", err)
+			}
+		})
+	}
+}
+
+// replayIdempotentResponse answers the request from the stored response, if any.
+func replayIdempotentResponse(w http.ResponseWriter, r *http.Request, cache zcache.RemoteCache, cacheKey, requestHash string) bool {
+	var stored idempotentResponse
+	if err := cache.Get(r.Context(), cacheKey, &stored); err != nil {
+		if !cache.IsNotFoundError(err) {
+			logger.GetLoggerFromContext(r.Context()).Errorf("Error reading idempotent response: %v", err)
+		}
+		return false
+	}
+
+	if stored.RequestHash != requestHash {
+		writeAPIError(w, domain.NewAPIErrorResponse(http.StatusUnprocessableEntity, idempotencyKeyReusedErrorCode, "Idempotency-Key was already used with a different request"))
+		return true
+	}
+
+	for key, values := range stored.Header {
+		w.Header()[key] = values
+	}
+	w.Header().Set(IdempotentReplayedHeader, strconv.FormatBool(true))
+	w.WriteHeader(stored.Status)
+	_, _ = w.Write(stored.Body)
+	return true
+}
+
+func idempotencyCacheKey(r *http.Request, idempotencyKey string) string {
+	scope := ""
+	if principal, ok := auth.PrincipalFromContext(r.Context()); ok {
+		scope = principal.ID
+	}
+	return fmt.Sprintf("%s:%s:%s", idempotencyCacheKeyPrefix, scope, idempotencyKey)
+}
+
+func idempotencyRequestHash(r *http.Request, body []byte) string {
+	hasher := sha256.New()
+	hasher.Write([]byte(r.Method + "\n" + r.URL.RequestURI() + "\n"))
+	hasher.Write(body)
+	return hex.EncodeToString(hasher.Sum(nil))
+}
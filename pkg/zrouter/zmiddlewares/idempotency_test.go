@@ -0,0 +1,137 @@
+package zmiddlewares
+
+import (
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/suite"
+	"github.com/zondax/golem/pkg/logger"
+	"github.com/zondax/golem/pkg/zcache"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type IdempotencySuite struct {
+	suite.Suite
+	mr      *miniredis.Miniredis
+	router  *chi.Mux
+	calls   atomic.Int32
+	release chan struct{}
+}
+
+func (s *IdempotencySuite) SetupTest() {
+	logger.InitLogger(logger.Config{})
+
+	mr, err := miniredis.Run()
+	s.Require().NoError(err)
+	s.mr = mr
+
+	cache, err := zcache.NewRemoteCache(&zcache.RemoteConfig{Addr: mr.Addr()})
+	s.Require().NoError(err)
+
+	s.calls.Store(0)
+	s.release = nil
+
+	s.router = chi.NewRouter()
+	s.router.Use(RequestID())
+	s.router.Use(Idempotency(IdempotencyOptions{Cache: cache, Retention: time.Hour}))
+
+	s.router.Post("/payments", func(w http.ResponseWriter, r *http.Request) {
+		call := s.calls.Add(1)
+		if s.release != nil {
+			<-s.release
+		}
+		w.Header().Set("Location", "/payments/"+strconv.Itoa(int(call)))
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte(`{"id":` + strconv.Itoa(int(call)) + `}`))
+	})
+	s.router.Post("/failing", func(w http.ResponseWriter, r *http.Request) {
+		s.calls.Add(1)
+		w.WriteHeader(http.StatusBadGateway)
+	})
+}
+
+func (s *IdempotencySuite) TearDownTest() {
+	s.mr.Close()
+}
+
+func (s *IdempotencySuite) post(path, key, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+	if key != "" {
+		req.Header.Set(IdempotencyKeyHeader, key)
+	}
+	rec := httptest.NewRecorder()
+	s.router.ServeHTTP(rec, req)
+	return rec
+}
+
+func (s *IdempotencySuite) TestReplaysStoredResponse() {
+	first := s.post("/payments", "key-1", `{"amount":10}`)
+	s.Equal(http.StatusCreated, first.Code)
+	s.Empty(first.Header().Get(IdempotentReplayedHeader))
+
+	retry := s.post("/payments", "key-1", `{"amount":10}`)
+	s.Equal(http.StatusCreated, retry.Code)
+	s.Equal(first.Body.String(), retry.Body.String())
+	s.Equal("/payments/1", retry.Header().Get("Location"))
+	s.Equal("true", retry.Header().Get(IdempotentReplayedHeader))
+	s.NotEqual(first.Header().Get(RequestIDHeader), retry.Header().Get(RequestIDHeader))
+	s.Equal(int32(1), s.calls.Load())
+
+	s.True(s.mr.TTL(idempotencyCacheKeyPrefix+"::key-1") > 59*time.Minute)
+}
+
+func (s *IdempotencySuite) TestRejectsKeyReuseWithDifferentPayload() {
+	s.Equal(http.StatusCreated, s.post("/payments", "key-1", `{"amount":10}`).Code)
+
+	rec := s.post("/payments", "key-1", `{"amount":99}`)
+	s.Equal(http.StatusUnprocessableEntity, rec.Code)
+	s.Contains(rec.Body.String(), idempotencyKeyReusedErrorCode)
+	s.Equal(int32(1), s.calls.Load())
+}
+
+func (s *IdempotencySuite) TestRequestsWithoutKeyAreNotDeduplicated() {
+	s.post("/payments", "", `{"amount":10}`)
+	s.post("/payments", "", `{"amount":10}`)
+	s.Equal(int32(2), s.calls.Load())
+}
+
+func (s *IdempotencySuite) TestServerErrorsAreNotStored() {
+	s.Equal(http.StatusBadGateway, s.post("/failing", "key-1", `{}`).Code)
+	s.Equal(http.StatusBadGateway, s.post("/failing", "key-1", `{}`).Code)
+	s.Equal(int32(2), s.calls.Load())
+}
+
+func (s *IdempotencySuite) TestConcurrentDuplicateWaitsForFirstResponse() {
+	s.release = make(chan struct{})
+
+	var wg sync.WaitGroup
+	responses := make([]*httptest.ResponseRecorder, 2)
+	for i := range responses {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			responses[i] = s.post("/payments", "key-1", `{"amount":10}`)
+		}(i)
+	}
+
+	s.Eventually(func() bool { return s.calls.Load() == 1 }, time.Second, 5*time.Millisecond)
+	time.Sleep(50 * time.Millisecond)
+	close(s.release)
+	wg.Wait()
+
+	s.Equal(int32(1), s.calls.Load())
+	for _, rec := range responses {
+		s.Equal(http.StatusCreated, rec.Code)
+		s.Equal(`{"id":1}`, rec.Body.String())
+	}
+}
+
+func TestIdempotencySuite(t *testing.T) {
+	suite.Run(t, new(IdempotencySuite))
+}
@@ -0,0 +1,90 @@
+package zmiddlewares
+
+import (
+	"github.com/zondax/golem/pkg/logger"
+	"github.com/zondax/golem/pkg/zrouter/auth"
+	"github.com/zondax/golem/pkg/zrouter/domain"
+	"net/http"
+)
+
+const (
+	wwwAuthenticateHeader = "WWW-Authenticate"
+
+	unauthorizedErrorCode = "unauthorized"
+	forbiddenErrorCode    = "forbidden"
+)
+
+type JWTAuthOptions struct {
+	// Optional lets requests without a bearer token through unauthenticated. Invalid tokens are always rejected.
+	Optional bool
+}
+
+// JWTAuth verifies the bearer token and stores its claims (auth.ClaimsFromContext) and the matching principal
+// (auth.PrincipalFromContext) in the request context.
+func JWTAuth(verifier *auth.Verifier, options JWTAuthOptions) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get(auth.Header) == "" && options.Optional {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			token, err := extractBearerToken(r)
+			if err != nil {
+				writeUnauthorized(w, `Bearer`, err.Error())
+				return
+			}
+
+			claims, err := verifier.Verify(r.Context(), token)
+			if err != nil {
+				logger.GetLoggerFromContext(r.Context()).Debugf("Rejected JWT: %v", err)
+				writeUnauthorized(w, `Bearer error="invalid_token"`, "invalid token")
+				return
+			}
+
+			ctx := auth.ContextWithClaims(r.Context(), claims)
+			next.ServeHTTP(w, r.WithContext(auth.ContextWithPrincipal(ctx, claims.Principal())))
+		})
+	}
+}
+
+// RequireScopes rejects requests whose principal was not granted every given scope. It must run after
+// an authentication middleware (JWTAuth, APIKeyAuth).
+func RequireScopes(scopes ...string) Middleware {
+	return requirePrincipal(func(principal *auth.Principal) bool {
+		return principal.HasScopes(scopes...)
+	}, "insufficient scope")
+}
+
+// RequireRoles rejects requests whose principal holds none of the given roles. It must run after
+// an authentication middleware.
+func RequireRoles(roles ...string) Middleware {
+	return requirePrincipal(func(principal *auth.Principal) bool {
+		return principal.HasAnyRole(roles...)
+	}, "insufficient role")
+}
+
+func requirePrincipal(allowed func(principal *auth.Principal) bool, message string) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			principal, ok := auth.PrincipalFromContext(r.Context())
+			if !ok {
+				writeUnauthorized(w, `Bearer`, "authentication required")
+				return
+			}
+
+			if !allowed(principal) {
+				w.Header().Set(wwwAuthenticateHeader, `Bearer error="insufficient_scope"`)
+				writeAPIError(w, domain.NewAPIErrorResponse(http.StatusForbidden, forbiddenErrorCode, message))
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func writeUnauthorized(w http.ResponseWriter, challenge, message string) {
+	w.Header().Set(wwwAuthenticateHeader, challenge)
+	writeAPIError(w, domain.NewAPIErrorResponse(http.StatusUnauthorized, unauthorizedErrorCode, message))
+}
@@ -0,0 +1,139 @@
+package zmiddlewares
+
+import (
+	"github.com/go-chi/chi/v5"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+	"github.com/zondax/golem/pkg/logger"
+	"github.com/zondax/golem/pkg/zrouter/auth"
+	"github.com/zondax/golem/pkg/zrouter/auth/authtest"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+type JWTAuthSuite struct {
+	suite.Suite
+	key    *authtest.KeyPair
+	jwks   *authtest.JWKSServer
+	router *chi.Mux
+}
+
+func (s *JWTAuthSuite) SetupSuite() {
+	logger.InitLogger(logger.Config{})
+
+	key, err := authtest.NewKeyPair(auth.AlgES256, "test-key")
+	s.Require().NoError(err)
+	s.key = key
+	s.jwks = authtest.NewJWKSServer(key)
+
+	verifier, err := auth.NewVerifier(auth.VerifierConfig{
+		JWKS:     auth.NewJWKSProvider(s.jwks.URL, auth.JWKSOptions{}),
+		Audience: []string{"api"},
+	})
+	s.Require().NoError(err)
+
+	s.router = chi.NewRouter()
+	s.router.Use(JWTAuth(verifier, JWTAuthOptions{}))
+
+	okHandler := func(w http.ResponseWriter, r *http.Request) {
+		claims, ok := auth.ClaimsFromContext(r.Context())
+		assert.True(s.T(), ok)
+		_, _ = w.Write([]byte(claims.Subject))
+	}
+	s.router.Get("/profile", okHandler)
+	s.router.With(RequireScopes("reports:read")).Get("/reports", okHandler)
+	s.router.With(RequireRoles("admin")).Get("/admin", okHandler)
+}
+
+func (s *JWTAuthSuite) TearDownSuite() {
+	s.jwks.Close()
+}
+
+func (s *JWTAuthSuite) token(claims jwt.MapClaims) string {
+	base := jwt.MapClaims{"sub": "user-1", "aud": "api", "exp": time.Now().Add(time.Hour).Unix()}
+	for key, value := range claims {
+		base[key] = value
+	}
+
+	token, err := s.key.Sign(base)
+	s.Require().NoError(err)
+	return token
+}
+
+func (s *JWTAuthSuite) do(path, authorization string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	if authorization != "" {
+		req.Header.Set(auth.Header, authorization)
+	}
+
+	rec := httptest.NewRecorder()
+	s.router.ServeHTTP(rec, req)
+	return rec
+}
+
+func (s *JWTAuthSuite) TestValidToken() {
+	rec := s.do("/profile", "Bearer "+s.token(nil))
+	s.Equal(http.StatusOK, rec.Code)
+	s.Equal("user-1", rec.Body.String())
+}
+
+func (s *JWTAuthSuite) TestMissingAndInvalidTokens() {
+	rec := s.do("/profile", "")
+	s.Equal(http.StatusUnauthorized, rec.Code)
+	s.Equal("Bearer", rec.Header().Get(wwwAuthenticateHeader))
+
+	rec = s.do("/profile", "Bearer not-a-jwt")
+	s.Equal(http.StatusUnauthorized, rec.Code)
+	s.Contains(rec.Header().Get(wwwAuthenticateHeader), "invalid_token")
+	s.Contains(rec.Body.String(), unauthorizedErrorCode)
+
+	rec = s.do("/profile", "Bearer "+s.token(jwt.MapClaims{"aud": "other"}))
+	s.Equal(http.StatusUnauthorized, rec.Code)
+}
+
+func (s *JWTAuthSuite) TestScopeGuard() {
+	rec := s.do("/reports", "Bearer "+s.token(jwt.MapClaims{"scope": "profile"}))
+	s.Equal(http.StatusForbidden, rec.Code)
+	s.Contains(rec.Body.String(), forbiddenErrorCode)
+
+	rec = s.do("/reports", "Bearer "+s.token(jwt.MapClaims{"scp": []string{"reports:read"}}))
+	s.Equal(http.StatusOK, rec.Code)
+}
+
+func (s *JWTAuthSuite) TestRoleGuard() {
+	rec := s.do("/admin", "Bearer "+s.token(jwt.MapClaims{"roles": []string{"viewer"}}))
+	s.Equal(http.StatusForbidden, rec.Code)
+
+	rec = s.do("/admin", "Bearer "+s.token(jwt.MapClaims{"roles": []string{"admin"}}))
+	s.Equal(http.StatusOK, rec.Code)
+}
+
+func TestJWTAuthSuite(t *testing.T) {
+	suite.Run(t, new(JWTAuthSuite))
+}
+
+func TestJWTAuthOptional(t *testing.T) {
+	verifier, err := auth.NewVerifier(auth.VerifierConfig{HMACSecret: []byte("secret")})
+	require.NoError(t, err)
+
+	r := chi.NewRouter()
+	r.Use(JWTAuth(verifier, JWTAuthOptions{Optional: true}))
+	r.Get("/", func(w http.ResponseWriter, r *http.Request) {
+		_, ok := auth.ClaimsFromContext(r.Context())
+		assert.False(t, ok)
+	})
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(auth.Header, "Bearer invalid")
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+}
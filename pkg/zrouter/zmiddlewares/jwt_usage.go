@@ -20,6 +20,9 @@ const (
 	defaultTTL             = time.Hour
 )
 
+// JWTUsageMiddleware counts the requests of each token jti by path. When JWTAuth runs outside it, only the
+// verified claims are used. Otherwise the jti is decoded from the unverified token, so it can be forged: order
+// JWTAuth before this middleware whenever a verifier is configured.
 func JWTUsageMiddleware(zCache zcache.RemoteCache, tokenDetailsTTL, usageMetricTTL time.Duration) func(next http.Handler) http.Handler {
 	if usageMetricTTL == 0 {
 		usageMetricTTL = defaultTTL
@@ -27,6 +30,15 @@ func JWTUsageMiddleware(zCache zcache.RemoteCache, tokenDetailsTTL, usageMetricT
 
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// Never fall back to the unverified token once JWTAuth has verified it
+			if claims, ok := auth.ClaimsFromContext(r.Context()); ok {
+				if claims.ID != "" {
+					incrementUsageCount(r.Context(), zCache, claims.ID, r.URL.Path, usageMetricTTL)
+				}
+				next.ServeHTTP(w, r)
+				return
+			}
+
 			token, err := extractBearerToken(r)
 			if err != nil {
 				logger.GetLoggerFromContext(r.Context()).Errorf("Error extracting bearer token %v", err.Error())
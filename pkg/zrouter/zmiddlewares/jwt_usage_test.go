@@ -136,3 +136,22 @@ func TestJWTUsageMiddleware(t *testing.T) {
 
 	mockCache.AssertExpectations(t)
 }
+
+func TestJWTUsageMiddlewareIgnoresTokenWhenClaimsAreVerified(t *testing.T) {
+	mockCache := &zcache.MockZCache{}
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	middleware := JWTUsageMiddleware(mockCache, time.Minute, time.Hour)(handler)
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Add("Authorization", testToken)
+	req = req.WithContext(auth.ContextWithClaims(req.Context(), &auth.Claims{}))
+	rec := httptest.NewRecorder()
+	middleware.ServeHTTP(rec, req)
+
+	require.Equal(t, http.StatusOK, rec.Code)
+	mockCache.AssertNotCalled(t, "Get", mock.Anything, mock.Anything, mock.Anything)
+	mockCache.AssertNotCalled(t, "ZIncrBy", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
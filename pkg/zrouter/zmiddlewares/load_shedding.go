@@ -0,0 +1,113 @@
+package zmiddlewares
+
+import (
+	"github.com/zondax/golem/pkg/logger"
+	"github.com/zondax/golem/pkg/metrics"
+	"github.com/zondax/golem/pkg/zrouter/domain"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	shedRequestsMetricName = "shed_requests"
+	shedReasonLabel        = "reason"
+
+	shedReasonInFlight = "in_flight"
+	shedReasonLatency  = "latency"
+
+	overloadedErrorCode = "overloaded"
+
+	latencyDecreaseFactor = 0.9
+)
+
+type LoadSheddingOptions struct {
+	// MaxInFlight is the maximum number of concurrent requests.
+	MaxInFlight int
+	// LatencyThreshold enables adaptive limiting: every request slower than the threshold lowers the
+	// concurrency limit by 10%, down to MinInFlight, and every faster one raises it by one, up to
+	// MaxInFlight. Zero keeps the limit fixed.
+	LatencyThreshold time.Duration
+	// MinInFlight defaults to 1.
+	MinInFlight int
+	// MetricsServer, when set, receives the shed request counts.
+	MetricsServer metrics.TaskMetrics
+}
+
+type loadShedder struct {
+	options  LoadSheddingOptions
+	mu       sync.Mutex
+	inFlight int
+	limit    float64
+}
+
+// LoadShedding rejects requests with 503 once the concurrency limit is reached.
+func LoadShedding(options LoadSheddingOptions) Middleware {
+	if options.MinInFlight <= 0 {
+		options.MinInFlight = 1
+	}
+	if options.MaxInFlight < options.MinInFlight {
+		options.MaxInFlight = options.MinInFlight
+	}
+
+	shedder := &loadShedder{options: options, limit: float64(options.MaxInFlight)}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if reason, ok := shedder.acquire(); !ok {
+				shedder.recordShed(r, reason)
+				w.Header().Set(RetryAfterHeader, "1")
+				writeAPIError(w, domain.NewAPIErrorResponse(http.StatusServiceUnavailable, overloadedErrorCode, "server overloaded, retry later"))
+				return
+			}
+
+			start := time.Now()
+			defer func() {
+				shedder.release(time.Since(start))
+			}()
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func (s *loadShedder) acquire() (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.inFlight < int(s.limit) {
+		s.inFlight++
+		return "", true
+	}
+
+	if int(s.limit) < s.options.MaxInFlight {
+		return shedReasonLatency, false
+	}
+	return shedReasonInFlight, false
+}
+
+func (s *loadShedder) release(latency time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.inFlight--
+	if s.options.LatencyThreshold == 0 {
+		return
+	}
+
+	if latency > s.options.LatencyThreshold {
+		s.limit = max(float64(s.options.MinInFlight), s.limit*latencyDecreaseFactor)
+		return
+	}
+	s.limit = min(float64(s.options.MaxInFlight), s.limit+1)
+}
+
+func (s *loadShedder) recordShed(r *http.Request, reason string) {
+	if s.options.MetricsServer == nil {
+		return
+	}
+
+	if err := s.options.MetricsServer.IncrementMetric(shedRequestsMetricName, GetSubRoutePattern(r), GetRoutePattern(r), reason); err != nil {
+		logger.GetLoggerFromContext(r.Context()).Errorf("error updating shed requests metric: %v", err.Error())
+	}
+}
@@ -0,0 +1,76 @@
+package zmiddlewares
+
+import (
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/zondax/golem/pkg/metrics"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestLoadSheddingInFlight(t *testing.T) {
+	mockMetrics := new(metrics.MockTaskMetrics)
+	mockMetrics.On("IncrementMetric", shedRequestsMetricName, mock.Anything, "/work", shedReasonInFlight).Return(nil)
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+
+	r := chi.NewRouter()
+	r.Use(LoadShedding(LoadSheddingOptions{MaxInFlight: 1, MetricsServer: mockMetrics}))
+	r.Get("/work", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("block") != "" {
+			close(started)
+			<-release
+		}
+	})
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/work?block=1", nil))
+	}()
+	<-started
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/work", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	assert.Equal(t, "1", rec.Header().Get(RetryAfterHeader))
+	assert.Contains(t, rec.Body.String(), overloadedErrorCode)
+	mockMetrics.AssertNumberOfCalls(t, "IncrementMetric", 1)
+
+	close(release)
+	wg.Wait()
+
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/work", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+}
+
+func TestLoadSheddingAdaptiveLimit(t *testing.T) {
+	shedder := &loadShedder{
+		options: LoadSheddingOptions{MaxInFlight: 10, MinInFlight: 2, LatencyThreshold: 10 * time.Millisecond},
+		limit:   10,
+	}
+
+	for i := 0; i < 30; i++ {
+		_, ok := shedder.acquire()
+		assert.True(t, ok)
+		shedder.release(time.Second)
+	}
+	assert.Equal(t, float64(2), shedder.limit)
+
+	_, _ = shedder.acquire()
+	_, _ = shedder.acquire()
+	reason, ok := shedder.acquire()
+	assert.False(t, ok)
+	assert.Equal(t, shedReasonLatency, reason)
+
+	shedder.release(time.Millisecond)
+	shedder.release(time.Millisecond)
+	assert.Equal(t, float64(4), shedder.limit)
+}
@@ -1,7 +1,6 @@
 package zmiddlewares
 
 import (
-	"bytes"
 	"github.com/zondax/golem/pkg/logger"
 	"net/http"
 	"regexp"
@@ -11,9 +10,17 @@ import (
 type LoggingMiddlewareOptions struct {
 	ExcludePaths []string
 	Enable       bool
+	// AccessLog replaces the log line with the structured AccessLog middleware. ExcludePaths also apply.
+	AccessLog *AccessLogOptions
 }
 
 func LoggingMiddleware(options LoggingMiddlewareOptions) func(http.Handler) http.Handler {
+	if options.AccessLog != nil {
+		accessLogOptions := *options.AccessLog
+		accessLogOptions.ExcludePaths = append(append([]string{}, accessLogOptions.ExcludePaths...), options.ExcludePaths...)
+		return AccessLog(accessLogOptions)
+	}
+
 	excludeRegexps := make([]*regexp.Regexp, len(options.ExcludePaths))
 	for i, path := range options.ExcludePaths {
 		excludeRegexps[i] = PathToRegexp(path)
@@ -29,17 +36,17 @@ func LoggingMiddleware(options LoggingMiddlewareOptions) func(http.Handler) http
 				}
 			}
 
-			buffer := &bytes.Buffer{}
-
+			// The response body is only kept for the debug log, capped, and never for event streams.
+			log := logger.GetLoggerFromContext(r.Context())
 			rw := &responseWriter{
 				ResponseWriter: w,
-				body:           buffer,
+				captureBody:    log.IsDebugEnabled(),
+				bodyLimit:      defaultAccessLogBodySize,
 			}
 
 			start := time.Now()
 			next.ServeHTTP(rw, r)
 			duration := time.Since(start)
-			log := logger.GetLoggerFromContext(r.Context())
 			if log.IsDebugEnabled() {
 				log.Debugf("Method: %s - URL: %s | Status: %d - Duration: %s - Response Body: %s",
 					r.Method, r.URL.String(), rw.status, duration, string(rw.Body()))
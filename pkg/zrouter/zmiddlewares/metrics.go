@@ -1,28 +1,133 @@
 package zmiddlewares
 
 import (
+	"context"
 	"github.com/zondax/golem/pkg/logger"
 	"github.com/zondax/golem/pkg/metrics"
 	"github.com/zondax/golem/pkg/metrics/collectors"
+	"github.com/zondax/golem/pkg/zobservability"
+	"io"
 	"net/http"
 	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
 const (
-	activeConnectionsMetricName    = "active_connections"
+	inFlightRequestsMetricName     = "requests_in_flight"
 	durationMillisecondsMetricName = "request_duration_ms"
+	requestSizeMetricName          = "request_size"
 	responseSizeMetricName         = "response_size"
+	uncompressedSizeMetricName     = "response_size_uncompressed"
 	totalRequestsMetricName        = "total_requests"
 	pathLabel                      = "path"
 	methodLabel                    = "method"
 	statusLabel                    = "status"
 	subRouteLabel                  = "sub_route"
+	versionLabel                   = "version"
+
+	unmatchedRouteLabel = "unmatched"
+	unversionedLabel    = "none"
+	otherRouteLabel     = "other"
+	otherMethodLabel    = "OTHER"
+
+	defaultMaxRoutes               = 1000
+	nativeHistogramBucketFactor    = 1.1
+	nativeHistogramMaxBucketNumber = 160
+
+	WebSocketConnectionsMetricName      = "websocket_active_connections"
+	WebSocketConnectionsTotalMetricName = "websocket_connections_total"
 )
 
+var (
+	// DefaultDurationBuckets are in milliseconds.
+	DefaultDurationBuckets = []float64{5, 10, 25, 50, 100, 250, 500, 1000, 2500, 5000, 10000}
+	// DefaultSizeBuckets are in bytes, from 100B to 10MB.
+	DefaultSizeBuckets = []float64{100, 1000, 10000, 100000, 1000000, 10000000}
+
+	requestLabels  = []string{subRouteLabel, methodLabel, pathLabel, statusLabel}
+	inFlightLabels = []string{subRouteLabel, methodLabel}
+
+	knownMethods = map[string]struct{}{
+		http.MethodGet: {}, http.MethodHead: {}, http.MethodPost: {}, http.MethodPut: {}, http.MethodPatch: {},
+		http.MethodDelete: {}, http.MethodConnect: {}, http.MethodOptions: {}, http.MethodTrace: {},
+	}
+)
+
+type RequestMetricsOptions struct {
+	// MetricsServer and MetricsProvider are the supported backends. When both are set, the request
+	// metrics go to MetricsProvider; the metrics of the other router middlewares are only registered
+	// on MetricsServer.
+	MetricsServer   metrics.TaskMetrics
+	MetricsProvider zobservability.MetricsProvider
+	// DurationBuckets are in milliseconds. Defaults to DefaultDurationBuckets.
+	DurationBuckets []float64
+	// SizeBuckets are in bytes and apply to request and response sizes. Defaults to DefaultSizeBuckets.
+	SizeBuckets []float64
+	// NativeHistograms also exposes the histograms as Prometheus native (exponential) histograms.
+	// Only supported by MetricsServer.
+	NativeHistograms bool
+	// MaxRoutes bounds the number of distinct path labels. Routes seen once the limit is reached are
+	// reported as "other" and unmatched requests as "unmatched". Defaults to 1000.
+	MaxRoutes int
+	// VersionLabel adds the API version resolved by the APIVersion middleware as a "version" label,
+	// "none" for unversioned requests. APIVersion must run before RequestMetrics.
+	VersionLabel bool
+}
+
+func (o *RequestMetricsOptions) setDefaultValues() {
+	if len(o.DurationBuckets) == 0 {
+		o.DurationBuckets = DefaultDurationBuckets
+	}
+
+	if len(o.SizeBuckets) == 0 {
+		o.SizeBuckets = DefaultSizeBuckets
+	}
+
+	if o.MaxRoutes <= 0 {
+		o.MaxRoutes = defaultMaxRoutes
+	}
+}
+
+func requestMetricDefinitions(options RequestMetricsOptions) []zobservability.MetricDefinition {
+	labels := requestLabels
+	if options.VersionLabel {
+		labels = append(append([]string{}, requestLabels...), versionLabel)
+	}
+
+	return []zobservability.MetricDefinition{
+		{Name: totalRequestsMetricName, Help: "Total number of HTTP requests made.", Type: zobservability.MetricTypeCounter, LabelNames: labels},
+		{Name: durationMillisecondsMetricName, Help: "Duration of HTTP requests in milliseconds.", Type: zobservability.MetricTypeHistogram, LabelNames: labels, Buckets: options.DurationBuckets},
+		{Name: requestSizeMetricName, Help: "Size of HTTP request bodies in bytes.", Type: zobservability.MetricTypeHistogram, LabelNames: labels, Buckets: options.SizeBuckets},
+		{Name: responseSizeMetricName, Help: "Size of HTTP response in bytes.", Type: zobservability.MetricTypeHistogram, LabelNames: labels, Buckets: options.SizeBuckets},
+		{Name: uncompressedSizeMetricName, Help: "Size of HTTP response in bytes before compression.", Type: zobservability.MetricTypeHistogram, LabelNames: labels, Buckets: options.SizeBuckets},
+		{Name: inFlightRequestsMetricName, Help: "Number of HTTP requests being served.", Type: zobservability.MetricTypeGauge, LabelNames: inFlightLabels},
+	}
+}
+
 func RegisterRequestMetrics(metricsServer metrics.TaskMetrics) []error {
+	return RegisterRequestMetricsWithOptions(RequestMetricsOptions{MetricsServer: metricsServer})
+}
+
+// RegisterRequestMetricsWithOptions registers the request metrics on the configured backend and, when
+// MetricsServer is set, the metrics of the other router middlewares.
+func RegisterRequestMetricsWithOptions(options RequestMetricsOptions) []error {
+	options.setDefaultValues()
+
 	var errs []error
+	recorder := newRequestMetricsRecorder(options)
+	for _, definition := range requestMetricDefinitions(options) {
+		if err := recorder.register(definition); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	metricsServer := options.MetricsServer
+	if metricsServer == nil {
+		return errs
+	}
 
 	register := func(name, help string, labels []string, handler metrics.MetricHandler) {
 		if err := metricsServer.RegisterMetric(name, help, labels, handler); err != nil {
@@ -30,10 +135,11 @@ func RegisterRequestMetrics(metricsServer metrics.TaskMetrics) []error {
 		}
 	}
 
-	register(totalRequestsMetricName, "Total number of HTTP requests made.", []string{subRouteLabel, methodLabel, pathLabel, statusLabel}, &collectors.Counter{})
-	register(durationMillisecondsMetricName, "Duration of HTTP requests in milliseconds.", []string{subRouteLabel, methodLabel, pathLabel, statusLabel}, &collectors.Gauge{})
-	register(responseSizeMetricName, "Size of HTTP response in bytes.", []string{subRouteLabel, methodLabel, pathLabel, statusLabel}, &collectors.Histogram{})
-	register(activeConnectionsMetricName, "Number of active HTTP connections.", []string{subRouteLabel, methodLabel, pathLabel}, &collectors.Gauge{})
+	register(WebSocketConnectionsMetricName, "Number of open WebSocket connections.", []string{pathLabel}, &collectors.Gauge{})
+	register(WebSocketConnectionsTotalMetricName, "Total number of accepted WebSocket connections.", []string{pathLabel}, &collectors.Counter{})
+
+	register(shedRequestsMetricName, "Number of requests rejected by load shedding.", []string{subRouteLabel, pathLabel, shedReasonLabel}, &collectors.Counter{})
+	register(apiKeyRequestsMetric, "Number of requests authenticated per API key.", []string{apiKeyLabel, authMethodLabel, pathLabel}, &collectors.Counter{})
 
 	register(getRequestBodyErrorMetric, "Register get request body error.", []string{subRouteLabel, pathLabel}, &collectors.Counter{})
 
@@ -47,49 +153,253 @@ func RegisterRequestMetrics(metricsServer metrics.TaskMetrics) []error {
 	return errs
 }
 
+type responseStatsKey struct{}
+
+// responseStats lets inner middlewares report what RequestMetrics cannot observe on the wire.
+type responseStats struct {
+	uncompressed int64
+	compressed   bool
+}
+
 func RequestMetrics(metricsServer metrics.TaskMetrics) Middleware {
-	var activeConnections int64
-	var mu sync.Mutex
+	return RequestMetricsWithOptions(RequestMetricsOptions{MetricsServer: metricsServer})
+}
+
+// RequestMetricsWithOptions records the request count, duration, sizes and in-flight requests. The
+// metrics must be registered first with RegisterRequestMetricsWithOptions and the same options.
+func RequestMetricsWithOptions(options RequestMetricsOptions) Middleware {
+	options.setDefaultValues()
+	recorder := newRequestMetricsRecorder(options)
+	routes := &routeLabelGuard{max: options.MaxRoutes, routes: make(map[string]struct{})}
 
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			path := GetRoutePattern(r)
-			subRoute := GetSubRoutePattern(r)
+			ctx := r.Context()
+			path := routes.label(GetRoutePattern(r))
+			subRoute := unmatchedRouteLabel
+			if path != unmatchedRouteLabel {
+				subRoute = GetSubRoutePattern(r)
+			}
+			method := methodLabelValue(r.Method)
 			startTime := time.Now()
 
-			mu.Lock()
-			activeConnections++
-			if err := metricsServer.UpdateMetric(activeConnectionsMetricName, float64(activeConnections), subRoute, r.Method, path); err != nil {
-				logger.GetLoggerFromContext(r.Context()).Errorf("error updating active connections metric: %v", err.Error())
+			if err := recorder.addInFlight(ctx, 1, subRoute, method); err != nil {
+				logger.GetLoggerFromContext(ctx).Errorf("error updating in-flight requests metric: %v", err.Error())
+			}
+
+			var body *countingReadCloser
+			if r.Body != nil && r.Body != http.NoBody {
+				body = &countingReadCloser{ReadCloser: r.Body}
+				r.Body = body
 			}
-			mu.Unlock()
 
+			stats := &responseStats{}
 			mrw := &responseWriter{ResponseWriter: w}
-			next.ServeHTTP(mrw, r)
+			next.ServeHTTP(mrw, r.WithContext(context.WithValue(ctx, responseStatsKey{}, stats)))
 
-			mu.Lock()
-			activeConnections--
-			if err := metricsServer.UpdateMetric(activeConnectionsMetricName, float64(activeConnections), subRoute, r.Method, path); err != nil {
-				logger.GetLoggerFromContext(r.Context()).Errorf("error updating active connections metric: %v", err.Error())
+			if err := recorder.addInFlight(ctx, -1, subRoute, method); err != nil {
+				logger.GetLoggerFromContext(ctx).Errorf("error updating in-flight requests metric: %v", err.Error())
 			}
-			mu.Unlock()
 
-			duration := float64(time.Since(startTime).Milliseconds())
+			duration := float64(time.Since(startTime)) / float64(time.Millisecond)
+
+			requestBytes := r.ContentLength
+			if body != nil && body.read > requestBytes {
+				requestBytes = body.read
+			}
+			if requestBytes < 0 {
+				requestBytes = 0
+			}
 
 			responseStatus := mrw.status
 			bytesWritten := mrw.written
+			uncompressedBytes := bytesWritten
+			if stats.compressed {
+				uncompressedBytes = stats.uncompressed
+			}
 
-			labels := []string{subRoute, r.Method, path, strconv.Itoa(responseStatus)}
+			labels := []string{subRoute, method, path, strconv.Itoa(responseStatus)}
+			if options.VersionLabel {
+				version, ok := APIVersionFromContext(ctx)
+				if !ok {
+					version = unversionedLabel
+				}
+				labels = append(labels, version)
+			}
 
-			if err := metricsServer.UpdateMetric(durationMillisecondsMetricName, duration, labels...); err != nil {
-				logger.GetLoggerFromContext(r.Context()).Errorf("error updating request duration metric: %v", err.Error())
+			if err := recorder.observe(ctx, durationMillisecondsMetricName, duration, labels...); err != nil {
+				logger.GetLoggerFromContext(ctx).Errorf("error updating request duration metric: %v", err.Error())
+			}
+			if err := recorder.observe(ctx, requestSizeMetricName, float64(requestBytes), labels...); err != nil {
+				logger.GetLoggerFromContext(ctx).Errorf("error updating request size metric: %v", err.Error())
 			}
-			if err := metricsServer.UpdateMetric(responseSizeMetricName, float64(bytesWritten), labels...); err != nil {
-				logger.GetLoggerFromContext(r.Context()).Errorf("error updating response size metric: %v", err.Error())
+			if err := recorder.observe(ctx, responseSizeMetricName, float64(bytesWritten), labels...); err != nil {
+				logger.GetLoggerFromContext(ctx).Errorf("error updating response size metric: %v", err.Error())
 			}
-			if err := metricsServer.UpdateMetric(totalRequestsMetricName, 1, labels...); err != nil {
-				logger.GetLoggerFromContext(r.Context()).Errorf("error updating total requests metric: %v", err.Error())
+			if err := recorder.observe(ctx, uncompressedSizeMetricName, float64(uncompressedBytes), labels...); err != nil {
+				logger.GetLoggerFromContext(ctx).Errorf("error updating uncompressed response size metric: %v", err.Error())
+			}
+			if err := recorder.count(ctx, totalRequestsMetricName, labels...); err != nil {
+				logger.GetLoggerFromContext(ctx).Errorf("error updating total requests metric: %v", err.Error())
 			}
 		})
 	}
 }
+
+// requestMetricsRecorder reports the request metrics to a metrics.TaskMetrics server or a
+// zobservability.MetricsProvider, which takes label maps instead of ordered label values.
+type requestMetricsRecorder struct {
+	server           metrics.TaskMetrics
+	provider         zobservability.MetricsProvider
+	nativeHistograms bool
+	labelNames       map[string][]string
+	// inFlight keeps the in-flight counts for providers, since OpenTelemetry gauges can only be set
+	inFlight sync.Map
+}
+
+func newRequestMetricsRecorder(options RequestMetricsOptions) *requestMetricsRecorder {
+	recorder := &requestMetricsRecorder{
+		server:           options.MetricsServer,
+		provider:         options.MetricsProvider,
+		nativeHistograms: options.NativeHistograms,
+		labelNames:       make(map[string][]string),
+	}
+
+	for _, definition := range requestMetricDefinitions(options) {
+		recorder.labelNames[definition.Name] = definition.LabelNames
+	}
+
+	return recorder
+}
+
+func (m *requestMetricsRecorder) register(definition zobservability.MetricDefinition) error {
+	if m.provider != nil {
+		switch definition.Type {
+		case zobservability.MetricTypeCounter:
+			return m.provider.RegisterCounter(definition.Name, definition.Help, definition.LabelNames)
+		case zobservability.MetricTypeGauge:
+			return m.provider.RegisterGauge(definition.Name, definition.Help, definition.LabelNames)
+		default:
+			return m.provider.RegisterHistogram(definition.Name, definition.Help, definition.LabelNames, definition.Buckets)
+		}
+	}
+
+	if m.server == nil {
+		return nil
+	}
+
+	var handler metrics.MetricHandler
+	switch definition.Type {
+	case zobservability.MetricTypeCounter:
+		handler = &collectors.Counter{}
+	case zobservability.MetricTypeGauge:
+		handler = &collectors.Gauge{}
+	default:
+		histogram := &collectors.Histogram{Buckets: definition.Buckets}
+		if m.nativeHistograms {
+			histogram.NativeBucketFactor = nativeHistogramBucketFactor
+			histogram.NativeMaxBucketNumber = nativeHistogramMaxBucketNumber
+		}
+		handler = histogram
+	}
+
+	return m.server.RegisterMetric(definition.Name, definition.Help, definition.LabelNames, handler)
+}
+
+func (m *requestMetricsRecorder) count(ctx context.Context, name string, labels ...string) error {
+	if m.provider != nil {
+		return m.provider.IncrementCounter(ctx, name, m.labelMap(name, labels))
+	}
+
+	if m.server == nil {
+		return nil
+	}
+	return m.server.UpdateMetric(name, 1, labels...)
+}
+
+func (m *requestMetricsRecorder) observe(ctx context.Context, name string, value float64, labels ...string) error {
+	if m.provider != nil {
+		return m.provider.RecordHistogram(ctx, name, value, m.labelMap(name, labels))
+	}
+
+	if m.server == nil {
+		return nil
+	}
+	return m.server.UpdateMetric(name, value, labels...)
+}
+
+func (m *requestMetricsRecorder) addInFlight(ctx context.Context, delta int64, labels ...string) error {
+	if m.provider != nil {
+		value, _ := m.inFlight.LoadOrStore(strings.Join(labels, "\x00"), new(atomic.Int64))
+		current := value.(*atomic.Int64).Add(delta)
+		return m.provider.SetGauge(ctx, inFlightRequestsMetricName, float64(current), m.labelMap(inFlightRequestsMetricName, labels))
+	}
+
+	if m.server == nil {
+		return nil
+	}
+	if delta > 0 {
+		return m.server.IncrementMetric(inFlightRequestsMetricName, labels...)
+	}
+	return m.server.DecrementMetric(inFlightRequestsMetricName, labels...)
+}
+
+func (m *requestMetricsRecorder) labelMap(name string, values []string) map[string]string {
+	names := m.labelNames[name]
+	labels := make(map[string]string, len(names))
+	for i, labelName := range names {
+		if i < len(values) {
+			labels[labelName] = values[i]
+		}
+	}
+	return labels
+}
+
+// routeLabelGuard bounds the cardinality of the path label.
+type routeLabelGuard struct {
+	max    int
+	mu     sync.RWMutex
+	routes map[string]struct{}
+}
+
+func (g *routeLabelGuard) label(route string) string {
+	if route == "" || route == undefinedPath {
+		return unmatchedRouteLabel
+	}
+
+	g.mu.RLock()
+	_, found := g.routes[route]
+	g.mu.RUnlock()
+	if found {
+		return route
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if _, found = g.routes[route]; found {
+		return route
+	}
+	if len(g.routes) >= g.max {
+		return otherRouteLabel
+	}
+	g.routes[route] = struct{}{}
+	return route
+}
+
+func methodLabelValue(method string) string {
+	if _, ok := knownMethods[method]; ok {
+		return method
+	}
+	return otherMethodLabel
+}
+
+type countingReadCloser struct {
+	io.ReadCloser
+	read int64
+}
+
+func (c *countingReadCloser) Read(p []byte) (int, error) {
+	n, err := c.ReadCloser.Read(p)
+	c.read += int64(n)
+	return n, err
+}
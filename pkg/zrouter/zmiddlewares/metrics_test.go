@@ -0,0 +1,206 @@
+package zmiddlewares
+
+import (
+	"context"
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/zondax/golem/pkg/logger"
+	"github.com/zondax/golem/pkg/metrics"
+	"github.com/zondax/golem/pkg/metrics/collectors"
+	"github.com/zondax/golem/pkg/zobservability"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+type observation struct {
+	name   string
+	value  float64
+	labels []string
+}
+
+type recordingTaskMetrics struct {
+	*metrics.MockTaskMetrics
+	mu           sync.Mutex
+	observations []observation
+}
+
+func newRecordingTaskMetrics() *recordingTaskMetrics {
+	m := &recordingTaskMetrics{MockTaskMetrics: new(metrics.MockTaskMetrics)}
+	m.On("RegisterMetric", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	m.On("IncrementMetric", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	m.On("DecrementMetric", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	return m
+}
+
+func (m *recordingTaskMetrics) UpdateMetric(name string, value float64, labels ...string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.observations = append(m.observations, observation{name: name, value: value, labels: labels})
+	return nil
+}
+
+func (m *recordingTaskMetrics) find(name string) []observation {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var found []observation
+	for _, o := range m.observations {
+		if o.name == name {
+			found = append(found, o)
+		}
+	}
+	return found
+}
+
+type recordingMetricsProvider struct {
+	zobservability.MetricsProvider
+	mu         sync.Mutex
+	histograms map[string][]float64
+	counters   map[string][]map[string]string
+	gauges     []float64
+	buckets    map[string][]float64
+}
+
+func newRecordingMetricsProvider() *recordingMetricsProvider {
+	return &recordingMetricsProvider{
+		MetricsProvider: zobservability.NewNoopMetricsProvider("test"),
+		histograms:      make(map[string][]float64),
+		counters:        make(map[string][]map[string]string),
+		buckets:         make(map[string][]float64),
+	}
+}
+
+func (p *recordingMetricsProvider) RegisterHistogram(name, _ string, _ []string, buckets []float64) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.buckets[name] = buckets
+	return nil
+}
+
+func (p *recordingMetricsProvider) RecordHistogram(_ context.Context, name string, value float64, _ map[string]string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.histograms[name] = append(p.histograms[name], value)
+	return nil
+}
+
+func (p *recordingMetricsProvider) IncrementCounter(_ context.Context, name string, labels map[string]string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.counters[name] = append(p.counters[name], labels)
+	return nil
+}
+
+func (p *recordingMetricsProvider) SetGauge(_ context.Context, name string, value float64, _ map[string]string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if name == inFlightRequestsMetricName {
+		p.gauges = append(p.gauges, value)
+	}
+	return nil
+}
+
+func TestRegisterRequestMetricsWithOptions(t *testing.T) {
+	ms := new(metrics.MockTaskMetrics)
+	ms.On("RegisterMetric", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+
+	errs := RegisterRequestMetricsWithOptions(RequestMetricsOptions{
+		MetricsServer:    ms,
+		DurationBuckets:  []float64{10, 100},
+		NativeHistograms: true,
+	})
+	assert.Empty(t, errs)
+
+	handlers := map[string]metrics.MetricHandler{}
+	for _, call := range ms.Calls {
+		handlers[call.Arguments.String(0)] = call.Arguments.Get(3).(metrics.MetricHandler)
+	}
+
+	duration, ok := handlers[durationMillisecondsMetricName].(*collectors.Histogram)
+	require.True(t, ok)
+	assert.Equal(t, []float64{10, 100}, duration.Buckets)
+	assert.Equal(t, nativeHistogramBucketFactor, duration.NativeBucketFactor)
+
+	size, ok := handlers[requestSizeMetricName].(*collectors.Histogram)
+	require.True(t, ok)
+	assert.Equal(t, DefaultSizeBuckets, size.Buckets)
+
+	assert.IsType(t, &collectors.Gauge{}, handlers[inFlightRequestsMetricName])
+	assert.IsType(t, &collectors.Counter{}, handlers[totalRequestsMetricName])
+	assert.Contains(t, handlers, WebSocketConnectionsMetricName)
+}
+
+func TestRequestMetrics_TaskMetrics(t *testing.T) {
+	logger.InitLogger(logger.Config{})
+	ms := newRecordingTaskMetrics()
+
+	mw := RequestMetricsWithOptions(RequestMetricsOptions{MetricsServer: ms, MaxRoutes: 1})
+
+	r := chi.NewRouter()
+	r.With(mw).Post("/users/{id}", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.Copy(io.Discard, r.Body)
+		_, _ = w.Write([]byte("created"))
+	})
+	r.With(mw).Get("/orders", func(w http.ResponseWriter, r *http.Request) {})
+	r.NotFound(mw(http.NotFoundHandler()).ServeHTTP)
+	r.MethodNotAllowed(mw(http.NotFoundHandler()).ServeHTTP)
+
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/users/1", strings.NewReader("payload")))
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/orders", nil))
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/missing/123", nil))
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("PURGE", "/users/2", nil))
+
+	totals := ms.find(totalRequestsMetricName)
+	require.Len(t, totals, 4)
+	assert.Equal(t, []string{"/users/*", http.MethodPost, "/users/{id}", "200"}, totals[0].labels)
+	assert.Equal(t, otherRouteLabel, totals[1].labels[2])
+	assert.Equal(t, unmatchedRouteLabel, totals[2].labels[2])
+	assert.Equal(t, "404", totals[2].labels[3])
+	assert.Equal(t, otherMethodLabel, totals[3].labels[1])
+
+	requestSizes := ms.find(requestSizeMetricName)
+	require.Len(t, requestSizes, 4)
+	assert.Equal(t, float64(len("payload")), requestSizes[0].value)
+
+	responseSizes := ms.find(responseSizeMetricName)
+	require.Len(t, responseSizes, 4)
+	assert.Equal(t, float64(len("created")), responseSizes[0].value)
+
+	assert.Len(t, ms.find(durationMillisecondsMetricName), 4)
+	ms.AssertCalled(t, "IncrementMetric", inFlightRequestsMetricName, "/users/*", http.MethodPost)
+	ms.AssertCalled(t, "DecrementMetric", inFlightRequestsMetricName, "/users/*", http.MethodPost)
+}
+
+func TestRequestMetrics_MetricsProvider(t *testing.T) {
+	logger.InitLogger(logger.Config{})
+	provider := newRecordingMetricsProvider()
+	options := RequestMetricsOptions{MetricsProvider: provider, SizeBuckets: []float64{1, 10}}
+
+	assert.Empty(t, RegisterRequestMetricsWithOptions(options))
+	assert.Equal(t, DefaultDurationBuckets, provider.buckets[durationMillisecondsMetricName])
+	assert.Equal(t, []float64{1, 10}, provider.buckets[responseSizeMetricName])
+
+	r := chi.NewRouter()
+	r.With(RequestMetricsWithOptions(options)).Get("/users/{id}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+	})
+
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/users/1", nil))
+
+	require.Len(t, provider.counters[totalRequestsMetricName], 1)
+	assert.Equal(t, map[string]string{
+		subRouteLabel: "/users/*",
+		methodLabel:   http.MethodGet,
+		pathLabel:     "/users/{id}",
+		statusLabel:   "202",
+	}, provider.counters[totalRequestsMetricName][0])
+	assert.Len(t, provider.histograms[durationMillisecondsMetricName], 1)
+	assert.Equal(t, []float64{0}, provider.histograms[requestSizeMetricName])
+	assert.Equal(t, []float64{1, 0}, provider.gauges)
+}
@@ -1,17 +1,27 @@
 package zmiddlewares
 
 import (
+	"bufio"
 	"bytes"
+	"errors"
+	"github.com/zondax/golem/pkg/zrouter/domain"
+	"net"
 	"net/http"
 )
 
 type Middleware func(next http.Handler) http.Handler
 
+// responseWriter records the status and the amount of bytes written. The body is only kept in memory
+// when captureBody is set or a buffer is provided, so streamed responses are not accumulated by
+// middlewares that only need the status. Event streams are never captured. A positive bodyLimit caps
+// the captured bytes.
 type responseWriter struct {
 	http.ResponseWriter
-	status  int
-	written int64
-	body    *bytes.Buffer
+	status      int
+	written     int64
+	body        *bytes.Buffer
+	captureBody bool
+	bodyLimit   int
 }
 
 func (rw *responseWriter) WriteHeader(statusCode int) {
@@ -20,7 +30,7 @@ func (rw *responseWriter) WriteHeader(statusCode int) {
 }
 
 func (rw *responseWriter) Write(p []byte) (int, error) {
-	if rw.body == nil {
+	if rw.body == nil && rw.captureBody {
 		rw.body = new(bytes.Buffer)
 	}
 
@@ -28,15 +38,59 @@ func (rw *responseWriter) Write(p []byte) (int, error) {
 		rw.WriteHeader(http.StatusOK)
 	}
 
-	rw.body.Write(p)
+	if rw.body != nil && !isEventStream(rw.Header().Get(domain.ContentTypeHeader)) {
+		rw.capture(p)
+	}
 	n, err := rw.ResponseWriter.Write(p)
 	rw.written += int64(n)
 	return n, err
 }
 
+func (rw *responseWriter) capture(p []byte) {
+	if rw.bodyLimit > 0 {
+		remaining := rw.bodyLimit - rw.body.Len()
+		if remaining <= 0 {
+			return
+		}
+		if len(p) > remaining {
+			p = p[:remaining]
+		}
+	}
+	rw.body.Write(p)
+}
+
 func (rw *responseWriter) Body() []byte {
 	if rw.body != nil {
 		return rw.body.Bytes()
 	}
 	return nil
 }
+
+// Flush implements http.Flusher so streaming handlers keep working behind the middleware chain.
+func (rw *responseWriter) Flush() {
+	if rw.status == 0 {
+		rw.WriteHeader(http.StatusOK)
+	}
+
+	if flusher, ok := rw.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Hijack implements http.Hijacker so connection upgrades (e.g. WebSockets) work behind the middleware chain.
+func (rw *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := rw.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("the underlying http.ResponseWriter does not implement http.Hijacker")
+	}
+
+	if rw.status == 0 {
+		rw.status = http.StatusSwitchingProtocols
+	}
+	return hijacker.Hijack()
+}
+
+// Unwrap exposes the underlying writer to http.ResponseController.
+func (rw *responseWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
@@ -0,0 +1,29 @@
+package zmiddlewares
+
+import (
+	"encoding/json"
+	"github.com/zondax/golem/pkg/zrouter/domain"
+	"net/http"
+)
+
+// WriteProblemDetails writes an application/problem+json response, filling the instance and the request ID
+// from the request when the problem does not carry them.
+func WriteProblemDetails(w http.ResponseWriter, r *http.Request, problem *domain.ProblemDetails) {
+	if problem.Instance == "" {
+		problem.Instance = r.URL.Path
+	}
+
+	if problem.RequestID == "" {
+		problem.RequestID = r.Header.Get(RequestIDHeader)
+	}
+
+	body, err := json.Marshal(problem)
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set(domain.ContentTypeHeader, domain.ContentTypeProblemJSON)
+	w.WriteHeader(problem.Status)
+	_, _ = w.Write(body)
+}
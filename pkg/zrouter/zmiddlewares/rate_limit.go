@@ -25,6 +25,7 @@ func getLimiter(key string, maxRPM int) *rate.Limiter {
 	return limiter
 }
 
+// RateLimitByFullPath keeps one in-process limiter per path, shared by every client. See RateLimiter.
 func RateLimitByFullPath(maxRPM int) Middleware {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -41,6 +42,7 @@ func RateLimitByFullPath(maxRPM int) Middleware {
 	}
 }
 
+// RateLimit applies a single in-process limit shared by every client. See RateLimiter.
 func RateLimit(maxRPM int) Middleware {
 	limiter := rate.NewLimiter(rate.Every(time.Minute/time.Duration(maxRPM)), maxRPM)
 
@@ -0,0 +1,247 @@
+package zmiddlewares
+
+import (
+	"context"
+	"fmt"
+	"github.com/zondax/golem/pkg/logger"
+	"github.com/zondax/golem/pkg/zcache"
+	"github.com/zondax/golem/pkg/zrouter/auth"
+	"github.com/zondax/golem/pkg/zrouter/domain"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	RateLimitLimitHeader     = "RateLimit-Limit"
+	RateLimitRemainingHeader = "RateLimit-Remaining"
+	RateLimitResetHeader     = "RateLimit-Reset"
+	RetryAfterHeader         = "Retry-After"
+
+	rateLimitedErrorCode    = "rate_limited"
+	rateLimitCacheKeyPrefix = "zrouter_rate_limit"
+	defaultRateLimitPolicy  = "default"
+	rateLimitSweepInterval  = time.Minute
+)
+
+// RateLimitPolicy allows Limit requests per Window. A zero Limit disables limiting.
+type RateLimitPolicy struct {
+	Limit  int
+	Window time.Duration
+}
+
+// RateLimitKeyFunc identifies the client a request is counted against. Returning false skips limiting.
+type RateLimitKeyFunc func(r *http.Request) (string, bool)
+
+func KeyByIP(r *http.Request) (string, bool) {
+	host := ClientIPFromRequest(r)
+	return "ip:" + host, host != ""
+}
+
+// KeyBySubject uses the authenticated principal: the JWT subject or the API key owner.
+func KeyBySubject(r *http.Request) (string, bool) {
+	principal, ok := auth.PrincipalFromContext(r.Context())
+	if !ok || principal.ID == "" {
+		return "", false
+	}
+	return "sub:" + principal.ID, true
+}
+
+func KeyByAPIKey(r *http.Request) (string, bool) {
+	principal, ok := auth.PrincipalFromContext(r.Context())
+	if !ok || principal.KeyID == "" {
+		return "", false
+	}
+	return "key:" + principal.KeyID, true
+}
+
+func KeyByHeader(header string) RateLimitKeyFunc {
+	return func(r *http.Request) (string, bool) {
+		value := r.Header.Get(header)
+		return "header:" + value, value != ""
+	}
+}
+
+// FirstKey tries each key function in order, e.g. FirstKey(KeyByAPIKey, KeyByIP).
+func FirstKey(keyFuncs ...RateLimitKeyFunc) RateLimitKeyFunc {
+	return func(r *http.Request) (string, bool) {
+		for _, keyFunc := range keyFuncs {
+			if key, ok := keyFunc(r); ok {
+				return key, true
+			}
+		}
+		return "", false
+	}
+}
+
+// RateLimitStore counts hits in fixed windows.
+type RateLimitStore interface {
+	// Take records a hit for key and returns the hits in the current window and when the window resets.
+	Take(ctx context.Context, key string, window time.Duration) (int64, time.Time, error)
+}
+
+type memoryRateLimitEntry struct {
+	count int64
+	reset time.Time
+}
+
+type memoryRateLimitStore struct {
+	mu        sync.Mutex
+	entries   map[string]*memoryRateLimitEntry
+	lastSweep time.Time
+}
+
+// NewMemoryRateLimitStore keeps counters in the process memory, evicting expired windows periodically.
+// Limits are enforced per replica.
+func NewMemoryRateLimitStore() RateLimitStore {
+	return &memoryRateLimitStore{
+		entries:   make(map[string]*memoryRateLimitEntry),
+		lastSweep: time.Now(),
+	}
+}
+
+func (s *memoryRateLimitStore) Take(_ context.Context, key string, window time.Duration) (int64, time.Time, error) {
+	now := time.Now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if now.Sub(s.lastSweep) >= rateLimitSweepInterval {
+		for entryKey, entry := range s.entries {
+			if !now.Before(entry.reset) {
+				delete(s.entries, entryKey)
+			}
+		}
+		s.lastSweep = now
+	}
+
+	entry, ok := s.entries[key]
+	if !ok || !now.Before(entry.reset) {
+		entry = &memoryRateLimitEntry{reset: now.Truncate(window).Add(window)}
+		s.entries[key] = entry
+	}
+	entry.count++
+
+	return entry.count, entry.reset, nil
+}
+
+type remoteRateLimitStore struct {
+	cache zcache.RemoteCache
+}
+
+// NewRemoteRateLimitStore shares counters through Redis so limits hold across replicas.
+func NewRemoteRateLimitStore(cache zcache.RemoteCache) RateLimitStore {
+	return &remoteRateLimitStore{cache: cache}
+}
+
+func (s *remoteRateLimitStore) Take(ctx context.Context, key string, window time.Duration) (int64, time.Time, error) {
+	start := time.Now().Truncate(window)
+	reset := start.Add(window)
+	counterKey := fmt.Sprintf("%s:%s:%d", rateLimitCacheKeyPrefix, key, start.Unix())
+
+	// Increment and expire in one transaction, so a counter is never left without a TTL. It outlives
+	// the window a little to absorb clock drift between replicas.
+	pipe := s.cache.TxPipeline()
+	count := pipe.IncrBy(ctx, counterKey, 1)
+	pipe.Expire(ctx, counterKey, window+time.Second)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return 0, reset, err
+	}
+
+	return count.Val(), reset, nil
+}
+
+type RateLimiterOptions struct {
+	// Policy applies to every request without a more specific policy.
+	Policy RateLimitPolicy
+	// Routes overrides the policy by route pattern (e.g. "/users/{id}"). Each route has its own counters.
+	Routes map[string]RateLimitPolicy
+	// Tiers overrides the policy by the rate tier of the authenticated principal.
+	Tiers map[string]RateLimitPolicy
+	// KeyFunc defaults to KeyByIP.
+	KeyFunc RateLimitKeyFunc
+	// Store defaults to an in-memory store.
+	Store RateLimitStore
+}
+
+func (o *RateLimiterOptions) setDefaultValues() {
+	if o.KeyFunc == nil {
+		o.KeyFunc = KeyByIP
+	}
+
+	if o.Store == nil {
+		o.Store = NewMemoryRateLimitStore()
+	}
+}
+
+// RateLimiter limits requests per client using fixed windows. Responses carry the RateLimit-Limit,
+// RateLimit-Remaining and RateLimit-Reset headers, and rejected ones a Retry-After header.
+// Requests are let through when the store is unavailable.
+func RateLimiter(options RateLimiterOptions) Middleware {
+	options.setDefaultValues()
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			name, policy := options.policyFor(r)
+			if policy.Limit <= 0 || policy.Window <= 0 {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			clientKey, ok := options.KeyFunc(r)
+			if !ok {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			count, reset, err := options.Store.Take(r.Context(), name+":"+clientKey, policy.Window)
+			if err != nil {
+				logger.GetLoggerFromContext(r.Context()).Errorf("Error checking rate limit: %v", err)
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			resetSeconds := int64(time.Until(reset).Round(time.Second).Seconds())
+			if resetSeconds < 1 {
+				resetSeconds = 1
+			}
+
+			remaining := int64(policy.Limit) - count
+			if remaining < 0 {
+				remaining = 0
+			}
+
+			w.Header().Set(RateLimitLimitHeader, strconv.Itoa(policy.Limit))
+			w.Header().Set(RateLimitRemainingHeader, strconv.FormatInt(remaining, 10))
+			w.Header().Set(RateLimitResetHeader, strconv.FormatInt(resetSeconds, 10))
+
+			if count > int64(policy.Limit) {
+				w.Header().Set(RetryAfterHeader, strconv.FormatInt(resetSeconds, 10))
+				writeAPIError(w, domain.NewAPIErrorResponse(http.StatusTooManyRequests, rateLimitedErrorCode, "rate limit exceeded"))
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func (o *RateLimiterOptions) policyFor(r *http.Request) (string, RateLimitPolicy) {
+	if len(o.Routes) > 0 {
+		route := GetRoutePattern(r)
+		if policy, ok := o.Routes[route]; ok {
+			return route, policy
+		}
+	}
+
+	if len(o.Tiers) > 0 {
+		if principal, ok := auth.PrincipalFromContext(r.Context()); ok {
+			if policy, ok := o.Tiers[principal.RateTier]; ok {
+				return "tier:" + principal.RateTier, policy
+			}
+		}
+	}
+
+	return defaultRateLimitPolicy, o.Policy
+}
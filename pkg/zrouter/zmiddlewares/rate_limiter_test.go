@@ -0,0 +1,171 @@
+package zmiddlewares
+
+import (
+	"context"
+	"errors"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zondax/golem/pkg/logger"
+	"github.com/zondax/golem/pkg/zcache"
+	"github.com/zondax/golem/pkg/zrouter/auth"
+	"github.com/zondax/golem/pkg/zrouter/domain"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+type failingRateLimitStore struct{}
+
+func (failingRateLimitStore) Take(context.Context, string, time.Duration) (int64, time.Time, error) {
+	return 0, time.Time{}, errors.New("store unavailable")
+}
+
+func newRateLimitedRouter(options RateLimiterOptions, middlewares ...func(http.Handler) http.Handler) *chi.Mux {
+	r := chi.NewRouter()
+	r.Use(middlewares...)
+	r.Use(RateLimiter(options))
+
+	okHandler := func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("OK"))
+	}
+	r.Get("/items", okHandler)
+	r.Get("/items/{id}", okHandler)
+	return r
+}
+
+func serveFrom(r http.Handler, target, remoteAddr string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, target, nil)
+	req.RemoteAddr = remoteAddr
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestRateLimiterByIP(t *testing.T) {
+	logger.InitLogger(logger.Config{})
+	r := newRateLimitedRouter(RateLimiterOptions{Policy: RateLimitPolicy{Limit: 2, Window: time.Minute}})
+
+	rec := serveFrom(r, "/items", "10.0.0.1:1234")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "2", rec.Header().Get(RateLimitLimitHeader))
+	assert.Equal(t, "1", rec.Header().Get(RateLimitRemainingHeader))
+
+	reset, err := strconv.Atoi(rec.Header().Get(RateLimitResetHeader))
+	require.NoError(t, err)
+	assert.True(t, reset >= 1 && reset <= 60)
+
+	assert.Equal(t, http.StatusOK, serveFrom(r, "/items", "10.0.0.1:1234").Code)
+
+	rec = serveFrom(r, "/items", "10.0.0.1:4321")
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+	assert.Equal(t, "0", rec.Header().Get(RateLimitRemainingHeader))
+	assert.NotEmpty(t, rec.Header().Get(RetryAfterHeader))
+	assert.Equal(t, domain.ContentTypeApplicationJSON, rec.Header().Get(domain.ContentTypeHeader))
+	assert.Contains(t, rec.Body.String(), rateLimitedErrorCode)
+
+	// Other clients have their own counters
+	assert.Equal(t, http.StatusOK, serveFrom(r, "/items", "10.0.0.2:1234").Code)
+}
+
+func TestRateLimiterRoutePolicy(t *testing.T) {
+	r := newRateLimitedRouter(RateLimiterOptions{
+		Policy: RateLimitPolicy{Limit: 100, Window: time.Minute},
+		Routes: map[string]RateLimitPolicy{"/items/{id}": {Limit: 1, Window: time.Minute}},
+	})
+
+	assert.Equal(t, http.StatusOK, serveFrom(r, "/items/1", "10.0.0.1:1").Code)
+	assert.Equal(t, http.StatusTooManyRequests, serveFrom(r, "/items/2", "10.0.0.1:1").Code)
+	assert.Equal(t, http.StatusOK, serveFrom(r, "/items", "10.0.0.1:1").Code)
+}
+
+func TestRateLimiterPrincipalTiers(t *testing.T) {
+	withPrincipal := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			principal := &auth.Principal{ID: "acme", KeyID: r.Header.Get("X-Test-Key"), RateTier: r.Header.Get("X-Test-Tier")}
+			next.ServeHTTP(w, r.WithContext(auth.ContextWithPrincipal(r.Context(), principal)))
+		})
+	}
+
+	r := newRateLimitedRouter(RateLimiterOptions{
+		Policy:  RateLimitPolicy{Limit: 1, Window: time.Minute},
+		Tiers:   map[string]RateLimitPolicy{"gold": {Limit: 3, Window: time.Minute}},
+		KeyFunc: FirstKey(KeyByAPIKey, KeyByIP),
+	}, withPrincipal)
+
+	serve := func(key, tier string) int {
+		req := httptest.NewRequest(http.MethodGet, "/items", nil)
+		req.Header.Set("X-Test-Key", key)
+		req.Header.Set("X-Test-Tier", tier)
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, http.StatusOK, serve("key-gold", "gold"))
+	}
+	assert.Equal(t, http.StatusTooManyRequests, serve("key-gold", "gold"))
+
+	assert.Equal(t, http.StatusOK, serve("key-free", ""))
+	assert.Equal(t, http.StatusTooManyRequests, serve("key-free", ""))
+}
+
+func TestRateLimiterFailsOpen(t *testing.T) {
+	logger.InitLogger(logger.Config{})
+	r := newRateLimitedRouter(RateLimiterOptions{
+		Policy: RateLimitPolicy{Limit: 1, Window: time.Minute},
+		Store:  failingRateLimitStore{},
+	})
+
+	assert.Equal(t, http.StatusOK, serveFrom(r, "/items", "10.0.0.1:1").Code)
+	assert.Equal(t, http.StatusOK, serveFrom(r, "/items", "10.0.0.1:1").Code)
+}
+
+func TestRemoteRateLimitStoreSharedAcrossReplicas(t *testing.T) {
+	mr, err := miniredis.Run()
+	require.NoError(t, err)
+	defer mr.Close()
+
+	cache, err := zcache.NewRemoteCache(&zcache.RemoteConfig{Addr: mr.Addr()})
+	require.NoError(t, err)
+
+	options := RateLimiterOptions{
+		Policy: RateLimitPolicy{Limit: 2, Window: time.Minute},
+		Store:  NewRemoteRateLimitStore(cache),
+	}
+	replicaA := newRateLimitedRouter(options)
+	replicaB := newRateLimitedRouter(options)
+
+	assert.Equal(t, http.StatusOK, serveFrom(replicaA, "/items", "10.0.0.1:1").Code)
+	assert.Equal(t, http.StatusOK, serveFrom(replicaB, "/items", "10.0.0.1:1").Code)
+	assert.Equal(t, http.StatusTooManyRequests, serveFrom(replicaA, "/items", "10.0.0.1:1").Code)
+
+	keys := mr.Keys()
+	require.Len(t, keys, 1)
+	assert.True(t, mr.TTL(keys[0]) > time.Minute)
+
+	// A counter that lost its TTL gets it back on the next request.
+	require.NoError(t, mr.Set(keys[0], "2"))
+	require.Zero(t, mr.TTL(keys[0]))
+	assert.Equal(t, http.StatusTooManyRequests, serveFrom(replicaB, "/items", "10.0.0.1:1").Code)
+	assert.True(t, mr.TTL(keys[0]) > time.Minute)
+}
+
+func TestMemoryRateLimitStoreWindows(t *testing.T) {
+	store := NewMemoryRateLimitStore()
+
+	count, reset, err := store.Take(context.Background(), "client", 50*time.Millisecond)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), count)
+
+	count, _, _ = store.Take(context.Background(), "client", 50*time.Millisecond)
+	assert.Equal(t, int64(2), count)
+
+	time.Sleep(time.Until(reset))
+	count, _, _ = store.Take(context.Background(), "client", 50*time.Millisecond)
+	assert.Equal(t, int64(1), count)
+}
@@ -0,0 +1,174 @@
+package zmiddlewares
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/santhosh-tekuri/jsonschema/v6"
+	"github.com/zondax/golem/pkg/logger"
+	"github.com/zondax/golem/pkg/zrouter/domain"
+	"golang.org/x/text/language"
+	"golang.org/x/text/message"
+	"net/http"
+	"strings"
+)
+
+const (
+	invalidJSONErrorCode      = "invalid_json"
+	validationFailedErrorCode = "validation_failed"
+
+	schemaResourceURL              = "schema.json"
+	defaultMaxValidatedResponseLen = 1 << 20
+)
+
+var schemaMessagePrinter = message.NewPrinter(language.English)
+
+// JSONSchema is a compiled JSON Schema document. Drafts 4 to 2020-12 are supported, the default being 2020-12.
+type JSONSchema struct {
+	schema *jsonschema.Schema
+}
+
+func CompileJSONSchema(document []byte) (*JSONSchema, error) {
+	doc, err := jsonschema.UnmarshalJSON(bytes.NewReader(document))
+	if err != nil {
+		return nil, fmt.Errorf("invalid JSON schema document: %w", err)
+	}
+
+	compiler := jsonschema.NewCompiler()
+	if err := compiler.AddResource(schemaResourceURL, doc); err != nil {
+		return nil, err
+	}
+
+	schema, err := compiler.Compile(schemaResourceURL)
+	if err != nil {
+		return nil, err
+	}
+	return &JSONSchema{schema: schema}, nil
+}
+
+func MustCompileJSONSchema(document []byte) *JSONSchema {
+	schema, err := CompileJSONSchema(document)
+	if err != nil {
+		panic(err)
+	}
+	return schema
+}
+
+// Validate returns the violations of a JSON value, or an error if it is not valid JSON.
+func (s *JSONSchema) Validate(data []byte) ([]domain.FieldError, error) {
+	value, err := jsonschema.UnmarshalJSON(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.schema.Validate(value)
+	var validationErr *jsonschema.ValidationError
+	if errors.As(err, &validationErr) {
+		return collectFieldErrors(validationErr, nil), nil
+	}
+	return nil, err
+}
+
+type SchemaValidationOptions struct {
+	Request  *JSONSchema
+	Response *JSONSchema
+	// ValidateResponses validates the successful JSON responses against Response and logs the
+	// violations. Responses are buffered up to MaxResponseBytes, so it is meant for non-production
+	// environments.
+	ValidateResponses bool
+	// MaxResponseBytes skips the validation of larger responses. Defaults to 1MB.
+	MaxResponseBytes int
+}
+
+func (o *SchemaValidationOptions) setDefaultValues() {
+	if o.MaxResponseBytes <= 0 {
+		o.MaxResponseBytes = defaultMaxValidatedResponseLen
+	}
+}
+
+// SchemaValidation rejects requests whose body is not valid JSON or does not match the Request schema
+// with 400, listing every violation in the error fields. Bodies over the MaxBodySize limit get 413.
+func SchemaValidation(options SchemaValidationOptions) Middleware {
+	options.setDefaultValues()
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if options.Request != nil {
+				body, err := getRequestBody(r)
+				var maxBytesErr *http.MaxBytesError
+				if errors.As(err, &maxBytesErr) {
+					writeAPIError(w, domain.NewAPIErrorResponse(http.StatusRequestEntityTooLarge, requestTooLargeErrorCode, "request body too large"))
+					return
+				}
+				if err != nil {
+					writeAPIError(w, domain.NewAPIErrorResponse(http.StatusBadRequest, invalidJSONErrorCode, "cannot read request body", err.Error()))
+					return
+				}
+
+				fields, err := options.Request.Validate(body)
+				if err != nil {
+					writeAPIError(w, domain.NewAPIErrorResponse(http.StatusBadRequest, invalidJSONErrorCode, "request body is not valid JSON", err.Error()))
+					return
+				}
+				if len(fields) > 0 {
+					writeAPIError(w, domain.NewAPIErrorResponse(http.StatusBadRequest, validationFailedErrorCode, "request body does not match the schema").WithFields(fields...))
+					return
+				}
+			}
+
+			if options.Response == nil || !options.ValidateResponses {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			rw := &responseWriter{ResponseWriter: w, captureBody: true, bodyLimit: options.MaxResponseBytes + 1}
+			next.ServeHTTP(rw, r)
+			validateResponse(r, rw, options)
+		})
+	}
+}
+
+func validateResponse(r *http.Request, rw *responseWriter, options SchemaValidationOptions) {
+	if rw.status >= http.StatusMultipleChoices || !strings.Contains(rw.Header().Get(domain.ContentTypeHeader), "json") {
+		return
+	}
+
+	log := logger.GetLoggerFromContext(r.Context())
+	if len(rw.Body()) > options.MaxResponseBytes {
+		log.Debugf("Skipping the schema validation of the %s %s response, larger than %d bytes", r.Method, r.URL.Path, options.MaxResponseBytes)
+		return
+	}
+
+	fields, err := options.Response.Validate(rw.Body())
+	if err != nil {
+		log.Warnf("Response of %s %s is not valid JSON: %v", r.Method, r.URL.Path, err)
+		return
+	}
+	for _, field := range fields {
+		log.Warnf("Response of %s %s does not match the schema at %q: %s", r.Method, r.URL.Path, field.Field, field.Message)
+	}
+}
+
+func collectFieldErrors(err *jsonschema.ValidationError, fields []domain.FieldError) []domain.FieldError {
+	if len(err.Causes) == 0 {
+		return append(fields, domain.FieldError{
+			Field:   jsonPointer(err.InstanceLocation),
+			Message: err.ErrorKind.LocalizedString(schemaMessagePrinter),
+		})
+	}
+
+	for _, cause := range err.Causes {
+		fields = collectFieldErrors(cause, fields)
+	}
+	return fields
+}
+
+func jsonPointer(tokens []string) string {
+	var pointer strings.Builder
+	escaper := strings.NewReplacer("~", "~0", "/", "~1")
+	for _, token := range tokens {
+		pointer.WriteByte('/')
+		pointer.WriteString(escaper.Replace(token))
+	}
+	return pointer.String()
+}
@@ -0,0 +1,121 @@
+package zmiddlewares
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zondax/golem/pkg/logger"
+	"github.com/zondax/golem/pkg/zrouter/domain"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+const userSchema = `{
+	"type": "object",
+	"required": ["name", "email"],
+	"properties": {
+		"name": {"type": "string", "minLength": 1},
+		"email": {"type": "string"},
+		"tags": {"type": "array", "items": {"type": "string"}}
+	}
+}`
+
+func TestSchemaValidation_Request(t *testing.T) {
+	handler := SchemaValidation(SchemaValidationOptions{Request: MustCompileJSONSchema([]byte(userSchema))})(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var user map[string]interface{}
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&user))
+			w.WriteHeader(http.StatusCreated)
+		}))
+
+	serve := func(body string) (*httptest.ResponseRecorder, domain.APIError) {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/users", strings.NewReader(body)))
+
+		var apiErr domain.APIError
+		if rec.Code != http.StatusCreated {
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &apiErr))
+		}
+		return rec, apiErr
+	}
+
+	rec, _ := serve(`{"name": "golem", "email": "golem@zondax.ch"}`)
+	assert.Equal(t, http.StatusCreated, rec.Code)
+
+	rec, apiErr := serve(`{"name": "", "tags": ["a", 1]}`)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Equal(t, validationFailedErrorCode, apiErr.ErrorCode)
+	require.Len(t, apiErr.Fields, 3)
+	fields := map[string]string{}
+	for _, field := range apiErr.Fields {
+		fields[field.Field] = field.Message
+	}
+	assert.Contains(t, fields[""], "email")
+	assert.Contains(t, fields["/name"], "minLength")
+	assert.Contains(t, fields["/tags/1"], "want string")
+
+	rec, apiErr = serve(`{"name": `)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Equal(t, invalidJSONErrorCode, apiErr.ErrorCode)
+}
+
+func TestSchemaValidation_RequestTooLarge(t *testing.T) {
+	handler := MaxBodySize(8)(SchemaValidation(SchemaValidationOptions{Request: MustCompileJSONSchema([]byte(userSchema))})(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusCreated)
+		})))
+
+	req := httptest.NewRequest(http.MethodPost, "/users", strings.NewReader(`{"name": "golem", "email": "golem@zondax.ch"}`))
+	req.ContentLength = -1
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusRequestEntityTooLarge, rec.Code)
+	assert.Contains(t, rec.Body.String(), requestTooLargeErrorCode)
+}
+
+func TestSchemaValidation_Response(t *testing.T) {
+	core, logs := observer.New(zap.WarnLevel)
+	zap.ReplaceGlobals(zap.New(core))
+	defer logger.InitLogger(logger.Config{})
+
+	response := `{"name": "golem"}`
+	handler := func(options SchemaValidationOptions) http.Handler {
+		return SchemaValidation(options)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set(domain.ContentTypeHeader, domain.ContentTypeApplicationJSON)
+			_, _ = w.Write([]byte(response))
+		}))
+	}
+	options := SchemaValidationOptions{Response: MustCompileJSONSchema([]byte(userSchema))}
+
+	rec := httptest.NewRecorder()
+	handler(options).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/users/1", nil))
+	assert.Equal(t, response, rec.Body.String())
+	assert.Zero(t, logs.FilterMessageSnippet("schema").Len())
+
+	options.ValidateResponses = true
+	rec = httptest.NewRecorder()
+	handler(options).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/users/1", nil))
+	assert.Equal(t, response, rec.Body.String())
+	violations := logs.FilterMessageSnippet("does not match the schema")
+	require.Equal(t, 1, violations.Len())
+	assert.Contains(t, violations.All()[0].Message, "missing property 'email'")
+
+	options.MaxResponseBytes = 4
+	handler(options).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/users/1", nil))
+	assert.Equal(t, 1, logs.FilterMessageSnippet("does not match the schema").Len())
+}
+
+func TestCompileJSONSchema(t *testing.T) {
+	_, err := CompileJSONSchema([]byte(`{"type": 1}`))
+	assert.Error(t, err)
+
+	_, err = CompileJSONSchema([]byte(`{`))
+	assert.Error(t, err)
+
+	assert.Equal(t, "/a~1b/~0c", jsonPointer([]string{"a/b", "~c"}))
+}
@@ -0,0 +1,129 @@
+package zmiddlewares
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"github.com/zondax/golem/pkg/logger"
+	"github.com/zondax/golem/pkg/zrouter/domain"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	timeoutErrorCode = "request_timeout"
+)
+
+// ErrRequestTimeout is the cause of the request context once the Timeout deadline fires, telling it apart
+// from the deadlines of the calls made by the handler.
+var ErrRequestTimeout = errors.New("request timed out")
+
+type TimeoutOptions struct {
+	Timeout time.Duration
+	// StatusCode answered when the deadline is exceeded: 504 (default) or 503.
+	StatusCode int
+}
+
+// Timeout cancels the request context after the given duration and answers with an APIError if the
+// handler has not responded by then. The handler keeps running until it observes the cancellation,
+// and whatever it writes afterwards is discarded. The response is buffered, so this middleware is
+// not meant for streaming or WebSocket routes.
+func Timeout(timeout time.Duration) Middleware {
+	return TimeoutWithOptions(TimeoutOptions{Timeout: timeout})
+}
+
+func TimeoutWithOptions(options TimeoutOptions) Middleware {
+	if options.StatusCode == 0 {
+		options.StatusCode = http.StatusGatewayTimeout
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx, cancel := context.WithTimeoutCause(r.Context(), options.Timeout, ErrRequestTimeout)
+			defer cancel()
+
+			tw := &timeoutWriter{header: make(http.Header)}
+			done := make(chan struct{})
+			panicked := make(chan interface{}, 1)
+
+			go func() {
+				defer func() {
+					if p := recover(); p != nil {
+						panicked <- p
+					}
+				}()
+				next.ServeHTTP(tw, r.WithContext(ctx))
+				close(done)
+			}()
+
+			select {
+			case p := <-panicked:
+				// Re-raised so the error handler middleware deals with it as usual
+				panic(p)
+			case <-done:
+				tw.mu.Lock()
+				defer tw.mu.Unlock()
+				tw.flushTo(w)
+			case <-ctx.Done():
+				tw.mu.Lock()
+				defer tw.mu.Unlock()
+				tw.timedOut = true
+
+				if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+					logger.GetLoggerFromContext(r.Context()).Warnf("Request %s %s timed out after %v", r.Method, r.URL.Path, options.Timeout)
+					writeAPIError(w, domain.NewAPIErrorResponse(options.StatusCode, timeoutErrorCode, "request timed out"))
+				}
+			}
+		})
+	}
+}
+
+// timeoutWriter buffers the handler response until it completes, so nothing is written once the
+// timeout response has been sent.
+type timeoutWriter struct {
+	mu       sync.Mutex
+	header   http.Header
+	body     bytes.Buffer
+	status   int
+	timedOut bool
+}
+
+func (tw *timeoutWriter) Header() http.Header {
+	return tw.header
+}
+
+func (tw *timeoutWriter) WriteHeader(statusCode int) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+
+	if tw.timedOut || tw.status != 0 {
+		return
+	}
+	tw.status = statusCode
+}
+
+func (tw *timeoutWriter) Write(p []byte) (int, error) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+
+	if tw.timedOut {
+		return 0, http.ErrHandlerTimeout
+	}
+	if tw.status == 0 {
+		tw.status = http.StatusOK
+	}
+	return tw.body.Write(p)
+}
+
+func (tw *timeoutWriter) flushTo(w http.ResponseWriter) {
+	for key, values := range tw.header {
+		w.Header()[key] = values
+	}
+
+	if tw.status == 0 {
+		tw.status = http.StatusOK
+	}
+	w.WriteHeader(tw.status)
+	_, _ = w.Write(tw.body.Bytes())
+}
@@ -0,0 +1,71 @@
+package zmiddlewares
+
+import (
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/zondax/golem/pkg/logger"
+	"github.com/zondax/golem/pkg/zrouter/domain"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestTimeout(t *testing.T) {
+	logger.InitLogger(logger.Config{})
+
+	cancelled := make(chan struct{})
+	r := chi.NewRouter()
+	r.Use(RequestID())
+	r.With(Timeout(20*time.Millisecond)).Get("/slow", func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+		close(cancelled)
+		_, _ = w.Write([]byte("too late"))
+	})
+	r.With(Timeout(time.Second)).Get("/fast", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Fast", "true")
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte("OK"))
+	})
+	r.With(TimeoutWithOptions(TimeoutOptions{Timeout: 20 * time.Millisecond, StatusCode: http.StatusServiceUnavailable})).Get("/unavailable", func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	})
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/slow", nil))
+	assert.Equal(t, http.StatusGatewayTimeout, rec.Code)
+	assert.Contains(t, rec.Body.String(), timeoutErrorCode)
+	assert.NotContains(t, rec.Body.String(), "too late")
+	assert.NotEmpty(t, rec.Header().Get(RequestIDHeader))
+
+	select {
+	case <-cancelled:
+	case <-time.After(time.Second):
+		t.Fatal("the handler context was not cancelled")
+	}
+
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/fast", nil))
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	assert.Equal(t, "OK", rec.Body.String())
+	assert.Equal(t, "true", rec.Header().Get("X-Fast"))
+
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/unavailable", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	assert.Equal(t, domain.ContentTypeApplicationJSON, rec.Header().Get(domain.ContentTypeHeader))
+}
+
+func TestTimeoutPropagatesPanics(t *testing.T) {
+	logger.InitLogger(logger.Config{})
+
+	r := chi.NewRouter()
+	r.Use(ErrorHandlerMiddleware())
+	r.With(Timeout(time.Second)).Get("/panic", func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	})
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/panic", nil))
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+}
@@ -0,0 +1,133 @@
+package zmiddlewares
+
+import (
+	"github.com/zondax/golem/pkg/logger"
+	"github.com/zondax/golem/pkg/zobservability"
+	"github.com/zondax/golem/pkg/zobservability/providers/signoz"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"go.opentelemetry.io/otel/trace"
+	"net"
+	"net/http"
+	"regexp"
+	"strconv"
+)
+
+const tracerName = "github.com/zondax/golem/pkg/zrouter"
+
+type TracingOptions struct {
+	// TracerProvider defaults to the global provider, which is the one configured by the SigNoz observer.
+	TracerProvider trace.TracerProvider
+	// Propagation selects the formats extracted from the incoming headers. When empty, the global
+	// propagator is used.
+	Propagation zobservability.PropagationConfig
+	// ExcludePaths are not traced. They use the same syntax as LoggingMiddlewareOptions.ExcludePaths.
+	ExcludePaths []string
+}
+
+// Tracing starts a server span per request, continuing the trace found in the request headers. The span
+// is named after the chi route pattern and the request logger is tagged with the trace and span IDs.
+func Tracing(options TracingOptions) Middleware {
+	tracerProvider := options.TracerProvider
+	if tracerProvider == nil {
+		tracerProvider = otel.GetTracerProvider()
+	}
+	tracer := tracerProvider.Tracer(tracerName)
+
+	// The global provider and propagator delegate to the ones set later on by the observer
+	propagator := otel.GetTextMapPropagator()
+	if len(options.Propagation.Formats) > 0 {
+		propagator = zobservability.NewPropagator(options.Propagation)
+	}
+
+	excludeRegexps := make([]*regexp.Regexp, len(options.ExcludePaths))
+	for i, path := range options.ExcludePaths {
+		excludeRegexps[i] = PathToRegexp(path)
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			for _, re := range excludeRegexps {
+				if re.MatchString(r.URL.Path) {
+					next.ServeHTTP(w, r)
+					return
+				}
+			}
+
+			route := GetRoutePattern(r)
+			ctx := propagator.Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+			ctx = signoz.WithHTTPRoute(ctx, route)
+			ctx, span := tracer.Start(ctx, r.Method+" "+route,
+				trace.WithSpanKind(trace.SpanKindServer),
+				trace.WithAttributes(requestAttributes(r, route)...),
+			)
+			defer span.End()
+
+			requestID := r.Header.Get(RequestIDHeader)
+			ctx = logger.ContextWithLogger(ctx, newRequestLogger(ctx, requestID))
+
+			rw := &responseWriter{ResponseWriter: w}
+			next.ServeHTTP(rw, r.WithContext(ctx))
+
+			// RequestID runs inside, so a generated ID is only known from the response
+			if requestID == "" {
+				requestID = rw.Header().Get(RequestIDHeader)
+			}
+			if requestID != "" {
+				span.SetAttributes(attribute.String(logger.RequestIDKey, requestID))
+			}
+
+			status := rw.status
+			if status == 0 {
+				status = http.StatusOK
+			}
+			span.SetAttributes(semconv.HTTPResponseStatusCode(status))
+			if status >= http.StatusInternalServerError {
+				span.SetStatus(codes.Error, http.StatusText(status))
+			}
+		})
+	}
+}
+
+func requestAttributes(r *http.Request, route string) []attribute.KeyValue {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+
+	attributes := []attribute.KeyValue{
+		semconv.HTTPRequestMethodKey.String(r.Method),
+		semconv.HTTPRoute(route),
+		semconv.URLPath(r.URL.Path),
+		semconv.URLScheme(scheme),
+		semconv.NetworkProtocolVersion(strconv.Itoa(r.ProtoMajor) + "." + strconv.Itoa(r.ProtoMinor)),
+	}
+
+	if r.URL.RawQuery != "" {
+		attributes = append(attributes, semconv.URLQuery(r.URL.RawQuery))
+	}
+
+	if userAgent := r.UserAgent(); userAgent != "" {
+		attributes = append(attributes, semconv.UserAgentOriginal(userAgent))
+	}
+
+	host, port, err := net.SplitHostPort(r.Host)
+	if err != nil {
+		host = r.Host
+	}
+	if host != "" {
+		attributes = append(attributes, semconv.ServerAddress(host))
+	}
+	if serverPort, err := strconv.Atoi(port); err == nil {
+		attributes = append(attributes, semconv.ServerPort(serverPort))
+	}
+
+	if clientAddress := ClientIPFromRequest(r); clientAddress != "" {
+		attributes = append(attributes, semconv.ClientAddress(clientAddress))
+	}
+
+	return attributes
+}
@@ -0,0 +1,168 @@
+package zmiddlewares
+
+import (
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zondax/golem/pkg/logger"
+	"github.com/zondax/golem/pkg/zobservability"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+const (
+	testTraceID      = "4bf92f3577b34ef2a1b0f0a2d3c4e5f6"
+	testParentSpanID = "00f067aa0ba902b7"
+)
+
+func newTracingRouter(recorder *tracetest.SpanRecorder, propagation zobservability.PropagationConfig) *chi.Mux {
+	tracerProvider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+	r := chi.NewRouter()
+	r.Use(Tracing(TracingOptions{
+		TracerProvider: tracerProvider,
+		Propagation:    propagation,
+		ExcludePaths:   []string{"/health"},
+	}))
+	r.Use(RequestID())
+	r.Get("/users/{id}", func(w http.ResponseWriter, r *http.Request) {
+		spanContext := trace.SpanContextFromContext(r.Context())
+		w.Header().Set("X-Trace-ID", spanContext.TraceID().String())
+		_, _ = w.Write([]byte("OK"))
+	})
+	r.Get("/fail", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	})
+	r.Get("/health", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	return r
+}
+
+func spanAttributes(span sdktrace.ReadOnlySpan) map[attribute.Key]attribute.Value {
+	attributes := make(map[attribute.Key]attribute.Value)
+	for _, kv := range span.Attributes() {
+		attributes[kv.Key] = kv.Value
+	}
+	return attributes
+}
+
+func TestTracing(t *testing.T) {
+	logger.InitLogger(logger.Config{})
+	recorder := tracetest.NewSpanRecorder()
+	r := newTracingRouter(recorder, zobservability.PropagationConfig{Formats: []string{zobservability.PropagationW3C}})
+
+	req := httptest.NewRequest(http.MethodGet, "/users/42?verbose=true", nil)
+	req.Header.Set("traceparent", "00-"+testTraceID+"-"+testParentSpanID+"-01")
+	req.Header.Set("User-Agent", "golem-test")
+	req.Header.Set(RequestIDHeader, "request-1")
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, testTraceID, rec.Header().Get("X-Trace-ID"))
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 1)
+	span := spans[0]
+	assert.Equal(t, "GET /users/{id}", span.Name())
+	assert.Equal(t, trace.SpanKindServer, span.SpanKind())
+	assert.Equal(t, testTraceID, span.SpanContext().TraceID().String())
+	assert.Equal(t, testParentSpanID, span.Parent().SpanID().String())
+	assert.True(t, span.Parent().IsRemote())
+	assert.Equal(t, codes.Unset, span.Status().Code)
+
+	attributes := spanAttributes(span)
+	assert.Equal(t, "GET", attributes["http.request.method"].AsString())
+	assert.Equal(t, "/users/{id}", attributes["http.route"].AsString())
+	assert.Equal(t, "/users/42", attributes["url.path"].AsString())
+	assert.Equal(t, "verbose=true", attributes["url.query"].AsString())
+	assert.Equal(t, "golem-test", attributes["user_agent.original"].AsString())
+	assert.Equal(t, "example.com", attributes["server.address"].AsString())
+	assert.Equal(t, "192.0.2.1", attributes["client.address"].AsString())
+	assert.Equal(t, "request-1", attributes[logger.RequestIDKey].AsString())
+	assert.Equal(t, int64(http.StatusOK), attributes["http.response.status_code"].AsInt64())
+}
+
+func TestTracing_GeneratedRequestID(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	r := newTracingRouter(recorder, zobservability.PropagationConfig{})
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/users/42", nil))
+
+	requestID := rec.Header().Get(RequestIDHeader)
+	require.NotEmpty(t, requestID)
+	spans := recorder.Ended()
+	require.Len(t, spans, 1)
+	assert.Equal(t, requestID, spanAttributes(spans[0])[logger.RequestIDKey].AsString())
+}
+
+func TestTracing_B3Propagation(t *testing.T) {
+	logger.InitLogger(logger.Config{})
+	recorder := tracetest.NewSpanRecorder()
+	r := newTracingRouter(recorder, zobservability.PropagationConfig{Formats: []string{zobservability.PropagationB3}})
+
+	req := httptest.NewRequest(http.MethodGet, "/users/42", nil)
+	req.Header.Set("b3", testTraceID+"-"+testParentSpanID+"-1")
+	r.ServeHTTP(httptest.NewRecorder(), req)
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 1)
+	assert.Equal(t, testTraceID, spans[0].SpanContext().TraceID().String())
+	assert.Equal(t, testParentSpanID, spans[0].Parent().SpanID().String())
+}
+
+func TestTracing_ServerErrorsAndExclusions(t *testing.T) {
+	logger.InitLogger(logger.Config{})
+	recorder := tracetest.NewSpanRecorder()
+	r := newTracingRouter(recorder, zobservability.PropagationConfig{})
+
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/health", nil))
+	assert.Empty(t, recorder.Ended())
+
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/fail", nil))
+	spans := recorder.Ended()
+	require.Len(t, spans, 1)
+	assert.False(t, spans[0].Parent().IsValid())
+	assert.Equal(t, codes.Error, spans[0].Status().Code)
+	assert.Equal(t, int64(http.StatusBadGateway), spanAttributes(spans[0])["http.response.status_code"].AsInt64())
+}
+
+func TestTracing_LoggerCorrelation(t *testing.T) {
+	core, observed := observer.New(zapcore.InfoLevel)
+	defer zap.ReplaceGlobals(zap.New(core))()
+
+	recorder := tracetest.NewSpanRecorder()
+	tracerProvider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+	r := chi.NewRouter()
+	r.Use(RequestID())
+	r.Use(Tracing(TracingOptions{TracerProvider: tracerProvider}))
+	r.Get("/", func(w http.ResponseWriter, r *http.Request) {
+		logger.GetLoggerFromContext(r.Context()).Info("handled")
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(RequestIDHeader, "request-1")
+	r.ServeHTTP(httptest.NewRecorder(), req)
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 1)
+	entries := observed.FilterMessage("handled").All()
+	require.Len(t, entries, 1)
+
+	fields := entries[0].ContextMap()
+	assert.Equal(t, "request-1", fields[logger.RequestIDKey])
+	assert.Equal(t, spans[0].SpanContext().TraceID().String(), fields[logger.TraceIDKey])
+	assert.Equal(t, spans[0].SpanContext().SpanID().String(), fields[logger.SpanIDKey])
+}
@@ -0,0 +1,265 @@
+package zmiddlewares
+
+import (
+	"context"
+	"fmt"
+	"github.com/zondax/golem/pkg/zrouter/domain"
+	"mime"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// VersionStrategyURLPrefix reads the version from a path segment, e.g. /v2/users.
+	VersionStrategyURLPrefix = "url_prefix"
+	// VersionStrategyAcceptHeader reads the version from the Accept media type, either a vendor type
+	// such as application/vnd.zondax.v2+json or a version parameter such as application/json; version=2.
+	VersionStrategyAcceptHeader = "accept_header"
+	// VersionStrategyHeader reads the version from a custom header, X-API-Version by default.
+	VersionStrategyHeader = "header"
+
+	APIVersionHeader  = "X-API-Version"
+	DeprecationHeader = "Deprecation"
+	SunsetHeader      = "Sunset"
+	LinkHeader        = "Link"
+
+	versionMediaTypeParam         = "version"
+	unsupportedVersionErrorCode   = "unsupported_api_version"
+	unsupportedVersionErrorDetail = "supported versions are %s"
+)
+
+var vendorVersionRegexp = regexp.MustCompile(`\.v(\d+(?:\.\d+)*)(?:\+|$)`)
+
+// VersionDeprecation marks a version as deprecated. Requests for it get the Deprecation (RFC 9745),
+// Sunset (RFC 8594) and Link headers.
+type VersionDeprecation struct {
+	// Date is when the version was deprecated. When zero, the Deprecation header is "true".
+	Date   time.Time
+	Sunset time.Time
+	// Link points to the migration guide.
+	Link string
+}
+
+type VersioningOptions struct {
+	Strategy string
+	// Versions are the supported versions, e.g. "v1", "v2". "2" and "v2" are equivalent.
+	Versions []string
+	// Default is used when a header strategy request does not ask for a version. Defaults to the
+	// latest version.
+	Default string
+	// Header is the header read by VersionStrategyHeader. Defaults to X-API-Version.
+	Header string
+	// PathPrefix is where the versioned routes are mounted with VersionStrategyURLPrefix, e.g. "/api"
+	// for /api/v2/users. Only the path segment right after it is read. Defaults to the root.
+	PathPrefix   string
+	Deprecations map[string]VersionDeprecation
+}
+
+func (o *VersioningOptions) setDefaultValues() {
+	if o.Strategy == "" {
+		o.Strategy = VersionStrategyURLPrefix
+	}
+
+	if o.Header == "" {
+		o.Header = APIVersionHeader
+	}
+
+	o.PathPrefix = "/" + strings.Trim(o.PathPrefix, "/")
+
+	versions := make([]string, 0, len(o.Versions))
+	for _, version := range o.Versions {
+		normalized := NormalizeAPIVersion(version)
+		if normalized == "" {
+			panic(fmt.Sprintf("invalid API version %q", version))
+		}
+		versions = append(versions, normalized)
+	}
+	sort.Slice(versions, func(i, j int) bool {
+		return CompareAPIVersions(versions[i], versions[j]) < 0
+	})
+	o.Versions = versions
+
+	if o.Default == "" && len(versions) > 0 {
+		o.Default = versions[len(versions)-1]
+	}
+	o.Default = NormalizeAPIVersion(o.Default)
+
+	deprecations := make(map[string]VersionDeprecation, len(o.Deprecations))
+	for version, deprecation := range o.Deprecations {
+		deprecations[NormalizeAPIVersion(version)] = deprecation
+	}
+	o.Deprecations = deprecations
+}
+
+type apiVersionKey struct{}
+
+func ContextWithAPIVersion(ctx context.Context, version string) context.Context {
+	return context.WithValue(ctx, apiVersionKey{}, version)
+}
+
+// APIVersionFromContext returns the version requested by the client, as resolved by APIVersion.
+func APIVersionFromContext(ctx context.Context) (string, bool) {
+	version, ok := ctx.Value(apiVersionKey{}).(string)
+	return version, ok && version != ""
+}
+
+// NormalizeAPIVersion returns the "vN[.M]" form of a version, or "" if it is not a version.
+func NormalizeAPIVersion(version string) string {
+	version = strings.TrimPrefix(strings.ToLower(strings.TrimSpace(version)), "v")
+	if version == "" {
+		return ""
+	}
+	for _, part := range strings.Split(version, ".") {
+		if _, err := strconv.ParseUint(part, 10, 32); err != nil {
+			return ""
+		}
+	}
+	return "v" + version
+}
+
+// CompareAPIVersions compares two normalized versions numerically, returning -1, 0 or 1.
+func CompareAPIVersions(a, b string) int {
+	partsA := strings.Split(strings.TrimPrefix(a, "v"), ".")
+	partsB := strings.Split(strings.TrimPrefix(b, "v"), ".")
+	for i := 0; i < len(partsA) || i < len(partsB); i++ {
+		var numberA, numberB uint64
+		if i < len(partsA) {
+			numberA, _ = strconv.ParseUint(partsA[i], 10, 32)
+		}
+		if i < len(partsB) {
+			numberB, _ = strconv.ParseUint(partsB[i], 10, 32)
+		}
+		if numberA != numberB {
+			if numberA < numberB {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
+
+// ResolveAPIVersion picks the highest of the available versions that is not newer than the requested one.
+func ResolveAPIVersion(requested string, available []string) (string, bool) {
+	resolved := ""
+	for _, version := range available {
+		if CompareAPIVersions(version, requested) <= 0 && (resolved == "" || CompareAPIVersions(version, resolved) > 0) {
+			resolved = version
+		}
+	}
+	return resolved, resolved != ""
+}
+
+// APIVersion resolves the API version requested by the client and stores it in the request context.
+// With the header strategies, requests without a version get the default one and requests for an
+// unknown version are rejected with 400. With the URL prefix strategy, only the segment after PathPrefix
+// is read, and paths without a known version there are left unversioned. It panics if a version is not valid.
+func APIVersion(options VersioningOptions) Middleware {
+	options.setDefaultValues()
+	supported := make(map[string]struct{}, len(options.Versions))
+	for _, version := range options.Versions {
+		supported[version] = struct{}{}
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var version string
+			switch options.Strategy {
+			case VersionStrategyURLPrefix:
+				segment := versionPathSegment(r.URL.Path, options.PathPrefix)
+				if normalized := NormalizeAPIVersion(segment); normalized != "" && strings.HasPrefix(segment, "v") {
+					if _, ok := supported[normalized]; ok {
+						version = normalized
+					}
+				}
+			default:
+				requested := requestedHeaderVersion(r, options)
+				AddVaryHeader(w.Header(), versionVaryHeader(options))
+				if requested == "" {
+					version = options.Default
+					break
+				}
+
+				version = NormalizeAPIVersion(requested)
+				if _, ok := supported[version]; !ok {
+					writeAPIError(w, domain.NewAPIErrorResponse(http.StatusBadRequest, unsupportedVersionErrorCode,
+						fmt.Sprintf("unsupported API version %q", requested),
+						fmt.Sprintf(unsupportedVersionErrorDetail, strings.Join(options.Versions, ", "))))
+					return
+				}
+			}
+
+			if version == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			w.Header().Set(APIVersionHeader, version)
+			if deprecation, ok := options.Deprecations[version]; ok {
+				setDeprecationHeaders(w.Header(), deprecation)
+			}
+			next.ServeHTTP(w, r.WithContext(ContextWithAPIVersion(r.Context(), version)))
+		})
+	}
+}
+
+// versionPathSegment returns the path segment right after prefix, or "" if path is not under prefix.
+func versionPathSegment(path, prefix string) string {
+	if prefix != "/" {
+		rest, ok := strings.CutPrefix(path, prefix)
+		if !ok || !strings.HasPrefix(rest, "/") {
+			return ""
+		}
+		path = rest
+	}
+
+	segment, _, _ := strings.Cut(strings.TrimPrefix(path, "/"), "/")
+	return segment
+}
+
+func requestedHeaderVersion(r *http.Request, options VersioningOptions) string {
+	if options.Strategy == VersionStrategyHeader {
+		return r.Header.Get(options.Header)
+	}
+
+	for _, accepted := range splitHeaderList(r.Header.Values(domain.AcceptHeader)) {
+		mediaType, params, err := mime.ParseMediaType(accepted)
+		if err != nil {
+			continue
+		}
+		if version := params[versionMediaTypeParam]; version != "" {
+			return version
+		}
+		if match := vendorVersionRegexp.FindStringSubmatch(mediaType); match != nil {
+			return match[1]
+		}
+	}
+	return ""
+}
+
+func versionVaryHeader(options VersioningOptions) string {
+	if options.Strategy == VersionStrategyHeader {
+		return options.Header
+	}
+	return domain.AcceptHeader
+}
+
+func setDeprecationHeaders(header http.Header, deprecation VersionDeprecation) {
+	if deprecation.Date.IsZero() {
+		header.Set(DeprecationHeader, "true")
+	} else {
+		header.Set(DeprecationHeader, "@"+strconv.FormatInt(deprecation.Date.Unix(), 10))
+	}
+
+	if !deprecation.Sunset.IsZero() {
+		header.Set(SunsetHeader, deprecation.Sunset.UTC().Format(http.TimeFormat))
+	}
+
+	if deprecation.Link != "" {
+		header.Add(LinkHeader, fmt.Sprintf(`<%s>; rel="deprecation"`, deprecation.Link))
+	}
+}
@@ -0,0 +1,205 @@
+package zmiddlewares
+
+import (
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zondax/golem/pkg/logger"
+	"github.com/zondax/golem/pkg/metrics/collectors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func serveAPIVersion(options VersioningOptions, req *http.Request) (*httptest.ResponseRecorder, string) {
+	var version string
+	handler := APIVersion(options)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		version, _ = APIVersionFromContext(r.Context())
+	}))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	return rec, version
+}
+
+func TestAPIVersion(t *testing.T) {
+	versions := []string{"v2", "1", "v1.5"}
+
+	tests := []struct {
+		name        string
+		options     VersioningOptions
+		path        string
+		headers     map[string]string
+		wantStatus  int
+		wantVersion string
+	}{
+		{
+			name:        "url prefix",
+			options:     VersioningOptions{Versions: versions},
+			path:        "/v1.5/users",
+			wantStatus:  http.StatusOK,
+			wantVersion: "v1.5",
+		},
+		{
+			name:        "url prefix under a path prefix",
+			options:     VersioningOptions{Versions: versions, PathPrefix: "/api/"},
+			path:        "/api/v1.5/users",
+			wantStatus:  http.StatusOK,
+			wantVersion: "v1.5",
+		},
+		{
+			name:       "url prefix only reads the mounted segment",
+			options:    VersioningOptions{Versions: versions},
+			path:       "/files/v2",
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "url prefix outside the path prefix is unversioned",
+			options:    VersioningOptions{Versions: versions, PathPrefix: "/api"},
+			path:       "/apis/v2/users",
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "url prefix without version is unversioned",
+			options:    VersioningOptions{Versions: versions},
+			path:       "/health",
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "url prefix ignores unknown versions",
+			options:    VersioningOptions{Versions: versions},
+			path:       "/v3/users",
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:        "accept vendor media type",
+			options:     VersioningOptions{Strategy: VersionStrategyAcceptHeader, Versions: versions},
+			path:        "/users",
+			headers:     map[string]string{"Accept": "text/html, application/vnd.zondax.v1+json"},
+			wantStatus:  http.StatusOK,
+			wantVersion: "v1",
+		},
+		{
+			name:        "accept version parameter",
+			options:     VersioningOptions{Strategy: VersionStrategyAcceptHeader, Versions: versions},
+			path:        "/users",
+			headers:     map[string]string{"Accept": "application/json; version=2"},
+			wantStatus:  http.StatusOK,
+			wantVersion: "v2",
+		},
+		{
+			name:        "accept without version uses the latest",
+			options:     VersioningOptions{Strategy: VersionStrategyAcceptHeader, Versions: versions},
+			path:        "/users",
+			headers:     map[string]string{"Accept": "application/json"},
+			wantStatus:  http.StatusOK,
+			wantVersion: "v2",
+		},
+		{
+			name:        "custom header with default",
+			options:     VersioningOptions{Strategy: VersionStrategyHeader, Versions: versions, Default: "1", Header: "Api-Version"},
+			path:        "/users",
+			wantStatus:  http.StatusOK,
+			wantVersion: "v1",
+		},
+		{
+			name:        "custom header",
+			options:     VersioningOptions{Strategy: VersionStrategyHeader, Versions: versions, Header: "Api-Version"},
+			path:        "/users",
+			headers:     map[string]string{"Api-Version": "V1.5"},
+			wantStatus:  http.StatusOK,
+			wantVersion: "v1.5",
+		},
+		{
+			name:       "unsupported header version",
+			options:    VersioningOptions{Strategy: VersionStrategyHeader, Versions: versions},
+			path:       "/users",
+			headers:    map[string]string{APIVersionHeader: "v3"},
+			wantStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			for key, value := range tt.headers {
+				req.Header.Set(key, value)
+			}
+
+			rec, version := serveAPIVersion(tt.options, req)
+			assert.Equal(t, tt.wantStatus, rec.Code)
+			assert.Equal(t, tt.wantVersion, version)
+			assert.Equal(t, tt.wantVersion, rec.Header().Get(APIVersionHeader))
+			if tt.wantStatus == http.StatusBadRequest {
+				assert.Contains(t, rec.Body.String(), unsupportedVersionErrorCode)
+			}
+		})
+	}
+}
+
+func TestAPIVersion_Deprecation(t *testing.T) {
+	deprecated := time.Date(2025, time.January, 1, 0, 0, 0, 0, time.UTC)
+	sunset := time.Date(2026, time.July, 1, 0, 0, 0, 0, time.UTC)
+	options := VersioningOptions{
+		Strategy: VersionStrategyHeader,
+		Versions: []string{"v1", "v2"},
+		Deprecations: map[string]VersionDeprecation{
+			"1": {Date: deprecated, Sunset: sunset, Link: "https://docs.example.com/migrate-to-v2"},
+		},
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/users", nil)
+	req.Header.Set(APIVersionHeader, "v1")
+	rec, _ := serveAPIVersion(options, req)
+
+	assert.Equal(t, "@1735689600", rec.Header().Get(DeprecationHeader))
+	assert.Equal(t, "Wed, 01 Jul 2026 00:00:00 GMT", rec.Header().Get(SunsetHeader))
+	assert.Equal(t, `<https://docs.example.com/migrate-to-v2>; rel="deprecation"`, rec.Header().Get(LinkHeader))
+	assert.Equal(t, APIVersionHeader, rec.Header().Get(VaryHeader))
+
+	req = httptest.NewRequest(http.MethodGet, "/users", nil)
+	rec = httptest.NewRecorder()
+	rec.Header().Set(VaryHeader, "Accept-Encoding, x-api-version")
+	APIVersion(options)(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {})).ServeHTTP(rec, req)
+	assert.Equal(t, []string{"Accept-Encoding, x-api-version"}, rec.Header().Values(VaryHeader))
+	assert.Empty(t, rec.Header().Get(DeprecationHeader))
+	assert.Empty(t, rec.Header().Get(SunsetHeader))
+}
+
+func TestResolveAPIVersion(t *testing.T) {
+	available := []string{"v1", "v1.10", "v3"}
+
+	for requested, expected := range map[string]string{"v1": "v1", "v1.9": "v1", "v2": "v1.10", "v3": "v3", "v4": "v3", "v0": ""} {
+		version, ok := ResolveAPIVersion(requested, available)
+		assert.Equal(t, expected, version, requested)
+		assert.Equal(t, expected != "", ok, requested)
+	}
+
+	assert.Equal(t, "", NormalizeAPIVersion("beta"))
+	assert.Equal(t, "v2.1", NormalizeAPIVersion(" V2.1 "))
+	assert.Panics(t, func() { APIVersion(VersioningOptions{Versions: []string{"latest"}}) })
+}
+
+func TestRequestMetrics_VersionLabel(t *testing.T) {
+	logger.InitLogger(logger.Config{})
+	ms := newRecordingTaskMetrics()
+	options := RequestMetricsOptions{MetricsServer: ms, VersionLabel: true}
+	assert.Empty(t, RegisterRequestMetricsWithOptions(options))
+	ms.AssertCalled(t, "RegisterMetric", totalRequestsMetricName, "Total number of HTTP requests made.",
+		[]string{subRouteLabel, methodLabel, pathLabel, statusLabel, versionLabel}, &collectors.Counter{})
+
+	ok := func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) }
+	versioning := APIVersion(VersioningOptions{Versions: []string{"v1"}})
+	r := chi.NewRouter()
+	r.With(versioning, RequestMetricsWithOptions(options)).Get("/{version}/users", ok)
+	r.With(versioning, RequestMetricsWithOptions(options)).Get("/health", ok)
+
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/v1/users", nil))
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/health", nil))
+
+	totals := ms.find(totalRequestsMetricName)
+	require.Len(t, totals, 2)
+	assert.Equal(t, []string{"/{version}/*", http.MethodGet, "/{version}/users", "200", "v1"}, totals[0].labels)
+	assert.Equal(t, unversionedLabel, totals[1].labels[4])
+}
@@ -0,0 +1,276 @@
+package zmiddlewares
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/zondax/golem/pkg/logger"
+	"github.com/zondax/golem/pkg/zcache"
+	"github.com/zondax/golem/pkg/zrouter/domain"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	WebhookEncodingHex    = "hex"
+	WebhookEncodingBase64 = "base64"
+
+	webhookDeliveryCacheKeyPrefix = "zrouter_webhook_delivery"
+	defaultWebhookTolerance       = 5 * time.Minute
+	defaultWebhookDeliveryTTL     = 24 * time.Hour
+	defaultWebhookLockTimeout     = time.Minute
+	defaultWebhookMaxBodySize     = 1 << 20
+
+	webhookChallenge = `Signature`
+
+	webhookDeliveryInProgressErrorCode = "webhook_delivery_in_progress"
+
+	webhookDeliveryProcessing = "processing"
+	webhookDeliveryDone       = "done"
+)
+
+var ErrInvalidWebhookSignature = errors.New("invalid webhook signature")
+
+// WebhookVerifier checks that a delivery was sent by the provider. Errors reject it with 401.
+type WebhookVerifier interface {
+	Verify(r *http.Request, body []byte) error
+}
+
+type WebhookVerifierFunc func(r *http.Request, body []byte) error
+
+func (f WebhookVerifierFunc) Verify(r *http.Request, body []byte) error {
+	return f(r, body)
+}
+
+type HMACWebhookOptions struct {
+	// Secrets are tried in order, so a secret can be rotated without rejecting deliveries.
+	Secrets []string
+	// SignatureHeader carries the signature, e.g. "X-Hub-Signature-256".
+	SignatureHeader string
+	// Prefix is stripped from the signature, e.g. "sha256=".
+	Prefix string
+	// Encoding is WebhookEncodingHex (default) or WebhookEncodingBase64.
+	Encoding string
+	// TimestampHeader, when set, carries the unix time of the delivery, which must be within Tolerance.
+	// The timestamp is then signed with the body, as built by Payload.
+	TimestampHeader string
+	// Tolerance defaults to 5m.
+	Tolerance time.Duration
+	// Payload builds the signed content. Defaults to the body, or to "timestamp.body" with a TimestampHeader.
+	Payload func(timestamp string, body []byte) []byte
+}
+
+func (o *HMACWebhookOptions) setDefaultValues() {
+	if o.Encoding == "" {
+		o.Encoding = WebhookEncodingHex
+	}
+
+	if o.Tolerance == 0 {
+		o.Tolerance = defaultWebhookTolerance
+	}
+
+	if o.Payload == nil {
+		o.Payload = func(timestamp string, body []byte) []byte {
+			if timestamp == "" {
+				return body
+			}
+			return append([]byte(timestamp+"."), body...)
+		}
+	}
+}
+
+// NewHMACWebhookVerifier verifies HMAC-SHA256 signatures sent in a header, as GitHub, Shopify or Slack
+// do. It panics without secrets or signature header.
+func NewHMACWebhookVerifier(options HMACWebhookOptions) WebhookVerifier {
+	if len(options.Secrets) == 0 || options.SignatureHeader == "" {
+		panic("hmac webhook verifier requires secrets and a signature header")
+	}
+	options.setDefaultValues()
+
+	return WebhookVerifierFunc(func(r *http.Request, body []byte) error {
+		signature, ok := strings.CutPrefix(r.Header.Get(options.SignatureHeader), options.Prefix)
+		if !ok || signature == "" {
+			return fmt.Errorf("%w: missing signature", ErrInvalidWebhookSignature)
+		}
+
+		received, err := decodeWebhookSignature(signature, options.Encoding)
+		if err != nil {
+			return fmt.Errorf("%w: malformed signature", ErrInvalidWebhookSignature)
+		}
+
+		var timestamp string
+		if options.TimestampHeader != "" {
+			timestamp = r.Header.Get(options.TimestampHeader)
+			unixTime, err := strconv.ParseInt(timestamp, 10, 64)
+			if err != nil {
+				return fmt.Errorf("%w: malformed timestamp", ErrInvalidWebhookSignature)
+			}
+			if skew := time.Since(time.Unix(unixTime, 0)); skew > options.Tolerance || skew < -options.Tolerance {
+				return fmt.Errorf("%w: timestamp outside the accepted window", ErrInvalidWebhookSignature)
+			}
+		}
+
+		payload := options.Payload(timestamp, body)
+		for _, secret := range options.Secrets {
+			mac := hmac.New(sha256.New, []byte(secret))
+			mac.Write(payload)
+			if hmac.Equal(mac.Sum(nil), received) {
+				return nil
+			}
+		}
+		return ErrInvalidWebhookSignature
+	})
+}
+
+func decodeWebhookSignature(signature, encoding string) ([]byte, error) {
+	if encoding == WebhookEncodingBase64 {
+		return base64.StdEncoding.DecodeString(signature)
+	}
+	return hex.DecodeString(strings.ToLower(signature))
+}
+
+type WebhookOptions struct {
+	Verifier WebhookVerifier
+	// DeliveryIDHeader carries the ID deliveries are deduplicated on, e.g. "X-GitHub-Delivery". Without it,
+	// or when a delivery lacks it, the verified body is used instead. The ID is not signed, so set a
+	// TimestampHeader on the verifier to bound replays under a new ID.
+	DeliveryIDHeader string
+	// Cache enables deduplication: a delivery is only passed to the handler once, duplicates are acknowledged
+	// with 200, or 409 while the first one is still being processed.
+	Cache zcache.RemoteCache
+	// Namespace separates the deliveries of different providers. Defaults to the request path.
+	Namespace string
+	// DeliveryTTL is how long deliveries are remembered. Defaults to 24h.
+	DeliveryTTL time.Duration
+	// LockTimeout bounds how long a delivery being processed blocks its duplicates. Defaults to 1m.
+	LockTimeout time.Duration
+	// MaxBodySize defaults to 1MB.
+	MaxBodySize int64
+}
+
+func (o *WebhookOptions) setDefaultValues() {
+	if o.DeliveryTTL == 0 {
+		o.DeliveryTTL = defaultWebhookDeliveryTTL
+	}
+
+	if o.LockTimeout == 0 {
+		o.LockTimeout = defaultWebhookLockTimeout
+	}
+
+	if o.MaxBodySize == 0 {
+		o.MaxBodySize = defaultWebhookMaxBodySize
+	}
+}
+
+type webhookBodyKey struct{}
+
+// WebhookBodyFromContext returns the raw body the signature was verified against.
+func WebhookBodyFromContext(ctx context.Context) ([]byte, bool) {
+	body, ok := ctx.Value(webhookBodyKey{}).([]byte)
+	return body, ok
+}
+
+// Webhook verifies incoming webhook deliveries, rejecting invalid ones with 401, and drops replayed
+// deliveries. The raw body is restored for the handler and kept in the context. A duplicate arriving
+// while the first delivery is being processed gets 409, so the provider retries it later. A delivery
+// whose handler fails with a server error or panics is forgotten, so the provider can retry it.
+func Webhook(options WebhookOptions) Middleware {
+	if options.Verifier == nil {
+		panic("webhook middleware requires a verifier")
+	}
+	options.setDefaultValues()
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, err := io.ReadAll(io.LimitReader(r.Body, options.MaxBodySize+1))
+			if err != nil {
+				writeAPIError(w, domain.NewAPIErrorResponse(http.StatusBadRequest, "invalid_body", "unable to read request body"))
+				return
+			}
+			if int64(len(body)) > options.MaxBodySize {
+				writeAPIError(w, domain.NewAPIErrorResponse(http.StatusRequestEntityTooLarge, requestTooLargeErrorCode, "request body too large"))
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+
+			if err = options.Verifier.Verify(r, body); err != nil {
+				logger.GetLoggerFromContext(r.Context()).Debugf("Rejected webhook delivery: %v", err)
+				writeUnauthorized(w, webhookChallenge, "invalid webhook signature")
+				return
+			}
+
+			ctx := context.WithValue(r.Context(), webhookBodyKey{}, body)
+			if options.Cache == nil {
+				next.ServeHTTP(w, r.WithContext(ctx))
+				return
+			}
+
+			// Deliveries are only recorded once verified, so forged ones cannot burn a delivery ID.
+			cacheKey := webhookDeliveryCacheKey(r, body, options)
+			deliveryID := r.Header.Get(options.DeliveryIDHeader)
+
+			fresh, err := options.Cache.SetNX(r.Context(), cacheKey, webhookDeliveryProcessing, options.LockTimeout)
+			if err != nil {
+				logger.GetLoggerFromContext(r.Context()).Errorf("Error recording webhook delivery: %v", err)
+				writeAPIError(w, domain.NewAPIErrorResponse(http.StatusInternalServerError, internalErrorCode, "webhook deduplication unavailable"))
+				return
+			}
+			if !fresh {
+				var state string
+				if err := options.Cache.Get(r.Context(), cacheKey, &state); err == nil && state == webhookDeliveryDone {
+					logger.GetLoggerFromContext(r.Context()).Debugf("Dropped duplicated webhook delivery %s", deliveryID)
+					w.WriteHeader(http.StatusOK)
+					return
+				}
+				// Still processing, or the first delivery failed meanwhile: the provider must retry.
+				writeAPIError(w, domain.NewAPIErrorResponse(http.StatusConflict, webhookDeliveryInProgressErrorCode, "webhook delivery is being processed"))
+				return
+			}
+
+			// The delivery is forgotten when the handler fails or panics, so the provider can retry it.
+			rw := &responseWriter{ResponseWriter: w}
+			completed := false
+			defer func() {
+				ctx := context.WithoutCancel(r.Context())
+				if completed && rw.status < http.StatusInternalServerError {
+					if err := options.Cache.Set(ctx, cacheKey, webhookDeliveryDone, options.DeliveryTTL); err != nil {
+						logger.GetLoggerFromContext(r.Context()).Errorf("Error recording webhook delivery %s: %v", deliveryID, err)
+					}
+					return
+				}
+				if err := options.Cache.Delete(ctx, cacheKey); err != nil {
+					logger.GetLoggerFromContext(r.Context()).Errorf("Error releasing webhook delivery %s: %v", deliveryID, err)
+				}
+			}()
+
+			next.ServeHTTP(rw, r.WithContext(ctx))
+			completed = true
+		})
+	}
+}
+
+// webhookDeliveryCacheKey is scoped to the namespace, and uses the delivery ID, or the body digest when
+// there is none.
+func webhookDeliveryCacheKey(r *http.Request, body []byte, options WebhookOptions) string {
+	namespace := options.Namespace
+	if namespace == "" {
+		namespace = r.URL.Path
+	}
+
+	if options.DeliveryIDHeader != "" {
+		if deliveryID := r.Header.Get(options.DeliveryIDHeader); deliveryID != "" {
+			return fmt.Sprintf("%s:%s:id:%s", webhookDeliveryCacheKeyPrefix, namespace, deliveryID)
+		}
+	}
+
+	digest := sha256.Sum256(body)
+	return fmt.Sprintf("%s:%s:sha256:%s", webhookDeliveryCacheKeyPrefix, namespace, hex.EncodeToString(digest[:]))
+}
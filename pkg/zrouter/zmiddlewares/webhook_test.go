@@ -0,0 +1,230 @@
+package zmiddlewares
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/suite"
+	"github.com/zondax/golem/pkg/logger"
+	"github.com/zondax/golem/pkg/zcache"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const (
+	webhookSecret          = "webhook-secret"
+	webhookSignatureHeader = "X-Hub-Signature-256"
+	webhookDeliveryHeader  = "X-GitHub-Delivery"
+	webhookPayload         = `{"action":"opened"}`
+)
+
+func signWebhook(secret string, payload []byte) []byte {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(payload)
+	return mac.Sum(nil)
+}
+
+type WebhookSuite struct {
+	suite.Suite
+	mr     *miniredis.Miniredis
+	router *chi.Mux
+	calls  atomic.Int32
+	status atomic.Int32
+	panics atomic.Bool
+}
+
+func (s *WebhookSuite) SetupTest() {
+	logger.InitLogger(logger.Config{})
+
+	mr, err := miniredis.Run()
+	s.Require().NoError(err)
+	s.mr = mr
+
+	cache, err := zcache.NewRemoteCache(&zcache.RemoteConfig{Addr: mr.Addr()})
+	s.Require().NoError(err)
+
+	s.calls.Store(0)
+	s.status.Store(http.StatusNoContent)
+	s.panics.Store(false)
+
+	s.router = chi.NewRouter()
+	s.router.With(Webhook(WebhookOptions{
+		Verifier: NewHMACWebhookVerifier(HMACWebhookOptions{
+			Secrets:         []string{"old-secret", webhookSecret},
+			SignatureHeader: webhookSignatureHeader,
+			Prefix:          "sha256=",
+		}),
+		DeliveryIDHeader: webhookDeliveryHeader,
+		Cache:            cache,
+		MaxBodySize:      64,
+	})).Post("/{provider}", func(w http.ResponseWriter, r *http.Request) {
+		s.calls.Add(1)
+
+		body, err := io.ReadAll(r.Body)
+		s.NoError(err)
+		raw, ok := WebhookBodyFromContext(r.Context())
+		s.True(ok)
+		s.Equal(string(raw), string(body))
+
+		if s.panics.Load() {
+			panic("handler failure")
+		}
+		w.WriteHeader(int(s.status.Load()))
+	})
+}
+
+func (s *WebhookSuite) TearDownTest() {
+	s.mr.Close()
+}
+
+func (s *WebhookSuite) deliver(deliveryID, signature, payload string) *httptest.ResponseRecorder {
+	return s.deliverTo("/github", deliveryID, signature, payload)
+}
+
+func (s *WebhookSuite) deliverTo(path, deliveryID, signature, payload string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(payload))
+	req.Header.Set(webhookDeliveryHeader, deliveryID)
+	req.Header.Set(webhookSignatureHeader, signature)
+	rec := httptest.NewRecorder()
+	s.router.ServeHTTP(rec, req)
+	return rec
+}
+
+func (s *WebhookSuite) validSignature() string {
+	return s.signature(webhookPayload)
+}
+
+func (s *WebhookSuite) signature(payload string) string {
+	return "sha256=" + hex.EncodeToString(signWebhook(webhookSecret, []byte(payload)))
+}
+
+func (s *WebhookSuite) TestVerifiesSignature() {
+	rec := s.deliver("1", s.validSignature(), webhookPayload)
+	s.Equal(http.StatusNoContent, rec.Code)
+	s.Equal(int32(1), s.calls.Load())
+
+	rec = s.deliver("2", "sha256="+hex.EncodeToString(signWebhook("wrong", []byte(webhookPayload))), webhookPayload)
+	s.Equal(http.StatusUnauthorized, rec.Code)
+	s.Contains(rec.Body.String(), unauthorizedErrorCode)
+
+	rec = s.deliver("3", "", webhookPayload)
+	s.Equal(http.StatusUnauthorized, rec.Code)
+
+	rec = s.deliver("4", s.validSignature(), strings.Repeat("a", 65))
+	s.Equal(http.StatusRequestEntityTooLarge, rec.Code)
+	s.Equal(int32(1), s.calls.Load())
+}
+
+func (s *WebhookSuite) TestDeduplicatesDeliveries() {
+	s.Equal(http.StatusNoContent, s.deliver("1", s.validSignature(), webhookPayload).Code)
+	s.Equal(http.StatusOK, s.deliver("1", s.validSignature(), webhookPayload).Code)
+	s.Equal(int32(1), s.calls.Load())
+
+	// Deliveries are deduplicated on their ID, not on their content.
+	s.Equal(http.StatusNoContent, s.deliver("2", s.validSignature(), webhookPayload).Code)
+	s.Equal(int32(2), s.calls.Load())
+
+	// A forged delivery does not burn the delivery ID.
+	other := `{"action":"closed"}`
+	s.Equal(http.StatusUnauthorized, s.deliver("3", "sha256=00", other).Code)
+	s.Equal(http.StatusNoContent, s.deliver("3", s.signature(other), other).Code)
+	s.Equal(int32(3), s.calls.Load())
+
+	// Deliveries are namespaced by route.
+	s.Equal(http.StatusNoContent, s.deliverTo("/gitlab", "1", s.validSignature(), webhookPayload).Code)
+	s.Equal(int32(4), s.calls.Load())
+
+	// Without a delivery ID, the verified body is the key.
+	s.Equal(http.StatusNoContent, s.deliverTo("/bitbucket", "", s.validSignature(), webhookPayload).Code)
+	s.Equal(http.StatusOK, s.deliverTo("/bitbucket", "", s.validSignature(), webhookPayload).Code)
+	s.Equal(int32(5), s.calls.Load())
+}
+
+func (s *WebhookSuite) TestDuplicateOfDeliveryInProgress() {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	router := chi.NewRouter()
+	cache, err := zcache.NewRemoteCache(&zcache.RemoteConfig{Addr: s.mr.Addr()})
+	s.Require().NoError(err)
+	router.With(Webhook(WebhookOptions{
+		Verifier:         WebhookVerifierFunc(func(r *http.Request, body []byte) error { return nil }),
+		DeliveryIDHeader: webhookDeliveryHeader,
+		Cache:            cache,
+	})).Post("/", func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})
+
+	deliver := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(webhookPayload))
+		req.Header.Set(webhookDeliveryHeader, "1")
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	first := make(chan int)
+	go func() { first <- deliver().Code }()
+	<-started
+
+	rec := deliver()
+	s.Equal(http.StatusConflict, rec.Code)
+	s.Contains(rec.Body.String(), webhookDeliveryInProgressErrorCode)
+
+	close(release)
+	s.Equal(http.StatusServiceUnavailable, <-first)
+}
+
+func (s *WebhookSuite) TestFailedDeliveryCanBeRetried() {
+	s.status.Store(http.StatusServiceUnavailable)
+	s.Equal(http.StatusServiceUnavailable, s.deliver("1", s.validSignature(), webhookPayload).Code)
+
+	s.status.Store(http.StatusNoContent)
+	s.Equal(http.StatusNoContent, s.deliver("1", s.validSignature(), webhookPayload).Code)
+	s.Equal(int32(2), s.calls.Load())
+}
+
+func (s *WebhookSuite) TestPanickedDeliveryCanBeRetried() {
+	s.panics.Store(true)
+	s.Panics(func() { s.deliver("1", s.validSignature(), webhookPayload) })
+
+	s.panics.Store(false)
+	s.Equal(http.StatusNoContent, s.deliver("1", s.validSignature(), webhookPayload).Code)
+	s.Equal(int32(2), s.calls.Load())
+}
+
+func (s *WebhookSuite) TestTimestampTolerance() {
+	verifier := NewHMACWebhookVerifier(HMACWebhookOptions{
+		Secrets:         []string{webhookSecret},
+		SignatureHeader: "X-Signature",
+		Encoding:        WebhookEncodingBase64,
+		TimestampHeader: "X-Timestamp",
+		Tolerance:       time.Minute,
+	})
+
+	request := func(at time.Time) *http.Request {
+		timestamp := strconv.FormatInt(at.Unix(), 10)
+		req := httptest.NewRequest(http.MethodPost, "/", nil)
+		req.Header.Set("X-Timestamp", timestamp)
+		req.Header.Set("X-Signature", base64.StdEncoding.EncodeToString(signWebhook(webhookSecret, []byte(timestamp+"."+webhookPayload))))
+		return req
+	}
+
+	s.NoError(verifier.Verify(request(time.Now()), []byte(webhookPayload)))
+	s.ErrorIs(verifier.Verify(request(time.Now().Add(-2*time.Minute)), []byte(webhookPayload)), ErrInvalidWebhookSignature)
+	s.ErrorIs(verifier.Verify(request(time.Now()), []byte(`{"action":"closed"}`)), ErrInvalidWebhookSignature)
+}
+
+func TestWebhookSuite(t *testing.T) {
+	suite.Run(t, new(WebhookSuite))
+}
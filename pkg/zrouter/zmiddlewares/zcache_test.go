@@ -39,14 +39,18 @@ func TestCacheMiddleware(t *testing.T) {
 
 	cachedResponseBody := []byte("Test!")
 
+	storedResponse := func(value cachedResponse) bool {
+		return value.Status == http.StatusOK && bytes.Equal(value.Body, cachedResponseBody) && value.Header.Get(ETagHeader) != ""
+	}
+
 	// Setup the mock for the first request (cache miss)
-	mockCache.On("Get", mock.Anything, expectedCacheKey, mock.AnythingOfType("*[]uint8")).Return(nil).Once()
-	mockCache.On("Set", mock.Anything, expectedCacheKey, cachedResponseBody, 5*time.Minute).Return(nil).Once()
+	mockCache.On("Get", mock.Anything, expectedCacheKey, mock.AnythingOfType("*zmiddlewares.cachedResponse")).Return(nil).Once()
+	mockCache.On("Set", mock.Anything, expectedCacheKey, mock.MatchedBy(storedResponse), 5*time.Minute).Return(nil).Once()
 
 	// Setup the mock for the second request (cache hit)
-	mockCache.On("Get", mock.Anything, expectedCacheKey, mock.AnythingOfType("*[]uint8")).Return(nil).Run(func(args mock.Arguments) {
-		arg := args.Get(2).(*[]byte) // Get the argument where the cached response will be stored
-		*arg = cachedResponseBody    // Simulate the cached response
+	mockCache.On("Get", mock.Anything, expectedCacheKey, mock.AnythingOfType("*zmiddlewares.cachedResponse")).Return(nil).Run(func(args mock.Arguments) {
+		arg := args.Get(2).(*cachedResponse) // Get the argument where the cached response will be stored
+		*arg = cachedResponse{Status: http.StatusOK, Header: http.Header{}, Body: cachedResponseBody, StoredAt: time.Now()}
 	})
 
 	// Perform the first request: the response should be generated and cached
@@ -98,12 +102,12 @@ func TestCacheMiddlewareWithRequestBody(t *testing.T) {
 	hashedBody := generateBodyHash(requestBody)
 	expectedCacheKey := fmt.Sprintf("zrouter_cache.POST:/post-path.body:%s", hashedBody)
 
-	mockCache.On("Get", mock.Anything, expectedCacheKey, mock.AnythingOfType("*[]uint8")).Return(nil).Once()
+	mockCache.On("Get", mock.Anything, expectedCacheKey, mock.AnythingOfType("*zmiddlewares.cachedResponse")).Return(nil).Once()
 	mockCache.On("Set", mock.Anything, expectedCacheKey, mock.Anything, 5*time.Minute).Return(nil).Once()
 
-	mockCache.On("Get", mock.Anything, expectedCacheKey, mock.AnythingOfType("*[]uint8")).Return(nil).Run(func(args mock.Arguments) {
-		arg := args.Get(2).(*[]byte)
-		*arg = []byte("Received: Request Body Content")
+	mockCache.On("Get", mock.Anything, expectedCacheKey, mock.AnythingOfType("*zmiddlewares.cachedResponse")).Return(nil).Run(func(args mock.Arguments) {
+		arg := args.Get(2).(*cachedResponse)
+		*arg = cachedResponse{Status: http.StatusOK, Header: http.Header{}, Body: []byte("Received: Request Body Content"), StoredAt: time.Now()}
 	}).Once()
 
 	req := httptest.NewRequest("POST", "/post-path", bytes.NewBuffer(requestBody))
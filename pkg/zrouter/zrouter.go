@@ -8,6 +8,7 @@ import (
 	"github.com/zondax/golem/pkg/metrics"
 	"github.com/zondax/golem/pkg/metrics/collectors"
 	"github.com/zondax/golem/pkg/zcache"
+	"github.com/zondax/golem/pkg/zrouter/domain"
 	"github.com/zondax/golem/pkg/zrouter/zmiddlewares"
 	"net/http"
 	"strings"
@@ -42,6 +43,48 @@ type SystemMetrics struct {
 	UpdateInterval time.Duration
 }
 
+type ProblemDetailsConfig struct {
+	// Enable writes handler errors and recovered panics as RFC 7807 application/problem+json.
+	Enable   bool
+	Registry *domain.ProblemRegistry
+}
+
+type CompressionConfig struct {
+	// Enable compresses responses in the default middleware chain, inside RequestMetrics and around
+	// every router middleware, so CacheMiddleware keeps uncompressed bodies.
+	Enable  bool
+	Options zmiddlewares.CompressionOptions
+}
+
+type TracingConfig struct {
+	// Enable starts a server span per request around the other default middlewares, so the logging,
+	// metrics and error handling middlewares run inside it.
+	Enable  bool
+	Options zmiddlewares.TracingOptions
+}
+
+type ClientIPConfig struct {
+	// Enable resolves the client IP, scheme and host before any other middleware, so logs, metrics,
+	// traces and rate limits see the original client instead of the proxy.
+	Enable  bool
+	Options zmiddlewares.ClientIPOptions
+}
+
+type VersioningConfig struct {
+	// Enable resolves the API version of every request and labels the request metrics with it. Routes
+	// declare their versions with RouteOptions.Versions.
+	Enable  bool
+	Options zmiddlewares.VersioningOptions
+}
+
+type SchemaValidationConfig struct {
+	// ValidateResponses validates the responses of routes with a ResponseSchema and logs the violations.
+	// It is ignored in production, i.e. when Environment is zobservability.EnvironmentProduction.
+	ValidateResponses bool
+	Environment       string
+	MaxResponseBytes  int
+}
+
 type Config struct {
 	ReadTimeOut           time.Duration
 	WriteTimeOut          time.Duration
@@ -51,6 +94,20 @@ type Config struct {
 	JWTUsageMetricsConfig JWTUsageMetricsConfig
 	AppVersion            string
 	AppRevision           string
+	// Encoders enables content negotiation on the Accept header. The first encoder is the default one,
+	// also used instead of a 406 when no encoder matches. When empty, responses are always JSON encoded.
+	Encoders       []domain.Encoder
+	ProblemDetails ProblemDetailsConfig
+	WebSocket      WebSocketConfig
+	Compression    CompressionConfig
+	Tracing        TracingConfig
+	ClientIP       ClientIPConfig
+	Versioning     VersioningConfig
+	// SchemaValidation configures the validation of the routes registered with JSON schemas.
+	SchemaValidation SchemaValidationConfig
+	// RequestMetrics configures the default RequestMetrics middleware. MetricsServer defaults to the
+	// router metrics server.
+	RequestMetrics zmiddlewares.RequestMetricsOptions
 }
 
 func (c *Config) setDefaultValues() {
@@ -69,8 +126,12 @@ func (c *Config) setDefaultValues() {
 }
 
 type RegisteredRoute struct {
-	Method string
-	Path   string
+	Method   string
+	Path     string
+	Name     string
+	Tags     []string
+	Metadata map[string]interface{}
+	Versions []string
 }
 
 type ZRouter interface {
@@ -85,13 +146,18 @@ type Routes interface {
 	PUT(path string, handler HandlerFunc, middlewares ...zmiddlewares.Middleware) Routes
 	PATCH(path string, handler HandlerFunc, middlewares ...zmiddlewares.Middleware) Routes
 	DELETE(path string, handler HandlerFunc, middlewares ...zmiddlewares.Middleware) Routes
+	WS(path string, handler WSHandlerFunc, middlewares ...zmiddlewares.Middleware) Routes
 	Handle(pattern string, handler HandlerFunc)
 	Route(method, path string, handler HandlerFunc, middlewares ...zmiddlewares.Middleware) Routes
+	RouteWithOptions(method, path string, handler HandlerFunc, options RouteOptions, middlewares ...zmiddlewares.Middleware) Routes
 	Mount(pattern string, subRouter Routes)
+	Transcode(options TranscodingOptions, middlewares ...zmiddlewares.Middleware) Routes
 	Group(prefix string) Routes
 	Use(middlewares ...zmiddlewares.Middleware) Routes
 	NoRoute(handler HandlerFunc)
 	GetRegisteredRoutes() []RegisteredRoute
+	DescribeRoutes() []RouteDescription
+	URLFor(name string, params map[string]string) (string, error)
 	SetDefaultMiddlewares(loggingOptions zmiddlewares.LoggingMiddlewareOptions)
 	GetHandler() http.Handler
 	ServeHTTP(w http.ResponseWriter, req *http.Request)
@@ -103,9 +169,14 @@ type zrouter struct {
 	middlewares        []zmiddlewares.Middleware
 	defaultMiddlewares []zmiddlewares.Middleware
 	metricsServer      metrics.TaskMetrics
-	routes             []RegisteredRoute
+	routes             []RouteDescription
+	mounts             []mountedRouter
+	versionedRoutes    map[string]*versionedRoute
 	mutex              sync.Mutex
 	config             *Config
+	requestIDInstalled bool
+	// inheritedMiddlewares names the parent middlewares installed on the chi router, outermost first.
+	inheritedMiddlewares []string
 }
 
 func New(metricsServer metrics.TaskMetrics, config *Config) ZRouter {
@@ -137,33 +208,67 @@ func New(metricsServer metrics.TaskMetrics, config *Config) ZRouter {
 }
 
 func (r *zrouter) NewSubRouter() ZRouter {
+	return r.newChildRouter()
+}
+
+// newChildRouter shares the config and metrics server with r, and runs the middlewares of r around
+// every request, in the same order as they wrap the routes of r. The middlewares are copied, so the
+// ones added to r afterwards do not run for the routes of the child.
+func (r *zrouter) newChildRouter() *zrouter {
 	newRouter := &zrouter{
-		router:        chi.NewRouter(),
-		metricsServer: r.metricsServer,
-		config:        r.config,
+		router:               chi.NewRouter(),
+		metricsServer:        r.metricsServer,
+		config:               r.config,
+		requestIDInstalled:   r.requestIDInstalled,
+		inheritedMiddlewares: append([]string{}, r.inheritedMiddlewares...),
 	}
 
-	for _, middleware := range r.middlewares {
-		newRouter.router.Use(middleware)
+	for i := len(r.defaultMiddlewares) - 1; i >= 0; i-- {
+		newRouter.router.Use(r.defaultMiddlewares[i])
+		newRouter.inheritedMiddlewares = append(newRouter.inheritedMiddlewares, middlewareName(r.defaultMiddlewares[i]))
 	}
 
-	for _, middleware := range r.defaultMiddlewares {
-		newRouter.router.Use(middleware)
+	for i := len(r.middlewares) - 1; i >= 0; i-- {
+		newRouter.router.Use(r.middlewares[i])
+		newRouter.inheritedMiddlewares = append(newRouter.inheritedMiddlewares, middlewareName(r.middlewares[i]))
 	}
 
 	return newRouter
 }
 
 func (r *zrouter) SetDefaultMiddlewares(loggingOptions zmiddlewares.LoggingMiddlewareOptions) {
-	if err := zmiddlewares.RegisterRequestMetrics(r.metricsServer); err != nil {
+	metricsOptions := r.config.RequestMetrics
+	if metricsOptions.MetricsServer == nil {
+		metricsOptions.MetricsServer = r.metricsServer
+	}
+	if r.config.Versioning.Enable {
+		metricsOptions.VersionLabel = true
+	}
+	if err := zmiddlewares.RegisterRequestMetricsWithOptions(metricsOptions); err != nil {
 		logger.GetLoggerFromContext(context.Background()).Errorf("Error registering metrics %v", err)
 	}
 
-	r.useDefaultMiddleware(zmiddlewares.ErrorHandlerMiddleware())
-	r.useDefaultMiddleware(zmiddlewares.RequestMetrics(r.metricsServer))
+	r.useDefaultMiddleware(zmiddlewares.ErrorHandlerMiddlewareWithOptions(zmiddlewares.ErrorHandlerOptions{
+		ProblemDetails:  r.config.ProblemDetails.Enable,
+		ProblemRegistry: r.config.ProblemDetails.Registry,
+	}))
+	if r.config.Compression.Enable {
+		r.useDefaultMiddleware(zmiddlewares.Compression(r.config.Compression.Options))
+	}
+	r.useDefaultMiddleware(zmiddlewares.RequestMetricsWithOptions(metricsOptions))
 	if loggingOptions.Enable {
 		r.useDefaultMiddleware(zmiddlewares.LoggingMiddleware(loggingOptions))
 	}
+	if r.config.Tracing.Enable {
+		r.useDefaultMiddleware(zmiddlewares.Tracing(r.config.Tracing.Options))
+	}
+	if r.config.ClientIP.Enable {
+		r.useDefaultMiddleware(zmiddlewares.ClientIP(r.config.ClientIP.Options))
+	}
+	if r.config.Versioning.Enable {
+		// Outermost, so unsupported versions are rejected before any work and the metrics get the version.
+		r.useDefaultMiddleware(zmiddlewares.APIVersion(r.config.Versioning.Options))
+	}
 
 	if r.config.JWTUsageMetricsConfig.Enable {
 		if r.config.JWTUsageMetricsConfig.RemoteCache == nil {
@@ -174,13 +279,12 @@ func (r *zrouter) SetDefaultMiddlewares(loggingOptions zmiddlewares.LoggingMiddl
 }
 
 func (r *zrouter) Group(prefix string) Routes {
-	newRouter := &zrouter{
-		router: chi.NewRouter(),
-	}
+	newRouter := r.newChildRouter()
 
 	r.router.Group(func(groupRouter chi.Router) {
 		groupRouter.Mount(prefix, newRouter.router)
 	})
+	r.mount(prefix, newRouter)
 
 	return newRouter
 }
@@ -231,14 +335,49 @@ func (r *zrouter) Run(addr ...string) error {
 }
 
 func (r *zrouter) Method(method, path string, handler HandlerFunc, middlewares ...zmiddlewares.Middleware) Routes {
-	chiHandler := getChiHandler(handler)
-	finalHandler := r.applyMiddlewares(chiHandler, middlewares...)
-	r.router.Method(method, path, finalHandler)
+	r.handle(method, path, getChiHandler(handler, r.responseSettings()), middlewares...)
+	return r
+}
+
+func (r *zrouter) handle(method, path string, handler http.HandlerFunc, middlewares ...zmiddlewares.Middleware) {
+	r.handleWithOptions(method, path, handler, RouteOptions{}, middlewares...)
+}
+
+func (r *zrouter) handleWithOptions(method, path string, handler http.HandlerFunc, options RouteOptions, middlewares ...zmiddlewares.Middleware) {
+	if options.Name != "" {
+		for _, route := range r.DescribeRoutes() {
+			if route.Name == options.Name {
+				panic(fmt.Sprintf("route name %q is already registered", options.Name))
+			}
+		}
+	}
+
+	if validation := r.schemaValidation(options); validation != nil {
+		// Innermost, so authentication and the other route middlewares run before the body is validated.
+		middlewares = append([]zmiddlewares.Middleware{validation}, middlewares...)
+	}
+
+	chain := r.middlewareChain(middlewares)
+	versions := options.Versions
+	if len(versions) > 0 {
+		path, versions = r.handleVersioned(method, path, handler, versions, middlewares...)
+	} else {
+		r.router.Method(method, path, r.applyMiddlewares(handler, middlewares...))
+	}
 
 	r.mutex.Lock()
-	r.routes = append(r.routes, RegisteredRoute{Method: method, Path: path})
+	r.routes = append(r.routes, RouteDescription{
+		RegisteredRoute: RegisteredRoute{
+			Method:   method,
+			Path:     path,
+			Name:     options.Name,
+			Tags:     options.Tags,
+			Metadata: options.Metadata,
+			Versions: versions,
+		},
+		Middlewares: chain,
+	})
 	r.mutex.Unlock()
-	return r
 }
 
 func (r *zrouter) GET(path string, handler HandlerFunc, middlewares ...zmiddlewares.Middleware) Routes {
@@ -272,9 +411,11 @@ func (r *zrouter) Route(method, path string, handler HandlerFunc, middlewares ..
 }
 
 func (r *zrouter) NoRoute(handler HandlerFunc) {
-	r.router.NotFound(getChiHandler(handler))
+	r.router.NotFound(getChiHandler(handler, r.responseSettings()))
 }
 
+// Use adds middlewares to the routes registered afterwards. Groups and sub-routers copy the middlewares
+// of r when they are created, so they do not get the ones added later.
 func (r *zrouter) Use(middlewares ...zmiddlewares.Middleware) Routes {
 	r.middlewares = append(r.middlewares, middlewares...)
 	return r
@@ -287,6 +428,7 @@ func (r *zrouter) Mount(pattern string, subRouter Routes) {
 	}
 
 	r.router.Mount(pattern, sr.router)
+	r.mount(pattern, sr)
 }
 
 func (r *zrouter) Handle(pattern string, handler HandlerFunc) {
@@ -294,16 +436,16 @@ func (r *zrouter) Handle(pattern string, handler HandlerFunc) {
 		panic("handler is mandatory")
 	}
 
-	r.router.Handle(pattern, getChiHandler(handler))
+	r.router.Handle(pattern, getChiHandler(handler, r.responseSettings()))
 }
 
 func (r *zrouter) GetRegisteredRoutes() []RegisteredRoute {
-	r.mutex.Lock()
-	defer r.mutex.Unlock()
-
-	routesCopy := make([]RegisteredRoute, len(r.routes))
-	copy(routesCopy, r.routes)
-	return routesCopy
+	descriptions := r.DescribeRoutes()
+	routes := make([]RegisteredRoute, len(descriptions))
+	for i, description := range descriptions {
+		routes[i] = description.RegisteredRoute
+	}
+	return routes
 }
 
 func (r *zrouter) GetHandler() http.Handler {
@@ -388,6 +530,18 @@ func LogTopJWTPathMetrics(ctx context.Context, zCache zcache.RemoteCache, update
 	}
 }
 
+func (r *zrouter) responseSettings() responseSettings {
+	if r.config == nil {
+		return responseSettings{}
+	}
+
+	return responseSettings{
+		encoders:       r.config.Encoders,
+		problemDetails: r.config.ProblemDetails.Enable,
+		problems:       r.config.ProblemDetails.Registry,
+	}
+}
+
 func (r *zrouter) useDefaultMiddleware(middlewares ...zmiddlewares.Middleware) {
 	r.defaultMiddlewares = append(r.defaultMiddlewares, middlewares...)
 }
@@ -412,8 +566,9 @@ func (r *zrouter) applyMiddlewares(handler http.HandlerFunc, middlewares ...zmid
 		wrappedHandler = mw(wrappedHandler)
 	}
 
-	if r.config.EnableRequestID {
+	if r.config.EnableRequestID && !r.requestIDInstalled {
 		r.Use(zmiddlewares.RequestID()) // IMPORTANT: RequestID MUST always be the LAST middleware applied
+		r.requestIDInstalled = true
 	}
 	return wrappedHandler
 }
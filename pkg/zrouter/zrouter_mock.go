@@ -40,11 +40,26 @@ func (m *MockZRouter) DELETE(path string, handler HandlerFunc, middlewares ...zm
 	return args.Get(0).(Routes)
 }
 
+func (m *MockZRouter) WS(path string, handler WSHandlerFunc, middlewares ...zmiddlewares.Middleware) Routes {
+	args := m.Called(path, handler, middlewares)
+	return args.Get(0).(Routes)
+}
+
 func (m *MockZRouter) Route(method, path string, handler HandlerFunc, middlewares ...zmiddlewares.Middleware) Routes {
 	args := m.Called(method, path, handler, middlewares)
 	return args.Get(0).(Routes)
 }
 
+func (m *MockZRouter) RouteWithOptions(method, path string, handler HandlerFunc, options RouteOptions, middlewares ...zmiddlewares.Middleware) Routes {
+	args := m.Called(method, path, handler, options, middlewares)
+	return args.Get(0).(Routes)
+}
+
+func (m *MockZRouter) Transcode(options TranscodingOptions, middlewares ...zmiddlewares.Middleware) Routes {
+	args := m.Called(options, middlewares)
+	return args.Get(0).(Routes)
+}
+
 func (m *MockZRouter) Use(middlewares ...zmiddlewares.Middleware) Routes {
 	args := m.Called(middlewares)
 	return args.Get(0).(Routes)
@@ -76,6 +91,16 @@ func (m *MockZRouter) GetRegisteredRoutes() []RegisteredRoute {
 	return args.Get(0).([]RegisteredRoute)
 }
 
+func (m *MockZRouter) DescribeRoutes() []RouteDescription {
+	args := m.Called()
+	return args.Get(0).([]RouteDescription)
+}
+
+func (m *MockZRouter) URLFor(name string, params map[string]string) (string, error) {
+	args := m.Called(name, params)
+	return args.String(0), args.Error(1)
+}
+
 func (m *MockZRouter) SetDefaultMiddlewares(loggingOptions zmiddlewares.LoggingMiddlewareOptions) {
 	m.Called(loggingOptions)
 }
@@ -0,0 +1,202 @@
+package zwebhook
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/zondax/golem/pkg/zdb"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	defaultEndpointsTable  = "webhook_endpoints"
+	defaultDeliveriesTable = "webhook_deliveries"
+	defaultAttemptsTable   = "webhook_attempts"
+)
+
+type DBStoreTables struct {
+	// Endpoints defaults to webhook_endpoints.
+	Endpoints string
+	// Deliveries defaults to webhook_deliveries.
+	Deliveries string
+	// Attempts defaults to webhook_attempts.
+	Attempts string
+}
+
+func (t *DBStoreTables) setDefaultValues() {
+	if t.Endpoints == "" {
+		t.Endpoints = defaultEndpointsTable
+	}
+
+	if t.Deliveries == "" {
+		t.Deliveries = defaultDeliveriesTable
+	}
+
+	if t.Attempts == "" {
+		t.Attempts = defaultAttemptsTable
+	}
+}
+
+// DBStore keeps webhooks in Postgres tables with the columns of Endpoint, Delivery and Attempt. Deliveries
+// are claimed with SELECT ... FOR UPDATE SKIP LOCKED, so several dispatchers can share the tables.
+type DBStore struct {
+	db     zdb.ZDatabase
+	tables DBStoreTables
+}
+
+func NewDBStore(db zdb.ZDatabase, tables DBStoreTables) *DBStore {
+	tables.setDefaultValues()
+	return &DBStore{db: db, tables: tables}
+}
+
+// AutoMigrate creates or updates the tables.
+func (s *DBStore) AutoMigrate() error {
+	conn := s.db.GetDbConnection()
+	if err := conn.Table(s.tables.Endpoints).AutoMigrate(&Endpoint{}); err != nil {
+		return err
+	}
+	if err := conn.Table(s.tables.Deliveries).AutoMigrate(&Delivery{}); err != nil {
+		return err
+	}
+	return conn.Table(s.tables.Attempts).AutoMigrate(&Attempt{})
+}
+
+func (s *DBStore) SaveEndpoint(ctx context.Context, endpoint *Endpoint) error {
+	return s.db.WithContext(ctx).Table(s.tables.Endpoints).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"url", "secret", "disabled", "disabled_at", "consecutive_failures", "updated_at"}),
+	}).Create(endpoint).Error()
+}
+
+func (s *DBStore) GetEndpoint(ctx context.Context, id string) (*Endpoint, error) {
+	var endpoint Endpoint
+	err := s.db.WithContext(ctx).Table(s.tables.Endpoints).Where("id = ?", id).First(&endpoint).Error()
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrEndpointNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &endpoint, nil
+}
+
+func (s *DBStore) RecordEndpointResult(ctx context.Context, id string, success bool, disableAfter int, now time.Time) (*Endpoint, error) {
+	updates := map[string]interface{}{"consecutive_failures": 0, "updated_at": now}
+	if !success {
+		// The expressions see the row before the update, so concurrent failures are all counted.
+		updates = map[string]interface{}{
+			"consecutive_failures": gorm.Expr("consecutive_failures + 1"),
+			"disabled":             gorm.Expr("disabled OR consecutive_failures + 1 >= ?", disableAfter),
+			"disabled_at":          gorm.Expr("CASE WHEN NOT disabled AND consecutive_failures + 1 >= ? THEN ? ELSE disabled_at END", disableAfter, now),
+			"updated_at":           now,
+		}
+	}
+
+	db := s.db.WithContext(ctx).Table(s.tables.Endpoints).Where("id = ?", id).Updates(updates)
+	if err := db.Error(); err != nil {
+		return nil, err
+	}
+	if db.RowsAffected() == 0 {
+		return nil, ErrEndpointNotFound
+	}
+	return s.GetEndpoint(ctx, id)
+}
+
+func (s *DBStore) CreateDelivery(ctx context.Context, delivery *Delivery) error {
+	return s.db.WithContext(ctx).Table(s.tables.Deliveries).Create(delivery).Error()
+}
+
+func (s *DBStore) GetDelivery(ctx context.Context, id string) (*Delivery, error) {
+	var delivery Delivery
+	err := s.db.WithContext(ctx).Table(s.tables.Deliveries).Where("id = ?", id).First(&delivery).Error()
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrDeliveryNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &delivery, nil
+}
+
+func (s *DBStore) UpdateDelivery(ctx context.Context, delivery *Delivery) error {
+	db := s.db.WithContext(ctx).Table(s.tables.Deliveries).Where("id = ?", delivery.ID).Updates(map[string]interface{}{
+		"status":          delivery.Status,
+		"attempts":        delivery.Attempts,
+		"next_attempt_at": delivery.NextAttemptAt,
+		"last_error":      delivery.LastError,
+		"updated_at":      delivery.UpdatedAt,
+	})
+	if err := db.Error(); err != nil {
+		return err
+	}
+	if db.RowsAffected() == 0 {
+		return ErrDeliveryNotFound
+	}
+	return nil
+}
+
+func (s *DBStore) ClaimDeliveries(ctx context.Context, now time.Time, limit int, leaseUntil time.Time) ([]*Delivery, error) {
+	var deliveries []*Delivery
+	err := s.db.WithContext(ctx).Transaction(func(tx zdb.ZDatabase) error {
+		err := tx.Table(s.tables.Deliveries).
+			Clauses(clause.Locking{Strength: clause.LockingStrengthUpdate, Options: clause.LockingOptionsSkipLocked}).
+			Where("status = ? AND next_attempt_at <= ?", DeliveryStatusPending, now).
+			Where(fmt.Sprintf("endpoint_id IN (SELECT id FROM %s WHERE disabled = ?)", s.tables.Endpoints), false).
+			Order("next_attempt_at").
+			Limit(limit).
+			Find(&deliveries).Error()
+		if err != nil || len(deliveries) == 0 {
+			return err
+		}
+
+		ids := make([]string, len(deliveries))
+		for i, delivery := range deliveries {
+			ids[i] = delivery.ID
+		}
+		return tx.Table(s.tables.Deliveries).Where("id IN ?", ids).Update("next_attempt_at", leaseUntil).Error()
+	})
+	if err != nil {
+		return nil, err
+	}
+	return deliveries, nil
+}
+
+func (s *DBStore) ListDeliveries(ctx context.Context, filter DeliveryFilter) ([]*Delivery, error) {
+	filter.setDefaultValues()
+
+	db := s.db.WithContext(ctx).Table(s.tables.Deliveries)
+	if filter.EndpointID != "" {
+		db = db.Where("endpoint_id = ?", filter.EndpointID)
+	}
+	if filter.Event != "" {
+		db = db.Where("event = ?", filter.Event)
+	}
+	if filter.Status != "" {
+		db = db.Where("status = ?", filter.Status)
+	}
+	if !filter.Since.IsZero() {
+		db = db.Where("created_at >= ?", filter.Since)
+	}
+
+	var deliveries []*Delivery
+	if err := db.Order("created_at DESC").Limit(filter.Limit).Find(&deliveries).Error(); err != nil {
+		return nil, err
+	}
+	return deliveries, nil
+}
+
+func (s *DBStore) CreateAttempt(ctx context.Context, attempt *Attempt) error {
+	return s.db.WithContext(ctx).Table(s.tables.Attempts).Create(attempt).Error()
+}
+
+func (s *DBStore) ListAttempts(ctx context.Context, deliveryID string) ([]*Attempt, error) {
+	var attempts []*Attempt
+	err := s.db.WithContext(ctx).Table(s.tables.Attempts).Where("delivery_id = ?", deliveryID).Order("created_at").Find(&attempts).Error()
+	if err != nil {
+		return nil, err
+	}
+	return attempts, nil
+}
@@ -0,0 +1,98 @@
+package zwebhook
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/zondax/golem/pkg/zdb"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+func TestDBStore(t *testing.T) {
+	ctx := context.Background()
+	db := new(zdb.MockZDatabase)
+	db.On("WithContext", ctx).Return(db)
+	db.On("Table", defaultDeliveriesTable).Return(db)
+	db.On("Where", "id = ?", "missing").Return(db).Once()
+	db.On("First", mock.AnythingOfType("*zwebhook.Delivery")).Return(db).Once()
+	db.On("Error").Return(gorm.ErrRecordNotFound).Once()
+
+	store := NewDBStore(db, DBStoreTables{})
+	_, err := store.GetDelivery(ctx, "missing")
+	assert.ErrorIs(t, err, ErrDeliveryNotFound)
+
+	db.On("Table", defaultEndpointsTable).Return(db)
+	db.On("Where", "id = ?", endpointID).Return(db).Once()
+	db.On("Updates", mock.AnythingOfType("map[string]interface {}")).Return(db).Once()
+	db.On("Error").Return(nil).Once()
+	db.On("RowsAffected").Return(int64(0)).Once()
+
+	_, err = store.RecordEndpointResult(ctx, endpointID, false, 3, time.Now())
+	assert.ErrorIs(t, err, ErrEndpointNotFound)
+	db.AssertExpectations(t)
+}
+
+func TestDBStore_ClaimDeliveries(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	leaseUntil := now.Add(time.Minute)
+
+	db := new(zdb.MockZDatabase)
+	db.On("WithContext", ctx).Return(db)
+	db.On("Transaction", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		assert.NoError(t, args.Get(0).(func(zdb.ZDatabase) error)(db))
+	}).Return(nil)
+	db.On("Table", "deliveries").Return(db)
+	db.On("Clauses", []clause.Expression{clause.Locking{Strength: clause.LockingStrengthUpdate, Options: clause.LockingOptionsSkipLocked}}).Return(db)
+	db.On("Where", "status = ? AND next_attempt_at <= ?", DeliveryStatusPending, now).Return(db)
+	db.On("Where", "endpoint_id IN (SELECT id FROM endpoints WHERE disabled = ?)", false).Return(db)
+	db.On("Order", "next_attempt_at").Return(db)
+	db.On("Limit", 2).Return(db)
+	db.On("Find", mock.AnythingOfType("*[]*zwebhook.Delivery")).Run(func(args mock.Arguments) {
+		*args.Get(0).(*[]*Delivery) = []*Delivery{{ID: "delivery-1"}, {ID: "delivery-2"}}
+	}).Return(db)
+	db.On("Where", "id IN ?", []string{"delivery-1", "delivery-2"}).Return(db)
+	db.On("Update", "next_attempt_at", leaseUntil).Return(db)
+	db.On("Error").Return(nil)
+
+	store := NewDBStore(db, DBStoreTables{Endpoints: "endpoints", Deliveries: "deliveries"})
+	deliveries, err := store.ClaimDeliveries(ctx, now, 2, leaseUntil)
+	assert.NoError(t, err)
+	assert.Len(t, deliveries, 2)
+	db.AssertExpectations(t)
+}
+
+func TestDBStore_RecordEndpointResult(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+
+	db := new(zdb.MockZDatabase)
+	db.On("WithContext", ctx).Return(db)
+	db.On("Table", defaultEndpointsTable).Return(db)
+	db.On("Where", "id = ?", endpointID).Return(db)
+	db.On("Updates", map[string]interface{}{
+		"consecutive_failures": gorm.Expr("consecutive_failures + 1"),
+		"disabled":             gorm.Expr("disabled OR consecutive_failures + 1 >= ?", 3),
+		"disabled_at":          gorm.Expr("CASE WHEN NOT disabled AND consecutive_failures + 1 >= ? THEN ? ELSE disabled_at END", 3, now),
+		"updated_at":           now,
+	}).Return(db).Once()
+	db.On("Updates", map[string]interface{}{"consecutive_failures": 0, "updated_at": now}).Return(db).Once()
+	db.On("Error").Return(nil)
+	db.On("RowsAffected").Return(int64(1))
+	db.On("First", mock.AnythingOfType("*zwebhook.Endpoint")).Run(func(args mock.Arguments) {
+		*args.Get(0).(*Endpoint) = Endpoint{ID: endpointID, Disabled: true, ConsecutiveFailures: 3}
+	}).Return(db)
+
+	store := NewDBStore(db, DBStoreTables{})
+	endpoint, err := store.RecordEndpointResult(ctx, endpointID, false, 3, now)
+	assert.NoError(t, err)
+	assert.True(t, endpoint.Disabled)
+
+	_, err = store.RecordEndpointResult(ctx, endpointID, true, 3, now)
+	assert.NoError(t, err)
+	db.AssertExpectations(t)
+}
@@ -0,0 +1,266 @@
+package zwebhook
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+)
+
+const defaultListLimit = 50
+
+var (
+	ErrEndpointNotFound = errors.New("webhook endpoint not found")
+	ErrDeliveryNotFound = errors.New("webhook delivery not found")
+)
+
+type DeliveryStatus string
+
+const (
+	DeliveryStatusPending   DeliveryStatus = "pending"
+	DeliveryStatusSucceeded DeliveryStatus = "succeeded"
+	DeliveryStatusFailed    DeliveryStatus = "failed"
+)
+
+// Endpoint is a customer URL receiving webhooks, signed with its Secret.
+type Endpoint struct {
+	ID     string `json:"id" gorm:"column:id;primaryKey"`
+	URL    string `json:"url" gorm:"column:url"`
+	Secret string `json:"-" gorm:"column:secret"`
+	// Disabled endpoints are not delivered to, their pending deliveries resume once enabled again.
+	Disabled            bool       `json:"disabled" gorm:"column:disabled"`
+	DisabledAt          *time.Time `json:"disabled_at,omitempty" gorm:"column:disabled_at"`
+	ConsecutiveFailures int        `json:"consecutive_failures" gorm:"column:consecutive_failures"`
+	CreatedAt           time.Time  `json:"created_at" gorm:"column:created_at"`
+	UpdatedAt           time.Time  `json:"updated_at" gorm:"column:updated_at"`
+}
+
+// Delivery is an event to send to an endpoint, retried until it succeeds or runs out of attempts.
+type Delivery struct {
+	ID            string         `json:"id" gorm:"column:id;primaryKey"`
+	EndpointID    string         `json:"endpoint_id" gorm:"column:endpoint_id;index"`
+	Event         string         `json:"event" gorm:"column:event"`
+	Payload       []byte         `json:"payload" gorm:"column:payload"`
+	Status        DeliveryStatus `json:"status" gorm:"column:status;index:idx_webhook_deliveries_due,priority:1"`
+	Attempts      int            `json:"attempts" gorm:"column:attempts"`
+	NextAttemptAt time.Time      `json:"next_attempt_at" gorm:"column:next_attempt_at;index:idx_webhook_deliveries_due,priority:2"`
+	LastError     string         `json:"last_error,omitempty" gorm:"column:last_error"`
+	CreatedAt     time.Time      `json:"created_at" gorm:"column:created_at"`
+	UpdatedAt     time.Time      `json:"updated_at" gorm:"column:updated_at"`
+}
+
+// Attempt is an entry of the delivery log, one per request sent.
+type Attempt struct {
+	ID         string        `json:"id" gorm:"column:id;primaryKey"`
+	DeliveryID string        `json:"delivery_id" gorm:"column:delivery_id;index"`
+	EndpointID string        `json:"endpoint_id" gorm:"column:endpoint_id"`
+	Number     int           `json:"number" gorm:"column:number"`
+	StatusCode int           `json:"status_code,omitempty" gorm:"column:status_code"`
+	Error      string        `json:"error,omitempty" gorm:"column:error"`
+	Response   string        `json:"response,omitempty" gorm:"column:response"`
+	Duration   time.Duration `json:"duration" gorm:"column:duration"`
+	CreatedAt  time.Time     `json:"created_at" gorm:"column:created_at"`
+}
+
+// DeliveryFilter selects deliveries of the log, newest first. Empty fields match everything.
+type DeliveryFilter struct {
+	EndpointID string
+	Event      string
+	Status     DeliveryStatus
+	Since      time.Time
+	// Limit defaults to 50.
+	Limit int
+}
+
+func (f *DeliveryFilter) setDefaultValues() {
+	if f.Limit <= 0 {
+		f.Limit = defaultListLimit
+	}
+}
+
+func (f *DeliveryFilter) matches(delivery *Delivery) bool {
+	return (f.EndpointID == "" || delivery.EndpointID == f.EndpointID) &&
+		(f.Event == "" || delivery.Event == f.Event) &&
+		(f.Status == "" || delivery.Status == f.Status) &&
+		(f.Since.IsZero() || !delivery.CreatedAt.Before(f.Since))
+}
+
+// Store persists endpoints, pending deliveries and the delivery log. Implementations must be safe for
+// concurrent use, ClaimDeliveries in particular must not hand the same delivery to two callers.
+type Store interface {
+	SaveEndpoint(ctx context.Context, endpoint *Endpoint) error
+	GetEndpoint(ctx context.Context, id string) (*Endpoint, error)
+	// RecordEndpointResult resets the consecutive failures of the endpoint on success. Otherwise it
+	// increments them, disabling the endpoint once they reach disableAfter.
+	RecordEndpointResult(ctx context.Context, id string, success bool, disableAfter int, now time.Time) (*Endpoint, error)
+
+	CreateDelivery(ctx context.Context, delivery *Delivery) error
+	GetDelivery(ctx context.Context, id string) (*Delivery, error)
+	UpdateDelivery(ctx context.Context, delivery *Delivery) error
+	// ClaimDeliveries returns up to limit pending deliveries due at now whose endpoint is enabled, and
+	// moves their next attempt to leaseUntil, so they are retried if the worker dies.
+	ClaimDeliveries(ctx context.Context, now time.Time, limit int, leaseUntil time.Time) ([]*Delivery, error)
+	ListDeliveries(ctx context.Context, filter DeliveryFilter) ([]*Delivery, error)
+
+	CreateAttempt(ctx context.Context, attempt *Attempt) error
+	ListAttempts(ctx context.Context, deliveryID string) ([]*Attempt, error)
+}
+
+// MemoryStore keeps everything in memory. Deliveries are lost on restart, so it suits tests and
+// single instance services that can afford it.
+type MemoryStore struct {
+	mu         sync.Mutex
+	endpoints  map[string]Endpoint
+	deliveries map[string]Delivery
+	attempts   map[string][]Attempt
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		endpoints:  map[string]Endpoint{},
+		deliveries: map[string]Delivery{},
+		attempts:   map[string][]Attempt{},
+	}
+}
+
+func (s *MemoryStore) SaveEndpoint(_ context.Context, endpoint *Endpoint) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.endpoints[endpoint.ID] = *endpoint
+	return nil
+}
+
+func (s *MemoryStore) GetEndpoint(_ context.Context, id string) (*Endpoint, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	endpoint, ok := s.endpoints[id]
+	if !ok {
+		return nil, ErrEndpointNotFound
+	}
+	return &endpoint, nil
+}
+
+func (s *MemoryStore) RecordEndpointResult(_ context.Context, id string, success bool, disableAfter int, now time.Time) (*Endpoint, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	endpoint, ok := s.endpoints[id]
+	if !ok {
+		return nil, ErrEndpointNotFound
+	}
+
+	if success {
+		endpoint.ConsecutiveFailures = 0
+	} else {
+		endpoint.ConsecutiveFailures++
+		if !endpoint.Disabled && endpoint.ConsecutiveFailures >= disableAfter {
+			endpoint.Disabled = true
+			endpoint.DisabledAt = &now
+		}
+	}
+	endpoint.UpdatedAt = now
+
+	s.endpoints[id] = endpoint
+	return &endpoint, nil
+}
+
+func (s *MemoryStore) CreateDelivery(_ context.Context, delivery *Delivery) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.deliveries[delivery.ID] = *delivery
+	return nil
+}
+
+func (s *MemoryStore) GetDelivery(_ context.Context, id string) (*Delivery, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delivery, ok := s.deliveries[id]
+	if !ok {
+		return nil, ErrDeliveryNotFound
+	}
+	return &delivery, nil
+}
+
+func (s *MemoryStore) UpdateDelivery(_ context.Context, delivery *Delivery) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.deliveries[delivery.ID]; !ok {
+		return ErrDeliveryNotFound
+	}
+	s.deliveries[delivery.ID] = *delivery
+	return nil
+}
+
+func (s *MemoryStore) ClaimDeliveries(_ context.Context, now time.Time, limit int, leaseUntil time.Time) ([]*Delivery, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var due []*Delivery
+	for _, delivery := range s.deliveries {
+		if delivery.Status != DeliveryStatusPending || delivery.NextAttemptAt.After(now) {
+			continue
+		}
+		if endpoint, ok := s.endpoints[delivery.EndpointID]; !ok || endpoint.Disabled {
+			continue
+		}
+		due = append(due, &delivery)
+	}
+
+	sort.Slice(due, func(i, j int) bool { return due[i].NextAttemptAt.Before(due[j].NextAttemptAt) })
+	if len(due) > limit {
+		due = due[:limit]
+	}
+
+	for _, delivery := range due {
+		stored := s.deliveries[delivery.ID]
+		stored.NextAttemptAt = leaseUntil
+		s.deliveries[delivery.ID] = stored
+	}
+	return due, nil
+}
+
+func (s *MemoryStore) ListDeliveries(_ context.Context, filter DeliveryFilter) ([]*Delivery, error) {
+	filter.setDefaultValues()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var deliveries []*Delivery
+	for _, delivery := range s.deliveries {
+		if filter.matches(&delivery) {
+			deliveries = append(deliveries, &delivery)
+		}
+	}
+
+	sort.Slice(deliveries, func(i, j int) bool { return deliveries[i].CreatedAt.After(deliveries[j].CreatedAt) })
+	if len(deliveries) > filter.Limit {
+		deliveries = deliveries[:filter.Limit]
+	}
+	return deliveries, nil
+}
+
+func (s *MemoryStore) CreateAttempt(_ context.Context, attempt *Attempt) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.attempts[attempt.DeliveryID] = append(s.attempts[attempt.DeliveryID], *attempt)
+	return nil
+}
+
+func (s *MemoryStore) ListAttempts(_ context.Context, deliveryID string) ([]*Attempt, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	attempts := make([]*Attempt, len(s.attempts[deliveryID]))
+	for i := range s.attempts[deliveryID] {
+		attempt := s.attempts[deliveryID][i]
+		attempts[i] = &attempt
+	}
+	return attempts, nil
+}
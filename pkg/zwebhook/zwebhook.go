@@ -0,0 +1,444 @@
+package zwebhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/cenkalti/backoff/v4"
+	"github.com/google/uuid"
+	"github.com/zondax/golem/pkg/clock"
+	"github.com/zondax/golem/pkg/logger"
+	"github.com/zondax/golem/pkg/zhttpclient"
+	zbackoff "github.com/zondax/golem/pkg/zhttpclient/backoff"
+)
+
+const (
+	DefaultSignatureHeader  = "X-Webhook-Signature"
+	DefaultTimestampHeader  = "X-Webhook-Timestamp"
+	DefaultEventHeader      = "X-Webhook-Event"
+	DefaultDeliveryIDHeader = "X-Webhook-ID"
+	SignaturePrefix         = "sha256="
+
+	defaultName           = "webhook-dispatcher"
+	defaultTimeout        = 10 * time.Second
+	defaultWorkers        = 4
+	defaultBatchSize      = 100
+	defaultPollInterval   = time.Second
+	defaultLease          = time.Minute
+	defaultMaxAttempts    = 10
+	defaultInitialBackoff = 10 * time.Second
+	defaultMaxBackoff     = time.Hour
+	defaultDisableAfter   = 20
+
+	// maxResponseLog is the number of response body bytes kept in the delivery log.
+	maxResponseLog = 1024
+)
+
+var (
+	ErrEndpointDisabled  = errors.New("webhook endpoint is disabled")
+	ErrDispatcherStopped = errors.New("webhook dispatcher is stopped")
+)
+
+type Config struct {
+	// Name of the runner task. Defaults to webhook-dispatcher.
+	Name  string
+	Store Store
+	// Client defaults to a zhttpclient with a 10s timeout. Retries are handled by the dispatcher, so the
+	// client should not retry by itself.
+	Client zhttpclient.ZHTTPClient
+	Clock  clock.Clock
+	// Workers is the number of deliveries sent concurrently. Defaults to 4.
+	Workers int
+	// BatchSize is the maximum number of deliveries sent per poll. Defaults to 100.
+	BatchSize int
+	// PollInterval defaults to 1s.
+	PollInterval time.Duration
+	// Lease is how long a claimed delivery is hidden from other dispatchers. It must be longer than the
+	// client timeout, or a slow delivery is sent again by another dispatcher. Defaults to 1m.
+	Lease time.Duration
+	// MaxAttempts is the number of requests sent before a delivery fails. Defaults to 10.
+	MaxAttempts int
+	// InitialBackoff and MaxBackoff bound the exponential delay between attempts. Default to 10s and 1h.
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	// DisableAfter is the number of consecutive failed attempts that disables an endpoint. Defaults to 20.
+	DisableAfter int
+	// Headers are added to every request, e.g. a User-Agent.
+	Headers map[string]string
+
+	SignatureHeader  string
+	TimestampHeader  string
+	EventHeader      string
+	DeliveryIDHeader string
+}
+
+func (c *Config) setDefaultValues() {
+	if c.Name == "" {
+		c.Name = defaultName
+	}
+
+	if c.Client == nil {
+		c.Client = zhttpclient.New(zhttpclient.Config{Timeout: defaultTimeout})
+	}
+
+	if c.Clock == nil {
+		c.Clock = clock.New()
+	}
+
+	if c.Workers <= 0 {
+		c.Workers = defaultWorkers
+	}
+
+	if c.BatchSize <= 0 {
+		c.BatchSize = defaultBatchSize
+	}
+
+	if c.PollInterval <= 0 {
+		c.PollInterval = defaultPollInterval
+	}
+
+	if c.Lease <= 0 {
+		c.Lease = defaultLease
+	}
+
+	if c.MaxAttempts <= 0 {
+		c.MaxAttempts = defaultMaxAttempts
+	}
+
+	if c.InitialBackoff <= 0 {
+		c.InitialBackoff = defaultInitialBackoff
+	}
+
+	if c.MaxBackoff <= 0 {
+		c.MaxBackoff = defaultMaxBackoff
+	}
+
+	if c.DisableAfter <= 0 {
+		c.DisableAfter = defaultDisableAfter
+	}
+
+	if c.SignatureHeader == "" {
+		c.SignatureHeader = DefaultSignatureHeader
+	}
+
+	if c.TimestampHeader == "" {
+		c.TimestampHeader = DefaultTimestampHeader
+	}
+
+	if c.EventHeader == "" {
+		c.EventHeader = DefaultEventHeader
+	}
+
+	if c.DeliveryIDHeader == "" {
+		c.DeliveryIDHeader = DefaultDeliveryIDHeader
+	}
+}
+
+// Dispatcher sends webhooks to customer endpoints. Deliveries are persisted in the store when enqueued and
+// sent by the worker, which runs as a runner.BlockingTask.
+type Dispatcher struct {
+	config Config
+
+	mu      sync.Mutex
+	cancel  context.CancelFunc
+	stopped bool
+	running sync.WaitGroup
+}
+
+// New panics without store.
+func New(config Config) *Dispatcher {
+	if config.Store == nil {
+		panic("webhook dispatcher requires a store")
+	}
+	config.setDefaultValues()
+
+	return &Dispatcher{config: config}
+}
+
+// Sign returns the signature of a payload sent at timestamp: the hex encoded HMAC-SHA256 of
+// "timestamp.payload", prefixed with "sha256=".
+func Sign(secret string, timestamp int64, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10) + "."))
+	mac.Write(payload)
+	return SignaturePrefix + hex.EncodeToString(mac.Sum(nil))
+}
+
+// RegisterEndpoint creates or replaces an endpoint, enabled.
+func (d *Dispatcher) RegisterEndpoint(ctx context.Context, id, url, secret string) (*Endpoint, error) {
+	now := d.config.Clock.Now()
+	endpoint := &Endpoint{ID: id, URL: url, Secret: secret, CreatedAt: now, UpdatedAt: now}
+	if existing, err := d.config.Store.GetEndpoint(ctx, id); err == nil {
+		endpoint.CreatedAt = existing.CreatedAt
+	} else if !errors.Is(err, ErrEndpointNotFound) {
+		return nil, err
+	}
+
+	if err := d.config.Store.SaveEndpoint(ctx, endpoint); err != nil {
+		return nil, err
+	}
+	return endpoint, nil
+}
+
+// EnableEndpoint enables an endpoint disabled after too many failures, resuming its pending deliveries.
+func (d *Dispatcher) EnableEndpoint(ctx context.Context, id string) error {
+	endpoint, err := d.config.Store.GetEndpoint(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	endpoint.Disabled = false
+	endpoint.DisabledAt = nil
+	endpoint.ConsecutiveFailures = 0
+	endpoint.UpdatedAt = d.config.Clock.Now()
+	return d.config.Store.SaveEndpoint(ctx, endpoint)
+}
+
+// Enqueue persists a delivery of the event, sent by the worker as soon as possible.
+func (d *Dispatcher) Enqueue(ctx context.Context, endpointID, event string, payload []byte) (*Delivery, error) {
+	endpoint, err := d.config.Store.GetEndpoint(ctx, endpointID)
+	if err != nil {
+		return nil, err
+	}
+	if endpoint.Disabled {
+		return nil, ErrEndpointDisabled
+	}
+
+	now := d.config.Clock.Now()
+	delivery := &Delivery{
+		ID:            uuid.NewString(),
+		EndpointID:    endpointID,
+		Event:         event,
+		Payload:       payload,
+		Status:        DeliveryStatusPending,
+		NextAttemptAt: now,
+		CreatedAt:     now,
+		UpdatedAt:     now,
+	}
+	if err = d.config.Store.CreateDelivery(ctx, delivery); err != nil {
+		return nil, err
+	}
+	return delivery, nil
+}
+
+// Redeliver schedules a delivery again with a fresh set of attempts, e.g. once a failed endpoint is fixed.
+func (d *Dispatcher) Redeliver(ctx context.Context, deliveryID string) (*Delivery, error) {
+	delivery, err := d.config.Store.GetDelivery(ctx, deliveryID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := d.config.Clock.Now()
+	delivery.Status = DeliveryStatusPending
+	delivery.Attempts = 0
+	delivery.NextAttemptAt = now
+	delivery.UpdatedAt = now
+	if err = d.config.Store.UpdateDelivery(ctx, delivery); err != nil {
+		return nil, err
+	}
+	return delivery, nil
+}
+
+// Deliveries queries the delivery log.
+func (d *Dispatcher) Deliveries(ctx context.Context, filter DeliveryFilter) ([]*Delivery, error) {
+	return d.config.Store.ListDeliveries(ctx, filter)
+}
+
+// Attempts returns the requests sent for a delivery, with the response of the endpoint.
+func (d *Dispatcher) Attempts(ctx context.Context, deliveryID string) ([]*Attempt, error) {
+	return d.config.Store.ListAttempts(ctx, deliveryID)
+}
+
+func (d *Dispatcher) Name() string {
+	return d.config.Name
+}
+
+// Start polls the store for due deliveries until Stop is called.
+func (d *Dispatcher) Start() error {
+	d.mu.Lock()
+	if d.stopped {
+		d.mu.Unlock()
+		return ErrDispatcherStopped
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	d.cancel = cancel
+	d.running.Add(1)
+	d.mu.Unlock()
+	defer d.running.Done()
+
+	ticker := time.NewTicker(d.config.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := d.ProcessDue(ctx); err != nil && ctx.Err() == nil {
+			logger.Errorf("Error dispatching webhooks: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// BlocksUntilStopped marks the dispatcher as a runner.BlockingTask, Start polls until Stop is called.
+func (d *Dispatcher) BlocksUntilStopped() {}
+
+// Stop waits for the deliveries in flight, which are then recorded.
+func (d *Dispatcher) Stop() error {
+	d.mu.Lock()
+	d.stopped = true
+	if d.cancel != nil {
+		d.cancel()
+	}
+	d.mu.Unlock()
+
+	d.running.Wait()
+	return nil
+}
+
+// ProcessDue sends the due deliveries, up to BatchSize, and returns how many were attempted. Start calls it
+// on every poll, it is exported to flush deliveries without running the worker.
+//
+// Deliveries are claimed Workers at a time, right before they are sent, so their lease only has to cover
+// one request. Once ctx is cancelled no more deliveries are claimed, the ones in flight are still recorded.
+func (d *Dispatcher) ProcessDue(ctx context.Context) (int, error) {
+	sent := 0
+	for sent < d.config.BatchSize && ctx.Err() == nil {
+		now := d.config.Clock.Now()
+		limit := min(d.config.Workers, d.config.BatchSize-sent)
+		deliveries, err := d.config.Store.ClaimDeliveries(ctx, now, limit, now.Add(d.config.Lease))
+		if err != nil {
+			return sent, err
+		}
+
+		d.deliverAll(context.WithoutCancel(ctx), deliveries)
+		sent += len(deliveries)
+		if len(deliveries) < limit {
+			break
+		}
+	}
+	return sent, nil
+}
+
+func (d *Dispatcher) deliverAll(ctx context.Context, deliveries []*Delivery) {
+	var wg sync.WaitGroup
+	for _, delivery := range deliveries {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			d.deliver(ctx, delivery)
+		}()
+	}
+	wg.Wait()
+}
+
+func (d *Dispatcher) deliver(ctx context.Context, delivery *Delivery) {
+	endpoint, err := d.config.Store.GetEndpoint(ctx, delivery.EndpointID)
+	if err != nil {
+		logger.Errorf("Error loading webhook endpoint %s: %v", delivery.EndpointID, err)
+		return
+	}
+
+	attempt := d.send(ctx, endpoint, delivery)
+	if err = d.config.Store.CreateAttempt(ctx, attempt); err != nil {
+		logger.Errorf("Error recording webhook attempt of delivery %s: %v", delivery.ID, err)
+	}
+
+	success := attempt.Error == ""
+	now := d.config.Clock.Now()
+	delivery.Attempts = attempt.Number
+	delivery.LastError = attempt.Error
+	delivery.UpdatedAt = now
+	delay := d.retryDelay(delivery.Attempts)
+	switch {
+	case success:
+		delivery.Status = DeliveryStatusSucceeded
+	case delay == backoff.Stop:
+		delivery.Status = DeliveryStatusFailed
+		logger.Warnf("Webhook delivery %s to endpoint %s failed after %d attempts: %s", delivery.ID, endpoint.ID, delivery.Attempts, attempt.Error)
+	default:
+		delivery.NextAttemptAt = now.Add(delay)
+	}
+	if err = d.config.Store.UpdateDelivery(ctx, delivery); err != nil {
+		logger.Errorf("Error updating webhook delivery %s: %v", delivery.ID, err)
+	}
+
+	updated, err := d.config.Store.RecordEndpointResult(ctx, endpoint.ID, success, d.config.DisableAfter, now)
+	if err != nil {
+		logger.Errorf("Error updating webhook endpoint %s: %v", endpoint.ID, err)
+		return
+	}
+	if updated.Disabled && !endpoint.Disabled {
+		logger.Warnf("Disabled webhook endpoint %s after %d consecutive failures", endpoint.ID, updated.ConsecutiveFailures)
+	}
+}
+
+func (d *Dispatcher) send(ctx context.Context, endpoint *Endpoint, delivery *Delivery) *Attempt {
+	start := d.config.Clock.Now()
+	attempt := &Attempt{
+		ID:         uuid.NewString(),
+		DeliveryID: delivery.ID,
+		EndpointID: endpoint.ID,
+		Number:     delivery.Attempts + 1,
+		CreatedAt:  start,
+	}
+
+	headers := make(map[string]string, len(d.config.Headers)+5)
+	for key, value := range d.config.Headers {
+		headers[key] = value
+	}
+	headers["Content-Type"] = "application/json"
+	headers[d.config.EventHeader] = delivery.Event
+	headers[d.config.DeliveryIDHeader] = delivery.ID
+	headers[d.config.TimestampHeader] = strconv.FormatInt(start.Unix(), 10)
+	headers[d.config.SignatureHeader] = Sign(endpoint.Secret, start.Unix(), delivery.Payload)
+
+	resp, err := d.config.Client.NewRequest().
+		SetURL(endpoint.URL).
+		SetHeaders(headers).
+		SetBody(bytes.NewReader(delivery.Payload)).
+		Post(ctx)
+	attempt.Duration = d.config.Clock.Now().Sub(start)
+
+	switch {
+	case err != nil:
+		attempt.Error = err.Error()
+	case resp.Code < 200 || resp.Code > 299:
+		attempt.StatusCode = resp.Code
+		attempt.Error = fmt.Sprintf("unexpected status code %d", resp.Code)
+	default:
+		attempt.StatusCode = resp.Code
+	}
+	if resp != nil {
+		attempt.Response = string(resp.Body[:min(len(resp.Body), maxResponseLog)])
+	}
+	return attempt
+}
+
+// retryDelay returns the delay before the attempt following attempts, or backoff.Stop once they are
+// exhausted. The delays grow exponentially, with jitter, from InitialBackoff up to MaxBackoff.
+func (d *Dispatcher) retryDelay(attempts int) time.Duration {
+	b := zbackoff.New().
+		WithMaxAttempts(d.config.MaxAttempts - 1).
+		WithInitialDuration(d.config.InitialBackoff).
+		WithMaxInterval(d.config.MaxBackoff).
+		Exponential()
+
+	delay := backoff.Stop
+	for i := 0; i < attempts; i++ {
+		if delay = b.NextBackOff(); delay == backoff.Stop {
+			break
+		}
+	}
+	return delay
+}
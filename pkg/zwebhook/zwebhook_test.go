@@ -0,0 +1,280 @@
+package zwebhook
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+	"github.com/zondax/golem/pkg/logger"
+	"github.com/zondax/golem/pkg/runner"
+	"github.com/zondax/golem/pkg/zrouter/zmiddlewares"
+)
+
+const (
+	endpointID     = "endpoint-1"
+	endpointSecret = "endpoint-secret"
+	testPayload    = `{"id":"order-1"}`
+)
+
+var _ runner.BlockingTask = (*Dispatcher)(nil)
+
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+type DispatcherSuite struct {
+	suite.Suite
+	ctx      context.Context
+	clock    *fakeClock
+	store    *MemoryStore
+	server   *httptest.Server
+	status   atomic.Int32
+	requests atomic.Int32
+	verifier zmiddlewares.WebhookVerifier
+}
+
+func (s *DispatcherSuite) SetupTest() {
+	logger.InitLogger(logger.Config{})
+	s.ctx = context.Background()
+	s.clock = &fakeClock{now: time.Now()}
+	s.store = NewMemoryStore()
+	s.status.Store(http.StatusOK)
+	s.requests.Store(0)
+
+	s.verifier = zmiddlewares.NewHMACWebhookVerifier(zmiddlewares.HMACWebhookOptions{
+		Secrets:         []string{endpointSecret},
+		SignatureHeader: DefaultSignatureHeader,
+		Prefix:          SignaturePrefix,
+		TimestampHeader: DefaultTimestampHeader,
+		// Tests move the clock of the dispatcher ahead of the real one.
+		Tolerance: 2 * time.Hour,
+	})
+
+	s.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.requests.Add(1)
+		body, err := io.ReadAll(r.Body)
+		s.NoError(err)
+		s.NoError(s.verifier.Verify(r, body))
+		s.Equal("order.created", r.Header.Get(DefaultEventHeader))
+		s.NotEmpty(r.Header.Get(DefaultDeliveryIDHeader))
+		s.Equal("golem", r.Header.Get("User-Agent"))
+
+		w.WriteHeader(int(s.status.Load()))
+		_, _ = w.Write([]byte("received"))
+	}))
+}
+
+func (s *DispatcherSuite) TearDownTest() {
+	s.server.Close()
+}
+
+func (s *DispatcherSuite) newDispatcher(config Config) *Dispatcher {
+	config.Store = s.store
+	config.Clock = s.clock
+	config.Headers = map[string]string{"User-Agent": "golem"}
+	dispatcher := New(config)
+
+	_, err := dispatcher.RegisterEndpoint(s.ctx, endpointID, s.server.URL, endpointSecret)
+	s.Require().NoError(err)
+	return dispatcher
+}
+
+func (s *DispatcherSuite) process(dispatcher *Dispatcher) int {
+	n, err := dispatcher.ProcessDue(s.ctx)
+	s.Require().NoError(err)
+	return n
+}
+
+func (s *DispatcherSuite) TestDelivers() {
+	dispatcher := s.newDispatcher(Config{})
+	delivery, err := dispatcher.Enqueue(s.ctx, endpointID, "order.created", []byte(testPayload))
+	s.Require().NoError(err)
+
+	s.Equal(1, s.process(dispatcher))
+	s.Equal(0, s.process(dispatcher))
+	s.Equal(int32(1), s.requests.Load())
+
+	delivery, err = s.store.GetDelivery(s.ctx, delivery.ID)
+	s.Require().NoError(err)
+	s.Equal(DeliveryStatusSucceeded, delivery.Status)
+	s.Equal(1, delivery.Attempts)
+
+	attempts, err := dispatcher.Attempts(s.ctx, delivery.ID)
+	s.Require().NoError(err)
+	s.Require().Len(attempts, 1)
+	s.Equal(http.StatusOK, attempts[0].StatusCode)
+	s.Equal("received", attempts[0].Response)
+	s.Empty(attempts[0].Error)
+
+	_, err = dispatcher.Enqueue(s.ctx, "unknown", "order.created", []byte(testPayload))
+	s.ErrorIs(err, ErrEndpointNotFound)
+}
+
+func (s *DispatcherSuite) TestRetriesWithBackoff() {
+	s.status.Store(http.StatusServiceUnavailable)
+	dispatcher := s.newDispatcher(Config{MaxAttempts: 3, InitialBackoff: time.Minute})
+	delivery, err := dispatcher.Enqueue(s.ctx, endpointID, "order.created", []byte(testPayload))
+	s.Require().NoError(err)
+
+	s.Equal(1, s.process(dispatcher))
+	delivery, err = s.store.GetDelivery(s.ctx, delivery.ID)
+	s.Require().NoError(err)
+	s.Equal(DeliveryStatusPending, delivery.Status)
+	s.Equal("unexpected status code 503", delivery.LastError)
+	// The first delay is a minute, with up to 50% of jitter.
+	s.WithinRange(delivery.NextAttemptAt, s.clock.Now().Add(30*time.Second), s.clock.Now().Add(90*time.Second))
+
+	s.Equal(0, s.process(dispatcher))
+	s.clock.Advance(2 * time.Minute)
+	s.Equal(1, s.process(dispatcher))
+	s.clock.Advance(4 * time.Minute)
+	s.Equal(1, s.process(dispatcher))
+	s.clock.Advance(time.Hour)
+	s.Equal(0, s.process(dispatcher))
+
+	delivery, err = s.store.GetDelivery(s.ctx, delivery.ID)
+	s.Require().NoError(err)
+	s.Equal(DeliveryStatusFailed, delivery.Status)
+	s.Equal(3, delivery.Attempts)
+
+	attempts, err := dispatcher.Attempts(s.ctx, delivery.ID)
+	s.Require().NoError(err)
+	s.Len(attempts, 3)
+	for i, attempt := range attempts {
+		s.Equal(i+1, attempt.Number)
+		s.Equal(http.StatusServiceUnavailable, attempt.StatusCode)
+	}
+
+	s.status.Store(http.StatusOK)
+	_, err = dispatcher.Redeliver(s.ctx, delivery.ID)
+	s.Require().NoError(err)
+	s.Equal(1, s.process(dispatcher))
+	delivery, err = s.store.GetDelivery(s.ctx, delivery.ID)
+	s.Require().NoError(err)
+	s.Equal(DeliveryStatusSucceeded, delivery.Status)
+}
+
+func (s *DispatcherSuite) TestDisablesFailingEndpoint() {
+	s.status.Store(http.StatusInternalServerError)
+	dispatcher := s.newDispatcher(Config{DisableAfter: 2, InitialBackoff: time.Second})
+	for i := 0; i < 3; i++ {
+		_, err := dispatcher.Enqueue(s.ctx, endpointID, "order.created", []byte(testPayload))
+		s.Require().NoError(err)
+	}
+
+	s.Equal(3, s.process(dispatcher))
+	endpoint, err := s.store.GetEndpoint(s.ctx, endpointID)
+	s.Require().NoError(err)
+	s.True(endpoint.Disabled)
+	s.NotNil(endpoint.DisabledAt)
+	s.Equal(3, endpoint.ConsecutiveFailures)
+
+	_, err = dispatcher.Enqueue(s.ctx, endpointID, "order.created", []byte(testPayload))
+	s.ErrorIs(err, ErrEndpointDisabled)
+	s.clock.Advance(time.Minute)
+	s.Equal(0, s.process(dispatcher))
+
+	s.status.Store(http.StatusOK)
+	s.Require().NoError(dispatcher.EnableEndpoint(s.ctx, endpointID))
+	s.Equal(3, s.process(dispatcher))
+
+	endpoint, err = s.store.GetEndpoint(s.ctx, endpointID)
+	s.Require().NoError(err)
+	s.False(endpoint.Disabled)
+	s.Zero(endpoint.ConsecutiveFailures)
+}
+
+func (s *DispatcherSuite) TestDeliveryLog() {
+	dispatcher := s.newDispatcher(Config{})
+	for _, event := range []string{"order.created", "order.created", "order.paid"} {
+		_, err := dispatcher.Enqueue(s.ctx, endpointID, event, []byte(testPayload))
+		s.Require().NoError(err)
+		s.clock.Advance(time.Second)
+	}
+
+	deliveries, err := dispatcher.Deliveries(s.ctx, DeliveryFilter{Event: "order.created"})
+	s.Require().NoError(err)
+	s.Len(deliveries, 2)
+
+	deliveries, err = dispatcher.Deliveries(s.ctx, DeliveryFilter{EndpointID: endpointID, Limit: 1})
+	s.Require().NoError(err)
+	s.Require().Len(deliveries, 1)
+	s.Equal("order.paid", deliveries[0].Event)
+
+	deliveries, err = dispatcher.Deliveries(s.ctx, DeliveryFilter{Status: DeliveryStatusSucceeded})
+	s.Require().NoError(err)
+	s.Empty(deliveries)
+}
+
+func (s *DispatcherSuite) TestStartStop() {
+	dispatcher := s.newDispatcher(Config{PollInterval: 10 * time.Millisecond})
+	s.Equal(defaultName, dispatcher.Name())
+
+	done := make(chan error)
+	go func() { done <- dispatcher.Start() }()
+
+	_, err := dispatcher.Enqueue(s.ctx, endpointID, "order.created", []byte(testPayload))
+	s.Require().NoError(err)
+	s.Eventually(func() bool { return s.requests.Load() == 1 }, time.Second, 10*time.Millisecond)
+
+	s.NoError(dispatcher.Stop())
+	s.NoError(<-done)
+	s.ErrorIs(dispatcher.Start(), ErrDispatcherStopped)
+}
+
+// claimRecorder records the limit of every claim.
+type claimRecorder struct {
+	*MemoryStore
+	limits []int
+}
+
+func (c *claimRecorder) ClaimDeliveries(ctx context.Context, now time.Time, limit int, leaseUntil time.Time) ([]*Delivery, error) {
+	c.limits = append(c.limits, limit)
+	return c.MemoryStore.ClaimDeliveries(ctx, now, limit, leaseUntil)
+}
+
+func (s *DispatcherSuite) TestClaimsPerWorker() {
+	store := &claimRecorder{MemoryStore: s.store}
+	dispatcher := New(Config{Store: store, Clock: s.clock, Workers: 2, BatchSize: 4, Headers: map[string]string{"User-Agent": "golem"}})
+	_, err := dispatcher.RegisterEndpoint(s.ctx, endpointID, s.server.URL, endpointSecret)
+	s.Require().NoError(err)
+	for i := 0; i < 5; i++ {
+		_, err = dispatcher.Enqueue(s.ctx, endpointID, "order.created", []byte(testPayload))
+		s.Require().NoError(err)
+	}
+
+	ctx, cancel := context.WithCancel(s.ctx)
+	cancel()
+	n, err := dispatcher.ProcessDue(ctx)
+	s.Require().NoError(err)
+	s.Zero(n)
+	s.Empty(store.limits)
+
+	s.Equal(4, s.process(dispatcher))
+	s.Equal([]int{2, 2}, store.limits)
+	s.Equal(1, s.process(dispatcher))
+	s.Equal([]int{2, 2, 2}, store.limits)
+}
+
+func TestDispatcherSuite(t *testing.T) {
+	suite.Run(t, new(DispatcherSuite))
+}